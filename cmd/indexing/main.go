@@ -5,6 +5,7 @@ import (
 	"flag"
 	"log"
 	"os"
+	"path/filepath"
 	"runtime/pprof"
 	"strings"
 
@@ -21,8 +22,13 @@ var (
 	outputDir          = flag.String("o", "lintang", "output directory buat simpan inverted index, ngram, dll")
 	cpuprofile         = flag.String("cpuprofile", "", "write cpu profile to file")
 	memprofile         = flag.String("memprofile", "", "write memory profile to this file")
-	regionBoundaryFile = flag.String("region-boundary", "region_boundary.json", "region boundary file")
+	regionBoundaryFile = flag.String("region-boundary", "region_boundary.json", "region boundary file (geojson FeatureCollection atau json array)")
 	spellErrorFile     = flag.String("spell-error", "spell-errors.txt", "spell error file")
+	osmAdminBoundary   = flag.Bool("osm-admin-boundary", false, "buat hierarki administratif dari relation boundary=administrative di file osm, bukan dari region boundary file")
+	adminLevelsFile    = flag.String("admin-levels", "", "file json mapping admin_level -> komponen address per country, contoh: {\"MY\": {\"2\": \"country\", \"4\": \"province\", \"6\": \"district\"}}")
+	boundaryProperties = flag.String("boundary-properties", "", "file json mapping komponen address -> nama property feature geojson region boundary, contoh: {\"province\": \"NAME_1\", \"district\": \"NAME_2\"}")
+	rulesFile          = flag.String("rules", "", "file yaml/json aturan indexing: tag osm yang di-index/di-exclude, tag key untuk tipe, & tag key feature filter")
+	country            = flag.String("country", "", "country code (ISO3166-1 alpha2) untuk mapping admin_level. default: dari tag relation admin_level=2")
 )
 
 func main() {
@@ -45,10 +51,41 @@ func main() {
 		os.Mkdir(*outputDir, 0700)
 	}
 
-	ways, onylySearchNodes, nodeMap, tagIDMap, spatialIndex, osmRelations, err := geo.ParseOSM(*mapFile, *regionBoundaryFile)
+	if *rulesFile != "" {
+		rules, err := geo.LoadIndexRules(*rulesFile)
+		if err != nil {
+			panic(err)
+		}
+		geo.SetIndexRules(rules)
+	}
+	// aturan indexing disimpan di outputDir, dipakai server saat apply osmChange & custom document.
+	err := geo.GetIndexRules().Save(filepath.Join(*outputDir, geo.INDEX_RULES_FILE))
+	if err != nil {
+		panic(err)
+	}
+
+	adminLevels, err := geo.LoadAdminLevelConfig(*adminLevelsFile)
+	if err != nil {
+		panic(err)
+	}
+	properties, err := geo.LoadBoundaryProperties(*boundaryProperties)
+	if err != nil {
+		panic(err)
+	}
+	adminConfig := geo.AdminBoundaryConfig{
+		FromOSM:    *osmAdminBoundary,
+		Levels:     adminLevels,
+		Country:    *country,
+		Properties: properties,
+	}
+
+	parsed, err := geo.ParseOSM(*mapFile, *regionBoundaryFile, *outputDir, adminConfig)
 	if err != nil {
 		panic(err)
 	}
+	ways, onylySearchNodes, relations, nodeCoords := parsed.Ways, parsed.Nodes, parsed.Relations, parsed.Coords
+	// node_coords.dat tetap disimpan di outputDir, dipakai server untuk apply osmChange.
+	defer nodeCoords.Close()
 
 	db, err := bolt.Open("docs_store.db", 0600, nil)
 	if err != nil {
@@ -78,7 +115,8 @@ func main() {
 		panic(err)
 	}
 
-	indexedData := index.NewIndexedData(ways, onylySearchNodes, nodeMap, tagIDMap, spatialIndex, osmRelations)
+	indexedData := index.NewIndexedData(ways, onylySearchNodes, nodeCoords, parsed.TagIDMap, parsed.SpatialIndex, parsed.AdminBoundaries)
+	indexedData.Relations = relations
 	invertedIndex, _ := index.NewDynamicIndex(*outputDir, 1e7, false, spellCorrectorBuilder,
 		indexedData, bboltKV)
 
@@ -91,6 +129,19 @@ func main() {
 		panic(err)
 	}
 
+	err = ways.Remove()
+	if err != nil {
+		panic(err)
+	}
+	err = onylySearchNodes.Remove()
+	if err != nil {
+		panic(err)
+	}
+	err = relations.Remove()
+	if err != nil {
+		panic(err)
+	}
+
 	if *memprofile != "" {
 		*memprofile = strings.Replace(*memprofile, ".mprof", "_indexing.mprof", -1)
 		f, err := os.Create(*memprofile)
@@ -112,7 +163,7 @@ func main() {
 	defer cleanup()
 
 	ngramLM.SetTermIDMap(invertedIndex.GetTermIDMap())
-	err = invertedIndex.BuildSpellCorrectorAndNgram(ctx, allSearchNodes, spatialIndex, osmRelations)
+	err = invertedIndex.BuildSpellCorrectorAndNgram(ctx, allSearchNodes, parsed.SpatialIndex, parsed.AdminBoundaries)
 	if err != nil {
 		panic(err)
 	}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"os"
 	"testing"
 
 	"go.uber.org/goleak"
@@ -8,5 +9,13 @@ import (
 
 func TestMain(m *testing.M) {
 	defer goleak.VerifyTestMain(m)
+
+	// output indexing (index rules, dll) ditulis ke temp dir, bukan ke source tree.
+	dir, err := os.MkdirTemp("", "osm-search-indexing")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	*outputDir = dir
 	main()
 }
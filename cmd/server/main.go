@@ -4,6 +4,7 @@ import (
 	"flag"
 
 	"github.com/lintang-b-s/osm-search/pkg/di"
+	updater_di "github.com/lintang-b-s/osm-search/pkg/di/updater"
 	myHttp "github.com/lintang-b-s/osm-search/pkg/http"
 	"github.com/lintang-b-s/osm-search/pkg/searcher"
 
@@ -15,6 +16,7 @@ import (
 var (
 	simiiliarityScoring = flag.String("sc", "BM25_FIELD", "similiarity scoring (only 2: BM25_PLUS or TF_IDF_COSINE)")
 	useRateLimit        = flag.Bool("ratelimit", false, "use rate limit")
+	oscDir              = flag.String("osc-dir", "", "directory of osmChange files (.osc/.osc.gz) applied while the server is running")
 )
 
 //	@title			OSM Search Engine API
@@ -45,7 +47,8 @@ func main() {
 		searcherScoring = searcher.BM25_FIELD
 	}
 
-	service, cleanup, err := di.InitializeSearcherService(searcherScoring, *useRateLimit)
+	service, cleanup, err := di.InitializeSearcherService(searcherScoring, *useRateLimit,
+		updater_di.ChangeDir(*oscDir))
 	defer cleanup()
 	if err != nil {
 		panic(err)
@@ -132,6 +132,11 @@ func DecodePostingsList(bs []byte) []int {
 	return numbers
 }
 
+// DecodePostingsListNoCopy. sama seperti DecodePostingsList tapi tanpa copy bs. bs tidak dimodifikasi, jadi aman untuk region mmap read-only.
+func DecodePostingsListNoCopy(bs []byte) []int {
+	return vebDecode2(bs)
+}
+
 func EncodePostingsList2(postingsList []int) []byte {
 
 	var buf bytes.Buffer
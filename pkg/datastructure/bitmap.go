@@ -0,0 +1,66 @@
+package datastructure
+
+import "math/bits"
+
+// Bitmap. bitset yang ukurannya bertambah otomatis. tidak thread-safe.
+type Bitmap struct {
+	words []uint64
+	count int
+}
+
+func NewBitmap() *Bitmap {
+	return &Bitmap{words: []uint64{}}
+}
+
+// Set. set bit ke-i. no-op kalau i < 0.
+func (b *Bitmap) Set(i int) {
+	if i < 0 {
+		return
+	}
+	word := i / 64
+	for word >= len(b.words) {
+		b.words = append(b.words, 0)
+	}
+	mask := uint64(1) << (uint(i) % 64)
+	if b.words[word]&mask == 0 {
+		b.words[word] |= mask
+		b.count++
+	}
+}
+
+// Remove. unset bit ke-i. no-op kalau bit belum di set.
+func (b *Bitmap) Remove(i int) {
+	if !b.Contains(i) {
+		return
+	}
+	b.words[i/64] &^= uint64(1) << (uint(i) % 64)
+	b.count--
+}
+
+// Contains. return true kalau bit ke-i sudah di set.
+func (b *Bitmap) Contains(i int) bool {
+	if i < 0 {
+		return false
+	}
+	word := i / 64
+	if word >= len(b.words) {
+		return false
+	}
+	return b.words[word]&(uint64(1)<<(uint(i)%64)) != 0
+}
+
+// Count. jumlah bit yang di set.
+func (b *Bitmap) Count() int {
+	return b.count
+}
+
+// ForEach. iterate semua bit yang di set, ascending.
+func (b *Bitmap) ForEach(fn func(i int)) {
+	for wordIdx, word := range b.words {
+		for word != 0 {
+			tz := bits.TrailingZeros64(word)
+			fn(wordIdx*64 + tz)
+			word &= word - 1
+		}
+	}
+}
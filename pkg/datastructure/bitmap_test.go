@@ -0,0 +1,30 @@
+package datastructure
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBitmap(t *testing.T) {
+	b := NewBitmap()
+	b.Set(3)
+	b.Set(64)
+	b.Set(200)
+	b.Set(3)
+	b.Set(-1)
+
+	assert.True(t, b.Contains(3))
+	assert.True(t, b.Contains(64))
+	assert.True(t, b.Contains(200))
+	assert.False(t, b.Contains(4))
+	assert.False(t, b.Contains(1000))
+	assert.False(t, b.Contains(-1))
+	assert.Equal(t, 3, b.Count())
+
+	setBits := []int{}
+	b.ForEach(func(i int) {
+		setBits = append(setBits, i)
+	})
+	assert.Equal(t, []int{3, 64, 200}, setBits)
+}
@@ -3,13 +3,176 @@ package datastructure
 // Node model info
 // @Description OSM Objects indexed in search engines. taken from object way, nodes from osm that have certain tags.
 type Node struct {
-	ID              int     `json:"id"`               // ID of osm object
-	Name            string  `json:"name"`             // osm object name. from osm tag name
-	Lat             float64 `json:"lat"`              // latitude of center point polygon osm way
-	Lon             float64 `json:"lon"`              // longitude of center point polygon osm way
-	Address         string  `json:"address"`          // from tag addr:city/addr:street/addr:place/dll osm, digabungin pakai koma
-	Tipe            string  `json:"type"`             // from value tag amenity / building osm or historic
-	ContainWikiData bool    `json:"contain_wikidata"` // true if this node contain wikidata
+	ID              int               `json:"id"`                 // ID of osm object
+	Name            string            `json:"name"`               // osm object name. from osm tag name
+	Lat             float64           `json:"lat"`                // latitude of point on surface of osm way/relation
+	Lon             float64           `json:"lon"`                // longitude of point on surface of osm way/relation
+	Address         string            `json:"address"`            // from tag addr:city/addr:street/addr:place/dll osm, digabungin pakai koma
+	Tipe            string            `json:"type"`               // from value tag amenity / building osm or historic
+	ContainWikiData bool              `json:"contain_wikidata"`   // true if this node contain wikidata
+	Source          string            `json:"source,omitempty"`   // source of custom document (e.g. merchant). empty for osm objects
+	OSMType         string            `json:"osm_type,omitempty"` // osm element type: node, way or relation. empty for custom documents
+	OSMID           int64             `json:"osm_id,omitempty"`   // osm element id
+	Tags            map[string]string `json:"tags,omitempty"`     // subset of osm tags, e.g. opening_hours, phone, website
+	City            string            `json:"city,omitempty"`     // district/city of the administrative boundary containing the osm object
+	Geometry        *Geometry         `json:"geometry,omitempty"` // geojson geometry of osm object. only returned if requested, not stored in doc store
+	BBox            []float64         `json:"bbox,omitempty"`     // bounding box of geometry: [minLon, minLat, maxLon, maxLat]
+}
+
+// Geometry model info
+// @Description geojson geometry (Point, LineString, Polygon or MultiPolygon). coordinates are [lon, lat].
+type Geometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+// layer reverse geocoding: jenis osm object yang bisa jadi hasil reverse geocoding.
+const (
+	REVERSE_LAYER_BUILDING = "building" // semua osm object
+	REVERSE_LAYER_STREET   = "street"
+	REVERSE_LAYER_VILLAGE  = "village"
+	REVERSE_LAYER_DISTRICT = "district"
+	REVERSE_LAYER_PROVINCE = "province"
+)
+
+// posisi titik reverse geocoding terhadap Place.
+const (
+	REVERSE_MATCH_INSIDE = "inside" // titik ada di dalam area (polygon) Place
+	REVERSE_MATCH_NEARBY = "nearby" // Place osm object terdekat dari titik
+)
+
+// ReverseGeocodingResult model info
+// @Description structured reverse geocoding result. all distances are in km from the query point.
+type ReverseGeocodingResult struct {
+	Place       Node              `json:"place"`                  // nearest osm object, or the smallest polygon containing the point
+	Dist        float64           `json:"dist"`                   // distance to place, 0 if inside
+	Match       string            `json:"match"`                  // inside (the point is inside the place area) or nearby
+	Street      *NearbyObject     `json:"street,omitempty"`       // nearest street. lat/lon = projection of the point to the street
+	CrossStreet *CrossStreet      `json:"cross_street,omitempty"` // street crossing the nearest street at the nearest intersection
+	HouseNumber *HouseNumberMatch `json:"house_number,omitempty"` // nearest osm object with addr:housenumber
+	POI         *NearbyObject     `json:"poi,omitempty"`          // nearest named osm object that is not a street, inside place if match is inside
+	Admin       AdminAddress      `json:"admin"`                  // administrative boundary containing the point
+}
+
+// ReverseGeocodingBatchResult. hasil reverse geocoding satu titik di batch. Err tidak nil kalau titik tsb gagal.
+type ReverseGeocodingBatchResult struct {
+	Result ReverseGeocodingResult
+	Err    error
+}
+
+// NearbyObject model info
+// @Description osm object near the reverse geocoding point.
+type NearbyObject struct {
+	Data Node    `json:"data"`
+	Dist float64 `json:"dist"`
+}
+
+// HouseNumberMatch model info
+// @Description house number (addr:housenumber) of an osm object near the reverse geocoding point.
+type HouseNumberMatch struct {
+	Number string `json:"number"`
+	NearbyObject
+}
+
+// CrossStreet model info
+// @Description street crossing the nearest street, at the intersection (lat, lon) nearest to the reverse geocoding point.
+type CrossStreet struct {
+	Name string  `json:"name"`
+	Lat  float64 `json:"lat"`
+	Lon  float64 `json:"lon"`
+	Dist float64 `json:"dist"` // km from the point to the intersection
+}
+
+// Intersection model info
+// @Description street intersection: osm node shared by highway ways with different names.
+type Intersection struct {
+	Lat     float64  `json:"lat"`
+	Lon     float64  `json:"lon"`
+	Streets []string `json:"streets"`
+}
+
+// AdminAddress model info
+// @Description administrative hierarchy, from the boundary polygon containing the point.
+type AdminAddress struct {
+	Village     string `json:"village,omitempty"`
+	SubDistrict string `json:"sub_district,omitempty"`
+	District    string `json:"district,omitempty"`
+	Province    string `json:"province,omitempty"`
+	Country     string `json:"country,omitempty"`
+	PostalCode  string `json:"postal_code,omitempty"`
+}
+
+// RoadMatch model info
+// @Description gps point snapped to the nearest road segment. lat/lon = projection of the point to the segment, dist in km.
+type RoadMatch struct {
+	WayID   int64       `json:"way_id"`
+	Name    string      `json:"name"`
+	Class   string      `json:"class"` // value of the highway tag, e.g. primary
+	Lat     float64     `json:"lat"`
+	Lon     float64     `json:"lon"`
+	Dist    float64     `json:"dist"`
+	Segment [][]float64 `json:"segment"` // [[lat, lon], [lat, lon]] of the matched road segment
+}
+
+// MatchedRoad model info
+// @Description road traversed by a gps trace.
+type MatchedRoad struct {
+	WayID int64  `json:"way_id"`
+	Name  string `json:"name"`
+	Class string `json:"class"`
+}
+
+// TraceMatch. hasil map matching trace gps. Points sejajar dengan titik trace (nil kalau titik tidak di-match),
+// Roads = urutan jalan yang dilewati trace.
+type TraceMatch struct {
+	Points []*RoadMatch
+	Roads  []MatchedRoad
+}
+
+// SearchBatchQuery. satu query batch forward geocoding. Lat & Lon dipakai kalau HasLocation, untuk bias lokasi & filter Radius (km).
+type SearchBatchQuery struct {
+	ID          string // id dari client, dikembalikan apa adanya di hasil
+	Query       string
+	TopK        int
+	Source      string
+	Type        string // filter tipe document, misal cafe
+	HasLocation bool
+	Lat         float64
+	Lon         float64
+	Radius      float64 // 0 = tanpa filter jarak
+}
+
+// SearchMatch. hasil full text search dengan confidence 0-1. Dist (km) hanya diisi kalau query punya lokasi.
+type SearchMatch struct {
+	Data       Node
+	Confidence float64
+	Dist       float64
+}
+
+// SearchBatchResult. hasil satu query batch forward geocoding, urut sesuai Index query di batch. Err tidak nil kalau query tsb gagal.
+type SearchBatchResult struct {
+	Index   int
+	Query   SearchBatchQuery
+	Matches []SearchMatch
+	Err     error
+}
+
+const SOURCE_OSM = "osm"
+
+// GetSource. return SOURCE_OSM untuk osm object.
+func (n Node) GetSource() string {
+	if n.Source == "" {
+		return SOURCE_OSM
+	}
+	return n.Source
+}
+
+// WithOSMObject. set osm element type, osm element id & tags osm yang disimpan di doc store.
+func (n Node) WithOSMObject(osmType string, osmID int64, tags map[string]string) Node {
+	n.OSMType = osmType
+	n.OSMID = osmID
+	n.Tags = tags
+	return n
 }
 
 func NewNode(id int, name string, lat float64, lon float64, address string, tipe string, city string, wikiData bool) Node {
@@ -21,6 +184,7 @@ func NewNode(id int, name string, lat float64, lon float64, address string, tipe
 		Lon:     lon,
 		Address: address,
 		Tipe:    tipe,
+		City:    city,
 		ContainWikiData: wikiData,
 	}
 }
@@ -603,6 +603,13 @@ type OSMObject struct {
 	Bound           RtreeBoundingBox
 	OsmBound        [2][]float64
 	BoundaryLatLons [][]float64
+	Polygon         *Multipolygon // polygon osm relation (multipolygon/boundary). nil untuk osm node & way
+}
+
+// Multipolygon. ring outer & inner (hole) dari osm relation. setiap ring tertutup, titik = [lat, lon].
+type Multipolygon struct {
+	Outer [][][]float64
+	Inner [][][]float64
 }
 
 func NewOSMObject(id int, lat, lon float64, tag map[int]int,
@@ -655,30 +662,37 @@ const (
 // NearestNeighboursRadiusFilterOSM. returns the k nearest neighbours (with filtered osm feature) within a given radius in km.
 func (rt *Rtree) NearestNeighboursRadiusFilterOSM(k, offfset int, p Point, maxRadius float64,
 	osmFeature int) []OSMObject {
-	nearestLists := make([]OSMObject, 0, k*100)
+	return rt.NearestNeighboursRadiusFilter(k, offfset, p, maxRadius, func(n OSMObject) bool {
+		_, ok := n.Tag[osmFeature]
+		return osmFeature == SKIP_OSM_FEATURE || ok
+	})
+}
+
+// NearestNeighboursRadiusFilter. returns the k nearest neighbours within a given radius in km yang lolos filter (setelah skip offset objek).
+// incremental nearest neighbor berhenti begitu offset+k objek ditemukan atau objek berikutnya di luar radius.
+func (rt *Rtree) NearestNeighboursRadiusFilter(k, offset int, p Point, maxRadius float64,
+	filter func(OSMObject) bool) []OSMObject {
+	nearestLists := make([]OSMObject, 0, k+offset)
 
 	callback := func(n OSMObject) bool {
 		dist := HaversineDistance(p.Lat, p.Lon, n.Lat, n.Lon)
-		if _, ok := n.Tag[osmFeature]; osmFeature != SKIP_OSM_FEATURE && ok && dist <= maxRadius {
-			nearestLists = append(nearestLists, n)
-		} else if osmFeature == SKIP_OSM_FEATURE && dist <= maxRadius {
+		if dist > maxRadius {
+			return false
+		}
+		if filter(n) {
 			nearestLists = append(nearestLists, n)
 		}
-
-		return dist <= maxRadius
+		return len(nearestLists) < k+offset
 	}
 
-	rt.incrementalNearestNeighbor(p, callback)
-
-	if len(nearestLists) > offfset {
-		nearestLists = nearestLists[offfset:]
+	if k > 0 {
+		rt.incrementalNearestNeighbor(p, callback)
 	}
 
-	if len(nearestLists) > k {
-		nearestLists = nearestLists[:k]
+	if len(nearestLists) > offset {
+		return nearestLists[offset:]
 	}
-
-	return nearestLists
+	return []OSMObject{}
 }
 
 // https://dl.acm.org/doi/pdf/10.1145/320248.320255 (Fig. 4.  incremental nearest neighbor algorithm)
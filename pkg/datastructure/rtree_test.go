@@ -474,6 +474,31 @@ func TestNearestNeighborRadiusFilterOsmFeature(t *testing.T) {
 	})
 }
 
+func TestNearestNeighboursRadiusFilter(t *testing.T) {
+	rt := NewRtree(25, 50, 2)
+	for i := 0; i < 100; i++ {
+		item := OSMObject{ID: i, Lat: -6.2 + float64(i)*0.001, Lon: 106.8, Tag: map[int]int{i % 2: 0}}
+		rt.InsertLeaf(NewRtreeBoundingBox(2, []float64{item.Lat - 0.0001, item.Lon - 0.0001}, []float64{item.Lat + 0.0001, item.Lon + 0.0001}), item, false)
+	}
+
+	visited := 0
+	results := rt.NearestNeighboursRadiusFilter(3, 2, Point{-6.2, 106.8}, 5.0, func(n OSMObject) bool {
+		visited++
+		_, ok := n.Tag[1]
+		return ok
+	})
+	ids := []int{}
+	for _, item := range results {
+		ids = append(ids, item.ID)
+	}
+	assert.Equal(t, []int{5, 7, 9}, ids)
+	assert.Equal(t, 10, visited) // berhenti setelah offset+k objek lolos filter
+
+	results = rt.NearestNeighboursRadiusFilter(10, 0, Point{-6.2, 106.8}, 0.5, func(n OSMObject) bool { return true })
+	assert.Len(t, results, 5) // 0.5 km ~ 4.5 x 0.001 derajat lat
+	assert.Len(t, rt.NearestNeighboursRadiusFilter(10, 10, Point{-6.2, 106.8}, 0.5, func(n OSMObject) bool { return true }), 0)
+}
+
 func TestDelete(t *testing.T) {
 	t.Run("Test Delete", func(t *testing.T) {
 		itemsData := []OSMObject{}
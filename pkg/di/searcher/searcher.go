@@ -2,14 +2,27 @@ package searcher_di
 
 import (
 	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
 
-	"github.com/lintang-b-s/osm-search/pkg/http/usecases"
+	"github.com/lintang-b-s/osm-search/pkg/geo"
 	"github.com/lintang-b-s/osm-search/pkg/index"
 	"github.com/lintang-b-s/osm-search/pkg/kvdb"
 	"github.com/lintang-b-s/osm-search/pkg/searcher"
+
+	"github.com/spf13/viper"
 )
 
-func New(ctx context.Context, db *kvdb.KVDB, scoring searcher.SimiliarityScoring) (usecases.Searcher, error) {
+func New(ctx context.Context, db *kvdb.KVDB, scoring searcher.SimiliarityScoring) (*searcher.Searcher, error) {
+	// aturan indexing yang dipakai saat indexing.
+	rules, err := geo.LoadIndexRules(filepath.Join("lintang", geo.INDEX_RULES_FILE))
+	if err == nil {
+		geo.SetIndexRules(rules)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
 	ngramLM := searcher.NewNGramLanguageModel("lintang")
 	spellCorrector := searcher.NewSpellCorrector(ngramLM, "lintang")
 	invertedIndex, err := index.NewDynamicIndex("lintang", 1e7, true, spellCorrector, index.IndexedData{},
@@ -18,12 +31,14 @@ func New(ctx context.Context, db *kvdb.KVDB, scoring searcher.SimiliarityScoring
 		return nil, err
 	}
 
-	err = spellCorrector.InitializeSpellCorrector(invertedIndex.TermIDMap.GetSortedTerms(), invertedIndex.GetTermIDMap())
+	err = spellCorrector.InitializeSpellCorrector(invertedIndex.GetTermIDMap())
 	if err != nil {
 		return nil, err
 	}
 
 	osmSearcher := searcher.NewSearcher(invertedIndex, db, spellCorrector, scoring)
+	viper.SetDefault("REVERSE_GEOCODING_MAX_RADIUS", searcher.REVERSE_GEOCODING_MAX_RADIUS)
+	osmSearcher.SetReverseGeocodingMaxRadius(viper.GetFloat64("REVERSE_GEOCODING_MAX_RADIUS"))
 	err = osmSearcher.LoadMainIndex()
 	if err != nil {
 		return nil, err
@@ -33,6 +48,8 @@ func New(ctx context.Context, db *kvdb.KVDB, scoring searcher.SimiliarityScoring
 		return nil, err
 	}
 
+	go osmSearcher.StartSegmentMerge(ctx)
+
 	cleanup := func() {
 		osmSearcher.Close()
 	}
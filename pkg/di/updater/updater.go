@@ -0,0 +1,42 @@
+package updater_di
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"github.com/lintang-b-s/osm-search/pkg/geo"
+	"github.com/lintang-b-s/osm-search/pkg/http/usecases"
+	"github.com/lintang-b-s/osm-search/pkg/kvdb"
+	"github.com/lintang-b-s/osm-search/pkg/searcher"
+	"github.com/lintang-b-s/osm-search/pkg/updater"
+)
+
+// ChangeDir. directory berisi file osmChange (.osc/.osc.gz) yang di-apply saat server jalan. kosong = tidak ada update.
+type ChangeDir string
+
+func New(ctx context.Context, db *kvdb.KVDB, osmSearcher *searcher.Searcher, changeDir ChangeDir) (usecases.DocumentIndex, error) {
+	osmUpdater := updater.NewUpdater(db, osmSearcher)
+
+	nodeCoords, err := geo.OpenNodeCoordStore(filepath.Join("lintang", geo.NODE_COORDS_FILE))
+	if err == nil {
+		osmUpdater.SetCoordinateLookup(nodeCoords)
+		go func() {
+			<-ctx.Done()
+			nodeCoords.Close()
+		}()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	err = osmUpdater.Restore()
+	if err != nil {
+		return nil, err
+	}
+	if changeDir != "" {
+		go osmUpdater.Watch(ctx, string(changeDir), time.Minute)
+	}
+	return osmUpdater, nil
+}
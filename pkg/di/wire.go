@@ -13,6 +13,7 @@ import (
 	kv_di "github.com/lintang-b-s/osm-search/pkg/di/kv"
 	logger_di "github.com/lintang-b-s/osm-search/pkg/di/logger"
 	searcher_di "github.com/lintang-b-s/osm-search/pkg/di/searcher"
+	updater_di "github.com/lintang-b-s/osm-search/pkg/di/updater"
 	searchHttp "github.com/lintang-b-s/osm-search/pkg/http"
 	"github.com/lintang-b-s/osm-search/pkg/http/http-router/controllers"
 	"github.com/lintang-b-s/osm-search/pkg/http/usecases"
@@ -26,6 +27,8 @@ var defaultSet = wire.NewSet(
 	logger_di.New,
 	kv_di.New,
 	searcher_di.New,
+	wire.Bind(new(usecases.Searcher), new(*searcher.Searcher)),
+	updater_di.New,
 	geofence_di.New,
 )
 
@@ -33,6 +36,7 @@ var searcherSet = wire.NewSet(
 	defaultSet,
 	NewSearcherService,
 	NewGeofenceService,
+	NewDocumentService,
 	NewSearchAPIServer,
 )
 
@@ -44,12 +48,17 @@ func NewGeofenceService(geofenceIndex usecases.GeofenceIndex) controllers.Geofen
 	return usecases.NewGeofenceService(geofenceIndex)
 }
 
+func NewDocumentService(documentIndex usecases.DocumentIndex) controllers.DocumentService {
+	return usecases.NewDocumentService(documentIndex)
+}
+
 func NewSearchAPIServer(ctx context.Context, log *zap.Logger,
-	searchService controllers.SearchService, geofenceService controllers.GeofenceService) (*searchHttp.Server, error) {
+	searchService controllers.SearchService, geofenceService controllers.GeofenceService,
+	documentService controllers.DocumentService, useRateLimit bool) (*searchHttp.Server, error) {
 	api := searchHttp.NewServer(log)
 
 	apiService, err := api.Use(
-		ctx, log, searchService, geofenceService,
+		ctx, log, searchService, geofenceService, documentService, useRateLimit,
 	)
 	if err != nil {
 		return nil, err
@@ -58,7 +67,8 @@ func NewSearchAPIServer(ctx context.Context, log *zap.Logger,
 	return apiService, nil
 }
 
-func InitializeSearcherService(scoring searcher.SimiliarityScoring, useRateLimit bool) (*searchHttp.Server, func(), error) {
+func InitializeSearcherService(scoring searcher.SimiliarityScoring, useRateLimit bool,
+	changeDir updater_di.ChangeDir) (*searchHttp.Server, func(), error) {
 
 	panic(wire.Build(searcherSet))
 }
@@ -15,6 +15,7 @@ import (
 	"github.com/lintang-b-s/osm-search/pkg/di/kv"
 	"github.com/lintang-b-s/osm-search/pkg/di/logger"
 	"github.com/lintang-b-s/osm-search/pkg/di/searcher"
+	"github.com/lintang-b-s/osm-search/pkg/di/updater"
 	"github.com/lintang-b-s/osm-search/pkg/http"
 	"github.com/lintang-b-s/osm-search/pkg/http/http-router/controllers"
 	"github.com/lintang-b-s/osm-search/pkg/http/usecases"
@@ -24,7 +25,7 @@ import (
 
 // Injectors from wire.go:
 
-func InitializeSearcherService(scoring searcher.SimiliarityScoring, useRateLimit bool) (*http.Server, func(), error) {
+func InitializeSearcherService(scoring searcher.SimiliarityScoring, useRateLimit bool, changeDir updater_di.ChangeDir) (*http.Server, func(), error) {
 	contextContext, cleanup, err := context.New()
 	if err != nil {
 		return nil, nil, err
@@ -40,16 +41,23 @@ func InitializeSearcherService(scoring searcher.SimiliarityScoring, useRateLimit
 		cleanup()
 		return nil, nil, err
 	}
-	usecasesSearcher, err := searcher_di.New(contextContext, kvdb, scoring)
+	searcherSearcher, err := searcher_di.New(contextContext, kvdb, scoring)
 	if err != nil {
 		cleanup2()
 		cleanup()
 		return nil, nil, err
 	}
-	searchService := NewSearcherService(logger, usecasesSearcher)
+	searchService := NewSearcherService(logger, searcherSearcher)
 	geofenceIndex := geofence_di.New(kvdb)
 	geofenceService := NewGeofenceService(geofenceIndex)
-	server, err := NewSearchAPIServer(contextContext, logger, searchService, geofenceService, useRateLimit)
+	documentIndex, err := updater_di.New(contextContext, kvdb, searcherSearcher, changeDir)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	documentService := NewDocumentService(documentIndex)
+	server, err := NewSearchAPIServer(contextContext, logger, searchService, geofenceService, documentService, useRateLimit)
 	if err != nil {
 		cleanup2()
 		cleanup()
@@ -63,12 +71,13 @@ func InitializeSearcherService(scoring searcher.SimiliarityScoring, useRateLimit
 
 // wire.go:
 
-var defaultSet = wire.NewSet(context.New, config.New, logger_di.New, kv_di.New, searcher_di.New, geofence_di.New)
+var defaultSet = wire.NewSet(context.New, config.New, logger_di.New, kv_di.New, searcher_di.New, wire.Bind(new(usecases.Searcher), new(*searcher.Searcher)), updater_di.New, geofence_di.New)
 
 var searcherSet = wire.NewSet(
 	defaultSet,
 	NewSearcherService,
 	NewGeofenceService,
+	NewDocumentService,
 	NewSearchAPIServer,
 )
 
@@ -80,13 +89,17 @@ func NewGeofenceService(geofenceIndex usecases.GeofenceIndex) controllers.Geofen
 	return usecases.NewGeofenceService(geofenceIndex)
 }
 
+func NewDocumentService(documentIndex usecases.DocumentIndex) controllers.DocumentService {
+	return usecases.NewDocumentService(documentIndex)
+}
+
 func NewSearchAPIServer(ctx context2.Context, log *zap.Logger,
-	searchService controllers.SearchService, geofenceService controllers.GeofenceService, 
-	useRateLimit	bool) (*http.Server, error) {
+	searchService controllers.SearchService, geofenceService controllers.GeofenceService,
+	documentService controllers.DocumentService, useRateLimit bool) (*http.Server, error) {
 	api := http.NewServer(log)
 
 	apiService, err := api.Use(
-		ctx, log, searchService, geofenceService,useRateLimit,
+		ctx, log, searchService, geofenceService, documentService, useRateLimit,
 	)
 	if err != nil {
 		return nil, err
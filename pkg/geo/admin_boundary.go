@@ -0,0 +1,232 @@
+package geo
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+)
+
+// komponen address administratif, sama dengan field Boundary.
+const (
+	ADDRESS_COUNTRY      = "country"
+	ADDRESS_PROVINCE     = "province"
+	ADDRESS_DISTRICT     = "district"
+	ADDRESS_SUB_DISTRICT = "sub_district"
+	ADDRESS_VILLAGE      = "village"
+
+	DEFAULT_COUNTRY = "default"
+)
+
+// AdminLevelConfig. country code (ISO3166-1 alpha2) -> admin_level osm -> komponen address.
+// admin_level yang tidak ada di config tidak dipakai di address. country yang tidak ada di config pakai DEFAULT_COUNTRY.
+type AdminLevelConfig map[string]map[int]string
+
+// DefaultAdminLevels. mapping admin_level bawaan, lihat https://wiki.openstreetmap.org/wiki/Tag:boundary%3Dadministrative#Country_specific_values_of_the_key_admin_level=*
+var DefaultAdminLevels = AdminLevelConfig{
+	DEFAULT_COUNTRY: {
+		2:  ADDRESS_COUNTRY,
+		4:  ADDRESS_PROVINCE,
+		8:  ADDRESS_DISTRICT,
+		9:  ADDRESS_SUB_DISTRICT,
+		10: ADDRESS_VILLAGE,
+	},
+	"ID": {
+		2: ADDRESS_COUNTRY,
+		4: ADDRESS_PROVINCE,     // provinsi
+		5: ADDRESS_DISTRICT,     // kabupaten/kota
+		6: ADDRESS_SUB_DISTRICT, // kecamatan
+		7: ADDRESS_VILLAGE,      // desa/kelurahan
+	},
+	"US": {
+		2: ADDRESS_COUNTRY,
+		4: ADDRESS_PROVINCE, // state
+		6: ADDRESS_SUB_DISTRICT,
+		8: ADDRESS_DISTRICT, // city
+	},
+}
+
+var addressComponents = map[string]bool{
+	ADDRESS_COUNTRY:      true,
+	ADDRESS_PROVINCE:     true,
+	ADDRESS_DISTRICT:     true,
+	ADDRESS_SUB_DISTRICT: true,
+	ADDRESS_VILLAGE:      true,
+}
+
+// LoadAdminLevelConfig. load mapping admin_level dari file json, contoh: {"MY": {"2": "country", "4": "province", "6": "district"}}.
+// country di file menggantikan mapping bawaan country tersebut.
+func LoadAdminLevelConfig(path string) (AdminLevelConfig, error) {
+	config := make(AdminLevelConfig, len(DefaultAdminLevels))
+	for country, levels := range DefaultAdminLevels {
+		config[country] = levels
+	}
+	if path == "" {
+		return config, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fileConfig AdminLevelConfig
+	err = json.Unmarshal(data, &fileConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid admin level config %s: %w", path, err)
+	}
+	for country, levels := range fileConfig {
+		for level, component := range levels {
+			if !addressComponents[component] {
+				return nil, fmt.Errorf("invalid admin level config %s: unknown address component %q for %s admin_level %d",
+					path, component, country, level)
+			}
+		}
+		config[country] = levels
+	}
+	return config, nil
+}
+
+func (c AdminLevelConfig) levels(country string) map[int]string {
+	if levels, ok := c[country]; ok {
+		return levels
+	}
+	return c[DEFAULT_COUNTRY]
+}
+
+// AdminBoundaryConfig. sumber boundary administratif untuk address. kalau FromOSM false, boundary di-load dari file
+// geojson/json (region_boundary.json).
+type AdminBoundaryConfig struct {
+	FromOSM    bool
+	Levels     AdminLevelConfig
+	Country    string             // country code, kalau kosong diambil dari tag ISO3166-1 relation admin_level=2
+	Properties BoundaryProperties // nama property feature geojson untuk setiap komponen address
+}
+
+type adminBoundary struct {
+	relation *OSMRelation
+	level    int
+	polygon  *datastructure.Multipolygon
+	bound    datastructure.RtreeBoundingBox
+	parent   int
+}
+
+// BuildAdminBoundaries. buat hierarki administratif dari osm relation boundary=administrative. parent setiap boundary adalah
+// boundary dengan admin_level terbesar (lebih kecil dari admin_level boundary tsb) yang berisi titik di dalam boundary.
+// boundary yang admin_level-nya ada di config di-insert ke regionRtree dengan id leaf = index di slice Boundary yang di-return.
+func BuildAdminBoundaries(relations []OSMRelation, config AdminBoundaryConfig, regionRtree *datastructure.Rtree) []Boundary {
+	if config.Levels == nil {
+		config.Levels = DefaultAdminLevels
+	}
+
+	admins := []adminBoundary{}
+	for i := range relations {
+		relation := &relations[i]
+		if relation.Type != RELATION_BOUNDARY || relation.TagMap["boundary"] != "administrative" || len(relation.OuterRings) == 0 {
+			continue
+		}
+		level, err := strconv.Atoi(relation.TagMap["admin_level"])
+		if err != nil {
+			continue
+		}
+		polygon := relation.Multipolygon()
+		minLat, minLon, maxLat, maxLon := MultipolygonBound(polygon)
+		admins = append(admins, adminBoundary{
+			relation: relation,
+			level:    level,
+			polygon:  polygon,
+			bound:    datastructure.NewRtreeBoundingBox(2, []float64{minLat, minLon}, []float64{maxLat, maxLon}),
+			parent:   -1,
+		})
+	}
+	sort.SliceStable(admins, func(i, j int) bool {
+		return admins[i].level < admins[j].level
+	})
+
+	// cari parent setiap boundary. boundary di-insert ke hierarchyRtree urut dari admin_level terkecil, jadi semua calon parent sudah ada di rtree.
+	hierarchyRtree := datastructure.NewRtree(25, 50, 2)
+	for i := range admins {
+		lat, lon := InteriorPoint(admins[i].relation.BoundaryLatLons())
+		candidates := hierarchyRtree.Search(datastructure.NewRtreeBoundingBox(2, []float64{lat - 1e-9, lon - 1e-9},
+			[]float64{lat + 1e-9, lon + 1e-9}))
+		sort.Slice(candidates, func(a, b int) bool {
+			return admins[candidates[a].Leaf.ID].level > admins[candidates[b].Leaf.ID].level
+		})
+		for _, candidate := range candidates {
+			parent := candidate.Leaf.ID
+			if admins[parent].level < admins[i].level && PointInMultipolygon(lat, lon, admins[parent].polygon) {
+				admins[i].parent = parent
+				break
+			}
+		}
+
+		hierarchyRtree.InsertLeaf(admins[i].bound, datastructure.OSMObject{ID: i, Lat: lat, Lon: lon}, false)
+	}
+
+	boundaries := []Boundary{}
+	for i := range admins {
+		country := config.Country
+		if country == "" {
+			country = admins[i].countryCode(admins)
+		}
+		levels := config.Levels.levels(country)
+		if _, ok := levels[admins[i].level]; !ok {
+			continue
+		}
+
+		boundary := Boundary{AdminLevel: admins[i].level}
+		for curr := i; curr != -1; curr = admins[curr].parent {
+			boundary.setComponent(levels[admins[curr].level], admins[curr].relation.TagMap["name"])
+		}
+
+		lat, lon := admins[i].relation.Center()
+		regionRtree.InsertLeaf(admins[i].bound, datastructure.OSMObject{
+			ID:      len(boundaries),
+			Lat:     lat,
+			Lon:     lon,
+			Polygon: admins[i].polygon,
+		}, false)
+		boundaries = append(boundaries, boundary)
+	}
+	return boundaries
+}
+
+// countryCode. country code dari relation admin_level=2 di hierarki boundary.
+func (a adminBoundary) countryCode(admins []adminBoundary) string {
+	for curr := &a; ; curr = &admins[curr.parent] {
+		if curr.level == 2 {
+			for _, key := range []string{"ISO3166-1:alpha2", "ISO3166-1", "country_code"} {
+				if code := curr.relation.TagMap[key]; code != "" {
+					return code
+				}
+			}
+		}
+		if curr.parent == -1 {
+			return DEFAULT_COUNTRY
+		}
+	}
+}
+
+// setComponent. isi field boundary untuk komponen address kalau field masih kosong.
+func (b *Boundary) setComponent(component, name string) {
+	var field *string
+	switch component {
+	case ADDRESS_COUNTRY:
+		field = &b.Country
+	case ADDRESS_PROVINCE:
+		field = &b.Province
+	case ADDRESS_DISTRICT:
+		field = &b.District
+	case ADDRESS_SUB_DISTRICT:
+		field = &b.SubDistrict
+	case ADDRESS_VILLAGE:
+		field = &b.Village
+	default:
+		return
+	}
+	if *field == "" {
+		*field = name
+	}
+}
@@ -0,0 +1,91 @@
+package geo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/stretchr/testify/assert"
+)
+
+func squareRing(minLat, minLon, maxLat, maxLon float64) [][]float64 {
+	return [][]float64{{minLat, minLon}, {minLat, maxLon}, {maxLat, maxLon}, {maxLat, minLon}, {minLat, minLon}}
+}
+
+func adminRelation(id int64, name, level string, ring [][]float64, tags map[string]string) OSMRelation {
+	tagMap := map[string]string{"name": name, "boundary": "administrative", "admin_level": level}
+	for k, v := range tags {
+		tagMap[k] = v
+	}
+	return OSMRelation{ID: id, Type: RELATION_BOUNDARY, TagMap: tagMap, OuterRings: [][][]float64{ring}}
+}
+
+func TestBuildAdminBoundaries(t *testing.T) {
+	relations := []OSMRelation{
+		adminRelation(5, "Sleman", "5", squareRing(-7.8, 110.2, -7.6, 110.5), nil),
+		adminRelation(7, "Caturtunggal", "7", squareRing(-7.79, 110.37, -7.76, 110.41), nil),
+		adminRelation(2, "Indonesia", "2", squareRing(-11, 95, 6, 141), map[string]string{"ISO3166-1": "ID"}),
+		adminRelation(4, "Daerah Istimewa Yogyakarta", "4", squareRing(-8.2, 110.0, -7.5, 110.9), nil),
+		adminRelation(6, "Depok", "6", squareRing(-7.8, 110.35, -7.7, 110.45), nil),
+		adminRelation(3, "Bantul", "5", squareRing(-8.1, 110.2, -7.81, 110.5), nil),
+		// bukan boundary administratif.
+		{ID: 8, Type: RELATION_BOUNDARY, TagMap: map[string]string{"name": "Taman Nasional", "boundary": "national_park", "admin_level": "5"},
+			OuterRings: [][][]float64{squareRing(-7.8, 110.2, -7.6, 110.5)}},
+	}
+
+	t.Run("country from admin_level 2", func(t *testing.T) {
+		regionRtree := datastructure.NewRtree(25, 50, 2)
+		boundaries := BuildAdminBoundaries(relations, AdminBoundaryConfig{FromOSM: true}, regionRtree)
+		assert.Len(t, boundaries, 6)
+		assert.Equal(t, 6, regionRtree.Size)
+
+		var village, bantul Boundary
+		for _, b := range boundaries {
+			if b.Village == "Caturtunggal" {
+				village = b
+			}
+			if b.District == "Bantul" {
+				bantul = b
+			}
+		}
+		assert.Equal(t, Boundary{Country: "Indonesia", Province: "Daerah Istimewa Yogyakarta", District: "Sleman",
+			SubDistrict: "Depok", Village: "Caturtunggal", AdminLevel: 7}, village)
+		assert.Equal(t, Boundary{Country: "Indonesia", Province: "Daerah Istimewa Yogyakarta", District: "Bantul",
+			AdminLevel: 5}, bantul)
+	})
+
+	t.Run("configured country", func(t *testing.T) {
+		regionRtree := datastructure.NewRtree(25, 50, 2)
+		levels := AdminLevelConfig{"XX": {4: ADDRESS_PROVINCE, 7: ADDRESS_DISTRICT}}
+		boundaries := BuildAdminBoundaries(relations, AdminBoundaryConfig{FromOSM: true, Levels: levels, Country: "XX"}, regionRtree)
+		assert.Len(t, boundaries, 2)
+		assert.Equal(t, Boundary{Province: "Daerah Istimewa Yogyakarta", AdminLevel: 4}, boundaries[0])
+		assert.Equal(t, Boundary{Province: "Daerah Istimewa Yogyakarta", District: "Caturtunggal", AdminLevel: 7}, boundaries[1])
+	})
+}
+
+func TestInteriorPoint(t *testing.T) {
+	// ring berbentuk U, centroid bounding box (0.5, 1.5) ada di luar ring.
+	ring := [][]float64{{0, 0}, {0, 3}, {1, 3}, {1, 2}, {0.2, 2}, {0.2, 1}, {1, 1}, {1, 0}, {0, 0}}
+	lat, lon := InteriorPoint(ring)
+	assert.True(t, pointInRing(lat, lon, ring))
+}
+
+func TestLoadAdminLevelConfig(t *testing.T) {
+	config, err := LoadAdminLevelConfig("")
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultAdminLevels["ID"], config.levels("ID"))
+	assert.Equal(t, DefaultAdminLevels[DEFAULT_COUNTRY], config.levels("MY"))
+
+	path := filepath.Join(t.TempDir(), "admin_levels.json")
+	assert.Nil(t, os.WriteFile(path, []byte(`{"MY": {"2": "country", "4": "province", "6": "district"}}`), 0600))
+	config, err = LoadAdminLevelConfig(path)
+	assert.Nil(t, err)
+	assert.Equal(t, map[int]string{2: ADDRESS_COUNTRY, 4: ADDRESS_PROVINCE, 6: ADDRESS_DISTRICT}, config.levels("MY"))
+	assert.Equal(t, DefaultAdminLevels["ID"], config.levels("ID"))
+
+	assert.Nil(t, os.WriteFile(path, []byte(`{"MY": {"6": "kampung"}}`), 0600))
+	_, err = LoadAdminLevelConfig(path)
+	assert.NotNil(t, err)
+}
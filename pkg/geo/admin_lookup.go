@@ -0,0 +1,106 @@
+package geo
+
+import (
+	"bytes"
+	"encoding/gob"
+	"os"
+	"sort"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+)
+
+const ADMIN_BOUNDARIES_FILE = "admin_boundaries.dat"
+
+// AdminIndex. boundary administratif & r-tree polygon-nya (id leaf = index di boundaries). dipakai untuk address saat indexing
+// & untuk hierarki administratif reverse geocoding saat query.
+type AdminIndex struct {
+	rtree      *datastructure.Rtree
+	boundaries []Boundary
+}
+
+func NewAdminIndex(rtree *datastructure.Rtree, boundaries []Boundary) *AdminIndex {
+	return &AdminIndex{rtree: rtree, boundaries: boundaries}
+}
+
+func (a *AdminIndex) Size() int {
+	if a == nil || a.rtree == nil {
+		return 0
+	}
+	return a.rtree.Size
+}
+
+// Lookup. boundary dengan admin_level terbesar (paling kecil) yang polygon-nya berisi titik (lat, lon). boundary dari osm & geojson
+// sudah berisi nama semua boundary parent-nya. false kalau tidak ada boundary yang berisi titik.
+func (a *AdminIndex) Lookup(lat, lon float64) (Boundary, bool) {
+	if a.Size() == 0 {
+		return Boundary{}, false
+	}
+	regions := a.rtree.Search(datastructure.NewRtreeBoundingBox(2, []float64{lat - 1e-9, lon - 1e-9},
+		[]float64{lat + 1e-9, lon + 1e-9}))
+	sort.SliceStable(regions, func(i, j int) bool {
+		return a.boundaries[regions[i].Leaf.ID].AdminLevel > a.boundaries[regions[j].Leaf.ID].AdminLevel
+	})
+	for _, region := range regions {
+		if region.Leaf.Polygon != nil && PointInMultipolygon(lat, lon, region.Leaf.Polygon) {
+			return a.boundaries[region.Leaf.ID], true
+		}
+	}
+	return Boundary{}, false
+}
+
+type adminBoundaryRecord struct {
+	Boundary Boundary
+	Lat      float64
+	Lon      float64
+	Polygon  *datastructure.Multipolygon
+}
+
+// Save. simpan boundary & polygon-nya ke file (gob), di-load server dengan LoadAdminIndex.
+func (a *AdminIndex) Save(path string) error {
+	records := make([]adminBoundaryRecord, len(a.boundaries))
+	for i := range a.boundaries {
+		records[i].Boundary = a.boundaries[i]
+		records[i].Boundary.Border = nil // border sudah ada di polygon
+	}
+	if a.Size() > 0 {
+		leaves := a.rtree.Search(datastructure.NewRtreeBoundingBox(2, []float64{-90, -180}, []float64{90, 180}))
+		for _, leaf := range leaves {
+			records[leaf.Leaf.ID].Lat = leaf.Leaf.Lat
+			records[leaf.Leaf.ID].Lon = leaf.Leaf.Lon
+			records[leaf.Leaf.ID].Polygon = leaf.Leaf.Polygon
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	err := gob.NewEncoder(buf).Encode(records)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// LoadAdminIndex. load boundary hasil Save & bangun ulang r-tree polygon-nya.
+func LoadAdminIndex(path string) (*AdminIndex, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var records []adminBoundaryRecord
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(&records)
+	if err != nil {
+		return nil, err
+	}
+
+	rtree := datastructure.NewRtree(25, 50, 2)
+	boundaries := make([]Boundary, len(records))
+	for i, record := range records {
+		boundaries[i] = record.Boundary
+		if record.Polygon == nil || len(record.Polygon.Outer) == 0 {
+			continue
+		}
+		minLat, minLon, maxLat, maxLon := MultipolygonBound(record.Polygon)
+		rtree.InsertLeaf(datastructure.NewRtreeBoundingBox(2, []float64{minLat, minLon}, []float64{maxLat, maxLon}),
+			datastructure.OSMObject{ID: i, Lat: record.Lat, Lon: record.Lon, Polygon: record.Polygon}, false)
+	}
+	return NewAdminIndex(rtree, boundaries), nil
+}
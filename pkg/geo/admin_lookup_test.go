@@ -0,0 +1,72 @@
+package geo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminIndex(t *testing.T) {
+	square := func(minLat, minLon, maxLat, maxLon float64) [][]float64 {
+		return [][]float64{{minLat, minLon}, {minLat, maxLon}, {maxLat, maxLon}, {maxLat, minLon}, {minLat, minLon}}
+	}
+	admin := func(id int64, name, level string, ring [][]float64) OSMRelation {
+		return OSMRelation{ID: id, Type: RELATION_BOUNDARY, OuterRings: [][][]float64{ring},
+			TagMap: map[string]string{"name": name, "boundary": "administrative", "admin_level": level}}
+	}
+	relations := []OSMRelation{
+		admin(1, "Daerah Istimewa Yogyakarta", "4", square(-8.2, 110.0, -7.5, 110.9)),
+		admin(2, "Sleman", "5", square(-7.8, 110.2, -7.6, 110.5)),
+		admin(3, "Depok", "6", square(-7.8, 110.35, -7.7, 110.45)),
+	}
+	regionRtree := datastructure.NewRtree(25, 50, 2)
+	adminIndex := NewAdminIndex(regionRtree, BuildAdminBoundaries(relations, AdminBoundaryConfig{FromOSM: true, Country: "ID"},
+		regionRtree))
+
+	path := filepath.Join(t.TempDir(), ADMIN_BOUNDARIES_FILE)
+	assert.Nil(t, adminIndex.Save(path))
+	loaded, err := LoadAdminIndex(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, loaded.Size())
+
+	for _, a := range []*AdminIndex{adminIndex, loaded} {
+		boundary, ok := a.Lookup(-7.75, 110.4)
+		assert.True(t, ok)
+		assert.Equal(t, Boundary{Province: "Daerah Istimewa Yogyakarta", District: "Sleman", SubDistrict: "Depok",
+			AdminLevel: 6}, boundary)
+
+		boundary, ok = a.Lookup(-7.65, 110.3)
+		assert.True(t, ok)
+		assert.Equal(t, "Sleman", boundary.District)
+		assert.Equal(t, "", boundary.SubDistrict)
+
+		_, ok = a.Lookup(-6.2, 106.8)
+		assert.False(t, ok)
+	}
+
+	var empty *AdminIndex
+	_, ok := empty.Lookup(-7.75, 110.4)
+	assert.False(t, ok)
+}
+
+func TestAdminIndexBoundaryArray(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "region_boundary.json")
+	data := `[{"province": "DI YOGYAKARTA", "district": "SLEMAN", "sub_district": "DEPOK", "village": "CATURTUNGGAL",
+		"postal_code": "55281", "border": [[110.37, -7.79], [110.41, -7.79], [110.41, -7.76], [110.37, -7.76], [110.37, -7.79]]}]`
+	assert.Nil(t, os.WriteFile(path, []byte(data), 0600))
+
+	regionRtree := datastructure.NewRtree(25, 50, 2)
+	boundaries, err := loadRegionBoundaries(path, DefaultBoundaryProperties, regionRtree)
+	assert.Nil(t, err)
+
+	boundary, ok := NewAdminIndex(regionRtree, boundaries).Lookup(-7.77, 110.38)
+	assert.True(t, ok)
+	assert.Equal(t, "Caturtunggal", boundary.Village)
+	assert.Equal(t, "55281", boundary.PostalCode)
+
+	_, ok = NewAdminIndex(regionRtree, boundaries).Lookup(-7.77, 110.42)
+	assert.False(t, ok)
+}
@@ -0,0 +1,255 @@
+package geo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+)
+
+const ADDRESS_POSTAL_CODE = "postal_code"
+
+// BoundaryProperties. komponen address -> nama property feature geojson, contoh untuk data GADM:
+// {"province": "NAME_1", "district": "NAME_2", "sub_district": "NAME_3", "village": "NAME_4"}.
+type BoundaryProperties map[string]string
+
+var DefaultBoundaryProperties = BoundaryProperties{
+	ADDRESS_COUNTRY:      "country",
+	ADDRESS_PROVINCE:     "province",
+	ADDRESS_DISTRICT:     "district",
+	ADDRESS_SUB_DISTRICT: "sub_district",
+	ADDRESS_VILLAGE:      "village",
+	ADDRESS_POSTAL_CODE:  "postal_code",
+}
+
+// urutan komponen address dari yang paling besar. dipakai sebagai AdminLevel boundary dari file geojson.
+var addressComponentLevels = map[string]int{
+	ADDRESS_COUNTRY:      2,
+	ADDRESS_PROVINCE:     4,
+	ADDRESS_DISTRICT:     6,
+	ADDRESS_SUB_DISTRICT: 8,
+	ADDRESS_VILLAGE:      10,
+}
+
+// LoadBoundaryProperties. load mapping property geojson dari file json. komponen yang tidak ada di file pakai DefaultBoundaryProperties.
+func LoadBoundaryProperties(path string) (BoundaryProperties, error) {
+	properties := make(BoundaryProperties, len(DefaultBoundaryProperties))
+	for component, property := range DefaultBoundaryProperties {
+		properties[component] = property
+	}
+	if path == "" {
+		return properties, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fileProperties BoundaryProperties
+	err = json.Unmarshal(data, &fileProperties)
+	if err != nil {
+		return nil, fmt.Errorf("invalid boundary properties %s: %w", path, err)
+	}
+	for component, property := range fileProperties {
+		if !addressComponents[component] && component != ADDRESS_POSTAL_CODE {
+			return nil, fmt.Errorf("invalid boundary properties %s: unknown address component %q", path, component)
+		}
+		properties[component] = property
+	}
+	return properties, nil
+}
+
+// loadRegionBoundaries. load boundary administratif dari file & insert ke regionRtree. file bisa berupa geojson FeatureCollection
+// (Polygon/MultiPolygon, bisa dengan hole) atau array json {province, district, sub_district, village, postal_code, border}.
+func loadRegionBoundaries(mapBoundaryFile string, properties BoundaryProperties, regionRtree *datastructure.Rtree) ([]Boundary, error) {
+	data, err := os.ReadFile(mapBoundaryFile)
+	if err != nil {
+		return []Boundary{}, err
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		if properties == nil {
+			properties = DefaultBoundaryProperties
+		}
+		return loadGeoJSONBoundaries(data, properties, regionRtree)
+	}
+	return loadBoundaryArray(data, regionRtree)
+}
+
+func loadBoundaryArray(data []byte, regionRtree *datastructure.Rtree) ([]Boundary, error) {
+	var indoRegionsBoundary []Boundary
+
+	err := json.Unmarshal(data, &indoRegionsBoundary)
+	if err != nil {
+		return []Boundary{}, err
+	}
+
+	for relID, village := range indoRegionsBoundary {
+		indoRegionsBoundary[relID].Province = capitalize(village.Province)
+		indoRegionsBoundary[relID].District = capitalize(village.District)
+		indoRegionsBoundary[relID].SubDistrict = capitalize(village.SubDistrict)
+		indoRegionsBoundary[relID].Village = capitalize(village.Village)
+
+		ring := make([][]float64, 0, len(village.Border))
+		for _, relway := range village.Border {
+			ring = append(ring, []float64{relway[1], relway[0]})
+		}
+
+		if len(ring) == 0 {
+			continue
+		}
+
+		polygon := &datastructure.Multipolygon{Outer: [][][]float64{ring}}
+		minLat, minLon, maxLat, maxLon := MultipolygonBound(polygon)
+		centerLat, centerLon := InteriorPoint(ring)
+
+		rtreeLeaf := datastructure.OSMObject{
+			ID:      relID,
+			Lat:     centerLat,
+			Lon:     centerLon,
+			Polygon: polygon,
+		}
+
+		// bound = [minLat, minLon, maxLat, maxLon]
+		bound := datastructure.NewRtreeBoundingBox(2, []float64{minLat, minLon}, []float64{maxLat, maxLon})
+
+		// insert r-tree per administrative level
+		regionRtree.InsertLeaf(bound, rtreeLeaf, false)
+	}
+	return indoRegionsBoundary, nil
+}
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string          `json:"type"`
+	Properties map[string]any  `json:"properties"`
+	Geometry   geoJSONGeometry `json:"geometry"`
+}
+
+type geoJSONGeometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// loadGeoJSONBoundaries. setiap feature Polygon/MultiPolygon jadi satu Boundary. ring pertama setiap polygon adalah ring outer,
+// sisanya hole. feature dengan geometry lain di-skip.
+func loadGeoJSONBoundaries(data []byte, properties BoundaryProperties, regionRtree *datastructure.Rtree) ([]Boundary, error) {
+	var collection geoJSONFeatureCollection
+	err := json.Unmarshal(data, &collection)
+	if err != nil {
+		return []Boundary{}, err
+	}
+	if collection.Type != "FeatureCollection" {
+		return []Boundary{}, fmt.Errorf("geojson boundary must be a FeatureCollection, got %q", collection.Type)
+	}
+
+	boundaries := []Boundary{}
+	for i, feature := range collection.Features {
+		polygon, err := feature.Geometry.multipolygon()
+		if err != nil {
+			return []Boundary{}, fmt.Errorf("invalid geometry of feature %d: %w", i, err)
+		}
+		if polygon == nil {
+			continue
+		}
+
+		boundary := Boundary{}
+		for component, property := range properties {
+			value := feature.property(property)
+			if value == "" {
+				continue
+			}
+			if component == ADDRESS_POSTAL_CODE {
+				boundary.PostalCode = value
+				continue
+			}
+			boundary.setComponent(component, value)
+			boundary.AdminLevel = max(boundary.AdminLevel, addressComponentLevels[component])
+		}
+
+		minLat, minLon, maxLat, maxLon := MultipolygonBound(polygon)
+		lat, lon := InteriorPoint(polygon.Outer[0])
+		regionRtree.InsertLeaf(datastructure.NewRtreeBoundingBox(2, []float64{minLat, minLon}, []float64{maxLat, maxLon}),
+			datastructure.OSMObject{
+				ID:      len(boundaries),
+				Lat:     lat,
+				Lon:     lon,
+				Polygon: polygon,
+			}, false)
+		boundaries = append(boundaries, boundary)
+	}
+	return boundaries, nil
+}
+
+func (f geoJSONFeature) property(name string) string {
+	value, ok := f.Properties[name]
+	if !ok || value == nil {
+		return ""
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+// multipolygon. konversi koordinat geojson [lon, lat] ke Multipolygon [lat, lon]. nil untuk geometry selain Polygon/MultiPolygon.
+func (g geoJSONGeometry) multipolygon() (*datastructure.Multipolygon, error) {
+	var polygons [][][][]float64
+	switch g.Type {
+	case "Polygon":
+		var rings [][][]float64
+		err := json.Unmarshal(g.Coordinates, &rings)
+		if err != nil {
+			return nil, err
+		}
+		polygons = [][][][]float64{rings}
+	case "MultiPolygon":
+		err := json.Unmarshal(g.Coordinates, &polygons)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, nil
+	}
+
+	multipolygon := &datastructure.Multipolygon{}
+	for _, rings := range polygons {
+		for i, ring := range rings {
+			latLons := make([][]float64, 0, len(ring))
+			for _, lonLat := range ring {
+				if len(lonLat) < 2 {
+					return nil, errors.New("position must have at least 2 elements")
+				}
+				latLons = append(latLons, []float64{lonLat[1], lonLat[0]})
+			}
+			if len(latLons) < 4 {
+				continue
+			}
+			if i == 0 {
+				multipolygon.Outer = append(multipolygon.Outer, latLons)
+			} else {
+				multipolygon.Inner = append(multipolygon.Inner, latLons)
+			}
+		}
+	}
+	if len(multipolygon.Outer) == 0 {
+		return nil, nil
+	}
+	return multipolygon, nil
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + strings.ToLower(s[1:])
+}
@@ -0,0 +1,101 @@
+package geo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/stretchr/testify/assert"
+)
+
+const testBoundaryGeoJSON = `{
+	"type": "FeatureCollection",
+	"features": [
+		{
+			"type": "Feature",
+			"properties": {"NAME_1": "Selangor", "NAME_2": "Petaling", "POSTCODE": 47301},
+			"geometry": {
+				"type": "Polygon",
+				"coordinates": [
+					[[101.5, 3.0], [101.7, 3.0], [101.7, 3.2], [101.5, 3.2], [101.5, 3.0]],
+					[[101.55, 3.05], [101.6, 3.05], [101.6, 3.1], [101.55, 3.1], [101.55, 3.05]]
+				]
+			}
+		},
+		{
+			"type": "Feature",
+			"properties": {"NAME_1": "Selangor", "NAME_2": null},
+			"geometry": {
+				"type": "MultiPolygon",
+				"coordinates": [
+					[[[101.0, 2.5], [102.0, 2.5], [102.0, 3.5], [101.0, 3.5], [101.0, 2.5]]],
+					[[[100.0, 4.0], [100.2, 4.0], [100.2, 4.2], [100.0, 4.2], [100.0, 4.0]]]
+				]
+			}
+		},
+		{
+			"type": "Feature",
+			"properties": {"NAME_1": "Titik"},
+			"geometry": {"type": "Point", "coordinates": [101.0, 3.0]}
+		}
+	]
+}`
+
+func TestLoadRegionBoundariesGeoJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "boundary.geojson")
+	assert.Nil(t, os.WriteFile(path, []byte(testBoundaryGeoJSON), 0600))
+
+	properties := BoundaryProperties{ADDRESS_PROVINCE: "NAME_1", ADDRESS_DISTRICT: "NAME_2", ADDRESS_POSTAL_CODE: "POSTCODE"}
+	regionRtree := datastructure.NewRtree(25, 50, 2)
+	boundaries, err := loadRegionBoundaries(path, properties, regionRtree)
+	assert.Nil(t, err)
+	assert.Equal(t, []Boundary{
+		{Province: "Selangor", District: "Petaling", PostalCode: "47301", AdminLevel: 6},
+		{Province: "Selangor", AdminLevel: 4},
+	}, boundaries)
+	assert.Equal(t, 2, regionRtree.Size)
+
+	leaves := map[int]*datastructure.Multipolygon{}
+	for _, leaf := range regionRtree.Search(datastructure.NewRtreeBoundingBox(2, []float64{-90, -180}, []float64{90, 180})) {
+		leaves[leaf.Leaf.ID] = leaf.Leaf.Polygon
+	}
+	petaling := leaves[0]
+	assert.Len(t, petaling.Outer, 1)
+	assert.Len(t, petaling.Inner, 1)
+	assert.Equal(t, []float64{3.0, 101.5}, petaling.Outer[0][0])
+	assert.True(t, PointInMultipolygon(3.15, 101.65, petaling))
+	assert.False(t, PointInMultipolygon(3.07, 101.57, petaling))
+
+	selangor := leaves[1]
+	assert.Len(t, selangor.Outer, 2)
+	assert.True(t, PointInMultipolygon(4.1, 100.1, selangor))
+}
+
+func TestLoadRegionBoundariesArray(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "region_boundary.json")
+	data := `[{"province": "DI YOGYAKARTA", "district": "SLEMAN", "sub_district": "DEPOK", "village": "CATURTUNGGAL",
+		"postal_code": "55281", "border": [[110.37, -7.79], [110.41, -7.79], [110.41, -7.76], [110.37, -7.76], [110.37, -7.79]]}]`
+	assert.Nil(t, os.WriteFile(path, []byte(data), 0600))
+
+	regionRtree := datastructure.NewRtree(25, 50, 2)
+	boundaries, err := loadRegionBoundaries(path, DefaultBoundaryProperties, regionRtree)
+	assert.Nil(t, err)
+	assert.Len(t, boundaries, 1)
+	assert.Equal(t, "Di yogyakarta", boundaries[0].Province)
+	assert.Equal(t, "Caturtunggal", boundaries[0].Village)
+	assert.Equal(t, 1, regionRtree.Size)
+}
+
+func TestLoadBoundaryProperties(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "boundary_properties.json")
+	assert.Nil(t, os.WriteFile(path, []byte(`{"province": "NAME_1"}`), 0600))
+	properties, err := LoadBoundaryProperties(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "NAME_1", properties[ADDRESS_PROVINCE])
+	assert.Equal(t, "district", properties[ADDRESS_DISTRICT])
+
+	assert.Nil(t, os.WriteFile(path, []byte(`{"kampung": "NAME_5"}`), 0600))
+	_, err = LoadBoundaryProperties(path)
+	assert.NotNil(t, err)
+}
@@ -3,6 +3,8 @@ package geo
 import (
 	"math"
 
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+
 	"github.com/golang/geo/s2"
 )
 
@@ -121,8 +123,24 @@ func windingNumber(pLat, pLon float64, polygonLat, polygonLon []float64) (wn int
 	return
 }
 
-func IsPointInPolygon(pLat, pLon float64, polygonLat, polygonLon []float64) bool {
-	return windingNumber(pLat, pLon, polygonLat, polygonLon) != 0
+// IsPointInPolygon. polygon terdiri dari satu atau lebih ring (ringsLat[i], ringsLon[i]), misal ring outer & hole atau
+// beberapa polygon multipolygon. pakai aturan even-odd: titik ada di dalam polygon kalau ada di dalam ring yang jumlahnya ganjil,
+// jadi titik di dalam hole (ring di dalam ring outer) tidak termasuk polygon.
+func IsPointInPolygon(pLat, pLon float64, ringsLat, ringsLon [][]float64) bool {
+	inside := false
+	for i := range ringsLat {
+		if isPointInRing(pLat, pLon, ringsLat[i], ringsLon[i]) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func isPointInRing(pLat, pLon float64, ringLat, ringLon []float64) bool {
+	if len(ringLat) == 0 {
+		return false
+	}
+	return windingNumber(pLat, pLon, ringLat, ringLon) != 0
 }
 
 // Given a start point, initial bearing, and distance, this will calculate the destina­tion point and final bearing travelling along a (shortest distance) great circle arc.
@@ -182,3 +200,21 @@ func ProjectPointToLineCoord(nearestStPoint Coordinate, secondNearestStPoint Coo
 	projectLatLng := s2.LatLngFromPoint(projection)
 	return NewCoordinate(projectLatLng.Lat.Degrees(), projectLatLng.Lng.Degrees())
 }
+
+// ProjectPointToPolyline. proyeksi titik (lat, lon) ke segment polyline ([lat, lon]) terdekat & jaraknya (km).
+// jarak = +Inf kalau polyline kosong.
+func ProjectPointToPolyline(polyline [][]float64, lat, lon float64) (Coordinate, float64) {
+	nearest, minDist := Coordinate{}, math.Inf(1)
+	for i := range polyline {
+		projection := NewCoordinate(polyline[i][0], polyline[i][1])
+		if i+1 < len(polyline) && (polyline[i][0] != polyline[i+1][0] || polyline[i][1] != polyline[i+1][1]) {
+			projection = ProjectPointToLineCoord(projection, NewCoordinate(polyline[i+1][0], polyline[i+1][1]),
+				NewCoordinate(lat, lon))
+		}
+		dist := datastructure.HaversineDistance(lat, lon, projection.Lat, projection.Lon)
+		if dist < minDist {
+			nearest, minDist = projection, dist
+		}
+	}
+	return nearest, minDist
+}
@@ -1,6 +1,7 @@
 package geo
 
 import (
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -44,3 +45,34 @@ func TestIsPointInsidePolygon(t *testing.T) {
 
 	
 }
+
+func TestIsPointInPolygonRings(t *testing.T) {
+	// ring outer (0,0)-(4,4) dengan hole (1,1)-(2,2), dan polygon kedua (10,10)-(11,11).
+	ringsLat := [][]float64{
+		{0, 0, 4, 4, 0},
+		{1, 1, 2, 2, 1},
+		{10, 10, 11, 11, 10},
+	}
+	ringsLon := [][]float64{
+		{0, 4, 4, 0, 0},
+		{1, 2, 2, 1, 1},
+		{10, 11, 11, 10, 10},
+	}
+
+	assert.True(t, IsPointInPolygon(3, 3, ringsLat, ringsLon))
+	assert.False(t, IsPointInPolygon(1.5, 1.5, ringsLat, ringsLon))
+	assert.True(t, IsPointInPolygon(10.5, 10.5, ringsLat, ringsLon))
+	assert.False(t, IsPointInPolygon(7, 7, ringsLat, ringsLon))
+	assert.False(t, IsPointInPolygon(3, 3, nil, nil))
+}
+
+func TestProjectPointToPolyline(t *testing.T) {
+	// jalan berbentuk huruf V terbalik, titik dekat puncaknya.
+	polyline := [][]float64{{0, 0}, {0.01, 0.01}, {0.01, 0.01}, {0, 0.02}}
+	projection, dist := ProjectPointToPolyline(polyline, 0.0099, 0.01)
+	assert.InDelta(t, 0.01, projection.Lon, 1e-4)
+	assert.Less(t, dist, 0.01)
+
+	_, dist = ProjectPointToPolyline(nil, 0, 0)
+	assert.True(t, math.IsInf(dist, 1))
+}
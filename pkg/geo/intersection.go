@@ -0,0 +1,239 @@
+package geo
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+)
+
+const INTERSECTIONS_FILE = "intersections.dat"
+
+// BuildIntersections. persimpangan jalan = osm node yang dipakai bersama oleh osm way jalan dengan nama berbeda (setelah NormalizeStreetName).
+// jalan tanpa nama tidak dihitung. pasangan (osm node, jalan) di-sort by node id di disk (file sementara di tmpDir), jadi yang disimpan
+// di memory hanya osm node yang dipakai 2+ jalan & nama jalannya. urutan hasil sesuai osm node id.
+func BuildIntersections(roads OSMObjectSource[Road], coords NodeCoordLookup, tmpDir string) ([]datastructure.Intersection, error) {
+	path := filepath.Join(tmpDir, INTERSECTIONS_FILE+".nodes")
+	writer, err := NewNodeCoordWriter(path)
+	if err != nil {
+		return nil, err
+	}
+	err = forEachRoad(roads, coords, func(r int, road Road, points [][]float64) error {
+		if road.Name == "" {
+			return nil
+		}
+		for _, nodeID := range road.NodeIDs {
+			err := writer.add(nodeCoord{id: nodeID, lat: int32(r)})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := OpenNodeCoordStore(path)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+	defer nodes.Close()
+
+	// osm node yang dipakai 2+ osm way jalan, beserta index jalannya (urut).
+	type sharedNode struct {
+		id    int64
+		roads []int32
+	}
+	shared := []sharedNode{}
+	names := make(map[int32]string)
+	for i := 0; i < nodes.Len(); {
+		j := i + 1
+		for j < nodes.Len() && nodes.record(j).id == nodes.record(i).id {
+			j++
+		}
+		if j-i >= 2 {
+			node := sharedNode{id: nodes.record(i).id, roads: make([]int32, 0, j-i)}
+			for k := i; k < j; k++ {
+				r := nodes.record(k).lat
+				node.roads = append(node.roads, r)
+				names[r] = ""
+			}
+			sort.Slice(node.roads, func(a, b int) bool { return node.roads[a] < node.roads[b] })
+			shared = append(shared, node)
+		}
+		i = j
+	}
+
+	err = forEachRoad(roads, coords, func(r int, road Road, points [][]float64) error {
+		if _, ok := names[int32(r)]; ok {
+			names[int32(r)] = road.Name
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	intersections := []datastructure.Intersection{}
+	for _, node := range shared {
+		streets := []string{}
+		for _, r := range node.roads {
+			duplicate := false
+			for _, street := range streets {
+				if NormalizeStreetName(street) == NormalizeStreetName(names[r]) {
+					duplicate = true
+					break
+				}
+			}
+			if !duplicate {
+				streets = append(streets, names[r])
+			}
+		}
+		if len(streets) < 2 {
+			continue
+		}
+		lat, lon, _ := coords.GetNode(node.id)
+		sort.Strings(streets)
+		intersections = append(intersections, datastructure.Intersection{Lat: lat, Lon: lon, Streets: streets})
+	}
+	return intersections, nil
+}
+
+// IntersectionIndex. r-tree titik persimpangan (id leaf = index di intersections) & index nama jalan -> persimpangan.
+type IntersectionIndex struct {
+	rtree         *datastructure.Rtree
+	intersections []datastructure.Intersection
+	byStreet      map[string][]int
+}
+
+func NewIntersectionIndex(intersections []datastructure.Intersection) *IntersectionIndex {
+	ii := &IntersectionIndex{rtree: datastructure.NewRtree(25, 50, 2), intersections: intersections,
+		byStreet: make(map[string][]int)}
+	for i, intersection := range intersections {
+		ii.rtree.InsertLeaf(datastructure.NewRtreeBoundingBox(2, []float64{intersection.Lat, intersection.Lon},
+			[]float64{intersection.Lat, intersection.Lon}), datastructure.OSMObject{ID: i, Lat: intersection.Lat,
+			Lon: intersection.Lon}, false)
+		for _, street := range intersection.Streets {
+			key := NormalizeStreetName(street)
+			ii.byStreet[key] = append(ii.byStreet[key], i)
+		}
+	}
+	return ii
+}
+
+func (ii *IntersectionIndex) Size() int {
+	if ii == nil {
+		return 0
+	}
+	return len(ii.intersections)
+}
+
+// Nearest. persimpangan terdekat dari titik (lat, lon) dalam radius (km) yang dilewati jalan street, beserta jaraknya.
+func (ii *IntersectionIndex) Nearest(lat, lon, radius float64, street string) (datastructure.Intersection, float64, bool) {
+	if ii.Size() == 0 || street == "" {
+		return datastructure.Intersection{}, 0, false
+	}
+	upRightLat, upRightLon := GetDestinationPoint(lat, lon, 45, radius*math.Sqrt2)
+	downLeftLat, downLeftLon := GetDestinationPoint(lat, lon, 225, radius*math.Sqrt2)
+	leaves := ii.rtree.Search(datastructure.NewRtreeBoundingBox(2, []float64{downLeftLat, downLeftLon},
+		[]float64{upRightLat, upRightLon}))
+
+	key := NormalizeStreetName(street)
+	nearest, minDist := -1, math.Inf(1)
+	for _, leaf := range leaves {
+		intersection := ii.intersections[leaf.Leaf.ID]
+		if !hasStreet(intersection, key) {
+			continue
+		}
+		dist := datastructure.HaversineDistance(lat, lon, intersection.Lat, intersection.Lon)
+		if dist <= radius && (dist < minDist || (dist == minDist && leaf.Leaf.ID < nearest)) {
+			nearest, minDist = leaf.Leaf.ID, dist
+		}
+	}
+	if nearest == -1 {
+		return datastructure.Intersection{}, 0, false
+	}
+	return ii.intersections[nearest], minDist, true
+}
+
+// Find. semua persimpangan jalan street1 & street2. nama jalan dibandingkan setelah NormalizeStreetName.
+func (ii *IntersectionIndex) Find(street1, street2 string) []datastructure.Intersection {
+	result := []datastructure.Intersection{}
+	if ii.Size() == 0 {
+		return result
+	}
+	key2 := NormalizeStreetName(street2)
+	for _, i := range ii.byStreet[NormalizeStreetName(street1)] {
+		if hasStreet(ii.intersections[i], key2) {
+			result = append(result, ii.intersections[i])
+		}
+	}
+	return result
+}
+
+// NormalizeStreetName. lowercase, spasi dirapikan & singkatan jl./jln. jadi jalan, supaya "Jl. Sudirman" = "jalan sudirman".
+func NormalizeStreetName(name string) string {
+	words := strings.Fields(strings.ToLower(name))
+	for i, word := range words {
+		switch word {
+		case "jl", "jl.", "jln", "jln.":
+			words[i] = "jalan"
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func hasStreet(intersection datastructure.Intersection, key string) bool {
+	for _, street := range intersection.Streets {
+		if NormalizeStreetName(street) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// CrossStreets. nama jalan lain (selain street) yang lewat persimpangan.
+func CrossStreets(intersection datastructure.Intersection, street string) []string {
+	key := NormalizeStreetName(street)
+	streets := []string{}
+	for _, s := range intersection.Streets {
+		if NormalizeStreetName(s) != key {
+			streets = append(streets, s)
+		}
+	}
+	return streets
+}
+
+// Save. simpan persimpangan ke file (gob), di-load server dengan LoadIntersectionIndex.
+func (ii *IntersectionIndex) Save(path string) error {
+	buf := new(bytes.Buffer)
+	err := gob.NewEncoder(buf).Encode(ii.intersections)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// LoadIntersectionIndex. load persimpangan hasil Save & bangun ulang r-tree & index nama jalannya.
+func LoadIntersectionIndex(path string) (*IntersectionIndex, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var intersections []datastructure.Intersection
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(&intersections)
+	if err != nil {
+		return nil, err
+	}
+	return NewIntersectionIndex(intersections), nil
+}
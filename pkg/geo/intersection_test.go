@@ -0,0 +1,60 @@
+package geo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntersectionIndex(t *testing.T) {
+	// Jalan Sudirman dipecah jadi dua osm way di node 2, Jalan Thamrin memotong di node 2 & Jl. Kebon Sirih di node 3.
+	roads := SliceSource[Road]{
+		{WayID: 1, Name: "Jalan Sudirman", NodeIDs: []int64{1, 2}},
+		{WayID: 2, Name: "Jalan Sudirman", NodeIDs: []int64{2, 3}},
+		{WayID: 3, Name: "Jalan Thamrin", NodeIDs: []int64{4, 2, 5}},
+		{WayID: 4, Name: "Jl. Kebon Sirih", NodeIDs: []int64{3, 6}},
+		{WayID: 5, Name: "", NodeIDs: []int64{1, 7}},
+		// osm way tertutup, node 8 dipakai dua kali oleh jalan yang sama
+		{WayID: 6, Name: "Bundaran HI", NodeIDs: []int64{8, 9, 10, 8}},
+	}
+	coords := mapCoordLookup{1: {-6.2, 106.82}, 2: {-6.19, 106.82}, 3: {-6.18, 106.82}, 4: {-6.19, 106.81}, 5: {-6.19, 106.83},
+		6: {-6.18, 106.83}, 7: {-6.2, 106.83}, 8: {-6.195, 106.823}, 9: {-6.194, 106.824}, 10: {-6.196, 106.824}}
+	tmpDir := t.TempDir()
+	intersections, err := BuildIntersections(roads, coords, tmpDir)
+	assert.Nil(t, err)
+	files, err := os.ReadDir(tmpDir)
+	assert.Nil(t, err)
+	assert.Empty(t, files)
+	assert.Equal(t, []datastructure.Intersection{
+		{Lat: -6.19, Lon: 106.82, Streets: []string{"Jalan Sudirman", "Jalan Thamrin"}},
+		{Lat: -6.18, Lon: 106.82, Streets: []string{"Jalan Sudirman", "Jl. Kebon Sirih"}},
+	}, intersections)
+
+	path := filepath.Join(t.TempDir(), INTERSECTIONS_FILE)
+	assert.Nil(t, NewIntersectionIndex(intersections).Save(path))
+	loaded, err := LoadIntersectionIndex(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, loaded.Size())
+
+	assert.Equal(t, intersections[1:], loaded.Find("jalan kebon sirih", "JL SUDIRMAN"))
+	assert.Empty(t, loaded.Find("Jalan Thamrin", "Jalan Kebon Sirih"))
+
+	intersection, dist, ok := loaded.Nearest(-6.181, 106.8201, 0.3, "Jalan Sudirman")
+	assert.True(t, ok)
+	assert.Equal(t, intersections[1], intersection)
+	assert.InDelta(t, 0.11, dist, 0.01)
+	assert.Equal(t, []string{"Jl. Kebon Sirih"}, CrossStreets(intersection, "Jalan Sudirman"))
+
+	// persimpangan Jalan Thamrin +-1 km
+	intersection, _, ok = loaded.Nearest(-6.181, 106.8201, 1.5, "Jalan Thamrin")
+	assert.True(t, ok)
+	assert.Equal(t, intersections[0], intersection)
+	_, _, ok = loaded.Nearest(-6.181, 106.8201, 0.3, "Jalan Thamrin")
+	assert.False(t, ok)
+
+	var empty *IntersectionIndex
+	assert.Empty(t, empty.Find("Jalan Sudirman", "Jalan Thamrin"))
+}
@@ -0,0 +1,338 @@
+package geo
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/blevesearch/mmap-go"
+)
+
+const (
+	NODE_COORDS_FILE = "node_coords.dat"
+
+	nodeCoordRecordSize = 16      // node id int64 + lat int32 + lon int32
+	nodeCoordChunkSize  = 1 << 22 // jumlah record per run file saat node tidak sorted (64MB)
+	coordPrecision      = 1e7     // presisi koordinat osm (7 digit desimal)
+)
+
+type nodeCoord struct {
+	id       int64
+	lat, lon int32
+}
+
+func newNodeCoord(id int64, lat, lon float64) nodeCoord {
+	return nodeCoord{
+		id:  id,
+		lat: int32(math.Round(lat * coordPrecision)),
+		lon: int32(math.Round(lon * coordPrecision)),
+	}
+}
+
+func (c nodeCoord) put(buf []byte) {
+	binary.LittleEndian.PutUint64(buf, uint64(c.id))
+	binary.LittleEndian.PutUint32(buf[8:], uint32(c.lat))
+	binary.LittleEndian.PutUint32(buf[12:], uint32(c.lon))
+}
+
+func readNodeCoord(buf []byte) nodeCoord {
+	return nodeCoord{
+		id:  int64(binary.LittleEndian.Uint64(buf)),
+		lat: int32(binary.LittleEndian.Uint32(buf[8:])),
+		lon: int32(binary.LittleEndian.Uint32(buf[12:])),
+	}
+}
+
+// NodeCoordWriter. tulis koordinat osm node ke file yang sorted by node id (record 16 byte).
+// node dari osm pbf biasanya sudah ascending, jadi langsung ditulis ke disk. kalau tidak ascending, node dibuffer per chunk,
+// chunk di-sort & ditulis sebagai run file, lalu semua run di-merge saat Close. memory yang dipakai maksimal satu chunk.
+type NodeCoordWriter struct {
+	path   string
+	runs   []string
+	chunk  []nodeCoord
+	direct *bufio.Writer // writer run pertama selama node masih ascending
+	file   *os.File
+	lastID int64
+	count  int
+
+	chunkSize int
+}
+
+func NewNodeCoordWriter(path string) (*NodeCoordWriter, error) {
+	runPath := path + ".run0"
+	file, err := os.OpenFile(runPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0700)
+	if err != nil {
+		return nil, err
+	}
+	return &NodeCoordWriter{
+		path:   path,
+		runs:   []string{runPath},
+		direct: bufio.NewWriterSize(file, 1<<20),
+		file:   file,
+		lastID: math.MinInt64,
+
+		chunkSize: nodeCoordChunkSize,
+	}, nil
+}
+
+// Add. tambah koordinat osm node.
+func (w *NodeCoordWriter) Add(id int64, lat, lon float64) error {
+	return w.add(newNodeCoord(id, lat, lon))
+}
+
+// add. tambah record 16 byte, di-sort by id. dipakai juga untuk record selain koordinat (misal grid index jaringan jalan).
+func (w *NodeCoordWriter) add(coord nodeCoord) error {
+	w.count++
+
+	if w.direct != nil {
+		if coord.id > w.lastID {
+			w.lastID = coord.id
+			var buf [nodeCoordRecordSize]byte
+			coord.put(buf[:])
+			_, err := w.direct.Write(buf[:])
+			return err
+		}
+		// node tidak ascending, run pertama selesai & sisanya di-sort per chunk.
+		err := w.closeDirect()
+		if err != nil {
+			return err
+		}
+		w.chunk = make([]nodeCoord, 0, w.chunkSize)
+	}
+
+	w.chunk = append(w.chunk, coord)
+	if len(w.chunk) == w.chunkSize {
+		return w.flushChunk()
+	}
+	return nil
+}
+
+func (w *NodeCoordWriter) closeDirect() error {
+	err := w.direct.Flush()
+	if err != nil {
+		return err
+	}
+	w.direct = nil
+	return w.file.Close()
+}
+
+func (w *NodeCoordWriter) flushChunk() error {
+	sort.Slice(w.chunk, func(i, j int) bool {
+		return w.chunk[i].id < w.chunk[j].id
+	})
+
+	runPath := w.path + ".run" + strconv.Itoa(len(w.runs))
+	file, err := os.OpenFile(runPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0700)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w.runs = append(w.runs, runPath)
+
+	bw := bufio.NewWriterSize(file, 1<<20)
+	var buf [nodeCoordRecordSize]byte
+	for _, coord := range w.chunk {
+		coord.put(buf[:])
+		_, err := bw.Write(buf[:])
+		if err != nil {
+			return err
+		}
+	}
+	w.chunk = w.chunk[:0]
+	return bw.Flush()
+}
+
+// Count. jumlah node yang sudah ditambahkan.
+func (w *NodeCoordWriter) Count() int {
+	return w.count
+}
+
+// Close. merge semua run file jadi satu file sorted di path.
+func (w *NodeCoordWriter) Close() error {
+	if w.direct != nil {
+		err := w.closeDirect()
+		if err != nil {
+			return err
+		}
+		return os.Rename(w.runs[0], w.path)
+	}
+
+	if len(w.chunk) > 0 {
+		err := w.flushChunk()
+		if err != nil {
+			return err
+		}
+	}
+	w.chunk = nil
+
+	err := mergeNodeCoordRuns(w.runs, w.path)
+	if err != nil {
+		return err
+	}
+	for _, run := range w.runs {
+		err := os.Remove(run)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type nodeCoordRun struct {
+	reader  *bufio.Reader
+	current nodeCoord
+}
+
+type nodeCoordRunHeap []*nodeCoordRun
+
+func (h nodeCoordRunHeap) Len() int           { return len(h) }
+func (h nodeCoordRunHeap) Less(i, j int) bool { return h[i].current.id < h[j].current.id }
+func (h nodeCoordRunHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *nodeCoordRunHeap) Push(x any)        { *h = append(*h, x.(*nodeCoordRun)) }
+func (h *nodeCoordRunHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+func (r *nodeCoordRun) next() (bool, error) {
+	var buf [nodeCoordRecordSize]byte
+	_, err := io.ReadFull(r.reader, buf[:])
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	r.current = readNodeCoord(buf[:])
+	return true, nil
+}
+
+// mergeNodeCoordRuns. k-way merge run file yang masing-masing sudah sorted.
+func mergeNodeCoordRuns(runs []string, path string) error {
+	out, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0700)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	bw := bufio.NewWriterSize(out, 1<<20)
+
+	h := make(nodeCoordRunHeap, 0, len(runs))
+	for _, runPath := range runs {
+		file, err := os.Open(runPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		run := &nodeCoordRun{reader: bufio.NewReaderSize(file, 1<<16)}
+		ok, err := run.next()
+		if err != nil {
+			return fmt.Errorf("error when reading %s: %w", runPath, err)
+		}
+		if ok {
+			h = append(h, run)
+		}
+	}
+	heap.Init(&h)
+
+	var buf [nodeCoordRecordSize]byte
+	for h.Len() > 0 {
+		run := h[0]
+		run.current.put(buf[:])
+		_, err := bw.Write(buf[:])
+		if err != nil {
+			return err
+		}
+
+		ok, err := run.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+		}
+	}
+	return bw.Flush()
+}
+
+// NodeCoordStore. lookup koordinat osm node dari file sorted hasil NodeCoordWriter. file di-mmap & dicari pakai binary search.
+type NodeCoordStore struct {
+	file *os.File
+	data mmap.MMap
+}
+
+func OpenNodeCoordStore(path string) (*NodeCoordStore, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if stat.Size()%nodeCoordRecordSize != 0 {
+		file.Close()
+		return nil, fmt.Errorf("invalid node coordinate file %s: size %d", path, stat.Size())
+	}
+	if stat.Size() == 0 {
+		return &NodeCoordStore{file: file}, nil
+	}
+
+	data, err := mmap.Map(file, mmap.RDONLY, 0)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error when mmap %s: %w", path, err)
+	}
+	return &NodeCoordStore{file: file, data: data}, nil
+}
+
+// Len. jumlah osm node di store.
+func (s *NodeCoordStore) Len() int {
+	return len(s.data) / nodeCoordRecordSize
+}
+
+// GetNode. return koordinat osm node. ok false kalau node tidak ada.
+func (s *NodeCoordStore) GetNode(id int64) (lat, lon float64, ok bool) {
+	i, ok := s.index(id)
+	if !ok {
+		return 0, 0, false
+	}
+	coord := s.record(i)
+	return float64(coord.lat) / coordPrecision, float64(coord.lon) / coordPrecision, true
+}
+
+// index. posisi record pertama dengan node id = id.
+func (s *NodeCoordStore) index(id int64) (int, bool) {
+	n := s.Len()
+	i := sort.Search(n, func(i int) bool {
+		return int64(binary.LittleEndian.Uint64(s.data[i*nodeCoordRecordSize:])) >= id
+	})
+	return i, i < n && s.record(i).id == id
+}
+
+func (s *NodeCoordStore) record(i int) nodeCoord {
+	return readNodeCoord(s.data[i*nodeCoordRecordSize:])
+}
+
+func (s *NodeCoordStore) Close() error {
+	if s.data != nil {
+		err := s.data.Unmap()
+		if err != nil {
+			return err
+		}
+		s.data = nil
+	}
+	return s.file.Close()
+}
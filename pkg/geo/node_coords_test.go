@@ -0,0 +1,89 @@
+package geo
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeCoordStore(t *testing.T) {
+	t.Run("ascending node ids", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), NODE_COORDS_FILE)
+		writer, err := NewNodeCoordWriter(path)
+		assert.Nil(t, err)
+		for id := int64(1); id <= 100; id++ {
+			assert.Nil(t, writer.Add(id*3, -7.0-float64(id)/1000, 110.0+float64(id)/1000))
+		}
+		assert.Nil(t, writer.Close())
+
+		store, err := OpenNodeCoordStore(path)
+		assert.Nil(t, err)
+		defer store.Close()
+
+		assert.Equal(t, 100, store.Len())
+		lat, lon, ok := store.GetNode(30)
+		assert.True(t, ok)
+		assert.InDelta(t, -7.01, lat, 1e-7)
+		assert.InDelta(t, 110.01, lon, 1e-7)
+
+		_, _, ok = store.GetNode(31)
+		assert.False(t, ok)
+		_, _, ok = store.GetNode(1000)
+		assert.False(t, ok)
+	})
+
+	t.Run("unsorted node ids are merged from sorted runs", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), NODE_COORDS_FILE)
+		writer, err := NewNodeCoordWriter(path)
+		assert.Nil(t, err)
+		writer.chunkSize = 4
+
+		ids := []int64{5, 9, 2, 15, 1, 12, 7, 3, 20, 11, 4, 18, -6}
+		for _, id := range ids {
+			assert.Nil(t, writer.Add(id, float64(id)/10, -float64(id)/10))
+		}
+		assert.Nil(t, writer.Close())
+		assert.NoFileExists(t, path+".run0")
+
+		store, err := OpenNodeCoordStore(path)
+		assert.Nil(t, err)
+		defer store.Close()
+
+		assert.Equal(t, len(ids), store.Len())
+		for _, id := range ids {
+			lat, lon, ok := store.GetNode(id)
+			assert.True(t, ok)
+			assert.InDelta(t, float64(id)/10, lat, 1e-7)
+			assert.InDelta(t, -float64(id)/10, lon, 1e-7)
+		}
+		_, _, ok := store.GetNode(6)
+		assert.False(t, ok)
+	})
+}
+
+func TestObjectSpool(t *testing.T) {
+	spool, err := NewObjectSpool[OSMWay](filepath.Join(t.TempDir(), OSM_WAYS_SPOOL_FILE))
+	assert.Nil(t, err)
+	for id := int64(0); id < 5; id++ {
+		err := spool.Append(NewOSMWay(id, []int64{id, id + 1}, map[string]string{"name": "Jalan Malioboro"}, false))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, spool.Finish())
+	assert.Equal(t, 5, spool.Len())
+
+	batchLens := []int{}
+	ids := []int64{}
+	for batch, err := range spool.Batches(2) {
+		assert.Nil(t, err)
+		batchLens = append(batchLens, len(batch))
+		for _, way := range batch {
+			ids = append(ids, way.ID)
+			assert.Equal(t, []int64{way.ID, way.ID + 1}, way.NodeIDs)
+			assert.Equal(t, "Jalan Malioboro", way.TagMap["name"])
+		}
+	}
+	assert.Equal(t, []int{2, 2, 1}, batchLens)
+	assert.Equal(t, []int64{0, 1, 2, 3, 4}, ids)
+	assert.Nil(t, spool.Remove())
+}
@@ -1,14 +1,13 @@
 package geo
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
+	"math"
 	"os"
-	"sort"
+	"path/filepath"
+	"strconv"
 
 	"github.com/lintang-b-s/osm-search/pkg"
 	"github.com/lintang-b-s/osm-search/pkg/datastructure"
@@ -17,247 +16,415 @@ import (
 	"github.com/paulmach/osm/osmpbf"
 )
 
-func ParseOSM(mapfile string, mapBoundaryFile string) ([]OSMWay, []OSMNode, NodeMapContainer, *pkg.IDMap, OSMSpatialIndex, []Boundary, error) {
-	var TagIDMap *pkg.IDMap = pkg.NewIDMap()
+const (
+	OSM_WAYS_SPOOL_FILE  = "osm_ways.spool"
+	OSM_NODES_SPOOL_FILE = "osm_nodes.spool"
 
+	OSM_RELATIONS_SPOOL_FILE = "osm_relations.spool"
+	OSM_ROADS_SPOOL_FILE     = "osm_roads.spool"
+)
+
+// ParsedOSM. hasil ParseOSM. spool & coords harus di-Remove/Close oleh caller.
+type ParsedOSM struct {
+	Ways            *ObjectSpool[OSMWay]
+	Nodes           *ObjectSpool[OSMNode]
+	Relations       *ObjectSpool[OSMRelation]
+	Coords          *NodeCoordStore
+	TagIDMap        *pkg.IDMap
+	SpatialIndex    OSMSpatialIndex
+	AdminBoundaries []Boundary
+}
+
+// ParseOSM. scan osm pbf sekali: koordinat semua osm node ditulis ke NODE_COORDS_FILE (sorted by node id, lookup via mmap),
+// osm way & osm node yang perlu di index ditulis ke spool file di outputDir. memory yang dipakai tidak tergantung ukuran file pbf
+// (kecuali r-tree jalan & boundary administratif).
+// osm relation multipolygon/boundary/route ditulis ke spool relation setelah ring-nya dirakit dari member way (scan kedua, hanya osm way).
+// boundary administratif untuk address di-load dari mapBoundaryFile, atau dari relation boundary=administrative kalau adminConfig.FromOSM.
+// file pbf harus sorted by type (semua node sebelum way & relation), seperti file dari planet/geofabrik.
+func ParseOSM(mapfile string, mapBoundaryFile string, outputDir string, adminConfig AdminBoundaryConfig) (ParsedOSM, error) {
 	streetRtree := datastructure.NewRtree(25, 50, 2)
 	regionRtree := datastructure.NewRtree(25, 50, 2)
 
-	count := 0
+	scanned, err := scanOSM(mapfile, outputDir, streetRtree, adminConfig.FromOSM)
+	if err != nil {
+		return ParsedOSM{}, err
+	}
 
-	ctr := NodeMapContainer{
-		nodeMap: make(map[int64]osm.Node),
+	// process poligon administrative boundary & rtree administrative boundary
+	var indoRegionsBoundary []Boundary
+	if adminConfig.FromOSM {
+		indoRegionsBoundary = BuildAdminBoundaries(scanned.adminRelations, adminConfig, regionRtree)
+		log.Printf("building administrative hierarchy done: %d administrative boundaries\n", len(indoRegionsBoundary))
+	} else {
+		indoRegionsBoundary, err = loadRegionBoundaries(mapBoundaryFile, adminConfig.Properties, regionRtree)
+		if err != nil {
+			return ParsedOSM{}, err
+		}
 	}
 
-	ways := []OSMWay{}
-	log.Printf("Parsing osm way objects...\n")
+	// jaringan jalan langsung ditulis ke outputDir, di-mmap server saat snap-to-road.
+	log.Printf("building road network of %d roads...\n", scanned.roads.Len())
+	err = BuildRoadNetwork(scanned.roads, scanned.coords, filepath.Join(outputDir, ROAD_NETWORK_FILE))
+	if err != nil {
+		return ParsedOSM{}, fmt.Errorf("error when building road network: %w", err)
+	}
+	intersections, err := BuildIntersections(scanned.roads, scanned.coords, outputDir)
+	if err != nil {
+		return ParsedOSM{}, fmt.Errorf("error when building intersections: %w", err)
+	}
+	err = scanned.roads.Remove()
+	if err != nil {
+		return ParsedOSM{}, err
+	}
+
+	spatialIndex := OSMSpatialIndex{
+		StreetRtree:                 streetRtree,
+		StreetNames:                 scanned.streetNames,
+		AdministrativeBoundaryRtree: regionRtree,
+		Intersections:               NewIntersectionIndex(intersections),
+	}
 
-	regionBoundaries := make([]Boundary, 0)
+	fmt.Printf("\n")
+	log.Printf("processing osm relation & way objects done \n")
 
-	onlyOsmNodes := []OSMNode{}
+	return ParsedOSM{
+		Ways:            scanned.ways,
+		Nodes:           scanned.nodes,
+		Relations:       scanned.relations,
+		Coords:          scanned.coords,
+		TagIDMap:        pkg.NewIDMap(),
+		SpatialIndex:    spatialIndex,
+		AdminBoundaries: indoRegionsBoundary,
+	}, nil
+}
 
-	// process osm ways
-	wayNodesMap := make(map[int64]bool)
+// scannedOSM. hasil scanOSM.
+type scannedOSM struct {
+	ways           *ObjectSpool[OSMWay]
+	nodes          *ObjectSpool[OSMNode]
+	relations      *ObjectSpool[OSMRelation]
+	adminRelations []OSMRelation // relation boundary=administrative, hanya kalau keepAdmin
+	coords         *NodeCoordStore
+	streetNames    []string           // id leaf streetRtree -> nama jalan
+	roads          *ObjectSpool[Road] // osm way jalan untuk jaringan jalan & persimpangan
+}
 
-	fWay, err := os.Open(mapfile)
+// scanOSM. satu kali scan file pbf. jalan (highway) langsung di-insert ke streetRtree, dengan id leaf = index di streetNames.
+// osm relation disimpan di memory (hanya id member way) sampai geometry-nya dirakit di buildRelations.
+// kalau keepAdmin, relation boundary=administrative juga di-return untuk membuat hierarki administratif.
+// osm way jalan juga ditulis ke spool untuk jaringan jalan (snap-to-road) & persimpangan.
+func scanOSM(mapfile, outputDir string, streetRtree *datastructure.Rtree, keepAdmin bool) (scannedOSM, error) {
+	f, err := os.Open(mapfile)
 	if err != nil {
-		return []OSMWay{}, []OSMNode{}, NodeMapContainer{}, &pkg.IDMap{}, OSMSpatialIndex{}, regionBoundaries, err
+		return scannedOSM{}, err
 	}
-	defer fWay.Close()
+	defer f.Close()
 
-	scannerWay := osmpbf.New(context.Background(), fWay, 1)
+	coordWriter, err := NewNodeCoordWriter(filepath.Join(outputDir, NODE_COORDS_FILE))
+	if err != nil {
+		return scannedOSM{}, err
+	}
+	ways, err := NewObjectSpool[OSMWay](filepath.Join(outputDir, OSM_WAYS_SPOOL_FILE))
+	if err != nil {
+		return scannedOSM{}, err
+	}
+	nodes, err := NewObjectSpool[OSMNode](filepath.Join(outputDir, OSM_NODES_SPOOL_FILE))
+	if err != nil {
+		return scannedOSM{}, err
+	}
+	roads, err := NewObjectSpool[Road](filepath.Join(outputDir, OSM_ROADS_SPOOL_FILE))
+	if err != nil {
+		return scannedOSM{}, err
+	}
 
-	fmt.Printf("\n")
-	log.Printf("Parsing osm way objects...\n")
-	for scannerWay.Scan() {
-		o := scannerWay.Object()
-		tipe := o.ObjectID().Type()
-		switch tipe {
-		case osm.TypeNode:
-			{
-				node := o.(*osm.Node)
+	var coords *NodeCoordStore
+	streetNames := []string{}
+	relations := []OSMRelation{}
 
-				name, _, _, _, _ := GetNameAddressTypeFromOSMWay(node.TagMap())
-				if name == "" {
-					continue
-				}
-				if checkIsNodeAlowed(node.TagMap()) {
-					lat := node.Lat
-					lon := node.Lon
-					tag := node.TagMap()
+	// finishNodes. semua osm node sudah di scan, tulis koordinat & buka store untuk lookup koordinat way.
+	finishNodes := func() error {
+		if coords != nil {
+			return nil
+		}
+		log.Printf("writing coordinates of %d osm nodes...\n", coordWriter.Count())
+		err := coordWriter.Close()
+		if err != nil {
+			return err
+		}
+		coords, err = OpenNodeCoordStore(filepath.Join(outputDir, NODE_COORDS_FILE))
+		return err
+	}
+
+	scanner := osmpbf.New(context.Background(), f, 1)
+	defer scanner.Close()
 
-					containWikiData := containWikiData(o.(*osm.Node).Tags)
+	fmt.Printf("\n")
+	log.Printf("Parsing osm objects...\n")
+	for scanner.Scan() {
+		o := scanner.Object()
+		switch o.ObjectID().Type() {
+		case osm.TypeNode:
+			node := o.(*osm.Node)
+			if coords != nil {
+				return scannedOSM{}, fmt.Errorf("osm node %d found after osm ways: %s must be sorted by type", node.ID, mapfile)
+			}
+			err := coordWriter.Add(int64(node.ID), node.Lat, node.Lon)
+			if err != nil {
+				return scannedOSM{}, err
+			}
 
-					onlyOsmNodes = append(onlyOsmNodes, NewOSMNode(int64(o.(*osm.Node).ID), lat, lon, tag, containWikiData))
+			searchNode, ok := NewSearchOSMNode(node)
+			if ok {
+				err := nodes.Append(searchNode)
+				if err != nil {
+					return scannedOSM{}, err
 				}
 			}
 		case osm.TypeWay:
-			{
-
-				tag := o.(*osm.Way).TagMap()
+			err := finishNodes()
+			if err != nil {
+				return scannedOSM{}, err
+			}
 
-				name, _, _, _, _ := GetNameAddressTypeFromOSMWay(tag)
-				if _, ok := tag["highway"]; !ok && name == "" {
-					continue
-				}
+			way, ok := NewSearchOSMWay(o.(*osm.Way))
+			if !ok {
+				continue
+			}
+			err = ways.Append(way)
+			if err != nil {
+				return scannedOSM{}, err
+			}
 
-				if !checkIsWayAlowed(tag) {
-					continue
-				}
+			if ways.Len()%100000 == 0 {
+				log.Printf("parsing osm ways: %d ...\n", ways.Len())
+			}
 
-				nodeIDs := []int64{}
-				for _, node := range o.(*osm.Way).Nodes {
-					wayNodesMap[int64(node.ID)] = true
-					nodeIDs = append(nodeIDs, int64(node.ID))
+			streetNames = InsertStreet(streetRtree, streetNames, way, coords)
+			if road, ok := newRoad(way, coords); ok {
+				err := roads.Append(road)
+				if err != nil {
+					return scannedOSM{}, err
 				}
+			}
+		case osm.TypeRelation:
+			err := finishNodes()
+			if err != nil {
+				return scannedOSM{}, err
+			}
 
-				containWikiData := containWikiData(o.(*osm.Way).Tags)
-
-				way := NewOSMWay(int64(o.(*osm.Way).ID), nodeIDs, tag, containWikiData)
-				ways = append(ways, way)
-
-				count++
+			relation, ok := NewSearchOSMRelation(o.(*osm.Relation))
+			if ok {
+				relations = append(relations, relation)
 			}
 		}
-
 	}
 
-	scanErr := scannerWay.Err()
-	if scanErr != nil {
-		return []OSMWay{}, []OSMNode{}, NodeMapContainer{}, &pkg.IDMap{}, OSMSpatialIndex{}, regionBoundaries, err
-	}
-	scannerWay.Close()
-
-	_, err = fWay.Seek(0, io.SeekStart)
+	err = scanner.Err()
 	if err != nil {
-		return []OSMWay{}, []OSMNode{}, NodeMapContainer{}, &pkg.IDMap{}, OSMSpatialIndex{}, regionBoundaries, err
+		return scannedOSM{}, err
 	}
 
-	scannerWay = osmpbf.New(context.Background(), fWay, 1)
-	defer scannerWay.Close()
-
-	for scannerWay.Scan() {
-		o := scannerWay.Object()
-		tipe := o.ObjectID().Type()
-		switch tipe {
-		case osm.TypeNode:
-			{
-				node := o.(*osm.Node)
-				if _, ok := wayNodesMap[int64(node.ID)]; ok {
-					ctr.nodeMap[int64(node.ID)] = *node
-				}
-			}
-		}
+	// file pbf tanpa osm way & relation.
+	err = finishNodes()
+	if err != nil {
+		return scannedOSM{}, err
 	}
 
-	scanErr = scannerWay.Err()
-	if scanErr != nil {
-		return []OSMWay{}, []OSMNode{}, NodeMapContainer{}, &pkg.IDMap{}, OSMSpatialIndex{}, regionBoundaries, err
+	err = ways.Finish()
+	if err != nil {
+		return scannedOSM{}, err
 	}
-
-	log.Printf("Parsing osm way objects done\n")
-
-	// process poligon administrative boundary & rtree administrative boundary
-	indoBoundaryFile, err := os.Open(mapBoundaryFile)
+	err = nodes.Finish()
 	if err != nil {
-		return []OSMWay{}, []OSMNode{}, NodeMapContainer{}, &pkg.IDMap{}, OSMSpatialIndex{}, regionBoundaries, err
+		return scannedOSM{}, err
 	}
-
-	defer indoBoundaryFile.Close()
-
-	var indoRegionsBoundary []Boundary
-
-	indoBoundaryFileStat, err := indoBoundaryFile.Stat()
+	err = roads.Finish()
 	if err != nil {
-		return []OSMWay{}, []OSMNode{}, NodeMapContainer{}, &pkg.IDMap{}, OSMSpatialIndex{}, regionBoundaries, err
+		return scannedOSM{}, err
 	}
 
-	buffer := bytes.NewBuffer(make([]byte, indoBoundaryFileStat.Size()))
-	_, err = indoBoundaryFile.Read(buffer.Bytes())
+	relationSpool, adminRelations, err := buildRelations(mapfile, outputDir, relations, coords, keepAdmin)
 	if err != nil {
-		return []OSMWay{}, []OSMNode{}, NodeMapContainer{}, &pkg.IDMap{}, OSMSpatialIndex{}, regionBoundaries, err
+		return scannedOSM{}, err
 	}
 
-	err = json.Unmarshal(buffer.Bytes(), &indoRegionsBoundary)
+	log.Printf("Parsing osm objects done: %d osm ways, %d osm nodes, %d osm relations\n", ways.Len(), nodes.Len(), relationSpool.Len())
+	return scannedOSM{
+		ways:           ways,
+		nodes:          nodes,
+		relations:      relationSpool,
+		adminRelations: adminRelations,
+		coords:         coords,
+		streetNames:    streetNames,
+		roads:          roads,
+	}, nil
+}
+
+// buildRelations. rakit geometry osm relation dari member way & tulis relation yang valid ke spool.
+// kalau keepAdmin, relation boundary=administrative yang valid juga di-return.
+func buildRelations(mapfile, outputDir string, relations []OSMRelation, coords NodeCoordLookup,
+	keepAdmin bool) (*ObjectSpool[OSMRelation], []OSMRelation, error) {
+	relationSpool, err := NewObjectSpool[OSMRelation](filepath.Join(outputDir, OSM_RELATIONS_SPOOL_FILE))
 	if err != nil {
-		return []OSMWay{}, []OSMNode{}, NodeMapContainer{}, &pkg.IDMap{}, OSMSpatialIndex{}, regionBoundaries, err
+		return nil, nil, err
 	}
+	adminRelations := []OSMRelation{}
 
-	for relID, village := range indoRegionsBoundary {
-		regionBoundaries = append(regionBoundaries, NewBoundary(
-			village.Province, village.District, village.SubDistrict, village.Village,
-			village.PostalCode, village.Border,
-		))
+	if len(relations) > 0 {
+		memberWays := make(map[int64]struct{})
+		for _, relation := range relations {
+			for _, member := range relation.Members {
+				memberWays[member.WayID] = struct{}{}
+			}
+		}
 
-		boundaryLat, boundaryLon := []float64{}, []float64{}
-		for _, relway := range village.Border {
-			boundaryLat = append(boundaryLat, relway[1])
-			boundaryLon = append(boundaryLon, relway[0])
+		log.Printf("collecting member ways of %d osm relations...\n", len(relations))
+		wayNodes, err := collectMemberWays(mapfile, memberWays)
+		if err != nil {
+			return nil, nil, err
 		}
 
-		if len(boundaryLat) == 0 || len(boundaryLon) == 0 {
-			continue
+		for i := range relations {
+			if !relations[i].BuildGeometry(wayNodes, coords) {
+				continue
+			}
+			err := relationSpool.Append(relations[i])
+			if err != nil {
+				return nil, nil, err
+			}
+			if keepAdmin && relations[i].Type == RELATION_BOUNDARY && relations[i].TagMap["boundary"] == "administrative" {
+				adminRelations = append(adminRelations, relations[i])
+			}
+			relations[i] = OSMRelation{}
 		}
+	}
 
-		sortedBoundaryLat, sortedBoundaryLon := make([]float64, len(boundaryLat)), make([]float64, len(boundaryLon))
+	return relationSpool, adminRelations, relationSpool.Finish()
+}
 
-		copy(sortedBoundaryLat, boundaryLat)
-		copy(sortedBoundaryLon, boundaryLon)
+var streetHighways = map[string]bool{
+	"motorway":       true,
+	"trunk":          true,
+	"primary":        true,
+	"secondary":      true,
+	"tertiary":       true,
+	"unclassified":   true,
+	"residential":    true,
+	"living_street":  true,
+	"service":        true,
+	"motorway_link":  true,
+	"trunk_link":     true,
+	"primary_link":   true,
+	"secondary_link": true,
+	"tertiary_link":  true,
+}
 
-		sort.Float64s(sortedBoundaryLat)
-		sort.Float64s(sortedBoundaryLon)
-		centerLat, centerLon := sortedBoundaryLat[len(sortedBoundaryLat)/2], sortedBoundaryLon[len(sortedBoundaryLon)/2]
+// InsertStreet. insert osm way jalan ke r-tree jalan. buat menentukan nama jalan dari osm way kalau di tag "addr:street" gak ada.
+// leaf menyimpan semua titik jalan & bound-nya bounding box seluruh jalan, jadi jalan panjang/melengkung tetap ketemu & jarak
+// dihitung ke setiap segment.
+func InsertStreet(streetRtree *datastructure.Rtree, streetNames []string, way OSMWay, coords NodeCoordLookup) []string {
+	highway, ok := way.TagMap["highway"]
+	if !ok || !streetHighways[highway] || len(way.NodeIDs) == 0 {
+		return streetNames
+	}
 
-		rtreeLeaf := datastructure.OSMObject{
-			ID:       relID,
-			Lat:      centerLat,
-			Lon:      centerLon,
-			OsmBound: [2][]float64{boundaryLat, boundaryLon},
+	points := make([][]float64, 0, len(way.NodeIDs))
+	minLat, minLon, maxLat, maxLon := math.MaxFloat64, math.MaxFloat64, -math.MaxFloat64, -math.MaxFloat64
+	for _, nodeID := range way.NodeIDs {
+		nodeLat, nodeLon, ok := coords.GetNode(nodeID)
+		if !ok {
+			return streetNames
 		}
+		points = append(points, []float64{nodeLat, nodeLon})
+		minLat, maxLat = math.Min(minLat, nodeLat), math.Max(maxLat, nodeLat)
+		minLon, maxLon = math.Min(minLon, nodeLon), math.Max(maxLon, nodeLon)
+	}
 
-		// // bound = [minLat, minLon, maxLat, maxLon]
-		bound := datastructure.NewRtreeBoundingBox(2, []float64{sortedBoundaryLat[0], sortedBoundaryLon[0]},
-			[]float64{sortedBoundaryLat[len(sortedBoundaryLat)-1], sortedBoundaryLon[len(sortedBoundaryLon)-1]})
+	midLat, midLon := MidPoint(minLat, minLon, maxLat, maxLon)
 
-		// insert r-tree per administrative level
-		regionRtree.InsertLeaf(bound, rtreeLeaf, false)
+	name, _, _, _, _ := GetNameAddressTypeFromOSMWay(way.TagMap)
+	rtreeLeaf := datastructure.OSMObject{
+		ID:              len(streetNames),
+		Lat:             midLat,
+		Lon:             midLon,
+		BoundaryLatLons: points,
+		Tag:             map[int]int{ROAD_PRIORITY_KEY: roadTypeMaxSpeed[highway]},
 	}
 
-	// process osm streets & rtree streets. buat menentukan nama jalan dari osm way kalau di tag "addr:street" gak ada.
-	for idx, way := range ways {
-		lat, lon := []float64{}, []float64{}
-		latLons := [][]float64{}
-		for _, nodeID := range way.NodeIDs {
-			node := ctr.GetNode(nodeID)
-			lat = append(lat, node.Lat)
-			lon = append(lon, node.Lon)
-			latLons = append(latLons, []float64{node.Lat, node.Lon})
-		}
-		sort.Float64s(lat)
-		sort.Float64s(lon)
-
-		midLat, midLon := MidPoint(lat[0], lon[0], lat[len(lat)-1], lon[len(lon)-1])
+	bound := datastructure.NewRtreeBoundingBox(2, []float64{minLat, minLon}, []float64{maxLat, maxLon})
+	streetRtree.InsertLeaf(bound, rtreeLeaf, false)
+	return append(streetNames, name)
+}
 
-		rtreeLeaf := datastructure.OSMObject{
-			ID:              idx,
-			Lat:             midLat,
-			Lon:             midLon,
-			BoundaryLatLons: latLons,
+// newRoad. osm way jalan untuk jaringan jalan. false kalau bukan jalan atau ada osm node yang tidak ditemukan.
+func newRoad(way OSMWay, coords NodeCoordLookup) (Road, bool) {
+	highway, ok := way.TagMap["highway"]
+	if !ok || !streetHighways[highway] || len(way.NodeIDs) < 2 {
+		return Road{}, false
+	}
+	for _, nodeID := range way.NodeIDs {
+		if _, _, ok := coords.GetNode(nodeID); !ok {
+			return Road{}, false
 		}
+	}
+	name, _, _, _, _ := GetNameAddressTypeFromOSMWay(way.TagMap)
+	return Road{WayID: way.ID, Name: name, Class: highway, NodeIDs: way.NodeIDs}, true
+}
 
-		highway, ok := way.TagMap["highway"]
-		if ok && (highway == "motorway" ||
-			highway == "trunk" ||
-			highway == "primary" ||
-			highway == "secondary" ||
-			highway == "tertiary" ||
-			highway == "unclassified" ||
-			highway == "residential" ||
-			highway == "living_street" ||
-			highway == "service" ||
-			highway == "motorway_link" ||
-			highway == "trunk_link" ||
-			highway == "primary_link" ||
-			highway == "secondary_link" ||
-			highway == "tertiary_link") {
-			bound := datastructure.NewRtreeBoundingBox(2, []float64{rtreeLeaf.Lat - 0.0001,
-				rtreeLeaf.Lon - 0.0001}, []float64{rtreeLeaf.Lat + 0.0001, rtreeLeaf.Lon + 0.0001})
-			rtreeLeaf.Tag = make(map[int]int)
-			rtreeLeaf.Tag[ROAD_PRIORITY_KEY] = roadTypeMaxSpeed[highway]
-			streetRtree.InsertLeaf(bound, rtreeLeaf, false)
-		}
+// NewSearchOSMNode. return OSMNode kalau osm node ini perlu di index (punya name & tag yang valid).
+func NewSearchOSMNode(node *osm.Node) (OSMNode, bool) {
+	tag := node.TagMap()
+	name, _, _, _, _ := GetNameAddressTypeFromOSMWay(tag)
+	if name == "" || !checkIsNodeAlowed(tag) {
+		return OSMNode{}, false
 	}
+	return NewOSMNode(int64(node.ID), node.Lat, node.Lon, tag, containWikiData(node.Tags)), true
+}
 
-	// update adress dari osm ways dan osm nodes
-	spatialIndex := OSMSpatialIndex{
-		StreetRtree:                 streetRtree,
-		AdministrativeBoundaryRtree: regionRtree,
+// NewSearchOSMWay. return OSMWay kalau osm way ini perlu di index (punya name/highway & tag yang valid).
+func NewSearchOSMWay(way *osm.Way) (OSMWay, bool) {
+	tag := way.TagMap()
+	name, _, _, _, _ := GetNameAddressTypeFromOSMWay(tag)
+	if _, ok := tag["highway"]; !ok && name == "" {
+		return OSMWay{}, false
+	}
+	if !checkIsWayAlowed(tag) {
+		return OSMWay{}, false
 	}
 
-	fmt.Printf("\n")
-	log.Printf("processing osm relation & way objects done \n")
+	nodeIDs := make([]int64, 0, len(way.Nodes))
+	for _, node := range way.Nodes {
+		nodeIDs = append(nodeIDs, int64(node.ID))
+	}
+	return NewOSMWay(int64(way.ID), nodeIDs, tag, containWikiData(way.Tags)), true
+}
 
-	return ways, onlyOsmNodes, ctr, TagIDMap, spatialIndex, indoRegionsBoundary, nil
+// OSMKey. key unik osm object, misal "node/123" atau "way/456".
+func OSMKey(tipe osm.Type, id int64) string {
+	return string(tipe) + "/" + strconv.FormatInt(id, 10)
+}
+
+// ParseOSMID. konversi osm id dengan prefix tipe (N123, W456, R789, format nominatim) ke OSMKey.
+func ParseOSMID(osmID string) (string, error) {
+	if len(osmID) < 2 {
+		return "", fmt.Errorf("invalid osm id %q", osmID)
+	}
+	var tipe osm.Type
+	switch osmID[0] {
+	case 'N', 'n':
+		tipe = osm.TypeNode
+	case 'W', 'w':
+		tipe = osm.TypeWay
+	case 'R', 'r':
+		tipe = osm.TypeRelation
+	default:
+		return "", fmt.Errorf("invalid osm id %q: must start with N, W or R", osmID)
+	}
+	id, err := strconv.ParseInt(osmID[1:], 10, 64)
+	if err != nil || id <= 0 {
+		return "", fmt.Errorf("invalid osm id %q", osmID)
+	}
+	return OSMKey(tipe, id), nil
 }
 
 func containWikiData(tags osm.Tags) bool {
@@ -284,76 +451,15 @@ func GetNameAddressTypeFromOSMWay(tag map[string]string) (string, string, string
 	return name, street, tipe, postalCode, houseNumber
 }
 
+// GetOSMObjectType. tipe osm object dari IndexRules.TypeKeys.
 func GetOSMObjectType(tag map[string]string) string {
-	tipe, ok := tag["amenity"]
-	if ok {
-		return tipe
-	}
-	tipe, ok = tag["highway"]
-	if ok {
-		return tipe
-	}
-	// building tidak include (karena cuma yes/no)
-	tipe, ok = tag["historic"]
-	if ok {
-		return tipe
-	}
-	tipe, ok = tag["sport"]
-	if ok {
-		return tipe
-	}
-	tipe, ok = tag["tourism"]
-	if ok {
-		return tipe
-	}
-	tipe, ok = tag["leisure"]
-	if ok {
-		return tipe
-	}
-	tipe, ok = tag["landuse"]
-	if ok {
-		return tipe
-	}
-	tipe, ok = tag["craft"]
-	if ok {
-		return tipe
-	}
-	tipe, ok = tag["aeroway"]
-	if ok {
-		return tipe
-	}
-	tipe, ok = tag["residential"]
-	if ok {
-		return tipe
-	}
-
-	tipe, ok = tag["industrial"]
-	if ok {
-		return tipe
-	}
-	tipe, ok = tag["shop"]
-	if ok {
-		return tipe
-	}
-	return ""
+	return indexRules.ObjectType(tag)
 }
 
 func checkIsWayAlowed(tag map[string]string) bool {
-	for k, _ := range tag {
-
-		if ValidSearchTags[k] {
-			return true
-		}
-
-	}
-	return false
+	return indexRules.Ways.Match(tag)
 }
 
 func checkIsNodeAlowed(tag map[string]string) bool {
-	for k, _ := range tag {
-		if ValidNodeSearchTag[k] {
-			return true
-		}
-	}
-	return false
+	return indexRules.Nodes.Match(tag)
 }
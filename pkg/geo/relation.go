@@ -0,0 +1,361 @@
+package geo
+
+import (
+	"context"
+	"math"
+	"os"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+
+	"github.com/paulmach/osm"
+	"github.com/paulmach/osm/osmpbf"
+)
+
+const (
+	RELATION_MULTIPOLYGON = "multipolygon"
+	RELATION_BOUNDARY     = "boundary"
+	RELATION_ROUTE        = "route"
+
+	ROLE_OUTER = "outer"
+	ROLE_INNER = "inner"
+)
+
+type OSMRelationMember struct {
+	WayID int64
+	Role  string
+}
+
+// OSMRelation. osm relation multipolygon/boundary/route yang di index. OuterRings & InnerRings diisi setelah ring dirakit dari member way.
+type OSMRelation struct {
+	ID              int64
+	Type            string
+	Members         []OSMRelationMember
+	TagMap          map[string]string
+	ContainWikidata bool
+	OuterRings      [][][]float64 // ring tertutup, titik = [lat, lon]
+	InnerRings      [][][]float64
+	RouteLatLons    [][]float64 // koordinat semua member way untuk route relation
+}
+
+// NewSearchOSMRelation. return OSMRelation kalau osm relation ini perlu di index (multipolygon/boundary/route yang punya name).
+func NewSearchOSMRelation(relation *osm.Relation) (OSMRelation, bool) {
+	tag := relation.TagMap()
+	relationType := tag["type"]
+	name, _, _, _, _ := GetNameAddressTypeFromOSMWay(tag)
+	if name == "" {
+		return OSMRelation{}, false
+	}
+
+	switch relationType {
+	case RELATION_MULTIPOLYGON:
+		if !checkIsWayAlowed(tag) {
+			return OSMRelation{}, false
+		}
+	case RELATION_BOUNDARY:
+		if tag["boundary"] == "" {
+			return OSMRelation{}, false
+		}
+	case RELATION_ROUTE:
+	default:
+		return OSMRelation{}, false
+	}
+
+	members := make([]OSMRelationMember, 0, len(relation.Members))
+	for _, member := range relation.Members {
+		if member.Type != osm.TypeWay {
+			continue
+		}
+		if relationType != RELATION_ROUTE && member.Role != ROLE_OUTER && member.Role != ROLE_INNER && member.Role != "" {
+			continue
+		}
+		members = append(members, OSMRelationMember{WayID: member.Ref, Role: member.Role})
+	}
+	if len(members) == 0 {
+		return OSMRelation{}, false
+	}
+
+	return OSMRelation{
+		ID:              int64(relation.ID),
+		Type:            relationType,
+		Members:         members,
+		TagMap:          tag,
+		ContainWikidata: containWikiData(relation.Tags),
+	}, true
+}
+
+// BuildGeometry. rakit ring outer & inner dari node member way. return false kalau tidak ada ring outer yang tertutup
+// (atau, untuk route, tidak ada koordinat member way).
+func (r *OSMRelation) BuildGeometry(wayNodes map[int64][]int64, coords NodeCoordLookup) bool {
+	if r.Type == RELATION_ROUTE {
+		for _, member := range r.Members {
+			for _, nodeID := range wayNodes[member.WayID] {
+				lat, lon, ok := coords.GetNode(nodeID)
+				if ok {
+					r.RouteLatLons = append(r.RouteLatLons, []float64{lat, lon})
+				}
+			}
+		}
+		return len(r.RouteLatLons) > 0
+	}
+
+	outerWays, innerWays := [][]int64{}, [][]int64{}
+	for _, member := range r.Members {
+		nodes, ok := wayNodes[member.WayID]
+		if !ok || len(nodes) < 2 {
+			continue
+		}
+		if member.Role == ROLE_INNER {
+			innerWays = append(innerWays, nodes)
+		} else {
+			outerWays = append(outerWays, nodes)
+		}
+	}
+
+	r.OuterRings = ringsLatLons(AssembleRings(outerWays), coords)
+	r.InnerRings = ringsLatLons(AssembleRings(innerWays), coords)
+	return len(r.OuterRings) > 0
+}
+
+// Multipolygon. geometry polygon relation. nil untuk route relation.
+func (r *OSMRelation) Multipolygon() *datastructure.Multipolygon {
+	if len(r.OuterRings) == 0 {
+		return nil
+	}
+	return &datastructure.Multipolygon{Outer: r.OuterRings, Inner: r.InnerRings}
+}
+
+// BoundaryLatLons. ring outer terbesar, dipakai sebagai boundary document relation. untuk route relation: semua koordinat member way.
+func (r *OSMRelation) BoundaryLatLons() [][]float64 {
+	if r.Type == RELATION_ROUTE {
+		return r.RouteLatLons
+	}
+	return largestRing(r.OuterRings)
+}
+
+// Center. titik yang mewakili relation: point-on-surface polygon relation, titik di setengah panjang untuk route relation.
+func (r *OSMRelation) Center() (float64, float64) {
+	if polygon := r.Multipolygon(); polygon != nil {
+		return PolygonInteriorPoint(polygon)
+	}
+	return LineMidPoint(r.BoundaryLatLons())
+}
+
+// ObjectType. tipe document relation. kalau tidak ada tag amenity/leisure/dll, pakai tag boundary/route.
+func (r *OSMRelation) ObjectType() string {
+	tipe := GetOSMObjectType(r.TagMap)
+	if tipe != "" {
+		return tipe
+	}
+	switch r.Type {
+	case RELATION_BOUNDARY:
+		return r.TagMap["boundary"]
+	case RELATION_ROUTE:
+		return r.TagMap["route"]
+	}
+	return r.TagMap["natural"]
+}
+
+func ringsLatLons(rings [][]int64, coords NodeCoordLookup) [][][]float64 {
+	latLonRings := make([][][]float64, 0, len(rings))
+	for _, ring := range rings {
+		latLons := make([][]float64, 0, len(ring))
+		for _, nodeID := range ring {
+			lat, lon, ok := coords.GetNode(nodeID)
+			if !ok {
+				// osm node tidak ada di extract, ring tidak lengkap.
+				latLons = nil
+				break
+			}
+			latLons = append(latLons, []float64{lat, lon})
+		}
+		if len(latLons) >= 4 {
+			latLonRings = append(latLonRings, latLons)
+		}
+	}
+	return latLonRings
+}
+
+// AssembleRings. gabungkan way (list node id) yang ujungnya sama jadi ring tertutup (node pertama == node terakhir).
+// way yang tidak bisa membentuk ring tertutup dibuang.
+func AssembleRings(ways [][]int64) [][]int64 {
+	used := make([]bool, len(ways))
+	rings := [][]int64{}
+
+	for i := range ways {
+		if used[i] {
+			continue
+		}
+		used[i] = true
+		ring := append([]int64{}, ways[i]...)
+
+		for ring[0] != ring[len(ring)-1] {
+			extended := false
+			end := ring[len(ring)-1]
+			for j := range ways {
+				if used[j] {
+					continue
+				}
+				way := ways[j]
+				if way[0] == end {
+					ring = append(ring, way[1:]...)
+				} else if way[len(way)-1] == end {
+					for k := len(way) - 2; k >= 0; k-- {
+						ring = append(ring, way[k])
+					}
+				} else {
+					continue
+				}
+				used[j] = true
+				extended = true
+				break
+			}
+			if !extended {
+				break
+			}
+		}
+
+		if len(ring) >= 4 && ring[0] == ring[len(ring)-1] {
+			rings = append(rings, ring)
+		}
+	}
+	return rings
+}
+
+// collectMemberWays. scan osm way di file pbf & return node id dari way yang jadi member relation.
+func collectMemberWays(mapfile string, memberWays map[int64]struct{}) (map[int64][]int64, error) {
+	f, err := os.Open(mapfile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := osmpbf.New(context.Background(), f, 1)
+	defer scanner.Close()
+	scanner.SkipNodes = true
+	scanner.SkipRelations = true
+
+	wayNodes := make(map[int64][]int64, len(memberWays))
+	for scanner.Scan() {
+		way, ok := scanner.Object().(*osm.Way)
+		if !ok {
+			continue
+		}
+		if _, ok := memberWays[int64(way.ID)]; !ok {
+			continue
+		}
+		nodeIDs := make([]int64, 0, len(way.Nodes))
+		for _, node := range way.Nodes {
+			nodeIDs = append(nodeIDs, int64(node.ID))
+		}
+		wayNodes[int64(way.ID)] = nodeIDs
+	}
+	return wayNodes, scanner.Err()
+}
+
+// PointInMultipolygon. true kalau titik ada di dalam ring outer & tidak di dalam ring inner (hole).
+// pakai aturan even-odd di IsPointInPolygon, jadi pulau di dalam hole juga termasuk polygon.
+func PointInMultipolygon(lat, lon float64, polygon *datastructure.Multipolygon) bool {
+	ringsLat := make([][]float64, 0, len(polygon.Outer)+len(polygon.Inner))
+	ringsLon := make([][]float64, 0, len(polygon.Outer)+len(polygon.Inner))
+	for _, rings := range [][][][]float64{polygon.Outer, polygon.Inner} {
+		for _, ring := range rings {
+			ringLat, ringLon := make([]float64, len(ring)), make([]float64, len(ring))
+			for i, latLon := range ring {
+				ringLat[i], ringLon[i] = latLon[0], latLon[1]
+			}
+			ringsLat = append(ringsLat, ringLat)
+			ringsLon = append(ringsLon, ringLon)
+		}
+	}
+	return IsPointInPolygon(lat, lon, ringsLat, ringsLon)
+}
+
+func pointInRing(lat, lon float64, ring [][]float64) bool {
+	ringLat, ringLon := make([]float64, len(ring)), make([]float64, len(ring))
+	for i, latLon := range ring {
+		ringLat[i], ringLon[i] = latLon[0], latLon[1]
+	}
+	return isPointInRing(lat, lon, ringLat, ringLon)
+}
+
+// PointInArea. true kalau titik (lat, lon) ada di dalam polygon osm relation atau osm way tertutup (area), beserta luas area-nya
+// (derajat persegi, hanya untuk membandingkan luas).
+func PointInArea(lat, lon float64, object datastructure.OSMObject) (bool, float64) {
+	if object.Polygon != nil {
+		if !PointInMultipolygon(lat, lon, object.Polygon) {
+			return false, 0
+		}
+		return true, MultipolygonArea(object.Polygon)
+	}
+	if !isClosedRing(object.BoundaryLatLons) {
+		return false, 0
+	}
+	ringLat, ringLon := make([]float64, len(object.BoundaryLatLons)), make([]float64, len(object.BoundaryLatLons))
+	for i, latLon := range object.BoundaryLatLons {
+		ringLat[i], ringLon[i] = latLon[0], latLon[1]
+	}
+	if !IsPointInPolygon(lat, lon, [][]float64{ringLat}, [][]float64{ringLon}) {
+		return false, 0
+	}
+	return true, ringArea(object.BoundaryLatLons)
+}
+
+// AreaBound. bounding box area osm object (polygon relation atau osm way tertutup): minLat, minLon, maxLat, maxLon.
+// false kalau osm object bukan area.
+func AreaBound(object datastructure.OSMObject) (float64, float64, float64, float64, bool) {
+	if object.Polygon != nil && len(object.Polygon.Outer) > 0 {
+		minLat, minLon, maxLat, maxLon := MultipolygonBound(object.Polygon)
+		return minLat, minLon, maxLat, maxLon, true
+	}
+	if !isClosedRing(object.BoundaryLatLons) {
+		return 0, 0, 0, 0, false
+	}
+	bbox := latLonsBBox(object.BoundaryLatLons)
+	return bbox[1], bbox[0], bbox[3], bbox[2], true
+}
+
+// RtreeLeafBound. bound leaf r-tree osm object: kotak 0.4 km di sekitar titik object, diperluas ke bounding box area (AreaBound)
+// supaya titik di dalam area ketemu saat reverse geocoding. dipakai saat indexing & saat document ditambah lewat osmChange/document api.
+func RtreeLeafBound(object datastructure.OSMObject) datastructure.RtreeBoundingBox {
+	upperRightLat, upperRightLon := GetDestinationPoint(object.Lat, object.Lon, 45, 0.4)
+	lowerLeftLat, lowerLeftLon := GetDestinationPoint(object.Lat, object.Lon, 225, 0.4)
+
+	if minLat, minLon, maxLat, maxLon, ok := AreaBound(object); ok {
+		lowerLeftLat, lowerLeftLon = math.Min(lowerLeftLat, minLat), math.Min(lowerLeftLon, minLon)
+		upperRightLat, upperRightLon = math.Max(upperRightLat, maxLat), math.Max(upperRightLon, maxLon)
+	}
+	return datastructure.NewRtreeBoundingBox(2, []float64{lowerLeftLat, lowerLeftLon}, []float64{upperRightLat, upperRightLon})
+}
+
+// MultipolygonArea. luas polygon dalam derajat persegi (shoelace). hanya untuk membandingkan ukuran polygon.
+func MultipolygonArea(polygon *datastructure.Multipolygon) float64 {
+	area := 0.0
+	for _, ring := range polygon.Outer {
+		area += ringArea(ring)
+	}
+	for _, ring := range polygon.Inner {
+		area -= ringArea(ring)
+	}
+	return area
+}
+
+func ringArea(ring [][]float64) float64 {
+	area := 0.0
+	for i := 0; i < len(ring)-1; i++ {
+		area += ring[i][1]*ring[i+1][0] - ring[i+1][1]*ring[i][0]
+	}
+	return math.Abs(area) / 2
+}
+
+// MultipolygonBound. bounding box semua ring outer: minLat, minLon, maxLat, maxLon.
+func MultipolygonBound(polygon *datastructure.Multipolygon) (float64, float64, float64, float64) {
+	minLat, minLon := math.MaxFloat64, math.MaxFloat64
+	maxLat, maxLon := -math.MaxFloat64, -math.MaxFloat64
+	for _, ring := range polygon.Outer {
+		for _, latLon := range ring {
+			minLat, maxLat = math.Min(minLat, latLon[0]), math.Max(maxLat, latLon[0])
+			minLon, maxLon = math.Min(minLon, latLon[1]), math.Max(maxLon, latLon[1])
+		}
+	}
+	return minLat, minLon, maxLat, maxLon
+}
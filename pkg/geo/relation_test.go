@@ -0,0 +1,147 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/stretchr/testify/assert"
+)
+
+type mapCoordLookup map[int64][]float64
+
+func (m mapCoordLookup) GetNode(id int64) (float64, float64, bool) {
+	latLon, ok := m[id]
+	if !ok {
+		return 0, 0, false
+	}
+	return latLon[0], latLon[1], true
+}
+
+func TestAssembleRings(t *testing.T) {
+	cases := []struct {
+		name     string
+		ways     [][]int64
+		expected [][]int64
+	}{
+		{
+			name:     "closed way",
+			ways:     [][]int64{{1, 2, 3, 4, 1}},
+			expected: [][]int64{{1, 2, 3, 4, 1}},
+		},
+		{
+			name:     "join ways, second way reversed",
+			ways:     [][]int64{{1, 2, 3}, {1, 4, 3}},
+			expected: [][]int64{{1, 2, 3, 4, 1}},
+		},
+		{
+			name:     "join three ways",
+			ways:     [][]int64{{1, 2}, {3, 4, 1}, {2, 3}},
+			expected: [][]int64{{1, 2, 3, 4, 1}},
+		},
+		{
+			name:     "open way dropped",
+			ways:     [][]int64{{5, 6, 7}, {1, 2, 3, 4, 1}},
+			expected: [][]int64{{1, 2, 3, 4, 1}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, AssembleRings(c.ways))
+		})
+	}
+}
+
+func TestBuildGeometry(t *testing.T) {
+	coords := mapCoordLookup{
+		// outer: kotak (0,0) - (1,1)
+		1: {0, 0}, 2: {0, 1}, 3: {1, 1}, 4: {1, 0},
+		// inner: kotak (0.4,0.4) - (0.6,0.6)
+		5: {0.4, 0.4}, 6: {0.4, 0.6}, 7: {0.6, 0.6}, 8: {0.6, 0.4},
+	}
+	wayNodes := map[int64][]int64{
+		10: {1, 2, 3},
+		11: {3, 4, 1},
+		12: {5, 6, 7, 8, 5},
+	}
+
+	t.Run("multipolygon with hole", func(t *testing.T) {
+		relation := OSMRelation{
+			ID:   100,
+			Type: RELATION_MULTIPOLYGON,
+			Members: []OSMRelationMember{
+				{WayID: 10, Role: ROLE_OUTER},
+				{WayID: 11, Role: ROLE_OUTER},
+				{WayID: 12, Role: ROLE_INNER},
+			},
+			TagMap: map[string]string{"name": "Taman Sari", "leisure": "park"},
+		}
+		assert.True(t, relation.BuildGeometry(wayNodes, coords))
+		assert.Len(t, relation.OuterRings, 1)
+		assert.Len(t, relation.InnerRings, 1)
+		assert.Equal(t, "park", relation.ObjectType())
+
+		polygon := relation.Multipolygon()
+		assert.True(t, PointInMultipolygon(0.2, 0.2, polygon))
+		assert.False(t, PointInMultipolygon(0.5, 0.5, polygon))
+		assert.False(t, PointInMultipolygon(1.5, 0.5, polygon))
+		assert.InDelta(t, 1-0.04, MultipolygonArea(polygon), 1e-9)
+
+		minLat, minLon, maxLat, maxLon := MultipolygonBound(polygon)
+		assert.Equal(t, []float64{0, 0, 1, 1}, []float64{minLat, minLon, maxLat, maxLon})
+	})
+
+	t.Run("missing member way", func(t *testing.T) {
+		relation := OSMRelation{
+			ID:      101,
+			Type:    RELATION_BOUNDARY,
+			Members: []OSMRelationMember{{WayID: 10, Role: ROLE_OUTER}, {WayID: 99, Role: ROLE_OUTER}},
+			TagMap:  map[string]string{"name": "Kecamatan Depok", "boundary": "administrative"},
+		}
+		assert.False(t, relation.BuildGeometry(wayNodes, coords))
+		assert.Nil(t, relation.Multipolygon())
+	})
+
+	t.Run("route relation", func(t *testing.T) {
+		relation := OSMRelation{
+			ID:      102,
+			Type:    RELATION_ROUTE,
+			Members: []OSMRelationMember{{WayID: 10}, {WayID: 11}},
+			TagMap:  map[string]string{"name": "Trans Jogja 1A", "route": "bus"},
+		}
+		assert.True(t, relation.BuildGeometry(wayNodes, coords))
+		assert.Len(t, relation.BoundaryLatLons(), 6)
+		assert.Nil(t, relation.Multipolygon())
+		assert.Equal(t, "bus", relation.ObjectType())
+	})
+}
+
+func TestPointInArea(t *testing.T) {
+	ring := [][]float64{{0, 0}, {0, 2}, {2, 2}, {2, 0}, {0, 0}}
+	closedWay := datastructure.OSMObject{BoundaryLatLons: ring}
+	inside, size := PointInArea(1, 1, closedWay)
+	assert.True(t, inside)
+	assert.InDelta(t, 4, size, 1e-9)
+	inside, _ = PointInArea(3, 1, closedWay)
+	assert.False(t, inside)
+
+	// linestring bukan area.
+	line := datastructure.OSMObject{BoundaryLatLons: ring[:4]}
+	inside, _ = PointInArea(1, 1, line)
+	assert.False(t, inside)
+	_, _, _, _, ok := AreaBound(line)
+	assert.False(t, ok)
+
+	// polygon relation dengan hole.
+	relation := datastructure.OSMObject{Polygon: &datastructure.Multipolygon{Outer: [][][]float64{ring},
+		Inner: [][][]float64{{{0.5, 0.5}, {0.5, 1.5}, {1.5, 1.5}, {1.5, 0.5}, {0.5, 0.5}}}}}
+	inside, _ = PointInArea(1, 1, relation)
+	assert.False(t, inside)
+	inside, size = PointInArea(0.2, 0.2, relation)
+	assert.True(t, inside)
+	assert.InDelta(t, 3, size, 1e-9)
+
+	minLat, minLon, maxLat, maxLon, ok := AreaBound(closedWay)
+	assert.True(t, ok)
+	assert.Equal(t, []float64{0, 0, 2, 2}, []float64{minLat, minLon, maxLat, maxLon})
+}
@@ -0,0 +1,753 @@
+package geo
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/blevesearch/mmap-go"
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+)
+
+const (
+	ROAD_NETWORK_FILE = "road_network.dat"
+
+	// parameter hmm map matching (Newson & Krumm 2009), dalam km.
+	MAP_MATCHING_GPS_SIGMA     = 0.01 // standar deviasi error gps
+	MAP_MATCHING_BETA          = 0.1  // beda jarak rute dengan jarak garis lurus antar titik gps yang masih wajar
+	MAP_MATCHING_MAX_CANDIDATE = 8    // jumlah maksimal segment jalan kandidat per titik gps
+)
+
+// Road. osm way jalan (highway), di-spool ke disk saat scan osm. koordinat NodeIDs diambil dari NodeCoordStore, NodeIDs juga dipakai
+// untuk konektivitas antar jalan.
+type Road struct {
+	WayID   int64
+	Name    string
+	Class   string // value tag highway, misal primary
+	NodeIDs []int64
+}
+
+type roadSegment struct {
+	road     int32
+	from, to int32 // vertex graph, from = titik awal segment searah osm way
+	length   float64
+}
+
+type roadEdge struct {
+	to     int32
+	road   int32
+	length float64
+}
+
+// RoadNetwork. graph jalan (vertex = osm node jalan, dua jalan terhubung kalau punya osm node yang sama) & grid index segment jalan,
+// di-mmap dari file hasil BuildRoadNetwork. arah jalan (oneway) tidak dipertimbangkan.
+//
+// format file (little endian): header jumlah road, vertex, edge, segment, cell & panjang string (uint64), lalu
+// road (way id int64, offset & panjang name, offset & panjang class uint32), vertex (lat & lon int32, presisi coordPrecision),
+// offset edge pertama setiap vertex (uint32, jumlah vertex+1), edge (vertex to & road int32, length float64),
+// segment (road, vertex from & to int32, length float64), cell grid (cell int64, segment uint32, sorted by cell) & string.
+type RoadNetwork struct {
+	file *os.File
+	data mmap.MMap
+
+	numSegments, numCells              int
+	roads, vertices, adjOffsets, edges []byte
+	segments, cells, strs              []byte
+}
+
+const (
+	roadNetworkHeaderSize = 8 * 6
+	roadRecordSize        = 24
+	vertexRecordSize      = 8
+	edgeRecordSize        = 16
+	segmentRecordSize     = 20
+	cellRecordSize        = 12
+	roadNetworkCellSize   = 0.01 // derajat (+-1.1 km)
+)
+
+// roadNetworkSections. potong data jadi section road, vertex, offset edge, edge, segment, cell & string sesuai header.
+// ok false kalau ukuran data tidak cocok dengan header.
+func roadNetworkSections(data []byte, counts []int) ([][]byte, bool) {
+	numRoads, numVertices, numEdges, numSegments, numCells, stringsLen := counts[0], counts[1], counts[2], counts[3],
+		counts[4], counts[5]
+	sizes := []int{numRoads * roadRecordSize, numVertices * vertexRecordSize, (numVertices + 1) * 4, numEdges * edgeRecordSize,
+		numSegments * segmentRecordSize, numCells * cellRecordSize, stringsLen}
+	sections := make([][]byte, 0, len(sizes))
+	offset := roadNetworkHeaderSize
+	for _, size := range sizes {
+		if size < 0 || offset+size > len(data) {
+			return nil, false
+		}
+		sections = append(sections, data[offset:offset+size])
+		offset += size
+	}
+	return sections, offset == len(data)
+}
+
+func roadCellKey(latCell, lonCell int64) int64 {
+	return latCell<<32 | (lonCell & 0xffffffff)
+}
+
+// roadCells. semua cell grid yang overlap bounding box.
+func roadCells(minLat, minLon, maxLat, maxLon float64) []int64 {
+	cells := []int64{}
+	for latCell := int64(math.Floor(minLat / roadNetworkCellSize)); latCell <= int64(math.Floor(maxLat/roadNetworkCellSize)); latCell++ {
+		for lonCell := int64(math.Floor(minLon / roadNetworkCellSize)); lonCell <= int64(math.Floor(maxLon/roadNetworkCellSize)); lonCell++ {
+			cells = append(cells, roadCellKey(latCell, lonCell))
+		}
+	}
+	return cells
+}
+
+// forEachRoad. panggil fn untuk setiap jalan yang semua osm node-nya punya koordinat, r = index jalan (urut, tanpa jalan yang dilewati).
+func forEachRoad(roads OSMObjectSource[Road], coords NodeCoordLookup, fn func(r int, road Road, points [][]float64) error) error {
+	r := 0
+	for batch, err := range roads.Batches(10000) {
+		if err != nil {
+			return err
+		}
+		for _, road := range batch {
+			if len(road.NodeIDs) < 2 {
+				continue
+			}
+			points := make([][]float64, 0, len(road.NodeIDs))
+			for _, nodeID := range road.NodeIDs {
+				lat, lon, ok := coords.GetNode(nodeID)
+				if !ok {
+					break
+				}
+				points = append(points, []float64{lat, lon})
+			}
+			if len(points) < len(road.NodeIDs) {
+				continue
+			}
+			err := fn(r, road, points)
+			if err != nil {
+				return err
+			}
+			r++
+		}
+	}
+	return nil
+}
+
+// roadSegments. segment jalan ke-r yang panjangnya > 0. vertex = index osm node di vertices.
+func roadSegments(r int, road Road, vertices *NodeCoordStore) []roadSegment {
+	segments := make([]roadSegment, 0, len(road.NodeIDs)-1)
+	for i := 0; i+1 < len(road.NodeIDs); i++ {
+		from, _ := vertices.index(road.NodeIDs[i])
+		to, _ := vertices.index(road.NodeIDs[i+1])
+		a, b := vertices.record(from), vertices.record(to)
+		length := datastructure.HaversineDistance(float64(a.lat)/coordPrecision, float64(a.lon)/coordPrecision,
+			float64(b.lat)/coordPrecision, float64(b.lon)/coordPrecision)
+		if length == 0 {
+			continue
+		}
+		segments = append(segments, roadSegment{road: int32(r), from: int32(from), to: int32(to), length: length})
+	}
+	return segments
+}
+
+// writeRoadVertices. tulis osm node unik dari file sorted nodesPath ke verticesPath & hapus nodesPath.
+func writeRoadVertices(nodesPath, verticesPath string) (*NodeCoordStore, error) {
+	nodes, err := OpenNodeCoordStore(nodesPath)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(nodesPath)
+	defer nodes.Close()
+
+	writer, err := NewNodeCoordWriter(verticesPath)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < nodes.Len(); i++ {
+		node := nodes.record(i)
+		if i > 0 && nodes.record(i-1).id == node.id {
+			continue
+		}
+		err := writer.add(node)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+	return OpenNodeCoordStore(verticesPath)
+}
+
+// BuildRoadNetwork. tulis file jaringan jalan ke path dari roads (spool) & koordinat osm node. jalan dibaca per batch & record
+// sementara di-sort di disk, memory yang dipakai hanya jumlah edge setiap vertex (4 byte per vertex).
+func BuildRoadNetwork(roads OSMObjectSource[Road], coords NodeCoordLookup, path string) error {
+	// osm node semua jalan sorted by node id, node yang dipakai beberapa jalan muncul beberapa kali.
+	nodesWriter, err := NewNodeCoordWriter(path + ".nodes")
+	if err != nil {
+		return err
+	}
+	numRoads, stringsLen := 0, 0
+	err = forEachRoad(roads, coords, func(r int, road Road, points [][]float64) error {
+		numRoads++
+		stringsLen += len(road.Name) + len(road.Class)
+		for i, nodeID := range road.NodeIDs {
+			err := nodesWriter.Add(nodeID, points[i][0], points[i][1])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = nodesWriter.Close()
+	if err != nil {
+		return err
+	}
+
+	// vertex = osm node jalan unik, index vertex = posisi di file vertices.
+	vertices, err := writeRoadVertices(path+".nodes", path+".vertices")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path + ".vertices")
+	defer vertices.Close()
+
+	// jumlah edge setiap vertex & cell grid setiap segment.
+	cellsWriter, err := NewNodeCoordWriter(path + ".cells")
+	if err != nil {
+		return err
+	}
+	degree := make([]uint32, vertices.Len())
+	numSegments := 0
+	err = forEachRoad(roads, coords, func(r int, road Road, points [][]float64) error {
+		for _, segment := range roadSegments(r, road, vertices) {
+			degree[segment.from]++
+			degree[segment.to]++
+			a, b := vertices.record(int(segment.from)), vertices.record(int(segment.to))
+			for _, cell := range roadCells(float64(min(a.lat, b.lat))/coordPrecision, float64(min(a.lon, b.lon))/coordPrecision,
+				float64(max(a.lat, b.lat))/coordPrecision, float64(max(a.lon, b.lon))/coordPrecision) {
+				err := cellsWriter.add(nodeCoord{id: cell, lat: int32(numSegments)})
+				if err != nil {
+					return err
+				}
+			}
+			numSegments++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = cellsWriter.Close()
+	if err != nil {
+		return err
+	}
+	cells, err := OpenNodeCoordStore(path + ".cells")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path + ".cells")
+	defer cells.Close()
+
+	// semua section ditulis langsung ke file output yang di-mmap.
+	counts := []int{numRoads, vertices.Len(), 2 * numSegments, numSegments, cells.Len(), stringsLen}
+	size := roadNetworkHeaderSize + counts[0]*roadRecordSize + counts[1]*vertexRecordSize + (counts[1]+1)*4 +
+		counts[2]*edgeRecordSize + counts[3]*segmentRecordSize + counts[4]*cellRecordSize + counts[5]
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	err = file.Truncate(int64(size))
+	if err != nil {
+		return err
+	}
+	data, err := mmap.Map(file, mmap.RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("error when mmap %s: %w", path, err)
+	}
+	defer data.Unmap()
+
+	for i, count := range counts {
+		binary.LittleEndian.PutUint64(data[8*i:], uint64(count))
+	}
+	sections, _ := roadNetworkSections(data, counts)
+	roadsData, verticesData, adjOffsets, edges, segments, cellsData, strs := sections[0], sections[1], sections[2],
+		sections[3], sections[4], sections[5], sections[6]
+
+	for v := 0; v < vertices.Len(); v++ {
+		vertex := vertices.record(v)
+		binary.LittleEndian.PutUint32(verticesData[v*vertexRecordSize:], uint32(vertex.lat))
+		binary.LittleEndian.PutUint32(verticesData[v*vertexRecordSize+4:], uint32(vertex.lon))
+	}
+	// setelah ini degree[v] = posisi edge berikutnya vertex v.
+	offset := uint32(0)
+	for v := range degree {
+		binary.LittleEndian.PutUint32(adjOffsets[4*v:], offset)
+		offset, degree[v] = offset+degree[v], offset
+	}
+	binary.LittleEndian.PutUint32(adjOffsets[4*len(degree):], offset)
+	for i := 0; i < cells.Len(); i++ {
+		cell := cells.record(i)
+		binary.LittleEndian.PutUint64(cellsData[i*cellRecordSize:], uint64(cell.id))
+		binary.LittleEndian.PutUint32(cellsData[i*cellRecordSize+8:], uint32(cell.lat))
+	}
+
+	stringsOffset, s := 0, 0
+	putString := func(buf []byte, str string) {
+		binary.LittleEndian.PutUint32(buf, uint32(stringsOffset))
+		binary.LittleEndian.PutUint32(buf[4:], uint32(len(str)))
+		stringsOffset += copy(strs[stringsOffset:], str)
+	}
+	putEdge := func(from int32, edge roadEdge) {
+		buf := edges[int(degree[from])*edgeRecordSize:]
+		binary.LittleEndian.PutUint32(buf, uint32(edge.to))
+		binary.LittleEndian.PutUint32(buf[4:], uint32(edge.road))
+		binary.LittleEndian.PutUint64(buf[8:], math.Float64bits(edge.length))
+		degree[from]++
+	}
+	err = forEachRoad(roads, coords, func(r int, road Road, points [][]float64) error {
+		buf := roadsData[r*roadRecordSize:]
+		binary.LittleEndian.PutUint64(buf, uint64(road.WayID))
+		putString(buf[8:], road.Name)
+		putString(buf[16:], road.Class)
+
+		for _, segment := range roadSegments(r, road, vertices) {
+			buf := segments[s*segmentRecordSize:]
+			binary.LittleEndian.PutUint32(buf, uint32(segment.road))
+			binary.LittleEndian.PutUint32(buf[4:], uint32(segment.from))
+			binary.LittleEndian.PutUint32(buf[8:], uint32(segment.to))
+			binary.LittleEndian.PutUint64(buf[12:], math.Float64bits(segment.length))
+			putEdge(segment.from, roadEdge{to: segment.to, road: segment.road, length: segment.length})
+			putEdge(segment.to, roadEdge{to: segment.from, road: segment.road, length: segment.length})
+			s++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return data.Flush()
+}
+
+// LoadRoadNetwork. mmap file hasil BuildRoadNetwork. graph & grid index dibaca langsung dari file, tidak di-load ke memory.
+func LoadRoadNetwork(path string) (*RoadNetwork, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if stat.Size() < roadNetworkHeaderSize {
+		file.Close()
+		return nil, fmt.Errorf("invalid road network file %s: size %d", path, stat.Size())
+	}
+	data, err := mmap.Map(file, mmap.RDONLY, 0)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error when mmap %s: %w", path, err)
+	}
+
+	counts := make([]int, 6)
+	for i := range counts {
+		counts[i] = int(binary.LittleEndian.Uint64(data[8*i:]))
+	}
+	sections, ok := roadNetworkSections(data, counts)
+	if !ok {
+		data.Unmap()
+		file.Close()
+		return nil, fmt.Errorf("invalid road network file %s (indexed by an older version?)", path)
+	}
+	return &RoadNetwork{file: file, data: data, numSegments: counts[3], numCells: counts[4],
+		roads: sections[0], vertices: sections[1], adjOffsets: sections[2], edges: sections[3], segments: sections[4],
+		cells: sections[5], strs: sections[6]}, nil
+}
+
+func (rn *RoadNetwork) Close() error {
+	if rn == nil {
+		return nil
+	}
+	if rn.data != nil {
+		err := rn.data.Unmap()
+		if err != nil {
+			return err
+		}
+		rn.data = nil
+	}
+	return rn.file.Close()
+}
+
+func (rn *RoadNetwork) Size() int {
+	if rn == nil {
+		return 0
+	}
+	return rn.numSegments
+}
+
+func (rn *RoadNetwork) vertex(v int32) []float64 {
+	buf := rn.vertices[int(v)*vertexRecordSize:]
+	return []float64{float64(int32(binary.LittleEndian.Uint32(buf))) / coordPrecision,
+		float64(int32(binary.LittleEndian.Uint32(buf[4:]))) / coordPrecision}
+}
+
+func (rn *RoadNetwork) segment(i int32) roadSegment {
+	buf := rn.segments[int(i)*segmentRecordSize:]
+	return roadSegment{
+		road:   int32(binary.LittleEndian.Uint32(buf)),
+		from:   int32(binary.LittleEndian.Uint32(buf[4:])),
+		to:     int32(binary.LittleEndian.Uint32(buf[8:])),
+		length: math.Float64frombits(binary.LittleEndian.Uint64(buf[12:])),
+	}
+}
+
+// adjacent. edge vertex v.
+func (rn *RoadNetwork) adjacent(v int32) []roadEdge {
+	start := binary.LittleEndian.Uint32(rn.adjOffsets[4*int(v):])
+	end := binary.LittleEndian.Uint32(rn.adjOffsets[4*int(v)+4:])
+	edges := make([]roadEdge, 0, end-start)
+	for i := start; i < end; i++ {
+		buf := rn.edges[int(i)*edgeRecordSize:]
+		edges = append(edges, roadEdge{
+			to:     int32(binary.LittleEndian.Uint32(buf)),
+			road:   int32(binary.LittleEndian.Uint32(buf[4:])),
+			length: math.Float64frombits(binary.LittleEndian.Uint64(buf[8:])),
+		})
+	}
+	return edges
+}
+
+func (rn *RoadNetwork) roadString(buf []byte) string {
+	offset := binary.LittleEndian.Uint32(buf)
+	length := binary.LittleEndian.Uint32(buf[4:])
+	return string(rn.strs[offset : offset+length])
+}
+
+// road. way id, nama & class jalan ke-r.
+func (rn *RoadNetwork) road(r int32) datastructure.MatchedRoad {
+	buf := rn.roads[int(r)*roadRecordSize:]
+	return datastructure.MatchedRoad{WayID: int64(binary.LittleEndian.Uint64(buf)), Name: rn.roadString(buf[8:]),
+		Class: rn.roadString(buf[16:])}
+}
+
+// cellSegments. segment jalan di cell grid.
+func (rn *RoadNetwork) cellSegments(cell int64) []int32 {
+	i := sort.Search(rn.numCells, func(i int) bool {
+		return int64(binary.LittleEndian.Uint64(rn.cells[i*cellRecordSize:])) >= cell
+	})
+	segments := []int32{}
+	for ; i < rn.numCells && int64(binary.LittleEndian.Uint64(rn.cells[i*cellRecordSize:])) == cell; i++ {
+		segments = append(segments, int32(binary.LittleEndian.Uint32(rn.cells[i*cellRecordSize+8:])))
+	}
+	return segments
+}
+
+// roadCandidate. proyeksi titik gps ke satu segment jalan.
+type roadCandidate struct {
+	segment  int32
+	lat, lon float64
+	dist     float64 // km dari titik gps
+	offset   float64 // km dari vertex from segment
+}
+
+// candidates. proyeksi titik (lat, lon) ke segment jalan dalam radius (km), satu segment terdekat per jalan, urut dari yang terdekat.
+func (rn *RoadNetwork) candidates(lat, lon, radius float64, limit int) []roadCandidate {
+	if rn.Size() == 0 {
+		return nil
+	}
+	upRightLat, upRightLon := GetDestinationPoint(lat, lon, 45, radius*math.Sqrt2)
+	downLeftLat, downLeftLon := GetDestinationPoint(lat, lon, 225, radius*math.Sqrt2)
+
+	nearestPerRoad := make(map[int32]roadCandidate)
+	for _, cell := range roadCells(downLeftLat, downLeftLon, upRightLat, upRightLon) {
+		for _, id := range rn.cellSegments(cell) {
+			segment := rn.segment(id)
+			a, b := rn.vertex(segment.from), rn.vertex(segment.to)
+			projection := ProjectPointToLineCoord(NewCoordinate(a[0], a[1]), NewCoordinate(b[0], b[1]), NewCoordinate(lat, lon))
+			dist := datastructure.HaversineDistance(lat, lon, projection.Lat, projection.Lon)
+			if dist > radius {
+				continue
+			}
+			if nearest, ok := nearestPerRoad[segment.road]; ok && (nearest.dist < dist || (nearest.dist == dist && nearest.segment <= id)) {
+				continue
+			}
+			nearestPerRoad[segment.road] = roadCandidate{segment: id, lat: projection.Lat, lon: projection.Lon,
+				dist: dist, offset: math.Min(datastructure.HaversineDistance(a[0], a[1], projection.Lat, projection.Lon), segment.length)}
+		}
+	}
+
+	candidates := make([]roadCandidate, 0, len(nearestPerRoad))
+	for _, candidate := range nearestPerRoad {
+		candidates = append(candidates, candidate)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].dist == candidates[j].dist {
+			return candidates[i].segment < candidates[j].segment
+		}
+		return candidates[i].dist < candidates[j].dist
+	})
+	if limit > 0 && len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates
+}
+
+func (rn *RoadNetwork) roadMatch(candidate roadCandidate) datastructure.RoadMatch {
+	segment := rn.segment(candidate.segment)
+	road := rn.road(segment.road)
+	return datastructure.RoadMatch{
+		WayID:   road.WayID,
+		Name:    road.Name,
+		Class:   road.Class,
+		Lat:     candidate.lat,
+		Lon:     candidate.lon,
+		Dist:    candidate.dist,
+		Segment: [][]float64{rn.vertex(segment.from), rn.vertex(segment.to)},
+	}
+}
+
+// Snap. segment jalan terdekat dari titik (lat, lon) dalam radius (km) & titik proyeksinya. false kalau tidak ada jalan dalam radius.
+func (rn *RoadNetwork) Snap(lat, lon, radius float64) (datastructure.RoadMatch, bool) {
+	candidates := rn.candidates(lat, lon, radius, 1)
+	if len(candidates) == 0 {
+		return datastructure.RoadMatch{}, false
+	}
+	return rn.roadMatch(candidates[0]), true
+}
+
+// MatchTrace. map matching trace gps dengan hidden markov model: state = kandidat segment jalan setiap titik, emission = jarak titik
+// ke jalan, transition = beda jarak rute (lewat graph jalan) dengan jarak garis lurus antar titik. urutan state paling mungkin
+// dicari dengan viterbi. titik tanpa jalan dalam radius tidak di-match (nil) & memutus trace, begitu juga kalau tidak ada rute
+// antara kandidat dua titik berurutan.
+func (rn *RoadNetwork) MatchTrace(points []datastructure.Point, radius float64) datastructure.TraceMatch {
+	result := datastructure.TraceMatch{Points: make([]*datastructure.RoadMatch, len(points)), Roads: []datastructure.MatchedRoad{}}
+
+	candidates := make([][]roadCandidate, len(points))
+	for i, point := range points {
+		candidates[i] = rn.candidates(point.Lat, point.Lon, radius, MAP_MATCHING_MAX_CANDIDATE)
+	}
+
+	// viterbi. score[t][j] = log probability terbesar state j di titik t, prev[t][j] = state titik t-1 (-1 = awal trace).
+	score := make([][]float64, len(points))
+	prev := make([][]int, len(points))
+	for t := range points {
+		score[t] = make([]float64, len(candidates[t]))
+		prev[t] = make([]int, len(candidates[t]))
+		for j, candidate := range candidates[t] {
+			score[t][j] = emissionLogProb(candidate.dist)
+			prev[t][j] = -1
+		}
+		if t == 0 || len(candidates[t-1]) == 0 || len(candidates[t]) == 0 {
+			continue
+		}
+
+		gcDist := datastructure.HaversineDistance(points[t-1].Lat, points[t-1].Lon, points[t].Lat, points[t].Lon)
+		maxRoute := maxRouteDistance(gcDist, radius)
+		best := make([]float64, len(candidates[t]))
+		for j := range best {
+			best[j] = math.Inf(-1)
+		}
+		for i, from := range candidates[t-1] {
+			if math.IsInf(score[t-1][i], -1) {
+				continue
+			}
+			routes := rn.routeDistances(from, candidates[t], maxRoute)
+			for j := range candidates[t] {
+				if math.IsInf(routes[j], 1) {
+					continue
+				}
+				s := score[t-1][i] - math.Abs(routes[j]-gcDist)/MAP_MATCHING_BETA
+				if s > best[j] {
+					best[j] = s
+					prev[t][j] = i
+				}
+			}
+		}
+
+		connected := false
+		for j := range best {
+			if !math.IsInf(best[j], -1) {
+				connected = true
+			}
+		}
+		if !connected {
+			// trace putus, mulai lagi dari titik t.
+			continue
+		}
+		for j := range score[t] {
+			score[t][j] += best[j]
+		}
+	}
+
+	// backtrack dari titik terakhir setiap bagian trace.
+	states := make([]int, len(points))
+	for t := len(points) - 1; t >= 0; t-- {
+		states[t] = -1
+		if len(candidates[t]) == 0 {
+			continue
+		}
+		if t+1 < len(points) && states[t+1] != -1 && prev[t+1][states[t+1]] != -1 {
+			states[t] = prev[t+1][states[t+1]]
+			continue
+		}
+		states[t] = 0
+		for j := range score[t] {
+			if score[t][j] > score[t][states[t]] {
+				states[t] = j
+			}
+		}
+		if math.IsInf(score[t][states[t]], -1) {
+			states[t] = -1
+		}
+	}
+
+	addRoad := func(r int32) {
+		road := rn.road(r)
+		if n := len(result.Roads); n > 0 && result.Roads[n-1].WayID == road.WayID {
+			return
+		}
+		result.Roads = append(result.Roads, road)
+	}
+	for t := range points {
+		if states[t] == -1 {
+			continue
+		}
+		candidate := candidates[t][states[t]]
+		match := rn.roadMatch(candidate)
+		result.Points[t] = &match
+
+		if t > 0 && prev[t][states[t]] != -1 {
+			// jalan yang dilewati rute dari titik t-1.
+			from := candidates[t-1][states[t-1]]
+			gcDist := datastructure.HaversineDistance(points[t-1].Lat, points[t-1].Lon, points[t].Lat, points[t].Lon)
+			for _, road := range rn.routeRoads(from, candidate, maxRouteDistance(gcDist, radius)) {
+				addRoad(road)
+			}
+		}
+		addRoad(rn.segment(candidate.segment).road)
+	}
+	return result
+}
+
+// maxRouteDistance. rute antar kandidat dua titik gps berurutan yang jauh lebih panjang dari jarak garis lurusnya dianggap tidak mungkin.
+func maxRouteDistance(gcDist, radius float64) float64 {
+	return 3*gcDist + 2*radius + MAP_MATCHING_BETA
+}
+
+func emissionLogProb(dist float64) float64 {
+	return -0.5 * (dist / MAP_MATCHING_GPS_SIGMA) * (dist / MAP_MATCHING_GPS_SIGMA)
+}
+
+// routeDistances. jarak rute terpendek (km) dari kandidat from ke setiap kandidat to, +Inf kalau lebih dari maxDist.
+func (rn *RoadNetwork) routeDistances(from roadCandidate, to []roadCandidate, maxDist float64) []float64 {
+	dist, _ := rn.dijkstra(from, maxDist)
+	routes := make([]float64, len(to))
+	for j, candidate := range to {
+		routes[j] = rn.candidateDistance(from, candidate, dist)
+		if routes[j] > maxDist {
+			routes[j] = math.Inf(1)
+		}
+	}
+	return routes
+}
+
+func (rn *RoadNetwork) candidateDistance(from, to roadCandidate, dist map[int32]float64) float64 {
+	if from.segment == to.segment {
+		return math.Abs(to.offset - from.offset)
+	}
+	segment := rn.segment(to.segment)
+	route := math.Inf(1)
+	if d, ok := dist[segment.from]; ok {
+		route = math.Min(route, d+to.offset)
+	}
+	if d, ok := dist[segment.to]; ok {
+		route = math.Min(route, d+segment.length-to.offset)
+	}
+	return route
+}
+
+// routeRoads. jalan yang dilewati rute terpendek (maksimal maxDist km) dari kandidat from ke kandidat to, urut dari jalan from
+// (jalan to tidak termasuk).
+func (rn *RoadNetwork) routeRoads(from, to roadCandidate, maxDist float64) []int32 {
+	if from.segment == to.segment {
+		return nil
+	}
+	dist, prev := rn.dijkstra(from, maxDist)
+	segment := rn.segment(to.segment)
+	end, best := int32(-1), math.Inf(1)
+	if d, ok := dist[segment.from]; ok && d+to.offset < best {
+		end, best = segment.from, d+to.offset
+	}
+	if d, ok := dist[segment.to]; ok && d+segment.length-to.offset < best {
+		end = segment.to
+	}
+	if end == -1 {
+		return nil
+	}
+
+	roads := []int32{rn.segment(from.segment).road}
+	path := []int32{}
+	for v := end; ; {
+		edge, ok := prev[v]
+		if !ok {
+			break
+		}
+		path = append(path, edge.road)
+		v = edge.to
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		roads = append(roads, path[i])
+	}
+	return roads
+}
+
+// dijkstra. jarak terpendek (km) dari kandidat from ke setiap vertex sampai maxDist. prev[v].to = vertex sebelumnya di rute.
+func (rn *RoadNetwork) dijkstra(from roadCandidate, maxDist float64) (map[int32]float64, map[int32]roadEdge) {
+	segment := rn.segment(from.segment)
+	dist := map[int32]float64{segment.from: from.offset, segment.to: segment.length - from.offset}
+	prev := make(map[int32]roadEdge)
+
+	pq := &vertexHeap{{segment.from, dist[segment.from]}, {segment.to, dist[segment.to]}}
+	heap.Init(pq)
+	for pq.Len() > 0 {
+		item := heap.Pop(pq).(vertexDist)
+		if item.dist > dist[item.vertex] || item.dist > maxDist {
+			continue
+		}
+		for _, edge := range rn.adjacent(item.vertex) {
+			d := item.dist + edge.length
+			if old, ok := dist[edge.to]; ok && old <= d {
+				continue
+			}
+			dist[edge.to] = d
+			prev[edge.to] = roadEdge{to: item.vertex, road: edge.road, length: edge.length}
+			heap.Push(pq, vertexDist{edge.to, d})
+		}
+	}
+	return dist, prev
+}
+
+type vertexDist struct {
+	vertex int32
+	dist   float64
+}
+
+type vertexHeap []vertexDist
+
+func (h vertexHeap) Len() int           { return len(h) }
+func (h vertexHeap) Less(i, j int) bool { return h[i].dist < h[j].dist }
+func (h vertexHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *vertexHeap) Push(x any)        { *h = append(*h, x.(vertexDist)) }
+func (h *vertexHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,86 @@
+package geo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoadNetwork(t *testing.T) {
+	// Jalan A & Jalan B terhubung di osm node 2, Jalan C sejajar Jalan A (+-33 m) tapi tidak terhubung ke jalan lain.
+	// Jalan E tidak masuk jaringan jalan karena koordinat osm node 10 tidak ada.
+	roads := SliceSource[Road]{
+		{WayID: 1, Name: "Jalan A", Class: "primary", NodeIDs: []int64{1, 2, 3}},
+		{WayID: 5, Name: "Jalan E", Class: "service", NodeIDs: []int64{3, 10}},
+		{WayID: 2, Name: "Jalan B", Class: "residential", NodeIDs: []int64{2, 4, 5}},
+		{WayID: 3, Name: "Jalan C", Class: "secondary", NodeIDs: []int64{6, 7}},
+		{WayID: 4, Name: "Jalan D", Class: "tertiary", NodeIDs: []int64{5, 8}},
+	}
+	coords := mapCoordLookup{1: {0, 0}, 2: {0, 0.01}, 3: {0, 0.02}, 4: {0.01, 0.01}, 5: {0.02, 0.01}, 6: {0.0003, 0},
+		7: {0.0003, 0.02}, 8: {0.02, 0.02}}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, ROAD_NETWORK_FILE)
+	assert.Nil(t, BuildRoadNetwork(roads, coords, path))
+	roadNetwork, err := LoadRoadNetwork(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer roadNetwork.Close()
+	assert.Equal(t, 6, roadNetwork.Size())
+	files, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+
+	match, ok := roadNetwork.Snap(0.0001, 0.015, 0.05)
+	if assert.True(t, ok) {
+		assert.Equal(t, int64(1), match.WayID)
+		assert.Equal(t, "Jalan A", match.Name)
+		assert.Equal(t, "primary", match.Class)
+		assert.InDelta(t, 0, match.Lat, 1e-9)
+		assert.InDelta(t, 0.015, match.Lon, 1e-9)
+		assert.InDelta(t, 0.0111, match.Dist, 0.0005)
+		assert.Equal(t, [][]float64{{0, 0.01}, {0, 0.02}}, match.Segment)
+	}
+	_, ok = roadNetwork.Snap(1, 1, 0.05)
+	assert.False(t, ok)
+
+	// titik di cell grid tengah Jalan C (lon 0.01-0.02), bukan cell titik awal/akhir-nya.
+	match, ok = roadNetwork.Snap(0.00035, 0.015, 0.05)
+	if assert.True(t, ok) {
+		assert.Equal(t, int64(3), match.WayID)
+	}
+
+	// dua titik pertama lebih dekat ke Jalan C, tapi Jalan C tidak terhubung ke Jalan B.
+	trace := []datastructure.Point{
+		datastructure.NewPoint(0.00017, 0.002),
+		datastructure.NewPoint(0.00017, 0.006),
+		datastructure.NewPoint(0.004, 0.0101),
+		datastructure.NewPoint(0.008, 0.0099),
+		datastructure.NewPoint(1, 1),
+	}
+	result := roadNetwork.MatchTrace(trace, 0.05)
+	if assert.Len(t, result.Points, 5) {
+		for i, wayID := range []int64{1, 1, 2, 2} {
+			if assert.NotNil(t, result.Points[i]) {
+				assert.Equal(t, wayID, result.Points[i].WayID)
+			}
+		}
+		assert.Nil(t, result.Points[4])
+	}
+	assert.Equal(t, []datastructure.MatchedRoad{{WayID: 1, Name: "Jalan A", Class: "primary"},
+		{WayID: 2, Name: "Jalan B", Class: "residential"}}, result.Roads)
+
+	// rute dari Jalan A ke Jalan D lewat Jalan B, walaupun tidak ada titik di Jalan B.
+	result = roadNetwork.MatchTrace([]datastructure.Point{datastructure.NewPoint(0.00005, 0.008),
+		datastructure.NewPoint(0.02, 0.015)}, 0.05)
+	assert.Equal(t, []datastructure.MatchedRoad{{WayID: 1, Name: "Jalan A", Class: "primary"},
+		{WayID: 2, Name: "Jalan B", Class: "residential"}, {WayID: 4, Name: "Jalan D", Class: "tertiary"}}, result.Roads)
+
+	// trace tanpa konteks: titik dekat Jalan C di-snap ke Jalan C.
+	result = roadNetwork.MatchTrace(trace[:1], 0.05)
+	assert.Equal(t, int64(3), result.Points[0].WayID)
+}
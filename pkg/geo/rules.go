@@ -0,0 +1,215 @@
+package geo
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	INDEX_RULES_FILE = "index_rules.json"
+
+	// ANY_TAG_VALUE. semua value dari tag key.
+	ANY_TAG_VALUE = "*"
+)
+
+// TagFilter. osm object di-index kalau punya tag yang ada di Include & tidak punya tag yang ada di Exclude.
+// key -> list value, list kosong atau "*" = semua value.
+type TagFilter struct {
+	Include map[string][]string `yaml:"include" json:"include"`
+	Exclude map[string][]string `yaml:"exclude" json:"exclude"`
+}
+
+// Match. true kalau tag osm object lolos filter.
+func (f TagFilter) Match(tag map[string]string) bool {
+	included := false
+	for k, v := range tag {
+		if matchTagValues(f.Exclude, k, v) {
+			return false
+		}
+		if !included && matchTagValues(f.Include, k, v) {
+			included = true
+		}
+	}
+	return included
+}
+
+func matchTagValues(rules map[string][]string, key, value string) bool {
+	values, ok := rules[key]
+	if !ok {
+		return false
+	}
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == ANY_TAG_VALUE || v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// IndexRules. aturan osm object yang di-index: filter tag osm way (& relation multipolygon) dan osm node, urutan tag key
+// untuk tipe document, tag key yang jadi feature filter nearby places, dan tag yang disimpan di document.
+type IndexRules struct {
+	Ways        TagFilter `yaml:"ways" json:"ways"`
+	Nodes       TagFilter `yaml:"nodes" json:"nodes"`
+	TypeKeys    []string  `yaml:"type_keys" json:"type_keys"`       // tipe document = value tag key pertama yang ada di osm object
+	FeatureKeys []string  `yaml:"feature_keys" json:"feature_keys"` // tag key=value yang bisa dipakai sebagai featureType
+	StoredTags  []string  `yaml:"stored_tags" json:"stored_tags"`   // tag osm yang disimpan di doc store & di-return di response api. "name:*" = semua tag berawalan name:
+
+	featureKeys map[string]bool
+}
+
+// DefaultIndexRules. aturan bawaan, sama dengan ValidSearchTags & ValidNodeSearchTag.
+func DefaultIndexRules() *IndexRules {
+	rules := &IndexRules{
+		Ways:  TagFilter{Include: tagKeysFilter(ValidSearchTags)},
+		Nodes: TagFilter{Include: tagKeysFilter(ValidNodeSearchTag)},
+		TypeKeys: []string{"amenity", "highway", "historic", "sport", "tourism", "leisure", "landuse", "craft", "aeroway",
+			"residential", "industrial", "shop"},
+		FeatureKeys: sortedTagKeys(ValidSearchTags),
+		StoredTags: []string{"opening_hours", "phone", "contact:phone", "website", "contact:website", "email", "cuisine",
+			"operator", "brand", "wikidata", "wikipedia", "wheelchair", "addr:housenumber", "addr:street", "addr:postcode",
+			"addr:city", "name:*"},
+	}
+	rules.init()
+	return rules
+}
+
+func tagKeysFilter(keys map[string]bool) map[string][]string {
+	filter := make(map[string][]string, len(keys))
+	for key := range keys {
+		filter[key] = []string{ANY_TAG_VALUE}
+	}
+	return filter
+}
+
+func sortedTagKeys(keys map[string]bool) []string {
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+func (r *IndexRules) init() {
+	r.featureKeys = make(map[string]bool, len(r.FeatureKeys))
+	for _, key := range r.FeatureKeys {
+		r.featureKeys[key] = true
+	}
+}
+
+// IsFeatureKey. true kalau tag key ini jadi feature filter.
+func (r *IndexRules) IsFeatureKey(key string) bool {
+	return r.featureKeys[key]
+}
+
+// ObjectType. value tag key pertama di TypeKeys yang ada di tag.
+func (r *IndexRules) ObjectType(tag map[string]string) string {
+	return tag[r.ObjectTypeKey(tag)]
+}
+
+// ObjectTypeKey. tag key pertama di TypeKeys yang ada di tag (misal amenity untuk amenity=cafe). "" kalau tidak ada.
+func (r *IndexRules) ObjectTypeKey(tag map[string]string) string {
+	for _, key := range r.TypeKeys {
+		if _, ok := tag[key]; ok {
+			return key
+		}
+	}
+	return ""
+}
+
+// StoredTagMap. subset tag osm object yang disimpan di doc store: StoredTags & tag yang menentukan tipe document
+// (supaya key tipe document, misal amenity, bisa didapat dari tag yang disimpan). key berakhiran * (misal name:*) = semua tag
+// dengan prefix tsb. nil kalau tidak ada.
+func (r *IndexRules) StoredTagMap(tag map[string]string) map[string]string {
+	var stored map[string]string
+	store := func(key, v string) {
+		if stored == nil {
+			stored = make(map[string]string)
+		}
+		stored[key] = v
+	}
+	keys := r.StoredTags
+	if typeKey := r.ObjectTypeKey(tag); typeKey != "" {
+		keys = append([]string{typeKey}, keys...)
+	}
+	for _, key := range keys {
+		if prefix, ok := strings.CutSuffix(key, "*"); ok {
+			for k, v := range tag {
+				if strings.HasPrefix(k, prefix) {
+					store(k, v)
+				}
+			}
+			continue
+		}
+		if v, ok := tag[key]; ok {
+			store(key, v)
+		}
+	}
+	return stored
+}
+
+// LoadIndexRules. load aturan indexing dari file yaml/json. bagian yang tidak ada di file pakai DefaultIndexRules.
+func LoadIndexRules(path string) (*IndexRules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fileRules IndexRules
+	err = yaml.Unmarshal(data, &fileRules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid index rules %s: %w", path, err)
+	}
+
+	rules := DefaultIndexRules()
+	if fileRules.Ways.Include != nil || fileRules.Ways.Exclude != nil {
+		rules.Ways = fileRules.Ways
+	}
+	if fileRules.Nodes.Include != nil || fileRules.Nodes.Exclude != nil {
+		rules.Nodes = fileRules.Nodes
+	}
+	if fileRules.TypeKeys != nil {
+		rules.TypeKeys = fileRules.TypeKeys
+	}
+	if fileRules.FeatureKeys != nil {
+		rules.FeatureKeys = fileRules.FeatureKeys
+	}
+	if fileRules.StoredTags != nil {
+		rules.StoredTags = fileRules.StoredTags
+	}
+	if len(rules.Ways.Include) == 0 && len(rules.Nodes.Include) == 0 {
+		return nil, fmt.Errorf("invalid index rules %s: ways.include and nodes.include are both empty", path)
+	}
+	rules.init()
+	return rules, nil
+}
+
+// Save. simpan aturan indexing ke file json, supaya server memakai aturan yang sama saat apply osmChange.
+func (r *IndexRules) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+var indexRules = DefaultIndexRules()
+
+// SetIndexRules. ganti aturan indexing yang dipakai NewSearchOSMNode, NewSearchOSMWay, GetOSMObjectType, dll.
+// dipanggil sekali saat startup, sebelum parsing/indexing.
+func SetIndexRules(rules *IndexRules) {
+	indexRules = rules
+}
+
+// GetIndexRules. aturan indexing yang sedang dipakai.
+func GetIndexRules() *IndexRules {
+	return indexRules
+}
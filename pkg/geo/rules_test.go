@@ -0,0 +1,117 @@
+package geo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagFilter(t *testing.T) {
+	filter := TagFilter{
+		Include: map[string][]string{"amenity": {"*"}, "highway": {}, "shop": {"bakery", "mall"}},
+		Exclude: map[string][]string{"highway": {"construction"}, "access": {"private"}},
+	}
+
+	cases := []struct {
+		name     string
+		tag      map[string]string
+		expected bool
+	}{
+		{"any value", map[string]string{"amenity": "cafe"}, true},
+		{"empty value list", map[string]string{"highway": "primary"}, true},
+		{"listed value", map[string]string{"shop": "bakery"}, true},
+		{"not listed value", map[string]string{"shop": "clothes"}, false},
+		{"excluded value", map[string]string{"highway": "construction"}, false},
+		{"excluded by other tag", map[string]string{"amenity": "parking", "access": "private"}, false},
+		{"no included tag", map[string]string{"name": "Tugu"}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, filter.Match(c.tag))
+		})
+	}
+}
+
+func TestDefaultIndexRules(t *testing.T) {
+	rules := DefaultIndexRules()
+	assert.True(t, rules.Ways.Match(map[string]string{"leisure": "park"}))
+	assert.False(t, rules.Ways.Match(map[string]string{"name": "Tugu"}))
+	assert.True(t, rules.Nodes.Match(map[string]string{"name": "Tugu"}))
+	assert.Equal(t, "cafe", rules.ObjectType(map[string]string{"shop": "coffee", "amenity": "cafe"}))
+	assert.Equal(t, "coffee", rules.ObjectType(map[string]string{"shop": "coffee"}))
+	assert.True(t, rules.IsFeatureKey("amenity"))
+	assert.False(t, rules.IsFeatureKey("name"))
+	assert.Equal(t, "amenity", rules.ObjectTypeKey(map[string]string{"shop": "coffee", "amenity": "cafe"}))
+	assert.Equal(t, map[string]string{"amenity": "hospital", "opening_hours": "24/7", "phone": "+62 21 123"},
+		rules.StoredTagMap(map[string]string{"amenity": "hospital", "opening_hours": "24/7", "phone": "+62 21 123",
+			"name": "RS Sardjito"}))
+	assert.Nil(t, rules.StoredTagMap(map[string]string{"name": "Tugu"}))
+	assert.Equal(t, map[string]string{"name:en": "Tugu Monument", "name:jv": "Tugu Pal Putih"},
+		rules.StoredTagMap(map[string]string{"name": "Tugu", "name:en": "Tugu Monument", "name:jv": "Tugu Pal Putih"}))
+}
+
+func TestParseOSMID(t *testing.T) {
+	osmKey, err := ParseOSMID("W123")
+	assert.Nil(t, err)
+	assert.Equal(t, "way/123", osmKey)
+	osmKey, err = ParseOSMID("n456")
+	assert.Nil(t, err)
+	assert.Equal(t, "node/456", osmKey)
+	osmKey, err = ParseOSMID("R789")
+	assert.Nil(t, err)
+	assert.Equal(t, "relation/789", osmKey)
+
+	for _, invalid := range []string{"", "W", "X123", "Wabc", "N-1"} {
+		_, err = ParseOSMID(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestLoadIndexRules(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rules.yaml")
+	data := `
+nodes:
+  include:
+    amenity: ["*"]
+    shop: [bakery]
+  exclude:
+    amenity: [bench, waste_basket]
+type_keys: [shop, amenity]
+feature_keys: [amenity, shop, cuisine]
+`
+	assert.Nil(t, os.WriteFile(path, []byte(data), 0600))
+
+	rules, err := LoadIndexRules(path)
+	assert.Nil(t, err)
+	// ways tidak ada di file, pakai aturan bawaan.
+	assert.Equal(t, DefaultIndexRules().Ways, rules.Ways)
+	assert.True(t, rules.Nodes.Match(map[string]string{"amenity": "cafe"}))
+	assert.False(t, rules.Nodes.Match(map[string]string{"amenity": "bench"}))
+	assert.False(t, rules.Nodes.Match(map[string]string{"name": "Tugu", "historic": "monument"}))
+	assert.Equal(t, "bakery", rules.ObjectType(map[string]string{"shop": "bakery", "amenity": "cafe"}))
+	assert.True(t, rules.IsFeatureKey("cuisine"))
+	assert.False(t, rules.IsFeatureKey("leisure"))
+
+	// simpan & load lagi (json).
+	savedPath := filepath.Join(dir, INDEX_RULES_FILE)
+	assert.Nil(t, rules.Save(savedPath))
+	saved, err := LoadIndexRules(savedPath)
+	assert.Nil(t, err)
+	assert.Equal(t, rules, saved)
+
+	assert.Nil(t, os.WriteFile(path, []byte("ways:\n  include: {}\nnodes:\n  include: {}\n"), 0600))
+	_, err = LoadIndexRules(path)
+	assert.NotNil(t, err)
+}
+
+func TestSetIndexRules(t *testing.T) {
+	defer SetIndexRules(DefaultIndexRules())
+
+	rules := DefaultIndexRules()
+	rules.TypeKeys = []string{"cuisine"}
+	SetIndexRules(rules)
+	assert.Equal(t, "padang", GetOSMObjectType(map[string]string{"amenity": "restaurant", "cuisine": "padang"}))
+}
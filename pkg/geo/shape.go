@@ -0,0 +1,247 @@
+package geo
+
+import (
+	"math"
+	"sort"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+)
+
+const (
+	GEOMETRY_POINT        = "Point"
+	GEOMETRY_LINESTRING   = "LineString"
+	GEOMETRY_POLYGON      = "Polygon"
+	GEOMETRY_MULTIPOLYGON = "MultiPolygon"
+)
+
+// InteriorPoint. titik yang pasti ada di dalam ring (tidak seperti centroid untuk polygon concave):
+// titik tengah dari segment terlebar garis horizontal di tengah bounding box ring yang ada di dalam ring.
+func InteriorPoint(ring [][]float64) (float64, float64) {
+	return interiorPoint(ring, [][][]float64{ring})
+}
+
+// PolygonInteriorPoint. point-on-surface multipolygon. garis horizontal di tengah ring outer terbesar, ring inner (hole) ikut
+// dihitung jadi titik tidak jatuh di dalam hole.
+func PolygonInteriorPoint(polygon *datastructure.Multipolygon) (float64, float64) {
+	rings := make([][][]float64, 0, len(polygon.Outer)+len(polygon.Inner))
+	rings = append(rings, polygon.Outer...)
+	rings = append(rings, polygon.Inner...)
+	return interiorPoint(largestRing(polygon.Outer), rings)
+}
+
+// interiorPoint. scanRing menentukan posisi garis horizontal, segment di dalam polygon dihitung dari semua rings (even-odd).
+func interiorPoint(scanRing [][]float64, rings [][][]float64) (float64, float64) {
+	if len(scanRing) == 0 {
+		return 0, 0
+	}
+	minLat, maxLat := math.MaxFloat64, -math.MaxFloat64
+	for _, latLon := range scanRing {
+		minLat, maxLat = math.Min(minLat, latLon[0]), math.Max(maxLat, latLon[0])
+	}
+	scanLat := (minLat + maxLat) / 2
+
+	crossings := []float64{}
+	for _, ring := range rings {
+		for i := 0; i < len(ring)-1; i++ {
+			a, b := ring[i], ring[i+1]
+			if (a[0] > scanLat) != (b[0] > scanLat) {
+				crossings = append(crossings, a[1]+(scanLat-a[0])*(b[1]-a[1])/(b[0]-a[0]))
+			}
+		}
+	}
+	sort.Float64s(crossings)
+
+	lat, lon := scanRing[0][0], scanRing[0][1]
+	widest := -1.0
+	for i := 0; i+1 < len(crossings); i += 2 {
+		if width := crossings[i+1] - crossings[i]; width > widest {
+			widest = width
+			lat, lon = scanLat, (crossings[i]+crossings[i+1])/2
+		}
+	}
+	return lat, lon
+}
+
+func largestRing(rings [][][]float64) [][]float64 {
+	largest, largestArea := [][]float64{}, -1.0
+	for _, ring := range rings {
+		area := ringArea(ring)
+		if area > largestArea {
+			largest, largestArea = ring, area
+		}
+	}
+	return largest
+}
+
+// isClosedRing. true kalau titik pertama = titik terakhir & minimal 4 titik (area).
+func isClosedRing(latLons [][]float64) bool {
+	return len(latLons) >= 4 && latLons[0][0] == latLons[len(latLons)-1][0] && latLons[0][1] == latLons[len(latLons)-1][1]
+}
+
+// RepresentativePoint. titik yang mewakili osm way: point-on-surface untuk way tertutup (area),
+// titik di setengah panjang untuk linestring. selalu ada di geometry way, tidak seperti median lat & lon.
+func RepresentativePoint(latLons [][]float64) (float64, float64) {
+	if len(latLons) == 0 {
+		return 0, 0
+	}
+	if isClosedRing(latLons) {
+		return InteriorPoint(latLons)
+	}
+	return LineMidPoint(latLons)
+}
+
+// LineMidPoint. titik di setengah panjang (haversine) linestring.
+func LineMidPoint(latLons [][]float64) (float64, float64) {
+	if len(latLons) == 0 {
+		return 0, 0
+	}
+	segmentLengths := make([]float64, len(latLons)-1)
+	length := 0.0
+	for i := 0; i < len(latLons)-1; i++ {
+		segmentLengths[i] = datastructure.HaversineDistance(latLons[i][0], latLons[i][1], latLons[i+1][0], latLons[i+1][1])
+		length += segmentLengths[i]
+	}
+
+	remaining := length / 2
+	for i, segmentLength := range segmentLengths {
+		if segmentLength > 0 && remaining <= segmentLength {
+			t := remaining / segmentLength
+			return latLons[i][0] + t*(latLons[i+1][0]-latLons[i][0]), latLons[i][1] + t*(latLons[i+1][1]-latLons[i][1])
+		}
+		remaining -= segmentLength
+	}
+	return latLons[0][0], latLons[0][1]
+}
+
+// SimplifyLine. simplifikasi douglas-peucker, tolerance dalam derajat. tolerance <= 0 = tanpa simplifikasi.
+// titik pertama & terakhir selalu dipertahankan.
+func SimplifyLine(latLons [][]float64, tolerance float64) [][]float64 {
+	if tolerance <= 0 || len(latLons) <= 2 {
+		return latLons
+	}
+	keep := make([]bool, len(latLons))
+	keep[0], keep[len(latLons)-1] = true, true
+
+	stack := [][2]int{{0, len(latLons) - 1}}
+	for len(stack) > 0 {
+		first, last := stack[len(stack)-1][0], stack[len(stack)-1][1]
+		stack = stack[:len(stack)-1]
+
+		farthest, maxDist := -1, tolerance
+		for i := first + 1; i < last; i++ {
+			dist := segmentDistance(latLons[i], latLons[first], latLons[last])
+			if dist > maxDist {
+				farthest, maxDist = i, dist
+			}
+		}
+		if farthest != -1 {
+			keep[farthest] = true
+			stack = append(stack, [2]int{first, farthest}, [2]int{farthest, last})
+		}
+	}
+
+	simplified := make([][]float64, 0, len(latLons))
+	for i, latLon := range latLons {
+		if keep[i] {
+			simplified = append(simplified, latLon)
+		}
+	}
+	return simplified
+}
+
+// segmentDistance. jarak planar titik p ke segment ab (dalam derajat).
+func segmentDistance(p, a, b []float64) float64 {
+	dLat, dLon := b[0]-a[0], b[1]-a[1]
+	if dLat == 0 && dLon == 0 {
+		return math.Hypot(p[0]-a[0], p[1]-a[1])
+	}
+	t := ((p[0]-a[0])*dLat + (p[1]-a[1])*dLon) / (dLat*dLat + dLon*dLon)
+	t = math.Max(0, math.Min(1, t))
+	return math.Hypot(p[0]-(a[0]+t*dLat), p[1]-(a[1]+t*dLon))
+}
+
+// simplifyRing. ring yang jadi kurang dari 4 titik setelah simplifikasi tidak di-simplifikasi.
+func simplifyRing(ring [][]float64, tolerance float64) [][]float64 {
+	simplified := SimplifyLine(ring, tolerance)
+	if len(simplified) < 4 {
+		return ring
+	}
+	return simplified
+}
+
+// NewGeometry. geometry geojson leaf r-tree osm object (polygon relation, polygon/linestring osm way, atau point) &
+// bbox [minLon, minLat, maxLon, maxLat]. bbox dihitung dari geometry sebelum simplifikasi.
+func NewGeometry(object datastructure.OSMObject, tolerance float64) (*datastructure.Geometry, []float64) {
+	switch {
+	case object.Polygon != nil && len(object.Polygon.Outer) > 0:
+		minLat, minLon, maxLat, maxLon := MultipolygonBound(object.Polygon)
+		polygons := groupPolygonRings(object.Polygon)
+		coordinates := make([][][][]float64, 0, len(polygons))
+		for _, rings := range polygons {
+			polygon := make([][][]float64, 0, len(rings))
+			for _, ring := range rings {
+				polygon = append(polygon, lonLats(simplifyRing(ring, tolerance)))
+			}
+			coordinates = append(coordinates, polygon)
+		}
+		bbox := []float64{minLon, minLat, maxLon, maxLat}
+		if len(coordinates) == 1 {
+			return &datastructure.Geometry{Type: GEOMETRY_POLYGON, Coordinates: coordinates[0]}, bbox
+		}
+		return &datastructure.Geometry{Type: GEOMETRY_MULTIPOLYGON, Coordinates: coordinates}, bbox
+	case len(object.BoundaryLatLons) >= 2:
+		bbox := latLonsBBox(object.BoundaryLatLons)
+		if isClosedRing(object.BoundaryLatLons) {
+			return &datastructure.Geometry{Type: GEOMETRY_POLYGON,
+				Coordinates: [][][]float64{lonLats(simplifyRing(object.BoundaryLatLons, tolerance))}}, bbox
+		}
+		return &datastructure.Geometry{Type: GEOMETRY_LINESTRING,
+			Coordinates: lonLats(SimplifyLine(object.BoundaryLatLons, tolerance))}, bbox
+	default:
+		return NewPointGeometry(object.Lat, object.Lon)
+	}
+}
+
+// NewPointGeometry. geometry point & bbox untuk document tanpa geometry (osm node, custom document).
+func NewPointGeometry(lat, lon float64) (*datastructure.Geometry, []float64) {
+	return &datastructure.Geometry{Type: GEOMETRY_POINT, Coordinates: []float64{lon, lat}}, []float64{lon, lat, lon, lat}
+}
+
+// groupPolygonRings. kelompokkan ring inner ke ring outer yang berisi ring inner tsb: [[outer, inner...], ...].
+// ring inner yang tidak ada di dalam ring outer manapun diabaikan.
+func groupPolygonRings(polygon *datastructure.Multipolygon) [][][][]float64 {
+	polygons := make([][][][]float64, 0, len(polygon.Outer))
+	for _, outer := range polygon.Outer {
+		polygons = append(polygons, [][][]float64{outer})
+	}
+	for _, inner := range polygon.Inner {
+		if len(inner) == 0 {
+			continue
+		}
+		for i, outer := range polygon.Outer {
+			if pointInRing(inner[0][0], inner[0][1], outer) {
+				polygons[i] = append(polygons[i], inner)
+				break
+			}
+		}
+	}
+	return polygons
+}
+
+func lonLats(latLons [][]float64) [][]float64 {
+	coordinates := make([][]float64, 0, len(latLons))
+	for _, latLon := range latLons {
+		coordinates = append(coordinates, []float64{latLon[1], latLon[0]})
+	}
+	return coordinates
+}
+
+func latLonsBBox(latLons [][]float64) []float64 {
+	minLat, minLon := math.MaxFloat64, math.MaxFloat64
+	maxLat, maxLon := -math.MaxFloat64, -math.MaxFloat64
+	for _, latLon := range latLons {
+		minLat, maxLat = math.Min(minLat, latLon[0]), math.Max(maxLat, latLon[0])
+		minLon, maxLon = math.Min(minLon, latLon[1]), math.Max(maxLon, latLon[1])
+	}
+	return []float64{minLon, minLat, maxLon, maxLat}
+}
@@ -0,0 +1,86 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepresentativePoint(t *testing.T) {
+	t.Run("closed way", func(t *testing.T) {
+		// way berbentuk U, median lat & lon (0.2, 1) ada di luar way.
+		ring := [][]float64{{0, 0}, {0, 3}, {1, 3}, {1, 2}, {0.2, 2}, {0.2, 1}, {1, 1}, {1, 0}, {0, 0}}
+		lat, lon := RepresentativePoint(ring)
+		assert.True(t, pointInRing(lat, lon, ring))
+	})
+
+	t.Run("linestring", func(t *testing.T) {
+		lat, lon := RepresentativePoint([][]float64{{0, 0}, {0, 1}, {0, 3}})
+		assert.InDelta(t, 0, lat, 1e-9)
+		assert.InDelta(t, 1.5, lon, 1e-6)
+	})
+
+	t.Run("single node", func(t *testing.T) {
+		lat, lon := RepresentativePoint([][]float64{{1, 2}})
+		assert.Equal(t, 1.0, lat)
+		assert.Equal(t, 2.0, lon)
+	})
+}
+
+func TestPolygonInteriorPoint(t *testing.T) {
+	// hole di tengah polygon, titik tengah bounding box ada di dalam hole.
+	polygon := &datastructure.Multipolygon{
+		Outer: [][][]float64{squareRing(0, 0, 3, 3)},
+		Inner: [][][]float64{squareRing(1, 1, 2, 2)},
+	}
+	lat, lon := PolygonInteriorPoint(polygon)
+	assert.True(t, PointInMultipolygon(lat, lon, polygon))
+}
+
+func TestSimplifyLine(t *testing.T) {
+	line := [][]float64{{0, 0}, {0.0001, 1}, {0, 2}, {1, 3}, {0, 4}}
+	assert.Equal(t, line, SimplifyLine(line, 0))
+	assert.Equal(t, [][]float64{{0, 0}, {0, 2}, {1, 3}, {0, 4}}, SimplifyLine(line, 0.01))
+	assert.Equal(t, [][]float64{{0, 0}, {0, 4}}, SimplifyLine(line, 2))
+}
+
+func TestNewGeometry(t *testing.T) {
+	t.Run("multipolygon with hole", func(t *testing.T) {
+		object := datastructure.OSMObject{Polygon: &datastructure.Multipolygon{
+			Outer: [][][]float64{squareRing(0, 0, 3, 3), squareRing(5, 5, 6, 6)},
+			Inner: [][][]float64{squareRing(1, 1, 2, 2)},
+		}}
+		geometry, bbox := NewGeometry(object, 0)
+		assert.Equal(t, GEOMETRY_MULTIPOLYGON, geometry.Type)
+		coordinates := geometry.Coordinates.([][][][]float64)
+		if assert.Len(t, coordinates, 2) {
+			assert.Len(t, coordinates[0], 2)
+			assert.Len(t, coordinates[1], 1)
+		}
+		assert.Equal(t, []float64{0, 0, 6, 6}, bbox)
+	})
+
+	t.Run("closed way", func(t *testing.T) {
+		object := datastructure.OSMObject{BoundaryLatLons: squareRing(-6.2, 106.8, -6.1, 106.9)}
+		geometry, bbox := NewGeometry(object, 0)
+		assert.Equal(t, GEOMETRY_POLYGON, geometry.Type)
+		assert.Equal(t, []float64{106.8, -6.2}, geometry.Coordinates.([][][]float64)[0][0])
+		assert.Equal(t, []float64{106.8, -6.2, 106.9, -6.1}, bbox)
+	})
+
+	t.Run("linestring", func(t *testing.T) {
+		object := datastructure.OSMObject{BoundaryLatLons: [][]float64{{0, 0}, {0.0001, 1}, {0, 2}}}
+		geometry, bbox := NewGeometry(object, 0.01)
+		assert.Equal(t, GEOMETRY_LINESTRING, geometry.Type)
+		assert.Equal(t, [][]float64{{0, 0}, {2, 0}}, geometry.Coordinates)
+		assert.Equal(t, []float64{0, 0, 2, 0.0001}, bbox)
+	})
+
+	t.Run("point", func(t *testing.T) {
+		geometry, bbox := NewGeometry(datastructure.OSMObject{Lat: -6.2, Lon: 106.8}, 0)
+		assert.Equal(t, GEOMETRY_POINT, geometry.Type)
+		assert.Equal(t, []float64{106.8, -6.2}, geometry.Coordinates)
+		assert.Equal(t, []float64{106.8, -6.2, 106.8, -6.2}, bbox)
+	})
+}
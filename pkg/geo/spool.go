@@ -0,0 +1,130 @@
+package geo
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"iter"
+	"os"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// OSMObjectSource. sumber osm object (OSMWay/OSMNode) yang dibaca indexer per batch.
+type OSMObjectSource[T any] interface {
+	Len() int
+	Batches(batchSize int) iter.Seq2[[]T, error]
+}
+
+// SliceSource. OSMObjectSource dari slice di memory.
+type SliceSource[T any] []T
+
+func (s SliceSource[T]) Len() int {
+	return len(s)
+}
+
+func (s SliceSource[T]) Batches(batchSize int) iter.Seq2[[]T, error] {
+	return func(yield func([]T, error) bool) {
+		for start := 0; start < len(s); start += batchSize {
+			end := min(start+batchSize, len(s))
+			if !yield(s[start:end], nil) {
+				return
+			}
+		}
+	}
+}
+
+// ObjectSpool. OSMObjectSource yang disimpan di disk (msgpack stream), jadi osm object tidak perlu disimpan semua di memory.
+// Append semua object, Finish, lalu baca per batch lewat Batches.
+type ObjectSpool[T any] struct {
+	path  string
+	file  *os.File
+	w     *bufio.Writer
+	enc   *msgpack.Encoder
+	count int
+}
+
+func NewObjectSpool[T any](path string) (*ObjectSpool[T], error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0700)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriterSize(file, 1<<20)
+	return &ObjectSpool[T]{
+		path: path,
+		file: file,
+		w:    w,
+		enc:  msgpack.NewEncoder(w),
+	}, nil
+}
+
+func (s *ObjectSpool[T]) Append(obj T) error {
+	err := s.enc.Encode(obj)
+	if err != nil {
+		return err
+	}
+	s.count++
+	return nil
+}
+
+// Finish. flush & tutup writer. dipanggil sekali setelah semua object di Append.
+func (s *ObjectSpool[T]) Finish() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.w.Flush()
+	if err != nil {
+		return err
+	}
+	err = s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (s *ObjectSpool[T]) Len() int {
+	return s.count
+}
+
+func (s *ObjectSpool[T]) Batches(batchSize int) iter.Seq2[[]T, error] {
+	return func(yield func([]T, error) bool) {
+		file, err := os.Open(s.path)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer file.Close()
+
+		dec := msgpack.NewDecoder(bufio.NewReaderSize(file, 1<<20))
+		batch := make([]T, 0, batchSize)
+		for {
+			var obj T
+			err := dec.Decode(&obj)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			batch = append(batch, obj)
+			if len(batch) == batchSize {
+				if !yield(batch, nil) {
+					return
+				}
+				batch = make([]T, 0, batchSize)
+			}
+		}
+		if len(batch) > 0 {
+			yield(batch, nil)
+		}
+	}
+}
+
+// Remove. hapus file spool.
+func (s *ObjectSpool[T]) Remove() error {
+	err := s.Finish()
+	if err != nil {
+		return err
+	}
+	return os.Remove(s.path)
+}
@@ -2,19 +2,11 @@ package geo
 
 import (
 	"github.com/lintang-b-s/osm-search/pkg/datastructure"
-	"github.com/paulmach/osm"
 )
 
-type NodeMapContainer struct {
-	nodeMap map[int64]osm.Node
-}
-
-func (nm *NodeMapContainer) SetNodeMap(nodeMap map[int64]osm.Node) {
-	nm.nodeMap = nodeMap
-}
-
-func (nm *NodeMapContainer) GetNode(id int64) osm.Node {
-	return nm.nodeMap[id]
+// NodeCoordLookup. lookup koordinat osm node dari node id.
+type NodeCoordLookup interface {
+	GetNode(id int64) (lat, lon float64, ok bool)
 }
 
 type OSMWay struct {
@@ -53,7 +45,9 @@ func NewOSMNode(id int64, lat float64, lon float64, tagMap map[string]string, wi
 
 type OSMSpatialIndex struct {
 	StreetRtree                 *datastructure.Rtree
+	StreetNames                 []string // id leaf StreetRtree -> nama jalan
 	AdministrativeBoundaryRtree *datastructure.Rtree
+	Intersections               *IntersectionIndex // persimpangan jalan, disimpan di outputDir. nil kalau tidak ada
 }
 
 type Boundary struct {
@@ -63,6 +57,8 @@ type Boundary struct {
 	Village     string      `json:"village"`
 	PostalCode  string      `json:"postal_code"`
 	Border      [][]float64 `json:"border"`
+	Country     string      `json:"country,omitempty"`
+	AdminLevel  int         `json:"admin_level,omitempty"` // admin_level osm. 0 untuk boundary dari file json
 }
 
 func NewBoundary(province, district, subDistrict, village, postalCode string, border [][]float64) Boundary {
@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/lintang-b-s/osm-search/pkg/updater"
+
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	maxDocumentsBodySize = 10 << 20
+	maxDocumentsPerBatch = 1000
+)
+
+// documentRequest model info
+//
+//	@Description	custom document (POI) yang ditambahkan ke index.
+type documentRequest struct {
+	Name    string            `json:"name" validate:"required"`                 // name of the place.
+	Lat     float64           `json:"lat" validate:"required,min=-90,max=90"`   // latitude of the place.
+	Lon     float64           `json:"lon" validate:"required,min=-180,max=180"` // longitude of the place.
+	Address string            `json:"address"`                                  // address of the place.
+	Type    string            `json:"type"`                                     // type of the place, e.g. cafe.
+	Source  string            `json:"source" validate:"required,max=64"`        // source of the document, e.g. merchant name. must not be osm.
+	Tags    map[string]string `json:"tags"`                                     // optional osm tags, used by the nearby places feature filter.
+}
+
+type addDocumentsRequest struct {
+	Documents []documentRequest `validate:"required,min=1,dive"`
+}
+
+// addDocuments godoc
+// @Summary		addDocuments operation allows user to add custom documents (POIs) to the search index.
+// @Description	addDocuments operation allows user to add custom documents (POIs) to the search index. body is a json array of documents, or newline delimited json with Content-Type application/x-ndjson.
+// @Tags			documents
+// @ID add-documents
+// @Param			body	body	[]documentRequest	true
+// @Accept			application/json
+// @Accept			application/x-ndjson
+// @Produce		application/json
+// @Router			/api/documents [post]
+// @Success		200	{object}	[]datastructure.Node
+// @Failure		400	{object}	errorResponse
+// @Failure		500	{object}	errorResponse
+func (api *searchAPI) addDocuments(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxDocumentsBodySize)
+
+	var (
+		request addDocumentsRequest
+		err     error
+	)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/x-ndjson" {
+		request.Documents, err = decodeNDJSONDocuments(r.Body)
+	} else {
+		err = json.NewDecoder(r.Body).Decode(&request.Documents)
+	}
+	if err != nil {
+		api.BadRequestResponse(w, r, err)
+		return
+	}
+
+	if err := r.Body.Close(); err != nil {
+		api.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if len(request.Documents) > maxDocumentsPerBatch {
+		api.BadRequestResponse(w, r, fmt.Errorf("validation error: maximum %d documents per request", maxDocumentsPerBatch))
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		english := en.New()
+		uni := ut.New(english, english)
+		trans, _ := uni.GetTranslator("en")
+		_ = enTranslations.RegisterDefaultTranslations(validate, trans)
+		vv := translateError(err, trans)
+		vvString := []string{}
+		for _, v := range vv {
+			vvString = append(vvString, v.Error())
+		}
+		api.BadRequestResponse(w, r, fmt.Errorf("validation error: %v", vvString))
+		return
+	}
+
+	docs := make([]updater.CustomDocument, 0, len(request.Documents))
+	for _, doc := range request.Documents {
+		if !regexSource.MatchString(doc.Source) || doc.Source == datastructure.SOURCE_OSM {
+			api.BadRequestResponse(w, r, fmt.Errorf("validation error: "+"source must be alphanumeric or contain special characters: _, - and must not be osm"))
+			return
+		}
+		node := datastructure.NewNode(0, doc.Name, doc.Lat, doc.Lon, doc.Address, doc.Type, "", false)
+		node.Source = doc.Source
+		docs = append(docs, updater.CustomDocument{Node: node, TagMap: doc.Tags})
+	}
+
+	results, err := api.documentService.AddDocuments(docs)
+	if err != nil {
+		api.getStatusCode(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+
+	if err := api.writeJSON(w, http.StatusOK, envelope{"data": results}, headers); err != nil {
+		api.ServerErrorResponse(w, r, err)
+	}
+}
+
+// decodeNDJSONDocuments. decode satu document json per baris.
+func decodeNDJSONDocuments(body io.Reader) ([]documentRequest, error) {
+	docs := []documentRequest{}
+	decoder := json.NewDecoder(body)
+	for {
+		var doc documentRequest
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid ndjson document %d: %w", len(docs)+1, err)
+		}
+		docs = append(docs, doc)
+	}
+}
+
+// deleteDocument godoc
+// @Summary		deleteDocument operation allows user to delete custom document.
+// @Description	deleteDocument operation allows user to delete custom document. osm objects can not be deleted.
+// @Tags			documents
+// @ID delete-document
+// @Param			id	path  int	true	"document id"
+// @Accept			application/json
+// @Produce		application/json
+// @Router			/api/documents/{id} [delete]
+// @Success		200	{object}	messageResponse
+// @Failure		400	{object}	errorResponse
+// @Failure		404	{object}	errorResponse
+// @Failure		500	{object}	errorResponse
+func (api *searchAPI) deleteDocument(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	docID, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil || docID < 0 {
+		api.BadRequestResponse(w, r, errors.New("id must be a non negative integer"))
+		return
+	}
+
+	err = api.documentService.DeleteDocument(docID)
+	if err != nil {
+		api.getStatusCode(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+
+	if err := api.writeJSON(w, http.StatusOK, envelope{"data": NewMessageResponse("delete document success")}, headers); err != nil {
+		api.ServerErrorResponse(w, r, err)
+	}
+}
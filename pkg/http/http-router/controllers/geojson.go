@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/lintang-b-s/osm-search/pkg/geo"
+
+	"go.uber.org/zap"
+)
+
+const (
+	FORMAT_JSON    = "json"
+	FORMAT_GEOJSON = "geojson"
+)
+
+// parseFormat. query param format: json (default) atau geojson.
+func parseFormat(query url.Values) (string, error) {
+	switch query.Get("format") {
+	case "", FORMAT_JSON:
+		return FORMAT_JSON, nil
+	case FORMAT_GEOJSON:
+		return FORMAT_GEOJSON, nil
+	default:
+		return "", errors.New("format must be json or geojson")
+	}
+}
+
+// featureCollection model info
+//
+//	@Description	geojson response (format=geojson). properties of each feature contain the osm object fields and distance.
+type featureCollection struct {
+	Type     string    `json:"type"`
+	Features []feature `json:"features"`
+}
+
+type feature struct {
+	Type       string                  `json:"type"`
+	Geometry   *datastructure.Geometry `json:"geometry"`
+	BBox       []float64               `json:"bbox,omitempty"`
+	Properties featureProperties       `json:"properties"`
+}
+
+type featureProperties struct {
+	datastructure.Node
+	Distance *float64 `json:"distance,omitempty"`
+}
+
+// NewFeatureCollection. geometry feature = geometry osm object kalau sudah diisi (geometry=true), selain itu point.
+// dists boleh nil (lookup tidak punya jarak).
+func NewFeatureCollection(docs []datastructure.Node, dists []float64) featureCollection {
+	features := make([]feature, 0, len(docs))
+	for i, doc := range docs {
+		geometry, bbox := doc.Geometry, doc.BBox
+		if geometry == nil {
+			geometry, _ = geo.NewPointGeometry(doc.Lat, doc.Lon)
+		}
+		doc.Geometry, doc.BBox = nil, nil
+
+		properties := featureProperties{Node: doc}
+		if dists != nil {
+			properties.Distance = &dists[i]
+		}
+		features = append(features, feature{
+			Type:       "Feature",
+			Geometry:   geometry,
+			BBox:       bbox,
+			Properties: properties,
+		})
+	}
+	return featureCollection{Type: "FeatureCollection", Features: features}
+}
+
+// writeResults. tulis hasil query sesuai format. json: envelope{"data": jsonData}, geojson: FeatureCollection dari docs & dists.
+func (api *searchAPI) writeResults(w http.ResponseWriter, r *http.Request, format string, jsonData any,
+	docs []datastructure.Node, dists []float64) {
+	headers := make(http.Header)
+
+	if format != FORMAT_GEOJSON {
+		if err := api.writeJSON(w, http.StatusOK, envelope{"data": jsonData}, headers); err != nil {
+			api.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	js, err := json.MarshalIndent(NewFeatureCollection(docs, dists), "", "\t")
+	if err != nil {
+		api.ServerErrorResponse(w, r, err)
+		return
+	}
+	js = append(js, '\n')
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(js); err != nil {
+		api.log.Error("failed to write GeoJSON response", zap.Error(err))
+	}
+}
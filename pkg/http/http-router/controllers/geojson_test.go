@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFeatureCollection(t *testing.T) {
+	park := datastructure.NewNode(1, "Taman Pintar", -7.8, 110.36, "Yogyakarta", "park", "", false)
+	park.Geometry = &datastructure.Geometry{Type: "Polygon", Coordinates: [][][]float64{{{110.35, -7.81}, {110.37, -7.81},
+		{110.37, -7.79}, {110.35, -7.81}}}}
+	park.BBox = []float64{110.35, -7.81, 110.37, -7.79}
+	cafe := datastructure.NewNode(2, "Kopi Klotok", -7.7, 110.4, "Sleman", "cafe", "", false)
+
+	collection := NewFeatureCollection([]datastructure.Node{park, cafe}, []float64{0.5, 1.5})
+	js, err := json.Marshal(collection)
+	assert.Nil(t, err)
+
+	var decoded map[string]any
+	assert.Nil(t, json.Unmarshal(js, &decoded))
+	assert.Equal(t, "FeatureCollection", decoded["type"])
+	features := decoded["features"].([]any)
+	if !assert.Len(t, features, 2) {
+		return
+	}
+
+	parkFeature := features[0].(map[string]any)
+	assert.Equal(t, "Polygon", parkFeature["geometry"].(map[string]any)["type"])
+	assert.Equal(t, []any{110.35, -7.81, 110.37, -7.79}, parkFeature["bbox"])
+	properties := parkFeature["properties"].(map[string]any)
+	assert.Equal(t, "Taman Pintar", properties["name"])
+	assert.Equal(t, 0.5, properties["distance"])
+	assert.NotContains(t, properties, "geometry")
+
+	cafeGeometry := features[1].(map[string]any)["geometry"].(map[string]any)
+	assert.Equal(t, "Point", cafeGeometry["type"])
+	assert.Equal(t, []any{110.4, -7.7}, cafeGeometry["coordinates"])
+
+	lookup := NewFeatureCollection([]datastructure.Node{cafe}, nil)
+	assert.Nil(t, lookup.Features[0].Properties.Distance)
+}
@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// intersection godoc
+// @Summary		find the intersections of two streets.
+// @Description	returns the coordinates of every intersection of street1 & street2 (osm nodes shared by both streets). street names are case insensitive and jl./jln. match jalan.
+// @Tags			search
+// @ID intersection
+// @Param        street1	query	string	true	"street name, e.g. Jalan Sudirman"
+// @Param        street2	query	string	true	"street name, e.g. Jalan Thamrin"
+// @Produce		application/json
+// @Router			/api/intersection [get]
+// @Success		200	{object}	[]datastructure.Intersection
+// @Failure		400	{object}	errorResponse
+// @Failure		404	{object}	errorResponse
+// @Failure		500	{object}	errorResponse
+func (api *searchAPI) intersection(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	query := r.URL.Query()
+	street1, street2 := strings.TrimSpace(query.Get("street1")), strings.TrimSpace(query.Get("street2"))
+	if street1 == "" || street2 == "" {
+		api.BadRequestResponse(w, r, errors.New("street1 and street2 must be provided"))
+		return
+	}
+	if !regexSearch.MatchString(street1) || !regexSearch.MatchString(street2) {
+		api.BadRequestResponse(w, r, errors.New("street1 and street2 must be alphanumeric or contain special characters: +, ., (, ), ,"))
+		return
+	}
+
+	intersections, err := api.searchService.Intersections(street1, street2)
+	if err != nil {
+		api.getStatusCode(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	if err := api.writeJSON(w, http.StatusOK, envelope{"data": intersections}, headers); err != nil {
+		api.ServerErrorResponse(w, r, err)
+	}
+}
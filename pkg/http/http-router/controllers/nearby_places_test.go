@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/lintang-b-s/osm-search/pkg"
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type nearbyPlacesSearchService struct {
+	SearchService
+}
+
+func (s nearbyPlacesSearchService) NearestNeighboursRadiusWithFeatureFilter(k, offset int, lat, lon, radius float64,
+	featureType, query string) ([]datastructure.Node, error) {
+	if featureType == "amenity=" {
+		msg := "invalid feature expression"
+		return nil, pkg.WrapErrorf(errors.New(msg), pkg.ErrBadParamInput, "%s", msg)
+	}
+	return []datastructure.Node{{Name: featureType + "|" + query, Lat: lat, Lon: lon}}, nil
+}
+
+func TestNearbyPlaces(t *testing.T) {
+	api := New(nearbyPlacesSearchService{}, nil, nil, zap.NewNop())
+
+	get := func(params url.Values) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		api.nearbyPlaces(w, httptest.NewRequest(http.MethodGet, "/api/places?"+params.Encode(), nil), nil)
+		return w
+	}
+	params := func(feature, query string) url.Values {
+		return url.Values{"lat": {"-6.1"}, "lon": {"106.8"}, "k": {"5"}, "feature": {feature}, "query": {query}}
+	}
+
+	for _, c := range []struct{ feature, query string }{
+		{"(amenity=restaurant OR amenity=cafe) AND NOT cuisine=fast_food", ""},
+		{"", "alfamart"},
+		{"shop=*", "alfamart kemang"},
+	} {
+		w := get(params(c.feature, c.query))
+		assert.Equal(t, http.StatusOK, w.Code)
+		var res struct {
+			Data []searchResponse `json:"data"`
+		}
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &res))
+		if assert.Len(t, res.Data, 1) {
+			assert.Equal(t, c.feature+"|"+c.query, res.Data[0].Place.Name)
+		}
+	}
+
+	assert.Equal(t, http.StatusBadRequest, get(params("", "")).Code)
+	assert.Equal(t, http.StatusBadRequest, get(params("amenity=", "")).Code)
+	assert.Equal(t, http.StatusBadRequest, get(params("amenity=cafe;", "")).Code)
+	assert.Equal(t, http.StatusBadRequest, get(params("", "alfamart?")).Code)
+}
@@ -0,0 +1,536 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/lintang-b-s/osm-search/pkg"
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/lintang-b-s/osm-search/pkg/geo"
+	helper "github.com/lintang-b-s/osm-search/pkg/http/http-router/router-helper"
+
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+)
+
+const (
+	NOMINATIM_FORMAT_JSON    = "json"
+	NOMINATIM_FORMAT_JSONV2  = "jsonv2"
+	NOMINATIM_FORMAT_GEOJSON = "geojson"
+
+	nominatimDefaultLimit = 10
+	nominatimMaxLimit     = 40
+
+	nominatimLicence = "Data © OpenStreetMap contributors, ODbL 1.0. https://osm.org/copyright"
+)
+
+// NominatimRoutes. api yang kompatibel dengan nominatim (https://nominatim.org/release-docs/latest/api/Overview/).
+func (api *searchAPI) NominatimRoutes(group *helper.RouteGroup) {
+	group.GET("/search", api.nominatimSearch)
+	group.GET("/reverse", api.nominatimReverse)
+	group.GET("/lookup", api.nominatimLookup)
+}
+
+// nominatimOptions. parameter nominatim yang dipakai semua endpoint.
+type nominatimOptions struct {
+	format         string
+	addressDetails bool
+	polygonGeoJSON bool
+	languages      []string
+}
+
+func parseNominatimOptions(query url.Values) (nominatimOptions, error) {
+	options := nominatimOptions{format: NOMINATIM_FORMAT_JSON}
+	switch query.Get("format") {
+	case "", NOMINATIM_FORMAT_JSON:
+	case NOMINATIM_FORMAT_JSONV2, NOMINATIM_FORMAT_GEOJSON:
+		options.format = query.Get("format")
+	default:
+		return options, errors.New("format must be json, jsonv2 or geojson")
+	}
+
+	options.addressDetails = query.Get("addressdetails") == "1"
+	options.polygonGeoJSON = query.Get("polygon_geojson") == "1"
+
+	acceptLanguage := query.Get("accept-language")
+	if acceptLanguage == "" {
+		acceptLanguage = query.Get("accept_language")
+	}
+	for _, language := range strings.Split(acceptLanguage, ",") {
+		// format header Accept-Language, misal "id,en;q=0.8".
+		language = strings.TrimSpace(strings.Split(language, ";")[0])
+		if language != "" {
+			options.languages = append(options.languages, language)
+		}
+	}
+	return options, nil
+}
+
+// nominatimViewbox. viewbox=<x1>,<y1>,<x2>,<y2> (lon, lat).
+type nominatimViewbox struct {
+	minLat, minLon, maxLat, maxLon float64
+}
+
+func parseNominatimViewbox(viewbox string) (*nominatimViewbox, error) {
+	if viewbox == "" {
+		return nil, nil
+	}
+	parts := strings.Split(viewbox, ",")
+	if len(parts) != 4 {
+		return nil, errors.New("viewbox must be <x1>,<y1>,<x2>,<y2>")
+	}
+	coords := make([]float64, 4)
+	for i, part := range parts {
+		coord, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, errors.New("viewbox must be <x1>,<y1>,<x2>,<y2>")
+		}
+		coords[i] = coord
+	}
+	return &nominatimViewbox{
+		minLon: min(coords[0], coords[2]), maxLon: max(coords[0], coords[2]),
+		minLat: min(coords[1], coords[3]), maxLat: max(coords[1], coords[3]),
+	}, nil
+}
+
+func (v *nominatimViewbox) contains(lat, lon float64) bool {
+	return lat >= v.minLat && lat <= v.maxLat && lon >= v.minLon && lon <= v.maxLon
+}
+
+// nominatimPlace model info
+//
+//	@Description	nominatim compatible place.
+type nominatimPlace struct {
+	PlaceID     int                     `json:"place_id"`
+	Licence     string                  `json:"licence"`
+	OSMType     string                  `json:"osm_type,omitempty"`
+	OSMID       int64                   `json:"osm_id,omitempty"`
+	Lat         string                  `json:"lat"`
+	Lon         string                  `json:"lon"`
+	Class       string                  `json:"class,omitempty"`    // format=json
+	Category    string                  `json:"category,omitempty"` // format=jsonv2
+	Type        string                  `json:"type"`
+	PlaceRank   int                     `json:"place_rank"`
+	AddressType string                  `json:"addresstype"`
+	Name        string                  `json:"name"`
+	DisplayName string                  `json:"display_name"`
+	Address     map[string]string       `json:"address,omitempty"`
+	BoundingBox []string                `json:"boundingbox"` // [minLat, maxLat, minLon, maxLon]
+	GeoJSON     *datastructure.Geometry `json:"geojson,omitempty"`
+}
+
+// NewNominatimPlace. konversi document ke place nominatim. doc.BBox & doc.Geometry harus sudah diisi (WithGeometry).
+func NewNominatimPlace(doc datastructure.Node, options nominatimOptions) nominatimPlace {
+	class := nominatimClass(doc)
+	name := localizedName(doc, options.languages)
+
+	place := nominatimPlace{
+		PlaceID:     doc.ID,
+		Licence:     nominatimLicence,
+		OSMType:     doc.OSMType,
+		OSMID:       doc.OSMID,
+		Lat:         strconv.FormatFloat(doc.Lat, 'f', -1, 64),
+		Lon:         strconv.FormatFloat(doc.Lon, 'f', -1, 64),
+		Type:        doc.Tipe,
+		PlaceRank:   30,
+		AddressType: class,
+		Name:        name,
+		DisplayName: displayName(name, doc.Address),
+	}
+	if class == "highway" {
+		place.PlaceRank, place.AddressType = 26, "road"
+	}
+	if options.format == NOMINATIM_FORMAT_JSONV2 {
+		place.Category = class
+	} else {
+		place.Class = class
+	}
+	if len(doc.BBox) == 4 {
+		place.BoundingBox = []string{formatCoord(doc.BBox[1]), formatCoord(doc.BBox[3]), formatCoord(doc.BBox[0]),
+			formatCoord(doc.BBox[2])}
+	}
+	if options.addressDetails {
+		place.Address = nominatimAddress(doc, class, name)
+	}
+	if options.polygonGeoJSON {
+		place.GeoJSON = doc.Geometry
+	}
+	return place
+}
+
+// nominatimClass. key tag yang menentukan tipe document (amenity, highway, dll), "place" kalau tidak diketahui.
+func nominatimClass(doc datastructure.Node) string {
+	if class := geo.GetIndexRules().ObjectTypeKey(doc.Tags); class != "" {
+		return class
+	}
+	return "place"
+}
+
+func formatCoord(coord float64) string {
+	return strconv.FormatFloat(coord, 'f', 7, 64)
+}
+
+// localizedName. name:<language> dari tag yang disimpan (name:* ada di stored_tags bawaan), kalau tidak ada pakai name.
+func localizedName(doc datastructure.Node, languages []string) string {
+	for _, language := range languages {
+		if name := doc.Tags["name:"+language]; name != "" {
+			return name
+		}
+	}
+	return doc.Name
+}
+
+// displayName. nama & komponen address yang tidak kosong, dipisah koma.
+func displayName(name, address string) string {
+	components := []string{}
+	if name != "" {
+		components = append(components, name)
+	}
+	for _, component := range strings.Split(address, ",") {
+		component = strings.TrimSpace(component)
+		if component != "" && component != name {
+			components = append(components, component)
+		}
+	}
+	return strings.Join(components, ", ")
+}
+
+// nominatimAddress. komponen address dari tag addr:* yang disimpan di doc store & city document.
+func nominatimAddress(doc datastructure.Node, class, name string) map[string]string {
+	address := map[string]string{}
+	if name != "" {
+		if class == "highway" {
+			address["road"] = name
+		} else {
+			address[class] = name
+		}
+	}
+	for key, component := range map[string]string{
+		"addr:housenumber": "house_number",
+		"addr:street":      "road",
+		"addr:city":        "city",
+		"addr:postcode":    "postcode",
+	} {
+		if value := doc.Tags[key]; value != "" {
+			address[component] = value
+		}
+	}
+	if address["city"] == "" && doc.City != "" {
+		address["city"] = doc.City
+	}
+	return address
+}
+
+type nominatimFeatureProperties struct {
+	PlaceID     int               `json:"place_id"`
+	OSMType     string            `json:"osm_type,omitempty"`
+	OSMID       int64             `json:"osm_id,omitempty"`
+	PlaceRank   int               `json:"place_rank"`
+	Category    string            `json:"category"`
+	Type        string            `json:"type"`
+	AddressType string            `json:"addresstype"`
+	Name        string            `json:"name"`
+	DisplayName string            `json:"display_name"`
+	Address     map[string]string `json:"address,omitempty"`
+}
+
+type nominatimFeature struct {
+	Type       string                     `json:"type"`
+	Properties nominatimFeatureProperties `json:"properties"`
+	BBox       []float64                  `json:"bbox,omitempty"`
+	Geometry   *datastructure.Geometry    `json:"geometry"`
+}
+
+type nominatimFeatureCollection struct {
+	Type     string             `json:"type"`
+	Licence  string             `json:"licence"`
+	Features []nominatimFeature `json:"features"`
+}
+
+// NewNominatimFeatureCollection. format=geojson. geometry feature = shape osm object kalau polygon_geojson=1, selain itu point.
+func NewNominatimFeatureCollection(docs []datastructure.Node, options nominatimOptions) nominatimFeatureCollection {
+	features := make([]nominatimFeature, 0, len(docs))
+	for _, doc := range docs {
+		place := NewNominatimPlace(doc, options)
+		geometry := place.GeoJSON
+		if geometry == nil {
+			geometry, _ = geo.NewPointGeometry(doc.Lat, doc.Lon)
+		}
+		features = append(features, nominatimFeature{
+			Type: "Feature",
+			Properties: nominatimFeatureProperties{
+				PlaceID:     place.PlaceID,
+				OSMType:     place.OSMType,
+				OSMID:       place.OSMID,
+				PlaceRank:   place.PlaceRank,
+				Category:    nominatimClass(doc),
+				Type:        place.Type,
+				AddressType: place.AddressType,
+				Name:        place.Name,
+				DisplayName: place.DisplayName,
+				Address:     place.Address,
+			},
+			BBox:     doc.BBox,
+			Geometry: geometry,
+		})
+	}
+	return nominatimFeatureCollection{Type: "FeatureCollection", Licence: nominatimLicence, Features: features}
+}
+
+// writeNominatim. response nominatim tidak dibungkus envelope. single = true untuk reverse (object, bukan array).
+func (api *searchAPI) writeNominatim(w http.ResponseWriter, r *http.Request, docs []datastructure.Node,
+	options nominatimOptions, single bool) {
+	var data any
+	contentType := "application/json"
+	switch {
+	case options.format == NOMINATIM_FORMAT_GEOJSON:
+		data = NewNominatimFeatureCollection(docs, options)
+		contentType = "application/geo+json"
+	case single && len(docs) > 0:
+		data = NewNominatimPlace(docs[0], options)
+	default:
+		places := make([]nominatimPlace, 0, len(docs))
+		for _, doc := range docs {
+			places = append(places, NewNominatimPlace(doc, options))
+		}
+		data = places
+	}
+
+	js, err := json.Marshal(data)
+	if err != nil {
+		api.ServerErrorResponse(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(js); err != nil {
+		api.log.Error("failed to write nominatim response", zap.Error(err))
+	}
+}
+
+// nominatimError. format error nominatim: {"error": {"code": 400, "message": "..."}}.
+func (api *searchAPI) nominatimError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	js, _ := json.Marshal(map[string]any{"error": map[string]any{"code": status, "message": err.Error()}})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(js); err != nil {
+		api.log.Error("failed to write nominatim response", zap.Error(err))
+	}
+}
+
+// nominatimSearch godoc
+// @Summary		nominatim compatible search.
+// @Description	nominatim compatible search (free-form query only). viewbox & bounded=1 restrict results to the viewbox, viewbox without bounded ranks results inside the viewbox first.
+// @Tags			nominatim
+// @ID nominatim-search
+// @Param        q	query	string	true	"free-form query"
+// @Param        format	query	string	false	"json (default), jsonv2 or geojson"
+// @Param        limit	query	int	false	"max results (default 10, max 40)"
+// @Param        viewbox	query	string	false	"<x1>,<y1>,<x2>,<y2>"
+// @Param        bounded	query	int	false	"1 = only return results inside viewbox"
+// @Param        addressdetails	query	int	false	"1 = include address breakdown"
+// @Param        polygon_geojson	query	int	false	"1 = include geojson geometry"
+// @Param        accept-language	query	string	false	"preferred languages, e.g. id,en"
+// @Produce		application/json
+// @Router			/search [get]
+// @Success		200	{object}	[]nominatimPlace
+// @Failure		400	{object}	errorResponse
+// @Failure		500	{object}	errorResponse
+func (api *searchAPI) nominatimSearch(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	query := r.URL.Query()
+	options, err := parseNominatimOptions(query)
+	if err != nil {
+		api.nominatimError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		api.nominatimError(w, r, http.StatusBadRequest, errors.New("q must be provided"))
+		return
+	}
+	if !regexSearch.MatchString(q) {
+		api.nominatimError(w, r, http.StatusBadRequest, errors.New("q must be alphanumeric or contain special characters: +, ., (, ), ,"))
+		return
+	}
+
+	limit := nominatimDefaultLimit
+	if query.Get("limit") != "" {
+		limit, err = strconv.Atoi(query.Get("limit"))
+		if err != nil || limit < 1 {
+			api.nominatimError(w, r, http.StatusBadRequest, errors.New("limit must be a positive integer"))
+			return
+		}
+		limit = min(limit, nominatimMaxLimit)
+	}
+
+	viewbox, err := parseNominatimViewbox(query.Get("viewbox"))
+	if err != nil {
+		api.nominatimError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	bounded := viewbox != nil && query.Get("bounded") == "1"
+
+	var results []datastructure.Node
+	if bounded {
+		// filter viewbox saat search (range query r-tree), bukan dari hasil search teratas.
+		results, err = api.searchService.SearchInBox(q, "", limit, 0, viewbox.minLat, viewbox.minLon, viewbox.maxLat,
+			viewbox.maxLon)
+	} else {
+		results, err = api.searchService.Search(q, "", limit, 0)
+	}
+	if err != nil {
+		api.nominatimError(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		api.logError(r, err)
+		return
+	}
+
+	if viewbox != nil && !bounded {
+		inside := make([]datastructure.Node, 0, len(results))
+		outside := []datastructure.Node{}
+		for _, result := range results {
+			if viewbox.contains(result.Lat, result.Lon) {
+				inside = append(inside, result)
+			} else {
+				outside = append(outside, result)
+			}
+		}
+		results = append(inside, outside...)
+	}
+
+	api.writeNominatim(w, r, api.searchService.WithGeometry(results, 0), options, false)
+}
+
+// nominatimLayer. zoom nominatim -> layer reverse geocoding.
+func nominatimLayer(zoom int) string {
+	switch {
+	case zoom <= 7:
+		return datastructure.REVERSE_LAYER_PROVINCE
+	case zoom <= 12:
+		return datastructure.REVERSE_LAYER_DISTRICT
+	case zoom <= 15:
+		return datastructure.REVERSE_LAYER_VILLAGE
+	case zoom <= 17:
+		return datastructure.REVERSE_LAYER_STREET
+	default:
+		return datastructure.REVERSE_LAYER_BUILDING
+	}
+}
+
+// nominatimReverse godoc
+// @Summary		nominatim compatible reverse geocoding.
+// @Description	nominatim compatible reverse geocoding. returns {"error": "Unable to geocode"} if nothing is found.
+// @Tags			nominatim
+// @ID nominatim-reverse
+// @Param        lat	query	float	true	"Latitude"
+// @Param        lon	query	float	true	"Longitude"
+// @Param        zoom	query	int	false	"level of detail 0-18 (default 18): <=7 province, 8-12 district, 13-15 village, 16-17 street, 18 building"
+// @Param        format	query	string	false	"json (default), jsonv2 or geojson"
+// @Param        addressdetails	query	int	false	"1 = include address breakdown"
+// @Param        polygon_geojson	query	int	false	"1 = include geojson geometry"
+// @Param        accept-language	query	string	false	"preferred languages, e.g. id,en"
+// @Produce		application/json
+// @Router			/reverse [get]
+// @Success		200	{object}	nominatimPlace
+// @Failure		400	{object}	errorResponse
+// @Failure		500	{object}	errorResponse
+func (api *searchAPI) nominatimReverse(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	query := r.URL.Query()
+	options, err := parseNominatimOptions(query)
+	if err != nil {
+		api.nominatimError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	lat, err := strconv.ParseFloat(query.Get("lat"), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		api.nominatimError(w, r, http.StatusBadRequest, errors.New("lat must be a float between -90 and 90"))
+		return
+	}
+	lon, err := strconv.ParseFloat(query.Get("lon"), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		api.nominatimError(w, r, http.StatusBadRequest, errors.New("lon must be a float between -180 and 180"))
+		return
+	}
+
+	zoom := 18
+	if query.Get("zoom") != "" {
+		zoom, err = strconv.Atoi(query.Get("zoom"))
+		if err != nil || zoom < 0 || zoom > 18 {
+			api.nominatimError(w, r, http.StatusBadRequest, errors.New("zoom must be an integer between 0 and 18"))
+			return
+		}
+	}
+
+	result, err := api.searchService.ReverseGeocoding(lat, lon, nominatimLayer(zoom))
+	var ierr *pkg.Error
+	if errors.As(err, &ierr) && ierr.Code() == pkg.ErrNotFound {
+		js, _ := json.Marshal(map[string]string{"error": "Unable to geocode"})
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(js); err != nil {
+			api.log.Error("failed to write nominatim response", zap.Error(err))
+		}
+		return
+	}
+	if err != nil {
+		api.nominatimError(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		api.logError(r, err)
+		return
+	}
+
+	api.writeNominatim(w, r, api.searchService.WithGeometry([]datastructure.Node{result.Place}, 0), options, true)
+}
+
+// nominatimLookup godoc
+// @Summary		nominatim compatible lookup by osm ids.
+// @Description	nominatim compatible lookup by osm ids (N for node, W for way, R for relation).
+// @Tags			nominatim
+// @ID nominatim-lookup
+// @Param        osm_ids	query	string	true	"comma separated osm ids, e.g. W123,N456,R789 (max 50)"
+// @Param        format	query	string	false	"json (default), jsonv2 or geojson"
+// @Param        addressdetails	query	int	false	"1 = include address breakdown"
+// @Param        polygon_geojson	query	int	false	"1 = include geojson geometry"
+// @Param        accept-language	query	string	false	"preferred languages, e.g. id,en"
+// @Produce		application/json
+// @Router			/lookup [get]
+// @Success		200	{object}	[]nominatimPlace
+// @Failure		400	{object}	errorResponse
+// @Failure		500	{object}	errorResponse
+func (api *searchAPI) nominatimLookup(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	query := r.URL.Query()
+	options, err := parseNominatimOptions(query)
+	if err != nil {
+		api.nominatimError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	osmIDs := strings.Split(query.Get("osm_ids"), ",")
+	if query.Get("osm_ids") == "" || len(osmIDs) > maxLookupOSMIDs {
+		api.nominatimError(w, r, http.StatusBadRequest, fmt.Errorf("osm_ids must contain 1 to %d osm ids", maxLookupOSMIDs))
+		return
+	}
+	osmKeys := make([]string, 0, len(osmIDs))
+	for _, osmID := range osmIDs {
+		osmKey, err := geo.ParseOSMID(strings.TrimSpace(osmID))
+		if err != nil {
+			api.nominatimError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		osmKeys = append(osmKeys, osmKey)
+	}
+
+	results, err := api.searchService.Lookup(osmKeys)
+	if err != nil {
+		api.nominatimError(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		api.logError(r, err)
+		return
+	}
+
+	api.writeNominatim(w, r, api.searchService.WithGeometry(results, 0), options, false)
+}
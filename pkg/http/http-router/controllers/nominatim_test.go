@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestNewNominatimPlace(t *testing.T) {
+	doc := datastructure.NewNode(3, "Kopi Klotok", -7.6651, 110.4174, "Jalan Kaliurang, Pakem, Sleman", "cafe", "", false).
+		WithOSMObject("node", 123, map[string]string{"amenity": "cafe", "addr:housenumber": "12", "name:en": "Klotok Coffee"})
+	doc.BBox = []float64{110.4174, -7.6651, 110.4174, -7.6651}
+
+	place := NewNominatimPlace(doc, nominatimOptions{format: NOMINATIM_FORMAT_JSON})
+	assert.Equal(t, 3, place.PlaceID)
+	assert.Equal(t, "node", place.OSMType)
+	assert.Equal(t, int64(123), place.OSMID)
+	assert.Equal(t, "-7.6651", place.Lat)
+	assert.Equal(t, "amenity", place.Class)
+	assert.Equal(t, "", place.Category)
+	assert.Equal(t, "cafe", place.Type)
+	assert.Equal(t, "Kopi Klotok, Jalan Kaliurang, Pakem, Sleman", place.DisplayName)
+	assert.Equal(t, []string{"-7.6651000", "-7.6651000", "110.4174000", "110.4174000"}, place.BoundingBox)
+	assert.Nil(t, place.Address)
+
+	place = NewNominatimPlace(doc, nominatimOptions{format: NOMINATIM_FORMAT_JSONV2, addressDetails: true,
+		languages: []string{"en"}})
+	assert.Equal(t, "amenity", place.Category)
+	assert.Equal(t, "", place.Class)
+	assert.Equal(t, "Klotok Coffee", place.Name)
+	assert.Equal(t, map[string]string{"amenity": "Klotok Coffee", "house_number": "12"}, place.Address)
+}
+
+func TestParseNominatimOptions(t *testing.T) {
+	options, err := parseNominatimOptions(url.Values{"format": {"jsonv2"}, "addressdetails": {"1"},
+		"accept-language": {"id, en;q=0.8"}})
+	assert.Nil(t, err)
+	assert.Equal(t, NOMINATIM_FORMAT_JSONV2, options.format)
+	assert.True(t, options.addressDetails)
+	assert.Equal(t, []string{"id", "en"}, options.languages)
+
+	_, err = parseNominatimOptions(url.Values{"format": {"xml"}})
+	assert.NotNil(t, err)
+
+	viewbox, err := parseNominatimViewbox("110.5,-7.6,110.3,-7.9")
+	assert.Nil(t, err)
+	assert.True(t, viewbox.contains(-7.8, 110.4))
+	assert.False(t, viewbox.contains(-7.5, 110.4))
+	_, err = parseNominatimViewbox("110.5,-7.6")
+	assert.NotNil(t, err)
+}
+
+func TestNominatimLayer(t *testing.T) {
+	assert.Equal(t, datastructure.REVERSE_LAYER_BUILDING, nominatimLayer(18))
+	assert.Equal(t, datastructure.REVERSE_LAYER_STREET, nominatimLayer(16))
+	assert.Equal(t, datastructure.REVERSE_LAYER_VILLAGE, nominatimLayer(14))
+	assert.Equal(t, datastructure.REVERSE_LAYER_DISTRICT, nominatimLayer(10))
+	assert.Equal(t, datastructure.REVERSE_LAYER_PROVINCE, nominatimLayer(5))
+}
+
+type nominatimSearchService struct {
+	SearchService
+}
+
+func (s nominatimSearchService) Search(query, source string, k int, offset int) ([]datastructure.Node, error) {
+	return []datastructure.Node{
+		datastructure.NewNode(1, "Taman Sari", -7.81, 110.36, "", "park", "", false),
+		datastructure.NewNode(2, "Taman Pintar", -7.8, 110.37, "", "park", "", false),
+	}, nil
+}
+
+// SearchInBox. satu document di tengah bounding box, supaya test bisa cek viewbox diteruskan ke search.
+func (s nominatimSearchService) SearchInBox(query, source string, k, offset int, minLat, minLon, maxLat,
+	maxLon float64) ([]datastructure.Node, error) {
+	return []datastructure.Node{datastructure.NewNode(3, "Taman Kota", (minLat+maxLat)/2, (minLon+maxLon)/2, "", "park", "",
+		false)}, nil
+}
+
+func (s nominatimSearchService) WithGeometry(docs []datastructure.Node, tolerance float64) []datastructure.Node {
+	return docs
+}
+
+func TestNominatimSearch(t *testing.T) {
+	api := New(nominatimSearchService{}, nil, nil, zap.NewNop())
+
+	search := func(url string) []nominatimPlace {
+		w := httptest.NewRecorder()
+		api.nominatimSearch(w, httptest.NewRequest(http.MethodGet, url, nil), nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		places := []nominatimPlace{}
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &places))
+		return places
+	}
+	names := func(places []nominatimPlace) []string {
+		result := []string{}
+		for _, place := range places {
+			result = append(result, place.Name)
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"Taman Sari", "Taman Pintar"}, names(search("/search?q=taman")))
+	// viewbox tanpa bounded: hasil di dalam viewbox di depan.
+	assert.Equal(t, []string{"Taman Pintar", "Taman Sari"}, names(search("/search?q=taman&viewbox=110.365,-7.79,110.375,-7.805")))
+
+	places := search("/search?q=taman&viewbox=110.3,-7.7,110.5,-7.9&bounded=1")
+	if assert.Len(t, places, 1) {
+		assert.Equal(t, "Taman Kota", places[0].Name)
+		lat, _ := strconv.ParseFloat(places[0].Lat, 64)
+		lon, _ := strconv.ParseFloat(places[0].Lon, 64)
+		assert.InDelta(t, -7.8, lat, 1e-9)
+		assert.InDelta(t, 110.4, lon, 1e-9)
+	}
+}
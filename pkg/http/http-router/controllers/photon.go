@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/lintang-b-s/osm-search/pkg/geo"
+	helper "github.com/lintang-b-s/osm-search/pkg/http/http-router/router-helper"
+
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+)
+
+const (
+	photonDefaultLimit = 15
+	photonMaxLimit     = 50
+)
+
+// PhotonRoutes. api autocomplete yang kompatibel dengan photon (https://github.com/komoot/photon).
+func (api *searchAPI) PhotonRoutes(group *helper.RouteGroup) {
+	group.GET("/api", api.photonAutocomplete)
+}
+
+var photonOSMTypes = map[string]string{
+	"node":     "N",
+	"way":      "W",
+	"relation": "R",
+}
+
+type photonProperties struct {
+	OSMID       int64     `json:"osm_id,omitempty"`
+	OSMType     string    `json:"osm_type,omitempty"` // N, W atau R
+	OSMKey      string    `json:"osm_key,omitempty"`
+	OSMValue    string    `json:"osm_value,omitempty"`
+	Type        string    `json:"type"`
+	Name        string    `json:"name,omitempty"`
+	HouseNumber string    `json:"housenumber,omitempty"`
+	Street      string    `json:"street,omitempty"`
+	Postcode    string    `json:"postcode,omitempty"`
+	City        string    `json:"city,omitempty"`
+	Extent      []float64 `json:"extent,omitempty"` // [minLon, maxLat, maxLon, minLat]
+}
+
+type photonFeature struct {
+	Type       string                  `json:"type"`
+	Geometry   *datastructure.Geometry `json:"geometry"`
+	Properties photonProperties        `json:"properties"`
+}
+
+// photonFeatureCollection model info
+//
+//	@Description	photon compatible autocomplete response.
+type photonFeatureCollection struct {
+	Type     string          `json:"type"`
+	Features []photonFeature `json:"features"`
+}
+
+// NewPhotonFeature. osm_key/osm_value dari tag yang menentukan tipe document, street/housenumber/postcode/city dari tag addr:*
+// yang disimpan & komponen address document.
+func NewPhotonFeature(doc datastructure.Node, languages []string) photonFeature {
+	osmKey := geo.GetIndexRules().ObjectTypeKey(doc.Tags)
+	properties := photonProperties{
+		OSMID:       doc.OSMID,
+		OSMType:     photonOSMTypes[doc.OSMType],
+		OSMKey:      osmKey,
+		OSMValue:    doc.Tipe,
+		Type:        "other",
+		Name:        localizedName(doc, languages),
+		HouseNumber: doc.Tags["addr:housenumber"],
+		Street:      doc.Tags["addr:street"],
+		Postcode:    doc.Tags["addr:postcode"],
+		City:        doc.Tags["addr:city"],
+	}
+	if properties.Street == "" && osmKey != "highway" {
+		// komponen pertama address adalah nama jalan (tag addr:street atau jalan terdekat saat indexing).
+		properties.Street = strings.TrimSpace(strings.Split(doc.Address, ",")[0])
+	}
+	if properties.City == "" {
+		properties.City = doc.City
+	}
+
+	switch {
+	case osmKey == "highway":
+		properties.Type = "street"
+	case properties.HouseNumber != "":
+		properties.Type = "house"
+	}
+
+	// extent hanya untuk osm object dengan geometry selain point, sama seperti photon.
+	if len(doc.BBox) == 4 && (doc.BBox[0] != doc.BBox[2] || doc.BBox[1] != doc.BBox[3]) {
+		properties.Extent = []float64{doc.BBox[0], doc.BBox[3], doc.BBox[2], doc.BBox[1]}
+	}
+
+	geometry, _ := geo.NewPointGeometry(doc.Lat, doc.Lon)
+	return photonFeature{Type: "Feature", Geometry: geometry, Properties: properties}
+}
+
+// photonAutocomplete godoc
+// @Summary		photon compatible autocomplete.
+// @Description	photon compatible autocomplete. only served if API_PHOTON is true in config.yaml. lat & lon rank results closer to the location higher.
+// @Tags			photon
+// @ID photon-autocomplete
+// @Param        q	query	string	true	"query"
+// @Param        lat	query	float	false	"Latitude of the location bias, requires lon"
+// @Param        lon	query	float	false	"Longitude of the location bias, requires lat"
+// @Param        limit	query	int	false	"max results (default 15, max 50)"
+// @Param        lang	query	string	false	"preferred language of the name"
+// @Produce		application/json
+// @Router			/api [get]
+// @Success		200	{object}	photonFeatureCollection
+// @Failure		400	{object}	errorResponse
+// @Failure		500	{object}	errorResponse
+func (api *searchAPI) photonAutocomplete(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	query := r.URL.Query()
+
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		api.BadRequestResponse(w, r, errors.New("q must be provided"))
+		return
+	}
+	if !regexSearch.MatchString(q) {
+		api.BadRequestResponse(w, r, errors.New("validation error: q must be alphanumeric or contain special characters: +, ., (, ), ,"))
+		return
+	}
+
+	limit := photonDefaultLimit
+	if query.Get("limit") != "" {
+		var err error
+		limit, err = strconv.Atoi(query.Get("limit"))
+		if err != nil || limit < 1 {
+			api.BadRequestResponse(w, r, errors.New("limit must be a positive integer"))
+			return
+		}
+		limit = min(limit, photonMaxLimit)
+	}
+
+	// lat & lon photon untuk bias lokasi: hasil di-rank ulang dengan jarak ke lokasi.
+	hasLocation := query.Get("lat") != "" || query.Get("lon") != ""
+	var lat, lon float64
+	if hasLocation {
+		var latErr, lonErr error
+		lat, latErr = strconv.ParseFloat(query.Get("lat"), 64)
+		lon, lonErr = strconv.ParseFloat(query.Get("lon"), 64)
+		if latErr != nil || lonErr != nil {
+			api.BadRequestResponse(w, r, errors.New("lat and lon must be provided together as floats"))
+			return
+		}
+		if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+			api.BadRequestResponse(w, r, errors.New("lat must be between -90 and 90 and lon between -180 and 180"))
+			return
+		}
+	}
+
+	languages := []string{}
+	if query.Get("lang") != "" {
+		languages = append(languages, query.Get("lang"))
+	}
+
+	var results []datastructure.Node
+	var err error
+	if hasLocation {
+		results, err = api.searchService.AutocompleteNear(q, "", limit, lat, lon)
+	} else {
+		results, err = api.searchService.Autocomplete(q, "", limit, 0)
+	}
+	if err != nil {
+		api.ServerErrorResponse(w, r, err)
+		return
+	}
+	results = api.searchService.WithGeometry(results, 0)
+
+	collection := photonFeatureCollection{Type: "FeatureCollection", Features: make([]photonFeature, 0, len(results))}
+	for _, result := range results {
+		collection.Features = append(collection.Features, NewPhotonFeature(result, languages))
+	}
+
+	js, err := json.Marshal(collection)
+	if err != nil {
+		api.ServerErrorResponse(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(js); err != nil {
+		api.log.Error("failed to write photon response", zap.Error(err))
+	}
+}
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestNewPhotonFeature(t *testing.T) {
+	cafe := datastructure.NewNode(1, "Kopi Klotok", -7.6651, 110.4174, "Jalan Kaliurang, 12, Pakem, Sleman", "cafe",
+		"Sleman", false).WithOSMObject("node", 123, map[string]string{"amenity": "cafe", "addr:housenumber": "12"})
+	cafe.BBox = []float64{110.4174, -7.6651, 110.4174, -7.6651}
+
+	feature := NewPhotonFeature(cafe, nil)
+	assert.Equal(t, "Point", feature.Geometry.Type)
+	assert.Equal(t, photonProperties{OSMID: 123, OSMType: "N", OSMKey: "amenity", OSMValue: "cafe", Type: "house",
+		Name: "Kopi Klotok", HouseNumber: "12", Street: "Jalan Kaliurang", City: "Sleman"}, feature.Properties)
+
+	road := datastructure.NewNode(2, "Jalan Kaliurang", -7.7, 110.4, ", Sleman", "primary", "", false).
+		WithOSMObject("way", 456, map[string]string{"highway": "primary"})
+	road.BBox = []float64{110.39, -7.75, 110.42, -7.6}
+
+	feature = NewPhotonFeature(road, nil)
+	assert.Equal(t, "W", feature.Properties.OSMType)
+	assert.Equal(t, "highway", feature.Properties.OSMKey)
+	assert.Equal(t, "street", feature.Properties.Type)
+	assert.Equal(t, "", feature.Properties.Street)
+	assert.Equal(t, []float64{110.39, -7.6, 110.42, -7.75}, feature.Properties.Extent)
+}
+
+type photonSearchService struct {
+	SearchService
+}
+
+func (s photonSearchService) Autocomplete(query, source string, k, offset int) ([]datastructure.Node, error) {
+	return []datastructure.Node{datastructure.NewNode(1, "Taman Anggrek", -6.11, 106.81, "", "park", "", false)}, nil
+}
+
+// AutocompleteNear. nama hasil berisi lokasi bias supaya test bisa cek lat & lon diteruskan.
+func (s photonSearchService) AutocompleteNear(query, source string, k int, lat, lon float64) ([]datastructure.Node, error) {
+	return []datastructure.Node{datastructure.NewNode(2, "Mall Taman Anggrek", lat, lon, "", "mall", "", false)}, nil
+}
+
+func (s photonSearchService) WithGeometry(docs []datastructure.Node, tolerance float64) []datastructure.Node {
+	return docs
+}
+
+func TestPhotonAutocomplete(t *testing.T) {
+	api := New(photonSearchService{}, nil, nil, zap.NewNop())
+
+	get := func(url string) (*httptest.ResponseRecorder, photonFeatureCollection) {
+		w := httptest.NewRecorder()
+		api.photonAutocomplete(w, httptest.NewRequest(http.MethodGet, url, nil), nil)
+		var collection photonFeatureCollection
+		if w.Code == http.StatusOK {
+			assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &collection))
+		}
+		return w, collection
+	}
+
+	w, collection := get("/api?q=Taman+Angg")
+	assert.Equal(t, http.StatusOK, w.Code)
+	if assert.Len(t, collection.Features, 1) {
+		assert.Equal(t, "Taman Anggrek", collection.Features[0].Properties.Name)
+	}
+
+	w, collection = get("/api?q=Taman+Angg&lat=-6.17&lon=106.87")
+	assert.Equal(t, http.StatusOK, w.Code)
+	if assert.Len(t, collection.Features, 1) {
+		assert.Equal(t, "Mall Taman Anggrek", collection.Features[0].Properties.Name)
+		assert.Equal(t, []any{106.87, -6.17}, collection.Features[0].Geometry.Coordinates)
+	}
+
+	w, _ = get("/api?q=Taman+Angg&lat=-6.17")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w, _ = get("/api?q=Taman+Angg&lat=-96.17&lon=106.87")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/lintang-b-s/osm-search/pkg"
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	maxReverseBatchBodySize = 1 << 20
+	maxReverseBatchPoints   = 1000
+)
+
+// reverseBatchPoint model info
+//
+//	@Description	coordinate to reverse geocode.
+type reverseBatchPoint struct {
+	Lat *float64 `json:"lat"`
+	Lon *float64 `json:"lon"`
+}
+
+// reverseBatchItem model info
+//
+//	@Description	reverse geocoding result of one coordinate, in the same order as the request. status is the http status of this coordinate.
+type reverseBatchItem struct {
+	Status int                       `json:"status"`
+	Data   *reverseGeocodingResponse `json:"data,omitempty"`
+	Error  string                    `json:"error,omitempty"`
+}
+
+// reverseGeocodingBatch godoc
+// @Summary		reverse geocode many coordinates (e.g. gps traces) in one request.
+// @Description	reverse geocode up to 1000 coordinates. body is a json array of {lat, lon}, or newline delimited json with Content-Type application/x-ndjson. results are returned in request order, a coordinate that fails is reported in its own item and does not fail the batch.
+// @Tags			search
+// @ID reverse-geocoding-batch
+// @Param			body	body	[]reverseBatchPoint	true
+// @Param        layer	query	string	false	"building (default), street, village, district or province"
+// @Accept			application/json
+// @Accept			application/x-ndjson
+// @Produce		application/json
+// @Router			/api/reverse/batch [post]
+// @Success		200	{object}	[]reverseBatchItem
+// @Failure		400	{object}	errorResponse
+// @Failure		500	{object}	errorResponse
+func (api *searchAPI) reverseGeocodingBatch(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxReverseBatchBodySize)
+
+	var (
+		points []reverseBatchPoint
+		err    error
+	)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/x-ndjson" {
+		points, err = decodeNDJSONPoints(r.Body)
+	} else {
+		err = json.NewDecoder(r.Body).Decode(&points)
+	}
+	if err != nil {
+		api.BadRequestResponse(w, r, err)
+		return
+	}
+
+	if err := r.Body.Close(); err != nil {
+		api.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if len(points) == 0 {
+		api.BadRequestResponse(w, r, errors.New("validation error: at least one coordinate must be provided"))
+		return
+	}
+	if len(points) > maxReverseBatchPoints {
+		api.BadRequestResponse(w, r, fmt.Errorf("validation error: maximum %d coordinates per request", maxReverseBatchPoints))
+		return
+	}
+
+	layer := r.URL.Query().Get("layer")
+	switch layer {
+	case "", datastructure.REVERSE_LAYER_BUILDING, datastructure.REVERSE_LAYER_STREET, datastructure.REVERSE_LAYER_VILLAGE,
+		datastructure.REVERSE_LAYER_DISTRICT, datastructure.REVERSE_LAYER_PROVINCE:
+	default:
+		api.BadRequestResponse(w, r, errors.New("validation error: layer must be one of building, street, village, district or province"))
+		return
+	}
+
+	// koordinat yang tidak valid langsung jadi item error, sisanya di-reverse geocode.
+	items := make([]reverseBatchItem, len(points))
+	validPoints := make([]datastructure.Point, 0, len(points))
+	validIdx := make([]int, 0, len(points))
+	for i, point := range points {
+		if err := point.validate(); err != nil {
+			items[i] = reverseBatchItem{Status: http.StatusBadRequest, Error: err.Error()}
+			continue
+		}
+		validPoints = append(validPoints, datastructure.NewPoint(*point.Lat, *point.Lon))
+		validIdx = append(validIdx, i)
+	}
+
+	results := api.searchService.ReverseGeocodingBatch(validPoints, layer)
+	for i, result := range results {
+		items[validIdx[i]] = api.newReverseBatchItem(r, result)
+	}
+
+	headers := make(http.Header)
+	if err := api.writeJSON(w, http.StatusOK, envelope{"data": items}, headers); err != nil {
+		api.ServerErrorResponse(w, r, err)
+	}
+}
+
+func (p reverseBatchPoint) validate() error {
+	if p.Lat == nil || p.Lon == nil {
+		return errors.New("lat and lon must be provided")
+	}
+	if *p.Lat < -90 || *p.Lat > 90 {
+		return errors.New("lat must be between -90 and 90")
+	}
+	if *p.Lon < -180 || *p.Lon > 180 {
+		return errors.New("lon must be between -180 and 180")
+	}
+	return nil
+}
+
+// newReverseBatchItem. status item sama dengan status endpoint /api/reverse untuk error yang sama.
+func (api *searchAPI) newReverseBatchItem(r *http.Request, result datastructure.ReverseGeocodingBatchResult) reverseBatchItem {
+	if result.Err == nil {
+		response := NewReverseGeocodingResponse(result.Result)
+		return reverseBatchItem{Status: http.StatusOK, Data: &response}
+	}
+
+	var ierr *pkg.Error
+	if errors.As(result.Err, &ierr) {
+		switch ierr.Code() {
+		case pkg.ErrNotFound:
+			return reverseBatchItem{Status: http.StatusNotFound, Error: result.Err.Error()}
+		case pkg.ErrBadParamInput:
+			return reverseBatchItem{Status: http.StatusBadRequest, Error: result.Err.Error()}
+		}
+	}
+	api.logError(r, result.Err)
+	return reverseBatchItem{Status: http.StatusInternalServerError,
+		Error: "the server encountered a problem and could not process your request"}
+}
+
+// decodeNDJSONPoints. decode satu koordinat json per baris.
+func decodeNDJSONPoints(body io.Reader) ([]reverseBatchPoint, error) {
+	points := []reverseBatchPoint{}
+	decoder := json.NewDecoder(body)
+	for {
+		var point reverseBatchPoint
+		err := decoder.Decode(&point)
+		if errors.Is(err, io.EOF) {
+			return points, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid ndjson coordinate %d: %w", len(points)+1, err)
+		}
+		points = append(points, point)
+	}
+}
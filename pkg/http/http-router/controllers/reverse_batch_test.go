@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lintang-b-s/osm-search/pkg"
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type reverseBatchSearchService struct {
+	SearchService
+}
+
+func (s reverseBatchSearchService) ReverseGeocodingBatch(points []datastructure.Point, layer string) []datastructure.ReverseGeocodingBatchResult {
+	results := make([]datastructure.ReverseGeocodingBatchResult, len(points))
+	for i, point := range points {
+		if point.Lat > 0 {
+			results[i].Err = pkg.WrapErrorf(errors.New("no osm object found"), pkg.ErrNotFound, "no osm object found")
+			continue
+		}
+		results[i].Result = datastructure.ReverseGeocodingResult{Place: datastructure.Node{Name: layer, Lat: point.Lat, Lon: point.Lon}}
+	}
+	return results
+}
+
+func TestReverseGeocodingBatch(t *testing.T) {
+	api := New(reverseBatchSearchService{}, nil, nil, zap.NewNop())
+
+	type response struct {
+		Data []reverseBatchItem `json:"data"`
+	}
+	post := func(body, contentType, layer string) (int, response) {
+		r := httptest.NewRequest(http.MethodPost, "/api/reverse/batch?layer="+layer, strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		api.reverseGeocodingBatch(w, r, nil)
+		var res response
+		_ = json.Unmarshal(w.Body.Bytes(), &res)
+		return w.Code, res
+	}
+
+	code, res := post(`[{"lat": -6.1, "lon": 106.8}, {"lat": 6.1, "lon": 106.8}, {"lat": -100, "lon": 106.8}, {"lon": 106.8}]`,
+		"application/json", "street")
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, res.Data, 4) {
+		assert.Equal(t, http.StatusOK, res.Data[0].Status)
+		assert.Equal(t, "street", res.Data[0].Data.Data.Name)
+		assert.Equal(t, -6.1, res.Data[0].Data.Data.Lat)
+		assert.Equal(t, http.StatusNotFound, res.Data[1].Status)
+		assert.Equal(t, http.StatusBadRequest, res.Data[2].Status)
+		assert.Equal(t, http.StatusBadRequest, res.Data[3].Status)
+		assert.Equal(t, "lat and lon must be provided", res.Data[3].Error)
+	}
+
+	code, res = post("{\"lat\": -6.1, \"lon\": 106.8}\n{\"lat\": -6.2, \"lon\": 106.9}\n", "application/x-ndjson", "")
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, res.Data, 2) {
+		assert.Equal(t, -6.2, res.Data[1].Data.Data.Lat)
+	}
+
+	code, _ = post(`[]`, "application/json", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = post(`[{"lat": -6.1, "lon": 106.8}]`, "application/json", "country")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = post(`{"lat": -6.1`, "application/x-ndjson", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
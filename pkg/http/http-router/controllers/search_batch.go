@@ -0,0 +1,262 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lintang-b-s/osm-search/pkg"
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+)
+
+// body ndjson sengaja tidak dibatasi ukuran & jumlah query-nya (untuk batch besar, misal jutaan alamat): body di-stream per baris,
+// memory dibatasi maxSearchBatchLineSize per baris & GOMAXPROCS query yang diproses sekaligus.
+const (
+	maxSearchBatchBodySize = 10 << 20 // hanya untuk body json array
+	maxSearchBatchQueries  = 10000    // hanya untuk body json array
+	maxSearchBatchLineSize = 64 << 10
+	maxSearchBatchTopK     = 50
+	searchBatchFlushEvery  = 100
+)
+
+// searchBatchQuery model info
+//
+//	@Description	one query of a batch forward geocoding request. lat & lon (optional) bias the ranking to the location, radius (km) drops results farther than radius from lat & lon.
+type searchBatchQuery struct {
+	ID     string   `json:"id"`     // optional client id, returned as is.
+	Query  string   `json:"query"`  // address or place name.
+	Lat    *float64 `json:"lat"`    // optional latitude of the location bias.
+	Lon    *float64 `json:"lon"`    // optional longitude of the location bias.
+	TopK   int      `json:"top_k"`  // max results (default 5, max 50).
+	Source string   `json:"source"` // optional source filter, e.g. osm.
+	Type   string   `json:"type"`   // optional type filter, e.g. cafe.
+	Radius float64  `json:"radius"` // optional max distance (km) from lat & lon.
+}
+
+// searchBatchMatch model info
+//
+//	@Description	search result with a confidence (0-1) of how well the query terms match the name & address of the osm object. dist (km) is only returned if the query has a location.
+type searchBatchMatch struct {
+	Data       datastructure.Node `json:"data"`
+	Confidence float64            `json:"confidence"`
+	Dist       *float64           `json:"dist,omitempty"`
+}
+
+// searchBatchItem model info
+//
+//	@Description	results of one query, in the same order as the request. status is the http status of this query.
+type searchBatchItem struct {
+	Index   int                `json:"index"`
+	ID      string             `json:"id,omitempty"`
+	Status  int                `json:"status"`
+	Results []searchBatchMatch `json:"results"`
+	Error   string             `json:"error,omitempty"`
+}
+
+// searchBatch godoc
+// @Summary		forward geocode many queries (e.g. a list of addresses) in one request.
+// @Description	forward geocode many queries concurrently. body is a json array of queries (max 10000, response is a json array), or newline delimited json with Content-Type application/x-ndjson (response is streamed as newline delimited json, one item per query). ndjson has no limit on the number of queries, each line is at most 64 KiB. results are returned in request order, a query that fails is reported in its own item and does not fail the batch. if the ndjson body can not be read (e.g. a line is too long), the stream ends with a {"error": ...} line.
+// @Tags			search
+// @ID search-batch
+// @Param			body	body	[]searchBatchQuery	true
+// @Accept			application/json
+// @Accept			application/x-ndjson
+// @Produce		application/json
+// @Produce		application/x-ndjson
+// @Router			/api/search/batch [post]
+// @Success		200	{object}	[]searchBatchItem
+// @Failure		400	{object}	errorResponse
+// @Failure		500	{object}	errorResponse
+func (api *searchAPI) searchBatch(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/x-ndjson" {
+		api.searchBatchNDJSON(w, r)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxSearchBatchBodySize)
+	var queries []searchBatchQuery
+	err := json.NewDecoder(r.Body).Decode(&queries)
+	if err != nil {
+		api.BadRequestResponse(w, r, err)
+		return
+	}
+	if err := r.Body.Close(); err != nil {
+		api.ServerErrorResponse(w, r, err)
+		return
+	}
+	if len(queries) == 0 {
+		api.BadRequestResponse(w, r, errors.New("validation error: at least one query must be provided"))
+		return
+	}
+	if len(queries) > maxSearchBatchQueries {
+		api.BadRequestResponse(w, r, fmt.Errorf("validation error: maximum %d queries per request, use ndjson for bigger batches",
+			maxSearchBatchQueries))
+		return
+	}
+
+	next := 0
+	items := make([]searchBatchItem, 0, len(queries))
+	err = api.searchService.SearchBatch(r.Context(), func() (datastructure.SearchBatchQuery, bool, error) {
+		if next >= len(queries) {
+			return datastructure.SearchBatchQuery{}, false, nil
+		}
+		query, err := queries[next].toSearchBatchQuery()
+		next++
+		return query, true, err
+	}, func(result datastructure.SearchBatchResult) error {
+		items = append(items, api.newSearchBatchItem(r, result))
+		return nil
+	})
+	if err != nil {
+		api.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	if err := api.writeJSON(w, http.StatusOK, envelope{"data": items}, headers); err != nil {
+		api.ServerErrorResponse(w, r, err)
+	}
+}
+
+// searchBatchNDJSON. satu query per baris, hasil ditulis per baris begitu query selesai (urut sesuai request).
+// baris yang bukan json valid jadi item error.
+func (api *searchAPI) searchBatchNDJSON(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	// SearchBatch menunggu goroutine yang membaca body, jadi read yang sedang block di-unblock saat batch berhenti di tengah
+	// (emit error / client putus). read deadline dulu karena Close body server menunggu Read yang sedang berjalan.
+	readStopped := make(chan struct{})
+	stopRead := context.AfterFunc(ctx, func() {
+		defer close(readStopped)
+		_ = http.NewResponseController(w).SetReadDeadline(time.Now())
+		r.Body.Close()
+	})
+	defer func() {
+		if !stopRead() {
+			<-readStopped
+		}
+	}()
+
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 4096), maxSearchBatchLineSize)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+
+	written := 0
+	err := api.searchService.SearchBatch(ctx, func() (datastructure.SearchBatchQuery, bool, error) {
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			var query searchBatchQuery
+			if err := json.Unmarshal([]byte(line), &query); err != nil {
+				return datastructure.SearchBatchQuery{}, true, pkg.WrapErrorf(err, pkg.ErrBadParamInput, "invalid ndjson query: %s", err)
+			}
+			batchQuery, err := query.toSearchBatchQuery()
+			return batchQuery, true, err
+		}
+		if err := scanner.Err(); err != nil {
+			if errors.Is(err, bufio.ErrTooLong) {
+				return datastructure.SearchBatchQuery{}, false, fmt.Errorf("ndjson line longer than %d bytes, remaining queries are not processed",
+					maxSearchBatchLineSize)
+			}
+			return datastructure.SearchBatchQuery{}, false, fmt.Errorf("error when reading ndjson body: %w", err)
+		}
+		return datastructure.SearchBatchQuery{}, false, nil
+	}, func(result datastructure.SearchBatchResult) error {
+		if err := encoder.Encode(api.newSearchBatchItem(r, result)); err != nil {
+			cancel()
+			return err
+		}
+		written++
+		if flusher != nil && written%searchBatchFlushEvery == 0 {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		// status 200 sudah terkirim, error ditulis sebagai baris terakhir.
+		api.logError(r, err)
+		if encErr := encoder.Encode(map[string]string{"error": err.Error()}); encErr != nil {
+			api.log.Error("failed to write search batch error", zap.Error(encErr))
+		}
+	}
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
+
+func (q searchBatchQuery) toSearchBatchQuery() (datastructure.SearchBatchQuery, error) {
+	query := datastructure.SearchBatchQuery{ID: q.ID, Query: strings.TrimSpace(q.Query), TopK: q.TopK, Source: q.Source,
+		Type: q.Type, Radius: q.Radius}
+	badRequest := func(msg string) (datastructure.SearchBatchQuery, error) {
+		return query, pkg.WrapErrorf(errors.New(msg), pkg.ErrBadParamInput, "%s", msg)
+	}
+
+	if query.Query == "" {
+		return badRequest("query must be provided")
+	}
+	if !regexSearch.MatchString(query.Query) {
+		return badRequest("query must be alphanumeric or contain special characters: +, ., (, ), ,")
+	}
+	if q.TopK < 0 || q.TopK > maxSearchBatchTopK {
+		return badRequest(fmt.Sprintf("top_k must be between 0 and %d", maxSearchBatchTopK))
+	}
+	if !regexSource.MatchString(q.Source) {
+		return badRequest("source must be alphanumeric or contain special characters: _, -")
+	}
+	if (q.Lat == nil) != (q.Lon == nil) {
+		return badRequest("lat and lon must be provided together")
+	}
+	if q.Lat != nil {
+		if *q.Lat < -90 || *q.Lat > 90 || *q.Lon < -180 || *q.Lon > 180 {
+			return badRequest("lat must be between -90 and 90 and lon between -180 and 180")
+		}
+		query.HasLocation, query.Lat, query.Lon = true, *q.Lat, *q.Lon
+	}
+	if q.Radius < 0 || (q.Radius > 0 && q.Lat == nil) {
+		return badRequest("radius must be positive and requires lat and lon")
+	}
+	return query, nil
+}
+
+// newSearchBatchItem. status item sama dengan status endpoint /api/search untuk error yang sama.
+func (api *searchAPI) newSearchBatchItem(r *http.Request, result datastructure.SearchBatchResult) searchBatchItem {
+	item := searchBatchItem{Index: result.Index, ID: result.Query.ID, Status: http.StatusOK, Results: []searchBatchMatch{}}
+	if result.Err != nil {
+		item.Status = http.StatusInternalServerError
+		item.Error = "the server encountered a problem and could not process your request"
+		var ierr *pkg.Error
+		if errors.As(result.Err, &ierr) && ierr.Code() == pkg.ErrBadParamInput {
+			item.Status = http.StatusBadRequest
+			item.Error = result.Err.Error()
+		} else {
+			api.logError(r, result.Err)
+		}
+		return item
+	}
+
+	for _, match := range result.Matches {
+		batchMatch := searchBatchMatch{Data: match.Data, Confidence: match.Confidence}
+		if result.Query.HasLocation {
+			dist := match.Dist
+			batchMatch.Dist = &dist
+		}
+		item.Results = append(item.Results, batchMatch)
+	}
+	return item
+}
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/lintang-b-s/osm-search/pkg/http/usecases"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"go.uber.org/zap"
+)
+
+type searchBatchSearchService struct {
+	SearchService
+}
+
+func (s searchBatchSearchService) SearchBatch(ctx context.Context, next func() (datastructure.SearchBatchQuery, bool, error),
+	emit func(datastructure.SearchBatchResult) error) error {
+	for i := 0; ; i++ {
+		query, ok, err := next()
+		if !ok {
+			return err
+		}
+		result := datastructure.SearchBatchResult{Index: i, Query: query, Err: err}
+		if err == nil {
+			result.Matches = []datastructure.SearchMatch{{Data: datastructure.Node{Name: query.Query}, Confidence: 1, Dist: 1.5}}
+		}
+		if err := emit(result); err != nil {
+			return err
+		}
+	}
+}
+
+func TestSearchBatch(t *testing.T) {
+	api := New(searchBatchSearchService{}, nil, nil, zap.NewNop())
+
+	post := func(body, contentType string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/search/batch", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		api.searchBatch(w, r, nil)
+		return w
+	}
+
+	w := post(`[{"id": "a", "query": "monas", "lat": -6.17, "lon": 106.82}, {"query": "ancol"}, {"query": ""},
+		{"query": "monas", "lat": -6.17}, {"query": "monas", "radius": 2}]`, "application/json")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var res struct {
+		Data []searchBatchItem `json:"data"`
+	}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &res))
+	if assert.Len(t, res.Data, 5) {
+		assert.Equal(t, "a", res.Data[0].ID)
+		assert.Equal(t, http.StatusOK, res.Data[0].Status)
+		assert.Equal(t, "monas", res.Data[0].Results[0].Data.Name)
+		if assert.NotNil(t, res.Data[0].Results[0].Dist) {
+			assert.Equal(t, 1.5, *res.Data[0].Results[0].Dist)
+		}
+		assert.Nil(t, res.Data[1].Results[0].Dist)
+		for i := 2; i < 5; i++ {
+			assert.Equal(t, i, res.Data[i].Index)
+			assert.Equal(t, http.StatusBadRequest, res.Data[i].Status)
+		}
+	}
+
+	w = post("{\"query\": \"monas\"}\n\n{\"query\": \n{\"query\": \"ancol\", \"top_k\": 3}\n", "application/x-ndjson")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	items := []searchBatchItem{}
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var item searchBatchItem
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &item))
+		items = append(items, item)
+	}
+	if assert.Len(t, items, 3) {
+		assert.Equal(t, http.StatusOK, items[0].Status)
+		assert.Equal(t, http.StatusBadRequest, items[1].Status)
+		assert.Equal(t, "ancol", items[2].Results[0].Data.Name)
+	}
+
+	// baris lebih dari maxSearchBatchLineSize: stream berhenti dengan baris error, bukan selesai seperti sukses.
+	w = post("{\"query\": \"monas\"}\n{\"query\": \""+strings.Repeat("a", maxSearchBatchLineSize)+"\"}\n{\"query\": \"ancol\"}\n",
+		"application/x-ndjson")
+	assert.Equal(t, http.StatusOK, w.Code)
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if assert.Len(t, lines, 2) {
+		var last map[string]string
+		assert.Nil(t, json.Unmarshal([]byte(lines[1]), &last))
+		assert.Contains(t, last["error"], "ndjson line longer than")
+	}
+
+	assert.Equal(t, http.StatusBadRequest, post(`[]`, "application/json").Code)
+	assert.Equal(t, http.StatusBadRequest, post(`{"query": "monas"}`, "application/json").Code)
+}
+
+type searchBatchSearcher struct {
+	usecases.Searcher
+}
+
+func (searchBatchSearcher) SearchWithConfidence(query datastructure.SearchBatchQuery) ([]datastructure.SearchMatch, error) {
+	return []datastructure.SearchMatch{{Data: datastructure.Node{Name: query.Query}, Confidence: 1}}, nil
+}
+
+// failingResponseWriter. write gagal setelah failAfter kali, seperti client yang sudah putus.
+type failingResponseWriter struct {
+	header    http.Header
+	writes    int
+	failAfter int
+}
+
+func (w *failingResponseWriter) Header() http.Header { return w.header }
+
+func (w *failingResponseWriter) WriteHeader(int) {}
+
+func (w *failingResponseWriter) Write(p []byte) (int, error) {
+	if w.writes >= w.failAfter {
+		return 0, errors.New("connection reset by peer")
+	}
+	w.writes++
+	return len(p), nil
+}
+
+func TestSearchBatchNDJSONEmitErrorNoGoroutineLeak(t *testing.T) {
+	ignoreCurrent := goleak.IgnoreCurrent()
+	api := New(usecases.New(zap.NewNop(), searchBatchSearcher{}), nil, nil, zap.NewNop())
+
+	// body tidak pernah selesai: tanpa menutup body, goroutine yang membaca query block selamanya.
+	body, bodyWriter := io.Pipe()
+	go bodyWriter.Write([]byte("{\"query\": \"monas\"}\n{\"query\": \"ancol\"}\n"))
+
+	r := httptest.NewRequest(http.MethodPost, "/api/search/batch", body)
+	r.Header.Set("Content-Type", "application/x-ndjson")
+	w := &failingResponseWriter{header: make(http.Header), failAfter: 1}
+	api.searchBatch(w, r, nil)
+
+	assert.Equal(t, 1, w.writes)
+	goleak.VerifyNone(t, ignoreCurrent)
+	bodyWriter.Close()
+}
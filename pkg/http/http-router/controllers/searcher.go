@@ -5,11 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/lintang-b-s/osm-search/pkg"
 	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/lintang-b-s/osm-search/pkg/geo"
 	"github.com/lintang-b-s/osm-search/pkg/geofence"
 	helper "github.com/lintang-b-s/osm-search/pkg/http/http-router/router-helper"
 
@@ -24,36 +27,48 @@ import (
 
 var (
 	regexSearch     = regexp.MustCompile("^[A-Za-z0-9_ +,.()'-]+$")
-	regexOSMFeature = regexp.MustCompile("^[a-zA-Z0-9_:=]+$")
+	regexOSMFeature = regexp.MustCompile("^[a-zA-Z0-9_:=*()|&!, .-]+$")
 	regexFenceName  = regexp.MustCompile("^[A-Za-z0-9_]+$")
+	regexSource     = regexp.MustCompile("^[A-Za-z0-9_-]*$")
 )
 
 type searchAPI struct {
 	searchService   SearchService
 	geofenceService GeofenceService
+	documentService DocumentService
 	log             *zap.Logger
 }
 
-func New(searchService SearchService, geofenceService GeofenceService, log *zap.Logger) *searchAPI {
+func New(searchService SearchService, geofenceService GeofenceService, documentService DocumentService, log *zap.Logger) *searchAPI {
 	return &searchAPI{
 		searchService:   searchService,
 		log:             log,
 		geofenceService: geofenceService,
+		documentService: documentService,
 	}
 
 }
 
 func (api *searchAPI) Routes(group *helper.RouteGroup) {
 	group.GET("/search", api.search)
+	group.POST("/search/batch", api.searchBatch)
 	group.GET("/autocomplete", api.autocomplete)
 	group.GET("/reverse", api.reverseGeocoding)
+	group.POST("/reverse/batch", api.reverseGeocodingBatch)
+	group.GET("/intersection", api.intersection)
+	group.GET("/snap", api.snapToRoad)
+	group.POST("/snap/trace", api.matchTrace)
 	group.GET("/places", api.nearbyPlaces)
+	group.GET("/lookup", api.lookup)
 	// geofences
 	group.POST("/geofence", api.addGeofence)
 	group.DELETE("/geofence/:fencename", api.deleteGeofence)
 	group.PUT("/geofence/:fencename/point", api.setQueryPoint)
 	group.GET("/geofence/:fencename", api.searchFence)
 	group.PUT("/geofence/:fencename", api.addFencePoint)
+	// custom documents
+	group.POST("/documents", api.addDocuments)
+	group.DELETE("/documents/:id", api.deleteDocument)
 }
 
 type errorResponse struct {
@@ -72,6 +87,7 @@ type searchRequest struct {
 	Offset int     `json:"offset" validate:"min=0"`                  // offset for pagination
 	Lat    float64 `json:"lat" validate:"required,min=-90,max=90"`   // latitude of the user.
 	Lon    float64 `json:"lon" validate:"required,min=-180,max=180"` // longitude of the user.
+	Source string  `json:"source" validate:"max=64"`                 // optional. only return documents from this source (osm or source of custom documents).
 }
 
 // searchResponse model info
@@ -94,12 +110,35 @@ func NewSearchResponse(data []datastructure.Node, dists []float64) []searchRespo
 	return response
 }
 
+// parseGeometryOption. query param geometry=true untuk menambahkan geometry geojson & bbox setiap osm object di response.
+// tolerance (derajat, default 0) untuk simplifikasi geometry.
+func parseGeometryOption(query url.Values) (bool, float64, error) {
+	if query.Get("geometry") == "" {
+		return false, 0, nil
+	}
+	withGeometry, err := strconv.ParseBool(query.Get("geometry"))
+	if err != nil {
+		return false, 0, errors.New("geometry must be a boolean")
+	}
+	tolerance := 0.0
+	if query.Get("tolerance") != "" {
+		tolerance, err = strconv.ParseFloat(query.Get("tolerance"), 64)
+		if err != nil || tolerance < 0 || tolerance > 1 {
+			return false, 0, errors.New("tolerance must be a float between 0 and 1")
+		}
+	}
+	return withGeometry, tolerance, nil
+}
+
 // search godoc
 // @Summary		search operation to find osm objects relevant to the query given by the user. Support spelling correction.
 // @Description	search operation to find osm objects relevant to the query given by the user. Support spelling correction.
 // @Tags			search
 // @ID search
 // @Param			body	body	searchRequest	true
+// @Param        geometry	query	bool	false	"return geojson geometry & bbox of each osm object"
+// @Param        tolerance	query	float	false	"geometry simplification tolerance in degrees"
+// @Param        format	query	string	false	"response format: json (default) or geojson (FeatureCollection)"
 // @Accept			application/json
 // @Produce		application/json
 // @Router			/api/search [get]
@@ -113,6 +152,7 @@ func (api *searchAPI) search(w http.ResponseWriter, r *http.Request, p httproute
 	)
 	query := r.URL.Query()
 	request.Query = query.Get("query")
+	request.Source = query.Get("source")
 
 	request.TopK, err = strconv.Atoi(query.Get("top_k"))
 	if err != nil {
@@ -155,23 +195,36 @@ func (api *searchAPI) search(w http.ResponseWriter, r *http.Request, p httproute
 		api.BadRequestResponse(w, r, fmt.Errorf("validation error: "+"query must be alphanumeric or contain special characters: +, ., (, ), ,"))
 		return
 	}
+	if !regexSource.MatchString(request.Source) {
+		api.BadRequestResponse(w, r, fmt.Errorf("validation error: "+"source must be alphanumeric or contain special characters: _, -"))
+		return
+	}
+	withGeometry, tolerance, err := parseGeometryOption(query)
+	if err != nil {
+		api.BadRequestResponse(w, r, err)
+		return
+	}
+	format, err := parseFormat(query)
+	if err != nil {
+		api.BadRequestResponse(w, r, err)
+		return
+	}
 
-	results, err := api.searchService.Search(request.Query, request.TopK, request.Offset)
+	results, err := api.searchService.Search(request.Query, request.Source, request.TopK, request.Offset)
 	if err != nil {
 		api.ServerErrorResponse(w, r, err)
 		return
 	}
-
-	headers := make(http.Header)
+	if withGeometry {
+		results = api.searchService.WithGeometry(results, tolerance)
+	}
 
 	dists := make([]float64, len(results))
 	for i, r := range results {
 		dists[i] = datastructure.HaversineDistance(request.Lat, request.Lon, r.Lat, r.Lon)
 	}
 
-	if err := api.writeJSON(w, http.StatusOK, envelope{"data": NewSearchResponse(results, dists)}, headers); err != nil {
-		api.ServerErrorResponse(w, r, err)
-	}
+	api.writeResults(w, r, format, NewSearchResponse(results, dists), results, dists)
 }
 
 // autocomplete godoc
@@ -180,6 +233,9 @@ func (api *searchAPI) search(w http.ResponseWriter, r *http.Request, p httproute
 // @Tags			search
 // @ID autocomplete
 // @Param			body	body	searchRequest	true
+// @Param        geometry	query	bool	false	"return geojson geometry & bbox of each osm object"
+// @Param        tolerance	query	float	false	"geometry simplification tolerance in degrees"
+// @Param        format	query	string	false	"response format: json (default) or geojson (FeatureCollection)"
 // @Accept			application/json
 // @Produce		application/json
 // @Router			/api/autocomplete [get]
@@ -193,6 +249,7 @@ func (api *searchAPI) autocomplete(w http.ResponseWriter, r *http.Request, _ htt
 	)
 	query := r.URL.Query()
 	request.Query = query.Get("query")
+	request.Source = query.Get("source")
 
 	request.TopK, err = strconv.Atoi(query.Get("top_k"))
 	if err != nil {
@@ -234,54 +291,85 @@ func (api *searchAPI) autocomplete(w http.ResponseWriter, r *http.Request, _ htt
 		api.BadRequestResponse(w, r, fmt.Errorf("validation error: "+"query must be alphanumeric or contain special characters: +, ., (, ), ,"))
 		return
 	}
+	if !regexSource.MatchString(request.Source) {
+		api.BadRequestResponse(w, r, fmt.Errorf("validation error: "+"source must be alphanumeric or contain special characters: _, -"))
+		return
+	}
+	withGeometry, tolerance, err := parseGeometryOption(query)
+	if err != nil {
+		api.BadRequestResponse(w, r, err)
+		return
+	}
+	format, err := parseFormat(query)
+	if err != nil {
+		api.BadRequestResponse(w, r, err)
+		return
+	}
 
-	results, err := api.searchService.Autocomplete(request.Query, request.TopK, request.Offset)
+	results, err := api.searchService.Autocomplete(request.Query, request.Source, request.TopK, request.Offset)
 	if err != nil {
 		api.ServerErrorResponse(w, r, err)
 		return
 	}
-
-	headers := make(http.Header)
+	if withGeometry {
+		results = api.searchService.WithGeometry(results, tolerance)
+	}
 
 	dists := make([]float64, len(results))
 	for i, r := range results {
 		dists[i] = datastructure.HaversineDistance(request.Lat, request.Lon, r.Lat, r.Lon)
 	}
 
-	if err := api.writeJSON(w, http.StatusOK, envelope{"data": NewSearchResponse(results, dists)}, headers); err != nil {
-		api.ServerErrorResponse(w, r, err)
-	}
+	api.writeResults(w, r, format, NewSearchResponse(results, dists), results, dists)
 }
 
 type reverseGeocodingRequest struct {
-	Lat float64 `json:"lat" validate:"required,min=-90,max=90"`
-	Lon float64 `json:"lon" validate:"required,min=-180,max=180"`
+	Lat   float64 `json:"lat" validate:"required,min=-90,max=90"`
+	Lon   float64 `json:"lon" validate:"required,min=-180,max=180"`
+	Layer string  `json:"layer" validate:"omitempty,oneof=building street village district province"`
 }
 
 type reverseGeocodingResponse struct {
-	Data datastructure.Node `json:"data"`
-	Dist float64            `json:"dist"`
+	Data        datastructure.Node              `json:"data"`
+	Dist        float64                         `json:"dist"`
+	Match       string                          `json:"match"`
+	Street      *datastructure.NearbyObject     `json:"street,omitempty"`
+	CrossStreet *datastructure.CrossStreet      `json:"cross_street,omitempty"`
+	HouseNumber *datastructure.HouseNumberMatch `json:"house_number,omitempty"`
+	POI         *datastructure.NearbyObject     `json:"poi,omitempty"`
+	Admin       datastructure.AdminAddress      `json:"admin"`
 }
 
-func NewReverseGeocodingResponse(data datastructure.Node, dist float64) reverseGeocodingResponse {
+func NewReverseGeocodingResponse(result datastructure.ReverseGeocodingResult) reverseGeocodingResponse {
 	return reverseGeocodingResponse{
-		Data: data,
-		Dist: dist,
+		Data:        result.Place,
+		Dist:        result.Dist,
+		Match:       result.Match,
+		Street:      result.Street,
+		CrossStreet: result.CrossStreet,
+		HouseNumber: result.HouseNumber,
+		POI:         result.POI,
+		Admin:       result.Admin,
 	}
 }
 
 // reverseGeocoding godoc
 // @Summary		reverseGeocoding operation allows users to get nearest osm objects based on the latitude and longitude given by the user.
-// @Description	reverseGeocoding operation allows users to get nearest osm objects based on the latitude and longitude given by the user.
+// @Description	reverseGeocoding returns the nearest osm object plus a structured address: nearest street, house number, nearest poi (with distances in km) and the administrative hierarchy of the boundary containing the point. if the point is inside an area (mall, campus, park, ...), data is the smallest area containing the point, poi is the nearest poi inside it and match is inside (otherwise nearby).
 // @Tags			search
 // @ID reverse-geocoding
 // @Param        lat	query	float	true	"Latitude"
 // @Param        lon	query	float	true	"Longitude"
+// @Param        layer	query	string	false	"building (default), street, village, district or province"
+// @Param        geometry	query	bool	false	"return geojson geometry & bbox of each osm object"
+// @Param        tolerance	query	float	false	"geometry simplification tolerance in degrees"
+// @Param        format	query	string	false	"response format: json (default) or geojson (FeatureCollection)"
 // @Accept			application/json
 // @Produce		application/json
 // @Router			/api/reverse [get]
 // @Success		200	{object}	reverseGeocodingResponse
 // @Failure		400	{object}	errorResponse
+// @Failure		404	{object}	errorResponse
 // @Failure		500	{object}	errorResponse
 func (api *searchAPI) reverseGeocoding(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 
@@ -302,8 +390,19 @@ func (api *searchAPI) reverseGeocoding(w http.ResponseWriter, r *http.Request, _
 		return
 	}
 	request := reverseGeocodingRequest{
-		Lat: lat,
-		Lon: lon,
+		Lat:   lat,
+		Lon:   lon,
+		Layer: query.Get("layer"),
+	}
+	withGeometry, tolerance, err := parseGeometryOption(query)
+	if err != nil {
+		api.BadRequestResponse(w, r, err)
+		return
+	}
+	format, err := parseFormat(query)
+	if err != nil {
+		api.BadRequestResponse(w, r, err)
+		return
 	}
 
 	validate := validator.New()
@@ -321,25 +420,23 @@ func (api *searchAPI) reverseGeocoding(w http.ResponseWriter, r *http.Request, _
 		return
 	}
 
-	result, err := api.searchService.ReverseGeocoding(request.Lat, request.Lon)
+	result, err := api.searchService.ReverseGeocoding(request.Lat, request.Lon, request.Layer)
 	if err != nil {
-		api.ServerErrorResponse(w, r, err)
+		api.getStatusCode(w, r, err)
 		return
 	}
-
-	headers := make(http.Header)
-
-	if err := api.writeJSON(w, http.StatusOK, envelope{"data": NewReverseGeocodingResponse(result, datastructure.HaversineDistance(
-		request.Lat, request.Lon, result.Lat, result.Lon,
-	))}, headers); err != nil {
-		api.ServerErrorResponse(w, r, err)
+	if withGeometry {
+		result.Place = api.searchService.WithGeometry([]datastructure.Node{result.Place}, tolerance)[0]
 	}
+
+	api.writeResults(w, r, format, NewReverseGeocodingResponse(result), []datastructure.Node{result.Place}, []float64{result.Dist})
 }
 
 type nearbyPlacesRequest struct {
 	Lat     float64 `json:"lat" validate:"required,min=-90,max=90"`
 	Lon     float64 `json:"lon" validate:"required,min=-180,max=180"`
-	Feature string  `json:"feature" validate:"required"`
+	Feature string  `json:"feature"`
+	Query   string  `json:"query"`
 	Radius  float64 `json:"radius" validate:"max=1000"`
 	K       int     `json:"k" validate:"required,min=1,max=100"`
 	Offset  int     `json:"offset" validate:"min=0"`
@@ -352,10 +449,14 @@ type nearbyPlacesRequest struct {
 // @ID nearest-places
 // @Param        lat	query	float	true	"Latitude"
 // @Param        lon	query	float	true	"Longitude"
-// @Param        feature	query	string	true	"Feature"
+// @Param        feature	query	string	false	"osm feature expression, e.g. amenity=restaurant, amenity=* or (amenity=restaurant OR amenity=cafe) AND NOT cuisine=fast_food"
+// @Param        query	query	string	false	"only return osm objects whose name or address contains all words of the query (with spell correction), e.g. alfamart. feature or query must be provided"
 // @Param        radius	float	false	"Radius"
 // @Param        k	query	int	true	"total nearest places"
 // @Param        offset	query	int	false	"offset"
+// @Param        geometry	query	bool	false	"return geojson geometry & bbox of each osm object"
+// @Param        tolerance	query	float	false	"geometry simplification tolerance in degrees"
+// @Param        format	query	string	false	"response format: json (default) or geojson (FeatureCollection)"
 // @Accept			application/json
 // @Produce		application/json
 // @Router			/api/places [get]
@@ -365,8 +466,8 @@ type nearbyPlacesRequest struct {
 func (api *searchAPI) nearbyPlaces(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 
 	query := r.URL.Query()
-	if query.Get("lat") == "" || query.Get("lon") == "" || query.Get("feature") == "" || query.Get("k") == "" {
-		api.BadRequestResponse(w, r, errors.New("lat, lon, feature, and k must be provided"))
+	if query.Get("lat") == "" || query.Get("lon") == "" || (query.Get("feature") == "" && query.Get("query") == "") || query.Get("k") == "" {
+		api.BadRequestResponse(w, r, errors.New("lat, lon, feature or query, and k must be provided"))
 		return
 	}
 
@@ -382,8 +483,23 @@ func (api *searchAPI) nearbyPlaces(w http.ResponseWriter, r *http.Request, _ htt
 	}
 
 	feature := query.Get("feature")
-	if !regexOSMFeature.MatchString(feature) {
-		api.BadRequestResponse(w, r, fmt.Errorf("validation error: "+"feature must be alphanumeric or contain special characters: =, :"))
+	if feature != "" && !regexOSMFeature.MatchString(feature) {
+		api.BadRequestResponse(w, r, fmt.Errorf("validation error: "+"feature must be alphanumeric or contain special characters: =, :, *, (, ), |, &, !, ,, ., -"))
+		return
+	}
+	textQuery := query.Get("query")
+	if textQuery != "" && !regexSearch.MatchString(textQuery) {
+		api.BadRequestResponse(w, r, fmt.Errorf("validation error: "+"query must be alphanumeric or contain special characters: +, ., (, ), ,"))
+		return
+	}
+	withGeometry, tolerance, err := parseGeometryOption(query)
+	if err != nil {
+		api.BadRequestResponse(w, r, err)
+		return
+	}
+	format, err := parseFormat(query)
+	if err != nil {
+		api.BadRequestResponse(w, r, err)
 		return
 	}
 
@@ -417,6 +533,7 @@ func (api *searchAPI) nearbyPlaces(w http.ResponseWriter, r *http.Request, _ htt
 		Lat:     lat,
 		Lon:     lon,
 		Feature: feature,
+		Query:   textQuery,
 		Radius:  radius,
 		K:       k,
 		Offset:  offset,
@@ -438,22 +555,84 @@ func (api *searchAPI) nearbyPlaces(w http.ResponseWriter, r *http.Request, _ htt
 	}
 
 	results, err := api.searchService.NearestNeighboursRadiusWithFeatureFilter(request.K, request.Offset, request.Lat, request.Lon,
-		request.Radius, request.Feature)
+		request.Radius, request.Feature, request.Query)
 	if err != nil {
-		api.ServerErrorResponse(w, r, err)
+		api.getStatusCode(w, r, err)
 		return
 	}
-
-	headers := make(http.Header)
+	if withGeometry {
+		results = api.searchService.WithGeometry(results, tolerance)
+	}
 
 	dists := make([]float64, len(results))
 	for i, r := range results {
 		dists[i] = datastructure.HaversineDistance(request.Lat, request.Lon, r.Lat, r.Lon)
 	}
 
-	if err := api.writeJSON(w, http.StatusOK, envelope{"data": NewSearchResponse(results, dists)}, headers); err != nil {
-		api.ServerErrorResponse(w, r, err)
+	api.writeResults(w, r, format, NewSearchResponse(results, dists), results, dists)
+}
+
+const maxLookupOSMIDs = 50
+
+// lookup godoc
+// @Summary		lookup operation allows users to get indexed osm objects by their osm ids.
+// @Description	lookup operation allows users to get indexed osm objects by their osm ids (N for node, W for way, R for relation). osm ids that are not indexed are skipped.
+// @Tags			search
+// @ID lookup
+// @Param        osm_ids	query	string	true	"comma separated osm ids, e.g. W123,N456,R789 (max 50)"
+// @Param        geometry	query	bool	false	"return geojson geometry & bbox of each osm object"
+// @Param        tolerance	query	float	false	"geometry simplification tolerance in degrees"
+// @Param        format	query	string	false	"response format: json (default) or geojson (FeatureCollection)"
+// @Accept			application/json
+// @Produce		application/json
+// @Router			/api/lookup [get]
+// @Success		200	{object}	[]datastructure.Node
+// @Failure		400	{object}	errorResponse
+// @Failure		500	{object}	errorResponse
+func (api *searchAPI) lookup(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+
+	query := r.URL.Query()
+	if query.Get("osm_ids") == "" {
+		api.BadRequestResponse(w, r, errors.New("osm_ids must be provided"))
+		return
+	}
+
+	osmIDs := strings.Split(query.Get("osm_ids"), ",")
+	if len(osmIDs) > maxLookupOSMIDs {
+		api.BadRequestResponse(w, r, fmt.Errorf("validation error: maximum %d osm_ids", maxLookupOSMIDs))
+		return
+	}
+	withGeometry, tolerance, err := parseGeometryOption(query)
+	if err != nil {
+		api.BadRequestResponse(w, r, err)
+		return
+	}
+	format, err := parseFormat(query)
+	if err != nil {
+		api.BadRequestResponse(w, r, err)
+		return
 	}
+
+	osmKeys := make([]string, 0, len(osmIDs))
+	for _, osmID := range osmIDs {
+		osmKey, err := geo.ParseOSMID(strings.TrimSpace(osmID))
+		if err != nil {
+			api.BadRequestResponse(w, r, err)
+			return
+		}
+		osmKeys = append(osmKeys, osmKey)
+	}
+
+	results, err := api.searchService.Lookup(osmKeys)
+	if err != nil {
+		api.getStatusCode(w, r, err)
+		return
+	}
+	if withGeometry {
+		results = api.searchService.WithGeometry(results, tolerance)
+	}
+
+	api.writeResults(w, r, format, results, results, nil)
 }
 
 type addGeofenceRequest struct {
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	maxTraceBodySize = 1 << 20
+	maxTracePoints   = 2000
+)
+
+// snapToRoad godoc
+// @Summary		snap a gps point to the nearest road.
+// @Description	returns the nearest road segment within radius (km, default 0.05, max 1), the point projected onto it (lat, lon), the road name & class (highway tag) and the distance in km.
+// @Tags			search
+// @ID snap-to-road
+// @Param        lat	query	float	true	"Latitude"
+// @Param        lon	query	float	true	"Longitude"
+// @Param        radius	query	float	false	"search radius in km"
+// @Produce		application/json
+// @Router			/api/snap [get]
+// @Success		200	{object}	datastructure.RoadMatch
+// @Failure		400	{object}	errorResponse
+// @Failure		404	{object}	errorResponse
+// @Failure		500	{object}	errorResponse
+func (api *searchAPI) snapToRoad(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	query := r.URL.Query()
+	if query.Get("lat") == "" || query.Get("lon") == "" {
+		api.BadRequestResponse(w, r, errors.New("lat and lon must be provided"))
+		return
+	}
+	lat, err := strconv.ParseFloat(query.Get("lat"), 64)
+	if err != nil {
+		api.BadRequestResponse(w, r, err)
+		return
+	}
+	lon, err := strconv.ParseFloat(query.Get("lon"), 64)
+	if err != nil {
+		api.BadRequestResponse(w, r, err)
+		return
+	}
+	radius := 0.0
+	if query.Get("radius") != "" {
+		radius, err = strconv.ParseFloat(query.Get("radius"), 64)
+		if err != nil {
+			api.BadRequestResponse(w, r, err)
+			return
+		}
+	}
+	point := reverseBatchPoint{Lat: &lat, Lon: &lon}
+	if err := point.validate(); err != nil {
+		api.BadRequestResponse(w, r, err)
+		return
+	}
+
+	match, err := api.searchService.SnapToRoad(lat, lon, radius)
+	if err != nil {
+		api.getStatusCode(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	if err := api.writeJSON(w, http.StatusOK, envelope{"data": match}, headers); err != nil {
+		api.ServerErrorResponse(w, r, err)
+	}
+}
+
+// matchTraceRequest model info
+//
+//	@Description	gps trace, points in the order they were recorded. radius (km, default 0.05, max 1) is the search radius of the roads of each point.
+type matchTraceRequest struct {
+	Points []reverseBatchPoint `json:"points"`
+	Radius float64             `json:"radius"`
+}
+
+// matchTraceResponse model info
+//
+//	@Description	points = each trace point snapped to its matched road (null if no road was found near the point). roads = sequence of roads traversed by the trace, including the roads connecting two consecutive points.
+type matchTraceResponse struct {
+	Points []*datastructure.RoadMatch  `json:"points"`
+	Roads  []datastructure.MatchedRoad `json:"roads"`
+}
+
+// matchTrace godoc
+// @Summary		map match a gps trace to the road network.
+// @Description	map matching with a hidden markov model (viterbi): the snapped roads of consecutive points must be connected by a route of similar length to the distance between the points. road direction (oneway) is not considered.
+// @Tags			search
+// @ID match-trace
+// @Param			body	body	matchTraceRequest	true
+// @Accept			application/json
+// @Produce		application/json
+// @Router			/api/snap/trace [post]
+// @Success		200	{object}	matchTraceResponse
+// @Failure		400	{object}	errorResponse
+// @Failure		404	{object}	errorResponse
+// @Failure		500	{object}	errorResponse
+func (api *searchAPI) matchTrace(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxTraceBodySize)
+	var request matchTraceRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		api.BadRequestResponse(w, r, err)
+		return
+	}
+	if err := r.Body.Close(); err != nil {
+		api.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if len(request.Points) == 0 {
+		api.BadRequestResponse(w, r, errors.New("validation error: at least one point must be provided"))
+		return
+	}
+	if len(request.Points) > maxTracePoints {
+		api.BadRequestResponse(w, r, fmt.Errorf("validation error: maximum %d points per trace", maxTracePoints))
+		return
+	}
+	points := make([]datastructure.Point, len(request.Points))
+	for i, point := range request.Points {
+		if err := point.validate(); err != nil {
+			api.BadRequestResponse(w, r, fmt.Errorf("validation error: point %d: %w", i, err))
+			return
+		}
+		points[i] = datastructure.NewPoint(*point.Lat, *point.Lon)
+	}
+
+	result, err := api.searchService.MatchTrace(points, request.Radius)
+	if err != nil {
+		api.getStatusCode(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	if err := api.writeJSON(w, http.StatusOK, envelope{"data": matchTraceResponse{Points: result.Points, Roads: result.Roads}},
+		headers); err != nil {
+		api.ServerErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type snapSearchService struct {
+	SearchService
+}
+
+func (s snapSearchService) SnapToRoad(lat, lon, radius float64) (datastructure.RoadMatch, error) {
+	return datastructure.RoadMatch{WayID: 1, Lat: lat, Lon: lon, Dist: radius}, nil
+}
+
+func (s snapSearchService) MatchTrace(points []datastructure.Point, radius float64) (datastructure.TraceMatch, error) {
+	result := datastructure.TraceMatch{Points: make([]*datastructure.RoadMatch, len(points)),
+		Roads: []datastructure.MatchedRoad{{WayID: 1, Name: "Jalan A"}}}
+	for i, point := range points[:len(points)-1] {
+		result.Points[i] = &datastructure.RoadMatch{WayID: 1, Lat: point.Lat, Lon: point.Lon}
+	}
+	return result, nil
+}
+
+func TestSnapToRoad(t *testing.T) {
+	api := New(snapSearchService{}, nil, nil, zap.NewNop())
+
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		api.snapToRoad(w, httptest.NewRequest(http.MethodGet, "/api/snap?"+query, nil), nil)
+		return w
+	}
+	w := get("lat=-6.1&lon=106.8&radius=0.1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var res struct {
+		Data datastructure.RoadMatch `json:"data"`
+	}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, datastructure.RoadMatch{WayID: 1, Lat: -6.1, Lon: 106.8, Dist: 0.1}, res.Data)
+	assert.Equal(t, http.StatusBadRequest, get("lat=-6.1").Code)
+	assert.Equal(t, http.StatusBadRequest, get("lat=-100&lon=106.8").Code)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		api.matchTrace(w, httptest.NewRequest(http.MethodPost, "/api/snap/trace", strings.NewReader(body)), nil)
+		return w
+	}
+	w = post(`{"points": [{"lat": -6.1, "lon": 106.8}, {"lat": -6.2, "lon": 106.9}], "radius": 0.05}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var traceRes struct {
+		Data matchTraceResponse `json:"data"`
+	}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &traceRes))
+	if assert.Len(t, traceRes.Data.Points, 2) {
+		assert.Equal(t, -6.1, traceRes.Data.Points[0].Lat)
+		assert.Nil(t, traceRes.Data.Points[1])
+	}
+	assert.Len(t, traceRes.Data.Roads, 1)
+
+	assert.Equal(t, http.StatusBadRequest, post(`{"points": []}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(`{"points": [{"lat": -6.1}]}`).Code)
+}
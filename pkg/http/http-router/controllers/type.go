@@ -1,16 +1,34 @@
 package controllers
 
 import (
+	"context"
+
 	"github.com/lintang-b-s/osm-search/pkg/datastructure"
 	"github.com/lintang-b-s/osm-search/pkg/geofence"
+	"github.com/lintang-b-s/osm-search/pkg/updater"
 )
 
 type SearchService interface {
-	Search(query string, k int, offset int) ([]datastructure.Node, error)
-	Autocomplete(query string, k, offset int) ([]datastructure.Node, error)
-	ReverseGeocoding(lat, lon float64) (datastructure.Node, error)
+	Search(query, source string, k int, offset int) ([]datastructure.Node, error)
+	SearchInBox(query, source string, k, offset int, minLat, minLon, maxLat, maxLon float64) ([]datastructure.Node, error)
+	SearchBatch(ctx context.Context, next func() (datastructure.SearchBatchQuery, bool, error),
+		emit func(datastructure.SearchBatchResult) error) error
+	Autocomplete(query, source string, k, offset int) ([]datastructure.Node, error)
+	AutocompleteNear(query, source string, k int, lat, lon float64) ([]datastructure.Node, error)
+	ReverseGeocoding(lat, lon float64, layer string) (datastructure.ReverseGeocodingResult, error)
+	ReverseGeocodingBatch(points []datastructure.Point, layer string) []datastructure.ReverseGeocodingBatchResult
+	SnapToRoad(lat, lon, radius float64) (datastructure.RoadMatch, error)
+	Intersections(street1, street2 string) ([]datastructure.Intersection, error)
+	MatchTrace(points []datastructure.Point, radius float64) (datastructure.TraceMatch, error)
 	NearestNeighboursRadiusWithFeatureFilter(k, offset int, lat, lon, radius float64,
-		featureType string) ([]datastructure.Node, error)
+		featureType, query string) ([]datastructure.Node, error)
+	Lookup(osmKeys []string) ([]datastructure.Node, error)
+	WithGeometry(docs []datastructure.Node, tolerance float64) []datastructure.Node
+}
+
+type DocumentService interface {
+	AddDocuments(docs []updater.CustomDocument) ([]datastructure.Node, error)
+	DeleteDocument(docID int) error
 }
 
 type GeofenceService interface {
@@ -50,6 +50,7 @@ func (api *API) Run(
 
 	searchService controllers.SearchService,
 	geofenceService controllers.GeofenceService,
+	documentService controllers.DocumentService,
 	useRateLimit bool,
 ) error {
 	log.Info("Run httprouter API")
@@ -72,10 +73,18 @@ func (api *API) Run(
 
 	group := router_helper.NewRouteGroup(router, "/api")
 
-	searcherRoutes := controllers.New(searchService, geofenceService, log)
+	searcherRoutes := controllers.New(searchService, geofenceService, documentService, log)
 
 	searcherRoutes.Routes(group)
 
+	// api yang kompatibel dengan nominatim: /search, /reverse, /lookup
+	searcherRoutes.NominatimRoutes(router_helper.NewRouteGroup(router, "/"))
+
+	if config.PhotonAPI {
+		// api autocomplete yang kompatibel dengan photon: /api?q=...
+		searcherRoutes.PhotonRoutes(router_helper.NewRouteGroup(router, "/"))
+	}
+
 	var mwChain []alice.Constructor
 	if useRateLimit {
 		mwChain = append(mwChain, corsHandler.Handler, EnforceJSONHandler, api.recoverPanic,
@@ -26,6 +26,7 @@ func (s *Server) Use(
 
 	searchService controllers.SearchService,
 	geofenceService controllers.GeofenceService,
+	documentService controllers.DocumentService,
 	useRateLimit bool,
 
 ) (*Server, error) {
@@ -33,9 +34,12 @@ func (s *Server) Use(
 
 	viper.SetDefault("API_TIMEOUT", "1000s")
 
+	viper.SetDefault("API_PHOTON", false)
+
 	config := http_server.Config{
-		Port:    viper.GetInt("API_PORT"),
-		Timeout: viper.GetDuration("API_TIMEOUT"),
+		Port:      viper.GetInt("API_PORT"),
+		Timeout:   viper.GetDuration("API_TIMEOUT"),
+		PhotonAPI: viper.GetBool("API_PHOTON"),
 	}
 
 	server := http_router.NewAPI(log)
@@ -44,7 +48,7 @@ func (s *Server) Use(
 
 	g.Go(func() error {
 		return server.Run(
-			ctx, config, log, searchService, geofenceService,
+			ctx, config, log, searchService, geofenceService, documentService,
 			useRateLimit,
 		)
 	})
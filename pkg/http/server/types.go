@@ -5,6 +5,7 @@ import "time"
 type Config struct {
 	Port int
 	Timeout time.Duration
+	PhotonAPI bool // serve photon compatible autocomplete at /api
 }
 
 type API struct {
@@ -1,8 +1,14 @@
 package usecases
 
 import (
+	"context"
+	"runtime"
+	"sync"
+
+	"github.com/lintang-b-s/osm-search/pkg/concurrent"
 	"github.com/lintang-b-s/osm-search/pkg/datastructure"
 	"github.com/lintang-b-s/osm-search/pkg/geofence"
+	"github.com/lintang-b-s/osm-search/pkg/updater"
 
 	"go.uber.org/zap"
 )
@@ -19,21 +25,136 @@ func New(log *zap.Logger, searcher Searcher) *SearcherService {
 	}
 }
 
-func (s *SearcherService) Search(query string, k, offset int) ([]datastructure.Node, error) {
-	return s.searcher.FreeFormQuery(query, k, offset)
+func (s *SearcherService) Search(query, source string, k, offset int) ([]datastructure.Node, error) {
+	return s.searcher.FreeFormQueryBySource(query, source, k, offset)
+}
+
+// SearchInBox. full text search yang hanya return document di dalam bounding box.
+func (s *SearcherService) SearchInBox(query, source string, k, offset int, minLat, minLon, maxLat, maxLon float64) ([]datastructure.Node, error) {
+	return s.searcher.FreeFormQueryInBox(query, source, k, offset, minLat, minLon, maxLat, maxLon)
+}
+
+// SearchBatch. forward geocoding banyak query secara paralel (maksimal GOMAXPROCS query sekaligus). query dibaca satu per satu
+// dari next sampai ok false, hasil dikirim ke emit urut sesuai urutan query, jadi input & output tidak perlu muat di memory.
+// error dari next dengan ok true (misal baris ndjson tidak valid) jadi hasil query tsb. error dengan ok false (input gagal dibaca)
+// di-return setelah hasil query sebelumnya dikirim. berhenti kalau emit error atau ctx selesai.
+// SearchBatch baru return setelah semua goroutine-nya selesai, jadi next yang block (misal baca body request) harus
+// di-unblock caller saat ctx selesai atau emit error.
+func (s *SearcherService) SearchBatch(ctx context.Context, next func() (datastructure.SearchBatchQuery, bool, error),
+	emit func(datastructure.SearchBatchResult) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	parallelism := runtime.GOMAXPROCS(0)
+	// hasil setiap query di channel sendiri, pending urut sesuai query. kapasitas pending membatasi query yang sedang diproses.
+	pending := make(chan chan datastructure.SearchBatchResult, parallelism)
+	var nextErr error // ditulis sebelum close(pending), dibaca setelah pending selesai.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(pending)
+		for index := 0; ; index++ {
+			query, ok, err := next()
+			if !ok {
+				nextErr = err
+				return
+			}
+			resultC := make(chan datastructure.SearchBatchResult, 1)
+			select {
+			case pending <- resultC:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				resultC <- datastructure.SearchBatchResult{Index: index, Query: query, Err: err}
+				continue
+			}
+			wg.Add(1)
+			go func(index int, query datastructure.SearchBatchQuery) {
+				defer wg.Done()
+				matches, err := s.searcher.SearchWithConfidence(query)
+				resultC <- datastructure.SearchBatchResult{Index: index, Query: query, Matches: matches, Err: err}
+			}(index, query)
+		}
+	}()
+
+	for resultC := range pending {
+		var result datastructure.SearchBatchResult
+		select {
+		case result = <-resultC:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err := emit(result); err != nil {
+			return err
+		}
+	}
+	if nextErr != nil {
+		return nextErr
+	}
+	return ctx.Err()
+}
+
+func (s *SearcherService) Autocomplete(query, source string, k, offset int) ([]datastructure.Node, error) {
+	return s.searcher.AutocompleteBySource(query, source, k, offset)
 }
 
-func (s *SearcherService) Autocomplete(query string, k, offset int) ([]datastructure.Node, error) {
-	return s.searcher.Autocomplete(query, k, offset)
+func (s *SearcherService) AutocompleteNear(query, source string, k int, lat, lon float64) ([]datastructure.Node, error) {
+	return s.searcher.AutocompleteNear(query, source, k, lat, lon)
 }
 
-func (s *SearcherService) ReverseGeocoding(lat, lon float64) (datastructure.Node, error) {
-	return s.searcher.ReverseGeocoding(lat, lon)
+func (s *SearcherService) ReverseGeocoding(lat, lon float64, layer string) (datastructure.ReverseGeocodingResult, error) {
+	return s.searcher.ReverseGeocoding(lat, lon, layer)
+}
+
+func (s *SearcherService) Intersections(street1, street2 string) ([]datastructure.Intersection, error) {
+	return s.searcher.Intersections(street1, street2)
+}
+
+func (s *SearcherService) SnapToRoad(lat, lon, radius float64) (datastructure.RoadMatch, error) {
+	return s.searcher.SnapToRoad(lat, lon, radius)
+}
+
+func (s *SearcherService) MatchTrace(points []datastructure.Point, radius float64) (datastructure.TraceMatch, error) {
+	return s.searcher.MatchTrace(points, radius)
+}
+
+// ReverseGeocodingBatch. reverse geocoding banyak titik secara paralel pakai concurrent.WorkerPool. hasil urut sesuai points,
+// error satu titik disimpan di hasil titik tsb & tidak menggagalkan titik lain.
+func (s *SearcherService) ReverseGeocodingBatch(points []datastructure.Point, layer string) []datastructure.ReverseGeocodingBatchResult {
+	results := make([]datastructure.ReverseGeocodingBatchResult, len(points))
+	if len(points) == 0 {
+		return results
+	}
+
+	workerPool := concurrent.NewWorkerPool[int, int](min(runtime.GOMAXPROCS(0), len(points)), len(points))
+	workerPool.Start(func(i int) int {
+		results[i].Result, results[i].Err = s.searcher.ReverseGeocoding(points[i].Lat, points[i].Lon, layer)
+		return i
+	})
+	for i := range points {
+		workerPool.AddJob(i)
+	}
+	workerPool.Close()
+	workerPool.Wait()
+	return results
 }
 
 func (s *SearcherService) NearestNeighboursRadiusWithFeatureFilter(k, offset int, lat, lon, radius float64,
-	featureType string) ([]datastructure.Node, error) {
-	return s.searcher.NearestNeighboursRadiusWithFeatureFilter(k, offset, lat, lon, radius, featureType)
+	featureType, query string) ([]datastructure.Node, error) {
+	return s.searcher.NearestNeighboursRadiusWithFeatureFilter(k, offset, lat, lon, radius, featureType, query)
+}
+
+func (s *SearcherService) Lookup(osmKeys []string) ([]datastructure.Node, error) {
+	return s.searcher.Lookup(osmKeys)
+}
+
+func (s *SearcherService) WithGeometry(docs []datastructure.Node, tolerance float64) []datastructure.Node {
+	return s.searcher.WithGeometry(docs, tolerance)
 }
 
 type GeofenceService struct {
@@ -66,3 +187,21 @@ func (s *GeofenceService) UpdateFencePoint(name string, lat, lon float64, fenceP
 func (s *GeofenceService) AddFencePoint(name, fencePointName string, lat, lon, radius float64) error {
 	return s.geofenceIndex.AddFencePoint(name, fencePointName, lat, lon, radius)
 }
+
+type DocumentService struct {
+	documentIndex DocumentIndex
+}
+
+func NewDocumentService(documentIndex DocumentIndex) *DocumentService {
+	return &DocumentService{
+		documentIndex: documentIndex,
+	}
+}
+
+func (s *DocumentService) AddDocuments(docs []updater.CustomDocument) ([]datastructure.Node, error) {
+	return s.documentIndex.AddDocuments(docs)
+}
+
+func (s *DocumentService) DeleteDocument(docID int) error {
+	return s.documentIndex.DeleteDocument(docID)
+}
@@ -3,13 +3,27 @@ package usecases
 import (
 	"github.com/lintang-b-s/osm-search/pkg/datastructure"
 	"github.com/lintang-b-s/osm-search/pkg/geofence"
+	"github.com/lintang-b-s/osm-search/pkg/updater"
 )
 
 type Searcher interface {
-	FreeFormQuery(query string, k, offset int) ([]datastructure.Node, error)
-	Autocomplete(query string, k, offset int) ([]datastructure.Node, error)
-	ReverseGeocoding(lat, lon float64) (datastructure.Node, error)
-	NearestNeighboursRadiusWithFeatureFilter(k, offset int, lat, lon, radius float64, featureType string) ([]datastructure.Node, error)
+	FreeFormQueryBySource(query, source string, k, offset int) ([]datastructure.Node, error)
+	FreeFormQueryInBox(query, source string, k, offset int, minLat, minLon, maxLat, maxLon float64) ([]datastructure.Node, error)
+	SearchWithConfidence(query datastructure.SearchBatchQuery) ([]datastructure.SearchMatch, error)
+	AutocompleteBySource(query, source string, k, offset int) ([]datastructure.Node, error)
+	AutocompleteNear(query, source string, k int, lat, lon float64) ([]datastructure.Node, error)
+	ReverseGeocoding(lat, lon float64, layer string) (datastructure.ReverseGeocodingResult, error)
+	SnapToRoad(lat, lon, radius float64) (datastructure.RoadMatch, error)
+	Intersections(street1, street2 string) ([]datastructure.Intersection, error)
+	MatchTrace(points []datastructure.Point, radius float64) (datastructure.TraceMatch, error)
+	NearestNeighboursRadiusWithFeatureFilter(k, offset int, lat, lon, radius float64, featureType, query string) ([]datastructure.Node, error)
+	Lookup(osmKeys []string) ([]datastructure.Node, error)
+	WithGeometry(docs []datastructure.Node, tolerance float64) []datastructure.Node
+}
+
+type DocumentIndex interface {
+	AddDocuments(docs []updater.CustomDocument) ([]datastructure.Node, error)
+	DeleteDocument(docID int) error
 }
 
 type GeofenceIndex interface {
@@ -1,5 +1,6 @@
 package index
 
 const (
-	BATCH_SIZE = 100000
+	BATCH_SIZE       = 100000
+	INDEXING_WORKERS = 8 // jumlah batch osm object yang di-index bersamaan
 )
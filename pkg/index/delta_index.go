@@ -0,0 +1,307 @@
+package index
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/lintang-b-s/osm-search/pkg"
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+
+	"github.com/RadhiFadlillah/go-sastrawi"
+)
+
+// FieldIndex. inverted index untuk satu field yang bisa di-query.
+type FieldIndex interface {
+	Close() error
+	GetPostingList(termID int) ([]int, error)
+	GetFieldLength(docID int) int
+	GetAverageFieldLength() float64
+}
+
+// DeltaIndex. inverted index in-memory kecil untuk document yang ditambahkan/dihapus setelah indexing (dari osmChange).
+// docID baru mulai dari docsCount main index, jadi selalu lebih besar dari docID di main index.
+// document yang dihapus/dimodifikasi ditandai di tombstone bitmap, tidak dihapus dari posting list.
+type DeltaIndex struct {
+	nameField    map[int][]int // termID -> docIDs (sorted)
+	addressField map[int][]int
+	nameLen      map[int]int // docID -> jumlah term di field name
+	addressLen   map[int]int
+	docWordCount map[int]int
+	terms        map[string]struct{}
+	tombstones   *datastructure.Bitmap
+	docsCount    int
+	nextDocID    int
+	termIDMap    *pkg.IDMap
+	lock         sync.RWMutex
+	flushLock    sync.Mutex
+}
+
+func NewDeltaIndex(termIDMap *pkg.IDMap, nextDocID int) *DeltaIndex {
+	return &DeltaIndex{
+		nameField:    make(map[int][]int),
+		addressField: make(map[int][]int),
+		nameLen:      make(map[int]int),
+		addressLen:   make(map[int]int),
+		docWordCount: make(map[int]int),
+		terms:        make(map[string]struct{}),
+		tombstones:   datastructure.NewBitmap(),
+		nextDocID:    nextDocID,
+		termIDMap:    termIDMap,
+	}
+}
+
+// NextDocID. reserve docID baru.
+func (d *DeltaIndex) NextDocID() int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	docID := d.nextDocID
+	d.nextDocID++
+	return docID
+}
+
+// AddDocument. index field name & address dari node.
+func (d *DeltaIndex) AddDocument(node datastructure.Node) {
+	nameWords := sastrawi.Tokenize(node.Name)
+	addressWords := sastrawi.Tokenize(node.Address)
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.addField(d.nameField, d.nameLen, node.ID, nameWords)
+	d.addField(d.addressField, d.addressLen, node.ID, addressWords)
+	d.docWordCount[node.ID] = len(nameWords) + len(addressWords)
+	d.docsCount++
+
+	if node.ID >= d.nextDocID {
+		d.nextDocID = node.ID + 1
+	}
+}
+
+func (d *DeltaIndex) addField(field map[int][]int, fieldLen map[int]int, docID int, words []string) {
+	if len(words) == 0 {
+		return
+	}
+	fieldLen[docID] = len(words)
+	for _, word := range words {
+		d.terms[word] = struct{}{}
+		termID := d.termIDMap.GetID(word)
+
+		postings := field[termID]
+		idx := sort.SearchInts(postings, docID)
+		postings = append(postings, 0)
+		copy(postings[idx+1:], postings[idx:])
+		postings[idx] = docID
+		field[termID] = postings
+	}
+}
+
+// Delete. tandai docID (main index atau delta) sebagai terhapus.
+func (d *DeltaIndex) Delete(docID int) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.tombstones.Set(docID)
+}
+
+func (d *DeltaIndex) IsDeleted(docID int) bool {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.tombstones.Contains(docID)
+}
+
+// tombstonesInRange. docID terhapus di [minDocID, maxDocID), dipakai saat merge segment.
+func (d *DeltaIndex) tombstonesInRange(minDocID, maxDocID int) *datastructure.Bitmap {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	deleted := datastructure.NewBitmap()
+	d.tombstones.ForEach(func(docID int) {
+		if docID >= minDocID && docID < maxDocID {
+			deleted.Set(docID)
+		}
+	})
+	return deleted
+}
+
+// ContainsTerm. true kalau term ada di document delta. term baru dari update tidak ada di term dictionary FST.
+func (d *DeltaIndex) ContainsTerm(term string) bool {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	_, ok := d.terms[term]
+	return ok
+}
+
+// DocsCount. jumlah document di delta yang belum di-flush ke segment.
+func (d *DeltaIndex) DocsCount() int {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.docsCount
+}
+
+// DocsCountDiff. selisih jumlah document (delta - terhapus) terhadap main index.
+func (d *DeltaIndex) DocsCountDiff() int {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.docsCount - d.tombstones.Count()
+}
+
+// GetDocWordCount. return jumlah term (name + address) document delta. ok false kalau docID bukan document delta.
+func (d *DeltaIndex) GetDocWordCount(docID int) (int, bool) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	wordCount, ok := d.docWordCount[docID]
+	return wordCount, ok
+}
+
+// Flush. tulis semua document delta sebagai segment baru & hapus dari delta. tombstone tetap di delta.
+// caller harus memastikan semua docID yang sudah di-reserve (NextDocID) sudah di AddDocument.
+func (d *DeltaIndex) Flush(segments *SegmentedIndex) error {
+	d.flushLock.Lock()
+	defer d.flushLock.Unlock()
+
+	d.lock.RLock()
+	if d.docsCount == 0 {
+		d.lock.RUnlock()
+		return nil
+	}
+	data := SegmentData{
+		NameField:    copyPostings(d.nameField),
+		NameLen:      copyFieldLen(d.nameLen),
+		AddressField: copyPostings(d.addressField),
+		AddressLen:   copyFieldLen(d.addressLen),
+		Terms:        make([]string, 0, len(d.terms)),
+		DocsCount:    d.docsCount,
+		MinDocID:     segments.MaxDocID(),
+		MaxDocID:     d.nextDocID,
+	}
+	for term := range d.terms {
+		data.Terms = append(data.Terms, term)
+	}
+	flushedDocs := make([]int, 0, len(d.docWordCount))
+	for docID := range d.docWordCount {
+		flushedDocs = append(flushedDocs, docID)
+	}
+	d.lock.RUnlock()
+
+	seg, err := segments.writeSegment(data)
+	if err != nil {
+		return fmt.Errorf("error when writing delta segment: %w", err)
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	err = segments.publishSegment(seg, data.Terms)
+	if err != nil {
+		return err
+	}
+
+	flushed := make(map[int]struct{}, len(flushedDocs))
+	for _, docID := range flushedDocs {
+		flushed[docID] = struct{}{}
+		delete(d.nameLen, docID)
+		delete(d.addressLen, docID)
+		delete(d.docWordCount, docID)
+	}
+	removeFlushedPostings(d.nameField, flushed)
+	removeFlushedPostings(d.addressField, flushed)
+	d.docsCount -= len(flushedDocs)
+	return nil
+}
+
+func copyPostings(field map[int][]int) map[int][]int {
+	postings := make(map[int][]int, len(field))
+	for termID, docIDs := range field {
+		postings[termID] = append([]int{}, docIDs...)
+	}
+	return postings
+}
+
+func copyFieldLen(fieldLen map[int]int) map[int]int {
+	copied := make(map[int]int, len(fieldLen))
+	for docID, length := range fieldLen {
+		copied[docID] = length
+	}
+	return copied
+}
+
+func removeFlushedPostings(field map[int][]int, flushed map[int]struct{}) {
+	for termID, docIDs := range field {
+		remaining := docIDs[:0]
+		for _, docID := range docIDs {
+			if _, ok := flushed[docID]; !ok {
+				remaining = append(remaining, docID)
+			}
+		}
+		if len(remaining) == 0 {
+			delete(field, termID)
+		} else {
+			field[termID] = remaining
+		}
+	}
+}
+
+// WrapNameField. gabungkan main index field name dengan delta.
+func (d *DeltaIndex) WrapNameField(main FieldIndex) *DeltaMergedIndex {
+	return &DeltaMergedIndex{main: main, delta: d, postings: d.nameField, fieldLen: d.nameLen}
+}
+
+// WrapAddressField. gabungkan main index field address dengan delta.
+func (d *DeltaIndex) WrapAddressField(main FieldIndex) *DeltaMergedIndex {
+	return &DeltaMergedIndex{main: main, delta: d, postings: d.addressField, fieldLen: d.addressLen}
+}
+
+// DeltaMergedIndex. view dari satu field main index + delta index, document yang ada di tombstone tidak di-return.
+type DeltaMergedIndex struct {
+	main     FieldIndex
+	delta    *DeltaIndex
+	postings map[int][]int
+	fieldLen map[int]int
+}
+
+func (m *DeltaMergedIndex) Close() error {
+	return m.main.Close()
+}
+
+// GetPostingList. posting list main index diikuti posting list delta. tetap sorted karena docID delta > docID main index.
+// lock delta dipegang selama baca main index, jadi flush delta ke segment tidak terlihat setengah jalan.
+func (m *DeltaMergedIndex) GetPostingList(termID int) ([]int, error) {
+	m.delta.lock.RLock()
+	defer m.delta.lock.RUnlock()
+
+	mainPostings, err := m.main.GetPostingList(termID)
+	if err != nil {
+		return []int{}, err
+	}
+
+	deltaPostings := m.postings[termID]
+	if m.delta.tombstones.Count() == 0 && len(deltaPostings) == 0 {
+		return mainPostings, nil
+	}
+
+	postings := make([]int, 0, len(mainPostings)+len(deltaPostings))
+	for _, docID := range mainPostings {
+		if !m.delta.tombstones.Contains(docID) {
+			postings = append(postings, docID)
+		}
+	}
+	for _, docID := range deltaPostings {
+		if !m.delta.tombstones.Contains(docID) {
+			postings = append(postings, docID)
+		}
+	}
+	return postings, nil
+}
+
+func (m *DeltaMergedIndex) GetFieldLength(docID int) int {
+	m.delta.lock.RLock()
+	fieldLength, ok := m.fieldLen[docID]
+	m.delta.lock.RUnlock()
+	if ok {
+		return fieldLength
+	}
+	return m.main.GetFieldLength(docID)
+}
+
+func (m *DeltaMergedIndex) GetAverageFieldLength() float64 {
+	return m.main.GetAverageFieldLength()
+}
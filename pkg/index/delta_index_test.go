@@ -0,0 +1,71 @@
+package index
+
+import (
+	"testing"
+
+	"github.com/lintang-b-s/osm-search/pkg"
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockFieldIndex struct {
+	postings    map[int][]int
+	fieldLength map[int]int
+}
+
+func (m *mockFieldIndex) Close() error {
+	return nil
+}
+
+func (m *mockFieldIndex) GetPostingList(termID int) ([]int, error) {
+	return m.postings[termID], nil
+}
+
+func (m *mockFieldIndex) GetFieldLength(docID int) int {
+	return m.fieldLength[docID]
+}
+
+func (m *mockFieldIndex) GetAverageFieldLength() float64 {
+	return 2
+}
+
+func TestDeltaIndex(t *testing.T) {
+	t.Run("merge delta postings with main index & filter tombstones", func(t *testing.T) {
+		termIDMap := pkg.NewIDMap()
+		tamanID := termIDMap.GetID("taman")
+		main := &mockFieldIndex{
+			postings:    map[int][]int{tamanID: {0, 1, 2}},
+			fieldLength: map[int]int{0: 2, 1: 2, 2: 3},
+		}
+
+		delta := NewDeltaIndex(termIDMap, 3)
+		nameField := delta.WrapNameField(main)
+
+		docID := delta.NextDocID()
+		assert.Equal(t, 3, docID)
+		delta.AddDocument(datastructure.Node{ID: docID, Name: "Taman Pintar", Address: "Jalan Panembahan Senopati"})
+		delta.Delete(1)
+
+		postings, err := nameField.GetPostingList(tamanID)
+		assert.Nil(t, err)
+		assert.Equal(t, []int{0, 2, 3}, postings)
+
+		pintarPostings, err := nameField.GetPostingList(termIDMap.GetID("pintar"))
+		assert.Nil(t, err)
+		assert.Equal(t, []int{3}, pintarPostings)
+
+		assert.True(t, delta.ContainsTerm("pintar"))
+		assert.False(t, delta.ContainsTerm("kebun"))
+		assert.True(t, delta.IsDeleted(1))
+		assert.False(t, delta.IsDeleted(3))
+
+		assert.Equal(t, 2, nameField.GetFieldLength(3))
+		assert.Equal(t, 3, nameField.GetFieldLength(2))
+		assert.Equal(t, 0, delta.DocsCountDiff())
+
+		wordCount, ok := delta.GetDocWordCount(3)
+		assert.True(t, ok)
+		assert.Equal(t, 5, wordCount)
+		assert.Equal(t, 4, delta.NextDocID())
+	})
+}
@@ -0,0 +1,187 @@
+package index
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/blevesearch/mmap-go"
+)
+
+// mmapFile. open file read-only & mmap isinya. data nil kalau file kosong (mmap gak bisa untuk file kosong).
+func mmapFile(path string) (*os.File, mmap.MMap, error) {
+	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0700)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if stat.Size() == 0 {
+		return file, nil, nil
+	}
+
+	data, err := mmap.Map(file, mmap.RDONLY, 0)
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("error when mmap %s: %w", path, err)
+	}
+	return file, data, nil
+}
+
+// uint32Array. flat array uint32 little endian di disk, index array = docID. dipakai untuk panjang document (jumlah term) per docID.
+type uint32Array struct {
+	file *os.File
+	data mmap.MMap
+}
+
+// writeUint32Array. tulis values ke path sebagai flat array dengan panjang max(key)+1. key yang tidak ada di values = 0.
+// bagian awal array sebelum min(key) tidak ditulis (sparse file), jadi segment dengan docID besar tetap kecil di disk.
+func writeUint32Array(path string, values map[int]int) error {
+	size, minKey := 0, -1
+	for key := range values {
+		if key < 0 {
+			continue
+		}
+		if key+1 > size {
+			size = key + 1
+		}
+		if minKey == -1 || key < minKey {
+			minKey = key
+		}
+	}
+	if minKey == -1 {
+		minKey = 0
+	}
+
+	buf := make([]byte, 4*(size-minKey))
+	for key, val := range values {
+		if key < 0 {
+			continue
+		}
+		if val > math.MaxUint32 {
+			return fmt.Errorf("value %d of key %d overflows uint32", val, key)
+		}
+		binary.LittleEndian.PutUint32(buf[4*(key-minKey):], uint32(val))
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0700)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	err = file.Truncate(int64(4 * size))
+	if err != nil {
+		return err
+	}
+	_, err = file.WriteAt(buf, int64(4*minKey))
+	return err
+}
+
+func openUint32Array(path string) (*uint32Array, error) {
+	file, data, err := mmapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &uint32Array{file: file, data: data}, nil
+}
+
+func (a *uint32Array) Len() int {
+	return len(a.data) / 4
+}
+
+// Get. return 0 kalau i di luar array.
+func (a *uint32Array) Get(i int) int {
+	if i < 0 || i >= a.Len() {
+		return 0
+	}
+	return int(binary.LittleEndian.Uint32(a.data[4*i:]))
+}
+
+func (a *uint32Array) Close() error {
+	if a.data != nil {
+		err := a.data.Unmap()
+		if err != nil {
+			return err
+		}
+		a.data = nil
+	}
+	return a.file.Close()
+}
+
+// termTable. id -> term dari term dictionary (kebalikan terms.fst) di disk. format: jumlah term (uint32), offset term ke-i (uint32 * (jumlah+1)), lalu byte semua term urut id.
+type termTable struct {
+	file *os.File
+	data mmap.MMap
+}
+
+// writeTermTable. tulis idToStr ke path. id yang tidak ada di idToStr = term kosong.
+func writeTermTable(path string, idToStr map[int]string) error {
+	size, blobLen := 0, 0
+	for id, term := range idToStr {
+		if id+1 > size {
+			size = id + 1
+		}
+		blobLen += len(term)
+	}
+	if blobLen > math.MaxUint32 {
+		return fmt.Errorf("term table size %d overflows uint32", blobLen)
+	}
+
+	headerLen := 4 * (size + 2)
+	buf := make([]byte, headerLen, headerLen+blobLen)
+	binary.LittleEndian.PutUint32(buf, uint32(size))
+	for id := 0; id < size; id++ {
+		binary.LittleEndian.PutUint32(buf[4*(id+1):], uint32(len(buf)-headerLen))
+		buf = append(buf, idToStr[id]...)
+	}
+	binary.LittleEndian.PutUint32(buf[4*(size+1):], uint32(len(buf)-headerLen))
+
+	return os.WriteFile(path, buf, 0700)
+}
+
+func openTermTable(path string) (*termTable, error) {
+	file, data, err := mmapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &termTable{file: file, data: data}, nil
+}
+
+func (t *termTable) Len() int {
+	if len(t.data) < 4 {
+		return 0
+	}
+	return int(binary.LittleEndian.Uint32(t.data))
+}
+
+// GetTerm. false kalau id di luar table atau term-nya kosong.
+func (t *termTable) GetTerm(id int) (string, bool) {
+	size := t.Len()
+	if id < 0 || id >= size {
+		return "", false
+	}
+	headerLen := 4 * (size + 2)
+	start := binary.LittleEndian.Uint32(t.data[4*(id+1):])
+	end := binary.LittleEndian.Uint32(t.data[4*(id+2):])
+	if start == end {
+		return "", false
+	}
+	return string(t.data[headerLen+int(start) : headerLen+int(end)]), true
+}
+
+func (t *termTable) Close() error {
+	if t.data != nil {
+		err := t.data.Unmap()
+		if err != nil {
+			return err
+		}
+		t.data = nil
+	}
+	return t.file.Close()
+}
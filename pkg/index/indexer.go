@@ -6,6 +6,7 @@ import (
 	"log"
 	"math"
 	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -16,7 +17,10 @@ import (
 	"github.com/lintang-b-s/osm-search/pkg/geo"
 
 	"github.com/RadhiFadlillah/go-sastrawi"
+	"github.com/blevesearch/vellum"
+	"github.com/paulmach/osm"
 	"github.com/vmihailenco/msgpack/v5"
+	"golang.org/x/sync/errgroup"
 )
 
 type DynamicIndex struct {
@@ -24,7 +28,11 @@ type DynamicIndex struct {
 	workingDir                string
 	intermediateIndices       []string
 	maxDynamicPostingListSize int
-	docWordCount              map[int]int
+	docWordCount              map[int]int  // hanya saat indexing.
+	docLengths                *uint32Array // docID -> jumlah term. di-load dari disk saat server.
+	termsFST                  *vellum.FST
+	termsByID                 *termTable // termID -> term, pasangan termsFST.
+	server                    bool
 	averageDocLength          float64
 	outputDir                 string
 	docsCount                 int
@@ -36,15 +44,16 @@ type DynamicIndex struct {
 }
 
 type IndexedData struct {
-	Ways            []geo.OSMWay
-	Nodes           []geo.OSMNode
-	Ctr             geo.NodeMapContainer
+	Ways            geo.OSMObjectSource[geo.OSMWay]
+	Nodes           geo.OSMObjectSource[geo.OSMNode]
+	Ctr             geo.NodeCoordLookup                  // koordinat osm node dari osm way
+	Relations       geo.OSMObjectSource[geo.OSMRelation] // osm relation multipolygon/boundary/route. boleh nil
 	TagIDMap        *pkg.IDMap
 	osmSpatialIndex geo.OSMSpatialIndex
-	regionsBoundary []geo.Boundary
+	adminIndex      *geo.AdminIndex // boundary administratif, disimpan di outputDir untuk reverse geocoding
 }
 
-func NewIndexedData(ways []geo.OSMWay, nodes []geo.OSMNode, ctr geo.NodeMapContainer, tagIDMap *pkg.IDMap,
+func NewIndexedData(ways geo.OSMObjectSource[geo.OSMWay], nodes geo.OSMObjectSource[geo.OSMNode], ctr geo.NodeCoordLookup, tagIDMap *pkg.IDMap,
 	osmSpatialIndex geo.OSMSpatialIndex, regionsBoundary []geo.Boundary) IndexedData {
 	return IndexedData{
 		Ways:            ways,
@@ -52,7 +61,7 @@ func NewIndexedData(ways []geo.OSMWay, nodes []geo.OSMNode, ctr geo.NodeMapConta
 		Ctr:             ctr,
 		TagIDMap:        tagIDMap,
 		osmSpatialIndex: osmSpatialIndex,
-		regionsBoundary: regionsBoundary,
+		adminIndex:      geo.NewAdminIndex(osmSpatialIndex.AdministrativeBoundaryRtree, regionsBoundary),
 	}
 }
 
@@ -79,6 +88,7 @@ func NewDynamicIndex(outputDir string, maxPostingListSize int,
 		documentStore:             boltDB,
 		OSMFeatureMap:             pkg.NewIDMap(),
 		WikidataObjects:           make(map[int]struct{}),
+		server:                    server,
 	}
 	if server {
 		err := idx.LoadMeta()
@@ -95,7 +105,7 @@ func NewDynamicIndex(outputDir string, maxPostingListSize int,
 func (Idx *DynamicIndex) SpimiBatchIndex(ctx context.Context) ([]datastructure.Node, error) {
 	var batchingLock sync.RWMutex // buat lock block & nodeIDx.
 
-	osmData := make([]datastructure.OSMObject, 0, len(Idx.IndexedData.Ways)+len(Idx.IndexedData.Nodes))
+	osmData := make([]datastructure.OSMObject, 0, Idx.IndexedData.Ways.Len()+Idx.IndexedData.Nodes.Len())
 
 	log.Printf("indexing osm objects...\n")
 
@@ -104,15 +114,42 @@ func (Idx *DynamicIndex) SpimiBatchIndex(ctx context.Context) ([]datastructure.N
 
 	nodeBoundingBox := make(map[string]geo.BoundingBox)
 
-	type IndexingResults struct {
-		Error error
-	}
+	allSearchNodes := make([]datastructure.Node, 0, Idx.IndexedData.Ways.Len()+Idx.IndexedData.Nodes.Len())
 
-	allSearchNodes := make([]datastructure.Node, 0, len(Idx.IndexedData.Ways)+len(Idx.IndexedData.Nodes))
+	// flushSearchNodes. spimi invert field name & address dari batch document, lalu simpan document ke doc store.
+	flushSearchNodes := func(searchNodes []datastructure.Node, osmIDs map[string]int, ctx context.Context, lock *sync.RWMutex) error {
+		errChan := make(chan error)
+		go func() {
+			errChan <- Idx.SpimiInvert(searchNodes, &block, lock, "name", ctx)
+		}()
 
-	processOSMWaysBatch := func(ways []geo.OSMWay, wg *sync.WaitGroup, ctx context.Context, lock *sync.RWMutex, indexingRes chan<- IndexingResults) {
-		defer wg.Done()
+		go func() {
+			errChan <- Idx.SpimiInvert(searchNodes, &block, lock, "address", ctx)
+		}()
+
+		// tunggu kedua goroutine selesai sebelum return.
+		errName, errAddress := <-errChan, <-errChan
+		if errName != nil {
+			return errName
+		}
+		if errAddress != nil {
+			return errAddress
+		}
+
+		err := Idx.saveDocs(searchNodes, osmIDs)
+		if err != nil {
+			return err
+		}
+
+		lock.Lock()
+		allSearchNodes = append(allSearchNodes, searchNodes...)
+		lock.Unlock()
+		return nil
+	}
+
+	processOSMWaysBatch := func(ways []geo.OSMWay, ctx context.Context, lock *sync.RWMutex) error {
 		searchNodes := []datastructure.Node{}
+		osmIDs := make(map[string]int) // osm key -> docID
 
 		for _, way := range ways {
 
@@ -124,27 +161,31 @@ func (Idx *DynamicIndex) SpimiBatchIndex(ctx context.Context) ([]datastructure.N
 
 			select {
 			case <-ctx.Done():
-				indexingRes <- IndexingResults{Error: fmt.Errorf("context cancelled")}
-				return
+				return fmt.Errorf("context cancelled")
 			default:
 			}
 
-			lat := make([]float64, len(way.NodeIDs))
-			lon := make([]float64, len(way.NodeIDs))
-			latLons := make([][]float64, len(way.NodeIDs))
-			for i := 0; i < len(way.NodeIDs); i++ {
-				node := way.NodeIDs[i]
-				nodeLat := Idx.IndexedData.Ctr.GetNode(node).Lat
-				nodeLon := Idx.IndexedData.Ctr.GetNode(node).Lon
-				lat[i] = nodeLat
-				lon[i] = nodeLon
-				latLons[i] = []float64{nodeLat, nodeLon}
+			lat := make([]float64, 0, len(way.NodeIDs))
+			lon := make([]float64, 0, len(way.NodeIDs))
+			latLons := make([][]float64, 0, len(way.NodeIDs))
+			for _, node := range way.NodeIDs {
+				nodeLat, nodeLon, ok := Idx.IndexedData.Ctr.GetNode(node)
+				if !ok {
+					// osm node tidak ada di file pbf (way terpotong di batas extract).
+					continue
+				}
+				lat = append(lat, nodeLat)
+				lon = append(lon, nodeLon)
+				latLons = append(latLons, []float64{nodeLat, nodeLon})
+			}
+			if len(lat) == 0 {
+				continue
 			}
 
 			sort.Float64s(lat)
 			sort.Float64s(lon)
 
-			centerLat, centerLon := lat[len(lat)/2], lon[len(lon)/2]
+			centerLat, centerLon := geo.RepresentativePoint(latLons)
 
 			name, street, tipe, postalCode, houseNumber := geo.GetNameAddressTypeFromOSMWay(way.TagMap)
 
@@ -165,9 +206,11 @@ func (Idx *DynamicIndex) SpimiBatchIndex(ctx context.Context) ([]datastructure.N
 			nodeBoundingBox[strings.ToLower(name)] = geo.NewBoundingBox(lat, lon)
 
 			searchNodes = append(searchNodes, datastructure.NewNode(nodeIDX, name, centerLat,
-				centerLon, address, tipe, city, way.ContainWikidata))
+				centerLon, address, tipe, city, way.ContainWikidata).
+				WithOSMObject(string(osm.TypeWay), way.ID, geo.GetIndexRules().StoredTagMap(way.TagMap)))
+			osmIDs[geo.OSMKey(osm.TypeWay, way.ID)] = nodeIDX
 
-			osmFeature := getOSMFeature(way.TagMap)
+			osmFeature := GetOSMFeature(way.TagMap)
 			osmFeatureInt := make(map[int]int, len(osmFeature))
 			for k, v := range osmFeature {
 				osmFeatureInt[Idx.OSMFeatureMap.GetID(k)] = Idx.OSMFeatureMap.GetID(v)
@@ -190,109 +233,50 @@ func (Idx *DynamicIndex) SpimiBatchIndex(ctx context.Context) ([]datastructure.N
 			lock.Unlock()
 
 			if len(searchNodes) == BATCH_SIZE {
-				errChan := make(chan error)
-
-				go func() {
-					errChan <- Idx.SpimiInvert(searchNodes, &block, lock, "name", ctx)
-				}()
-
-				go func() {
-					errChan <- Idx.SpimiInvert(searchNodes, &block, lock, "address", ctx)
-				}()
-
-				if err := <-errChan; err != nil {
-					indexingRes <- IndexingResults{Error: err}
-					return
-				}
-
-				if err := <-errChan; err != nil {
-					indexingRes <- IndexingResults{Error: err}
-					return
-				}
-
-				err := Idx.documentStore.SaveDocs(searchNodes)
+				err := flushSearchNodes(searchNodes, osmIDs, ctx, lock)
 				if err != nil {
-					indexingRes <- IndexingResults{Error: err}
-					return
+					return err
 				}
 
-				lock.Lock()
-				allSearchNodes = append(allSearchNodes, searchNodes...)
-				lock.Unlock()
-
 				searchNodes = []datastructure.Node{}
+				osmIDs = make(map[string]int)
 			}
 		}
 
 		if len(searchNodes) != 0 {
-			errChan := make(chan error)
-			go func() {
-				errChan <- Idx.SpimiInvert(searchNodes, &block, lock, "name", ctx)
-			}()
-
-			go func() {
-				errChan <- Idx.SpimiInvert(searchNodes, &block, lock, "address", ctx)
-			}()
-
-			if err := <-errChan; err != nil {
-				indexingRes <- IndexingResults{Error: err}
-				return
-			}
-
-			if err := <-errChan; err != nil {
-				indexingRes <- IndexingResults{Error: err}
-				return
-			}
-
-			err := Idx.documentStore.SaveDocs(searchNodes)
+			err := flushSearchNodes(searchNodes, osmIDs, ctx, lock)
 			if err != nil {
-				indexingRes <- IndexingResults{Error: err}
-				return
+				return err
 			}
-
-			lock.Lock()
-			allSearchNodes = append(allSearchNodes, searchNodes...)
-			lock.Unlock()
 		}
-
+		return nil
 	}
 
-	indexingRes := make(chan IndexingResults)
-
-	batchingOSMWays := func(ways []geo.OSMWay, ctx context.Context) error {
-		var wg sync.WaitGroup
-		for start, end := 0, 0; start < len(ways); start = end {
-			wg.Add(1)
-			end = start + BATCH_SIZE
-			if end > len(ways) {
-				end = len(ways)
+	// batchingOSMWays. baca osm way per batch dari source & proses maksimal INDEXING_WORKERS batch sekaligus,
+	// jadi osm way yang ada di memory dibatasi INDEXING_WORKERS*BATCH_SIZE (tidak tergantung ukuran file pbf).
+	batchingOSMWays := func(ways geo.OSMObjectSource[geo.OSMWay], ctx context.Context) error {
+		g, gctx := errgroup.WithContext(ctx)
+		g.SetLimit(INDEXING_WORKERS)
+		for batch, err := range ways.Batches(BATCH_SIZE) {
+			if err != nil {
+				g.Wait()
+				return err
 			}
-			batch := ways[start:end]
-			go processOSMWaysBatch(batch, &wg, ctx, &batchingLock, indexingRes)
-		}
-
-		go func() {
-			// semua goroutine done jika context cancelled atau semua goroutine selesai atau ada salah satu goroutine batchProcessing yang error.
-			// kalau done semua goroutine, close indexingRes channel.
-			wg.Wait()
-			close(indexingRes)
-		}()
-
-		for res := range indexingRes {
-			// iterate indexing res channel, jika ada yang error, return error & keluar dari SpimiBatchIndexing ->
-			// cancel context, semua goroutine batchProcessing akan Done & close indexingRes Channel.
-
-			if res.Error != nil {
-				return res.Error
+			if gctx.Err() != nil {
+				// ada batch yang error.
+				break
 			}
+			g.Go(func() error {
+				return processOSMWaysBatch(batch, gctx, &batchingLock)
+			})
 		}
-		return nil
+		return g.Wait()
 	}
 
-	processOSMNodesBatch := func(nodes []geo.OSMNode, wg *sync.WaitGroup, ctx context.Context, lock *sync.RWMutex, indexingRes chan<- IndexingResults) {
-		defer wg.Done()
+	processOSMNodesBatch := func(nodes []geo.OSMNode, ctx context.Context, lock *sync.RWMutex) error {
 
 		searchNodes := []datastructure.Node{}
+		osmIDs := make(map[string]int) // osm key -> docID
 
 		for _, node := range nodes {
 
@@ -304,8 +288,7 @@ func (Idx *DynamicIndex) SpimiBatchIndex(ctx context.Context) ([]datastructure.N
 
 			select {
 			case <-ctx.Done():
-				indexingRes <- IndexingResults{Error: fmt.Errorf("context cancelled")}
-				return
+				return fmt.Errorf("context cancelled")
 			default:
 			}
 
@@ -324,9 +307,11 @@ func (Idx *DynamicIndex) SpimiBatchIndex(ctx context.Context) ([]datastructure.N
 			lock.Lock()
 
 			searchNodes = append(searchNodes, datastructure.NewNode(nodeIDX, name, node.Lat,
-				node.Lon, address, tipe, city, node.ContainWikiData))
+				node.Lon, address, tipe, city, node.ContainWikiData).
+				WithOSMObject(string(osm.TypeNode), node.ID, geo.GetIndexRules().StoredTagMap(node.TagMap)))
+			osmIDs[geo.OSMKey(osm.TypeNode, node.ID)] = nodeIDX
 
-			osmFeature := getOSMFeature(node.TagMap)
+			osmFeature := GetOSMFeature(node.TagMap)
 			osmFeatureInt := make(map[int]int, len(osmFeature))
 			for k, v := range osmFeature {
 				osmFeatureInt[Idx.OSMFeatureMap.GetID(k)] = Idx.OSMFeatureMap.GetID(v)
@@ -348,106 +333,150 @@ func (Idx *DynamicIndex) SpimiBatchIndex(ctx context.Context) ([]datastructure.N
 			lock.Unlock()
 
 			if len(searchNodes) == BATCH_SIZE {
-				errChan := make(chan error)
-				go func() {
-					errChan <- Idx.SpimiInvert(searchNodes, &block, lock, "name", ctx)
-				}()
-
-				go func() {
-					errChan <- Idx.SpimiInvert(searchNodes, &block, lock, "address", ctx)
-				}()
-
-				if err := <-errChan; err != nil {
-					indexingRes <- IndexingResults{Error: err}
-					return
-				}
-
-				if err := <-errChan; err != nil {
-					indexingRes <- IndexingResults{Error: err}
-					return
-				}
-
-				err := Idx.documentStore.SaveDocs(searchNodes)
+				err := flushSearchNodes(searchNodes, osmIDs, ctx, lock)
 				if err != nil {
-					indexingRes <- IndexingResults{Error: err}
-					return
+					return err
 				}
 
-				lock.Lock()
-				allSearchNodes = append(allSearchNodes, searchNodes...)
-				lock.Unlock()
-
 				searchNodes = []datastructure.Node{}
+				osmIDs = make(map[string]int)
 			}
 		}
 
 		if len(searchNodes) != 0 {
-			errChan := make(chan error)
-			go func() {
-				errChan <- Idx.SpimiInvert(searchNodes, &block, lock, "name", ctx)
-			}()
+			err := flushSearchNodes(searchNodes, osmIDs, ctx, lock)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 
-			go func() {
-				errChan <- Idx.SpimiInvert(searchNodes, &block, lock, "address", ctx)
-			}()
+	batchingOSMNodes := func(nodes geo.OSMObjectSource[geo.OSMNode], ctx context.Context) error {
+		g, gctx := errgroup.WithContext(ctx)
+		g.SetLimit(INDEXING_WORKERS)
+		for batch, err := range nodes.Batches(BATCH_SIZE) {
+			if err != nil {
+				g.Wait()
+				return err
+			}
+			if gctx.Err() != nil {
+				break
+			}
+			g.Go(func() error {
+				return processOSMNodesBatch(batch, gctx, &batchingLock)
+			})
+		}
+		return g.Wait()
+	}
 
-			if err := <-errChan; err != nil {
-				indexingRes <- IndexingResults{Error: err}
-				return
+	processOSMRelationsBatch := func(relations []geo.OSMRelation, ctx context.Context, lock *sync.RWMutex) error {
+		searchNodes := []datastructure.Node{}
+		osmIDs := make(map[string]int) // osm key -> docID
+
+		for _, relation := range relations {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("context cancelled")
+			default:
 			}
 
-			if err := <-errChan; err != nil {
-				indexingRes <- IndexingResults{Error: err}
-				return
+			boundary := relation.BoundaryLatLons()
+			if len(boundary) == 0 {
+				continue
 			}
+			lat := make([]float64, 0, len(boundary))
+			lon := make([]float64, 0, len(boundary))
+			for _, latLon := range boundary {
+				lat = append(lat, latLon[0])
+				lon = append(lon, latLon[1])
+			}
+			sort.Float64s(lat)
+			sort.Float64s(lon)
 
-			err := Idx.documentStore.SaveDocs(searchNodes)
-			if err != nil {
-				indexingRes <- IndexingResults{Error: err}
-				return
+			centerLat, centerLon := relation.Center()
+
+			name, street, _, postalCode, houseNumber := geo.GetNameAddressTypeFromOSMWay(relation.TagMap)
+
+			if isWayDuplicateCheck(strings.ToLower(name), lat, lon, nodeBoundingBox, lock) {
+				// multipolygon yang sama sudah di index sebagai osm way.
+				continue
 			}
 
+			address, city := Idx.GetFullAdress(street, postalCode, houseNumber, centerLat, centerLon)
+
 			lock.Lock()
-			allSearchNodes = append(allSearchNodes, searchNodes...)
+
+			nodeBoundingBox[strings.ToLower(name)] = geo.NewBoundingBox(lat, lon)
+
+			searchNodes = append(searchNodes, datastructure.NewNode(nodeIDX, name, centerLat,
+				centerLon, address, relation.ObjectType(), city, relation.ContainWikidata).
+				WithOSMObject(string(osm.TypeRelation), relation.ID, geo.GetIndexRules().StoredTagMap(relation.TagMap)))
+			osmIDs[geo.OSMKey(osm.TypeRelation, relation.ID)] = nodeIDX
+
+			osmFeature := GetOSMFeature(relation.TagMap)
+			osmFeatureInt := make(map[int]int, len(osmFeature))
+			for k, v := range osmFeature {
+				osmFeatureInt[Idx.OSMFeatureMap.GetID(k)] = Idx.OSMFeatureMap.GetID(v)
+			}
+			rtreeItem := datastructure.OSMObject{
+				ID:              nodeIDX,
+				Lat:             centerLat,
+				Lon:             centerLon,
+				Tag:             osmFeatureInt,
+				BoundaryLatLons: boundary,
+				Polygon:         relation.Multipolygon(),
+			}
+			osmData = append(osmData, rtreeItem)
+
+			if relation.ContainWikidata {
+				Idx.WikidataObjects[nodeIDX] = struct{}{}
+			}
+
+			nodeIDX++
 			lock.Unlock()
-		}
-	}
 
-	indexingNodesRes := make(chan IndexingResults)
+			if len(searchNodes) == BATCH_SIZE {
+				err := flushSearchNodes(searchNodes, osmIDs, ctx, lock)
+				if err != nil {
+					return err
+				}
 
-	batchingOSMNodes := func(nodes []geo.OSMNode, ctx context.Context) error {
-		var wg sync.WaitGroup
-		for start, end := 0, 0; start < len(nodes); start = end {
-			wg.Add(1)
-			end = start + BATCH_SIZE
-			if end > len(nodes) {
-				end = len(nodes)
+				searchNodes = []datastructure.Node{}
+				osmIDs = make(map[string]int)
 			}
-			batch := nodes[start:end]
-			go processOSMNodesBatch(batch, &wg, ctx, &batchingLock, indexingNodesRes)
 		}
 
-		go func() {
-			// semua goroutine done jika context cancelled atau semua goroutine selesai atau ada salah satu goroutine batchProcessing yang error.
-			// kalau done semua goroutine, close indexingNodesRes channel.
-			wg.Wait()
-			close(indexingNodesRes)
-		}()
+		if len(searchNodes) != 0 {
+			return flushSearchNodes(searchNodes, osmIDs, ctx, lock)
+		}
+		return nil
+	}
 
-		for res := range indexingNodesRes {
-			// iterate indexing res channel, jika ada yang error, return error & keluar dari SpimiBatchIndexing ->
-			// cancel context, semua goroutine batchProcessing akan Done & close indexingRes Channel.
-			// if tidak error -> tunggu sampai wait.Done() unblock -> close indexingNodesRes channel & exit loop ini.
-			if res.Error != nil {
-				return res.Error
+	batchingOSMRelations := func(relations geo.OSMObjectSource[geo.OSMRelation], ctx context.Context) error {
+		if relations == nil {
+			return nil
+		}
+		g, gctx := errgroup.WithContext(ctx)
+		g.SetLimit(INDEXING_WORKERS)
+		for batch, err := range relations.Batches(BATCH_SIZE) {
+			if err != nil {
+				g.Wait()
+				return err
+			}
+			if gctx.Err() != nil {
+				break
 			}
+			g.Go(func() error {
+				return processOSMRelationsBatch(batch, gctx, &batchingLock)
+			})
 		}
-		return nil
+		return g.Wait()
 	}
 
 	var wg sync.WaitGroup
-	wg.Add(2)
-	var errChan = make(chan error, 2)
+	wg.Add(3)
+	var errChan = make(chan error, 3)
 	go func() {
 		defer wg.Done()
 
@@ -469,6 +498,11 @@ func (Idx *DynamicIndex) SpimiBatchIndex(ctx context.Context) ([]datastructure.N
 		errChan <- nil
 	}()
 
+	go func() {
+		defer wg.Done()
+		errChan <- batchingOSMRelations(Idx.IndexedData.Relations, ctx)
+	}()
+
 	go func() {
 		wg.Wait()
 		close(errChan)
@@ -486,16 +520,24 @@ func (Idx *DynamicIndex) SpimiBatchIndex(ctx context.Context) ([]datastructure.N
 	}
 
 	for i := 0; i < len(osmData); i++ {
-		upperRightLat, upperRightLon := geo.GetDestinationPoint(osmData[i].Lat, osmData[i].Lon, 45, 0.4)
-		lowerLeftLat, lowerLeftLon := geo.GetDestinationPoint(osmData[i].Lat, osmData[i].Lon, 225, 0.4)
-
-		osmData[i].SetBound(datastructure.NewRtreeBoundingBox(2,
-			[]float64{lowerLeftLat, lowerLeftLon}, []float64{upperRightLat, upperRightLon}))
+		osmData[i].SetBound(geo.RtreeLeafBound(osmData[i]))
 	}
 	err := datastructure.SerializeRtreeData(Idx.workingDir, Idx.outputDir, osmData)
 	if err != nil {
 		return nil, err
 	}
+	if Idx.IndexedData.adminIndex != nil {
+		err = Idx.IndexedData.adminIndex.Save(filepath.Join(Idx.workingDir, Idx.outputDir, geo.ADMIN_BOUNDARIES_FILE))
+		if err != nil {
+			return nil, fmt.Errorf("error when saving admin boundaries: %w", err)
+		}
+	}
+	if Idx.IndexedData.osmSpatialIndex.Intersections != nil {
+		err = Idx.IndexedData.osmSpatialIndex.Intersections.Save(filepath.Join(Idx.workingDir, Idx.outputDir, geo.INTERSECTIONS_FILE))
+		if err != nil {
+			return nil, fmt.Errorf("error when saving intersections: %w", err)
+		}
+	}
 
 	Idx.docsCount = nodeIDX
 
@@ -579,6 +621,15 @@ func (Idx *DynamicIndex) SpimiBatchIndex(ctx context.Context) ([]datastructure.N
 	return allSearchNodes, nil
 }
 
+// saveDocs. simpan document & mapping osm key -> docID ke doc store.
+func (Idx *DynamicIndex) saveDocs(searchNodes []datastructure.Node, osmIDs map[string]int) error {
+	err := Idx.documentStore.SaveDocs(searchNodes)
+	if err != nil {
+		return err
+	}
+	return Idx.documentStore.SaveOSMIDs(osmIDs)
+}
+
 func isWayDuplicateCheck(name string, lats, lons []float64, nodeBoundingBox map[string]geo.BoundingBox,
 	lock *sync.RWMutex) bool {
 	if name == "" {
@@ -618,21 +669,30 @@ func isNodeDuplicateCheck(name string, lats, lon float64, nodeBoundingBox map[st
 }
 
 func (Idx *DynamicIndex) MergeFieldLengths(indices []*InvertedIndex) map[int]int {
+	return mergeFieldLengths(indices, nil)
+}
+
+// mergeFieldLengths. gabungkan panjang field dari semua index. docID di deleted (boleh nil) tidak diikutkan.
+func mergeFieldLengths(indices []*InvertedIndex, deleted *datastructure.Bitmap) map[int]int {
 	lenDF := make(map[int]int)
 	for _, index := range indices {
-		for docID, fieldLength := range index.lenFieldInDoc {
-			if _, ok := lenDF[docID]; ok {
-				lenDF[docID] += fieldLength
-			} else {
-				lenDF[docID] = fieldLength
+		index.forEachFieldLength(func(docID, fieldLength int) {
+			if deleted != nil && deleted.Contains(docID) {
+				return
 			}
-		}
+			lenDF[docID] += fieldLength
+		})
 	}
 	return lenDF
 }
 
 // Merge. merge k inverted indexes into 1 merged index.
 func (Idx *DynamicIndex) Merge(indices []*InvertedIndex, mergedIndex *InvertedIndex) error {
+	return mergeIndices(indices, mergedIndex, nil)
+}
+
+// mergeIndices. merge posting list semua index per termID. docID di deleted (boleh nil) dibuang dari posting list.
+func mergeIndices(indices []*InvertedIndex, mergedIndex *InvertedIndex, deleted *datastructure.Bitmap) error {
 	lastTerm, lastPosting := -1, []int{}
 	mergeKArrayIterator := NewMergeKArrayIterator(indices)
 	for output, err := range mergeKArrayIterator.mergeKSortedArray() {
@@ -645,8 +705,7 @@ func (Idx *DynamicIndex) Merge(indices []*InvertedIndex, mergedIndex *InvertedIn
 		if currTerm != lastTerm {
 
 			if lastTerm != -1 {
-				sort.Ints(lastPosting)
-				err := mergedIndex.AppendPostingList(lastTerm, lastPosting)
+				err := appendMergedPostingList(mergedIndex, lastTerm, lastPosting, deleted)
 				if err != nil {
 					return fmt.Errorf("error when merge posting lists: %w", err)
 				}
@@ -658,8 +717,7 @@ func (Idx *DynamicIndex) Merge(indices []*InvertedIndex, mergedIndex *InvertedIn
 	}
 
 	if lastTerm != -1 {
-		sort.Ints(lastPosting)
-		err := mergedIndex.AppendPostingList(lastTerm, lastPosting)
+		err := appendMergedPostingList(mergedIndex, lastTerm, lastPosting, deleted)
 		if err != nil {
 			return err
 		}
@@ -667,6 +725,23 @@ func (Idx *DynamicIndex) Merge(indices []*InvertedIndex, mergedIndex *InvertedIn
 	return nil
 }
 
+func appendMergedPostingList(mergedIndex *InvertedIndex, termID int, postings []int, deleted *datastructure.Bitmap) error {
+	sort.Ints(postings)
+	if deleted != nil && deleted.Count() > 0 {
+		remaining := postings[:0]
+		for _, docID := range postings {
+			if !deleted.Contains(docID) {
+				remaining = append(remaining, docID)
+			}
+		}
+		if len(remaining) == 0 {
+			return nil
+		}
+		postings = remaining
+	}
+	return mergedIndex.AppendPostingList(termID, postings)
+}
+
 // SpimiInvert is a function to invert a batch of nodes into a posting list & write it to inverted index file.
 // https://nlp.stanford.edu/IR-book/pdf/04const.pdf (Figure 4.4 Spimi-invert)
 func (Idx *DynamicIndex) SpimiInvert(nodes []datastructure.Node, block *int, lock *sync.RWMutex, field string,
@@ -845,50 +920,88 @@ func (Idx *DynamicIndex) BuildSpellCorrectorAndNgram(ctx context.Context, allSea
 	return nil
 }
 
+const (
+	TERMS_FST_FILE      = "terms.fst"
+	TERMS_BY_ID_FILE    = "terms_by_id.dat"
+	DOC_WORD_COUNT_FILE = "doc_word_count.doclen"
+)
+
+// SpimiIndexMetadata. metadata kecil yang di-serialize pakai msgpack. term dictionary disimpan di TERMS_FST_FILE (& kebalikannya di TERMS_BY_ID_FILE) & jumlah term per document di DOC_WORD_COUNT_FILE.
 type SpimiIndexMetadata struct {
-	TermIDMap       *pkg.IDMap
-	DocWordCount    map[int]int
-	DocsCount       int
-	OSMFeatureMap   *pkg.IDMap
-	WikidataObjects map[int]struct{}
+	DocsCount        int
+	AverageDocLength float64
+	OSMFeatureMap    *pkg.IDMap
+	WikidataObjects  map[int]struct{}
 }
 
-func NewSpimiIndexMetadata(termIDMap *pkg.IDMap, docWordCount map[int]int, docsCount int,
+func NewSpimiIndexMetadata(docsCount int, averageDocLength float64,
 	osmFeatureMap *pkg.IDMap, wikidataObjects map[int]struct{}) SpimiIndexMetadata {
 	return SpimiIndexMetadata{
-		TermIDMap:       termIDMap,
-		DocWordCount:    docWordCount,
-		DocsCount:       docsCount,
-		OSMFeatureMap:   osmFeatureMap,
-		WikidataObjects: wikidataObjects,
+		DocsCount:        docsCount,
+		AverageDocLength: averageDocLength,
+		OSMFeatureMap:    osmFeatureMap,
+		WikidataObjects:  wikidataObjects,
 	}
 }
+
+// Close. saat indexing: simpan metadata ke disk. saat server: release term dictionary & doc length yang di-mmap.
 func (Idx *DynamicIndex) Close() error {
-	err := Idx.SaveMeta()
-	return err
+	if !Idx.server {
+		return Idx.SaveMeta()
+	}
+
+	if Idx.termsFST != nil {
+		err := Idx.termsFST.Close()
+		if err != nil {
+			return err
+		}
+		Idx.termsFST = nil
+	}
+	if Idx.termsByID != nil {
+		err := Idx.termsByID.Close()
+		if err != nil {
+			return err
+		}
+		Idx.termsByID = nil
+	}
+	if Idx.docLengths != nil {
+		err := Idx.docLengths.Close()
+		if err != nil {
+			return err
+		}
+		Idx.docLengths = nil
+	}
+	return nil
+}
+
+func (Idx *DynamicIndex) getFilePath(name string) string {
+	if Idx.workingDir != "/" {
+		return Idx.workingDir + "/" + Idx.outputDir + "/" + name
+	}
+	return Idx.outputDir + "/" + name
 }
 
 // SaveMeta is a function to save the metadata of the main inverted index to disk.
 func (Idx *DynamicIndex) SaveMeta() error {
 	// save to disk
-	SpimiMeta := NewSpimiIndexMetadata(Idx.TermIDMap, Idx.docWordCount, Idx.docsCount, Idx.OSMFeatureMap, Idx.WikidataObjects)
+	Idx.averageDocLength = 0
+	for i := 0; i < Idx.docsCount; i++ {
+		Idx.averageDocLength += float64(Idx.docWordCount[i])
+	}
+	if Idx.docsCount != 0 {
+		Idx.averageDocLength /= float64(Idx.docsCount)
+	}
+
+	SpimiMeta := NewSpimiIndexMetadata(Idx.docsCount, Idx.averageDocLength, Idx.OSMFeatureMap, Idx.WikidataObjects)
 
 	buf, err := msgpack.Marshal(&SpimiMeta)
 	if err != nil {
 		return fmt.Errorf("error when marshalling metadata: %w", err)
 	}
 
-	var metadataFile *os.File
-	if Idx.workingDir != "/" {
-		metadataFile, err = os.OpenFile(Idx.workingDir+"/"+Idx.outputDir+"/"+"meta.metadata", os.O_RDWR|os.O_CREATE, 0700)
-		if err != nil {
-			return err
-		}
-	} else {
-		metadataFile, err = os.OpenFile(Idx.outputDir+"/"+"meta.metadata", os.O_RDWR|os.O_CREATE, 0700)
-		if err != nil {
-			return err
-		}
+	metadataFile, err := os.OpenFile(Idx.getFilePath("meta.metadata"), os.O_RDWR|os.O_CREATE, 0700)
+	if err != nil {
+		return err
 	}
 
 	defer metadataFile.Close()
@@ -898,35 +1011,37 @@ func (Idx *DynamicIndex) SaveMeta() error {
 	}
 
 	_, err = metadataFile.Write(buf)
+	if err != nil {
+		return err
+	}
 
-	return err
-}
-
-// LoadMeta is a function to load the metadata of the main inverted index from disk.
-func (Idx *DynamicIndex) LoadMeta() error {
-	var metadataFile *os.File
-	var err error
-	if Idx.workingDir != "/" {
-		metadataFile, err = os.OpenFile(Idx.workingDir+"/"+Idx.outputDir+"/"+"meta.metadata", os.O_RDWR|os.O_CREATE, 0700)
-		if err != nil {
-			return err
-		}
-	} else {
-		metadataFile, err = os.OpenFile(Idx.outputDir+"/"+"meta.metadata", os.O_RDWR|os.O_CREATE, 0700)
-		if err != nil {
-			return err
-		}
+	termsFSTFile, err := os.OpenFile(Idx.getFilePath(TERMS_FST_FILE), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0700)
+	if err != nil {
+		return err
 	}
+	defer termsFSTFile.Close()
 
-	defer metadataFile.Close()
+	err = Idx.TermIDMap.WriteFST(termsFSTFile)
+	if err != nil {
+		return fmt.Errorf("error when writing term dictionary: %w", err)
+	}
+	err = writeTermTable(Idx.getFilePath(TERMS_BY_ID_FILE), Idx.TermIDMap.IDToStr)
+	if err != nil {
+		return fmt.Errorf("error when writing term table: %w", err)
+	}
 
-	stat, err := os.Stat(metadataFile.Name())
+	// index baru = satu segment (merged index), segment lama dari server sebelumnya tidak dipakai lagi.
+	err = writeSegmentManifest(Idx.getFilePath(SEGMENTS_MANIFEST_FILE), NewSegmentManifest(Idx.docsCount))
 	if err != nil {
-		return fmt.Errorf("error when getting metadata file stat: %w", err)
+		return err
 	}
 
-	buf := make([]byte, stat.Size()*2)
-	_, err = metadataFile.Read(buf)
+	return writeUint32Array(Idx.getFilePath(DOC_WORD_COUNT_FILE), Idx.docWordCount)
+}
+
+// LoadMeta is a function to load the metadata of the main inverted index from disk.
+func (Idx *DynamicIndex) LoadMeta() error {
+	buf, err := os.ReadFile(Idx.getFilePath("meta.metadata"))
 	if err != nil {
 		return fmt.Errorf("error when reading metadata file: %w", err)
 	}
@@ -938,16 +1053,29 @@ func (Idx *DynamicIndex) LoadMeta() error {
 		return fmt.Errorf("error when unmarshalling metadata merged_index: %w", err)
 	}
 
-	Idx.TermIDMap = save.TermIDMap
-	Idx.docWordCount = save.DocWordCount
 	Idx.docsCount = save.DocsCount
+	Idx.averageDocLength = save.AverageDocLength
 	Idx.OSMFeatureMap = save.OSMFeatureMap
 	Idx.WikidataObjects = save.WikidataObjects
 
-	for i := 0; i < Idx.docsCount; i++ {
-		Idx.averageDocLength += float64(Idx.docWordCount[i])
+	termsFST, err := vellum.Open(Idx.getFilePath(TERMS_FST_FILE))
+	if err != nil {
+		return fmt.Errorf("error when opening term dictionary: %w", err)
+	}
+	Idx.termsFST = termsFST
+
+	termsByID, err := openTermTable(Idx.getFilePath(TERMS_BY_ID_FILE))
+	if err != nil {
+		return fmt.Errorf("error when opening term table: %w", err)
+	}
+	Idx.termsByID = termsByID
+	Idx.TermIDMap = pkg.NewIDMapFromFST(termsFST, termsByID)
+
+	docLengths, err := openUint32Array(Idx.getFilePath(DOC_WORD_COUNT_FILE))
+	if err != nil {
+		return fmt.Errorf("error when opening doc word count: %w", err)
 	}
-	Idx.averageDocLength /= float64(Idx.docsCount)
+	Idx.docLengths = docLengths
 	return nil
 }
 
@@ -963,8 +1091,12 @@ func (Idx *DynamicIndex) GetWorkingDir() string {
 	return Idx.workingDir
 }
 
-func (Idx *DynamicIndex) GetDocWordCount() map[int]int {
-	return Idx.docWordCount
+// GetDocWordCount. return jumlah term (name + address) di document docID.
+func (Idx *DynamicIndex) GetDocWordCount(docID int) int {
+	if Idx.docLengths != nil {
+		return Idx.docLengths.Get(docID)
+	}
+	return Idx.docWordCount[docID]
 }
 
 func (Idx *DynamicIndex) GetDocsCount() int {
@@ -1004,24 +1136,14 @@ func (Idx *DynamicIndex) GetFullAdress(street, postalCode, houseNumber string, c
 		nearestStreetID := -1
 		minDist := math.MaxFloat64
 		for _, currStreet := range streets {
-
-			streetBoundary := currStreet.Leaf.BoundaryLatLons
-			projection := geo.ProjectPointToLineCoord(
-				geo.NewCoordinate(streetBoundary[0][0],
-					streetBoundary[0][1]),
-				geo.NewCoordinate(streetBoundary[len(streetBoundary)-1][0],
-					streetBoundary[len(streetBoundary)-1][1]), geo.NewCoordinate(centerLat, centerLon),
-			)
-
-			dist := datastructure.HaversineDistance(centerLat, centerLon, projection.Lat, projection.Lon)
+			_, dist := geo.ProjectPointToPolyline(currStreet.Leaf.BoundaryLatLons, centerLat, centerLon)
 			if dist < minDist {
 				minDist = dist
 				nearestStreetID = currStreet.Leaf.ID
 			}
 		}
 		if nearestStreetID != -1 {
-			streetName, _, _, _, _ := geo.GetNameAddressTypeFromOSMWay(Idx.IndexedData.Ways[nearestStreetID].TagMap)
-			address += streetName
+			address += Idx.IndexedData.osmSpatialIndex.StreetNames[nearestStreetID]
 		}
 	}
 
@@ -1032,32 +1154,9 @@ func (Idx *DynamicIndex) GetFullAdress(street, postalCode, houseNumber string, c
 	// kelurahan
 	addressRegion := ""
 	city := ""
-	if Idx.IndexedData.osmSpatialIndex.AdministrativeBoundaryRtree.Size > 0 {
-		regions := Idx.IndexedData.osmSpatialIndex.AdministrativeBoundaryRtree.Search(boundingBox)
-
-		for _, region := range regions {
-			boundaryLat := make([]float64, 0, len(region.Leaf.OsmBound))
-			boundaryLon := make([]float64, 0, len(region.Leaf.OsmBound))
-
-			for _, boundLat := range region.Leaf.OsmBound[0] {
-				boundaryLat = append(boundaryLat, boundLat)
-			}
-
-			for _, boundLon := range region.Leaf.OsmBound[1] {
-				boundaryLon = append(boundaryLon, boundLon)
-			}
-
-			isPointInRegionBoundary := geo.IsPointInPolygon(centerLat, centerLon, boundaryLat, boundaryLon)
-			if isPointInRegionBoundary {
-				regionObj := Idx.IndexedData.regionsBoundary[region.Leaf.ID]
-
-				addressRegion = capitalize(regionObj.Village) + ", " + capitalize(regionObj.SubDistrict) + ", " + capitalize(regionObj.District) +
-					", " + capitalize(regionObj.Province)
-
-				city = regionObj.District
-				break
-			}
-		}
+	if regionObj, ok := Idx.IndexedData.adminIndex.Lookup(centerLat, centerLon); ok {
+		addressRegion = regionAddress(regionObj)
+		city = regionObj.District
 	}
 
 	address += ", " + addressRegion
@@ -1065,17 +1164,21 @@ func (Idx *DynamicIndex) GetFullAdress(street, postalCode, houseNumber string, c
 	return address, city
 }
 
-func capitalize(s string) string {
-	if s == "" {
-		return s
+// regionAddress. village, sub district, district, province (& country) dari boundary administratif.
+func regionAddress(region geo.Boundary) string {
+	components := []string{}
+	for _, name := range []string{region.Village, region.SubDistrict, region.District, region.Province, region.Country} {
+		if name != "" {
+			components = append(components, name)
+		}
 	}
-	return strings.ToUpper(s[:1]) + strings.ToLower(s[1:])
+	return strings.Join(components, ", ")
 }
 
-func getOSMFeature(tagMap map[string]string) map[string]string {
+func GetOSMFeature(tagMap map[string]string) map[string]string {
 	featureTag := make(map[string]string)
 	for key, value := range tagMap {
-		if _, ok := geo.ValidSearchTags[key]; ok {
+		if geo.GetIndexRules().IsFeatureKey(key) {
 			featureTag[key+"="+value] = ""
 		}
 	}
@@ -5,10 +5,13 @@ import (
 	"errors"
 	"io/fs"
 	"os"
+	"path/filepath"
 	"sort"
 	"sync"
 	"testing"
 
+	"github.com/blevesearch/vellum"
+	"github.com/lintang-b-s/osm-search/pkg"
 	"github.com/lintang-b-s/osm-search/pkg/datastructure"
 	"github.com/lintang-b-s/osm-search/pkg/geo"
 	"github.com/lintang-b-s/osm-search/pkg/kvdb"
@@ -64,7 +67,7 @@ func TestSpimiParseOSMNode(t *testing.T) {
 
 	t.Run("Test Spimi Parse OSM Nodes", func(t *testing.T) {
 		for _, c := range cases {
-			spimi, err := NewDynamicIndex("test", 500, false, nil, NewIndexedData([]geo.OSMWay{}, []geo.OSMNode{}, geo.NodeMapContainer{},
+			spimi, err := NewDynamicIndex("test", 500, false, nil, NewIndexedData(geo.SliceSource[geo.OSMWay]{}, geo.SliceSource[geo.OSMNode]{}, nil,
 				nil, geo.OSMSpatialIndex{}, []geo.Boundary{}), nil)
 			if err != nil {
 				t.Errorf("Error creating new dynamic index: %v", err)
@@ -117,7 +120,7 @@ func TestSpimiParseOSMNodes(t *testing.T) {
 
 	t.Run("Test Spimi Parse OSM Nodes", func(t *testing.T) {
 		for _, c := range cases {
-			spimi, err := NewDynamicIndex("test", 500, false, nil, NewIndexedData([]geo.OSMWay{}, []geo.OSMNode{}, geo.NodeMapContainer{},
+			spimi, err := NewDynamicIndex("test", 500, false, nil, NewIndexedData(geo.SliceSource[geo.OSMWay]{}, geo.SliceSource[geo.OSMNode]{}, nil,
 				nil, geo.OSMSpatialIndex{}, []geo.Boundary{}), nil)
 			if err != nil {
 				t.Errorf("Error creating new dynamic index: %v", err)
@@ -173,7 +176,7 @@ func TestSpimiInvert(t *testing.T) {
 
 	t.Run("Test Spimi Invert", func(t *testing.T) {
 		for _, c := range cases {
-			spimi, err := NewDynamicIndex("test", 500, false, nil, NewIndexedData([]geo.OSMWay{}, []geo.OSMNode{}, geo.NodeMapContainer{},
+			spimi, err := NewDynamicIndex("test", 500, false, nil, NewIndexedData(geo.SliceSource[geo.OSMWay]{}, geo.SliceSource[geo.OSMNode]{}, nil,
 				nil, geo.OSMSpatialIndex{}, []geo.Boundary{}), nil)
 			if err != nil {
 				t.Errorf("Error creating new dynamic index: %v", err)
@@ -265,7 +268,7 @@ func TestSpimiMerge(t *testing.T) {
 
 	t.Run("Test Spimi Merge", func(t *testing.T) {
 		for _, c := range cases {
-			spimi, err := NewDynamicIndex("test", 500, false, nil, NewIndexedData([]geo.OSMWay{}, []geo.OSMNode{}, geo.NodeMapContainer{},
+			spimi, err := NewDynamicIndex("test", 500, false, nil, NewIndexedData(geo.SliceSource[geo.OSMWay]{}, geo.SliceSource[geo.OSMNode]{}, nil,
 				nil, geo.OSMSpatialIndex{}, []geo.Boundary{}), nil)
 			if err != nil {
 				t.Errorf("Error creating new dynamic index: %v", err)
@@ -369,7 +372,7 @@ func TestMergeFieldLength(t *testing.T) {
 
 	t.Run("Test Spimi Merge", func(t *testing.T) {
 		for _, c := range cases {
-			spimi, err := NewDynamicIndex("test", 500, false, nil, NewIndexedData([]geo.OSMWay{}, []geo.OSMNode{}, geo.NodeMapContainer{},
+			spimi, err := NewDynamicIndex("test", 500, false, nil, NewIndexedData(geo.SliceSource[geo.OSMWay]{}, geo.SliceSource[geo.OSMNode]{}, nil,
 				nil, geo.OSMSpatialIndex{}, []geo.Boundary{}), nil)
 			if err != nil {
 				t.Errorf("Error creating new dynamic index: %v", err)
@@ -405,6 +408,33 @@ func TestMergeFieldLength(t *testing.T) {
 	})
 }
 
+// writeTestNodeCoords. tulis koordinat nodeMap ke node coordinate store di folder test.
+func writeTestNodeCoords(t *testing.T, nodeMap map[int64]osm.Node) *geo.NodeCoordStore {
+	prepare(t)
+	path := filepath.Join("test", geo.NODE_COORDS_FILE)
+	writer, err := geo.NewNodeCoordWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, node := range nodeMap {
+		err := writer.Add(id, node.Lat, node.Lon)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	coords, err := geo.OpenNodeCoordStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { coords.Close() })
+	return coords
+}
+
 func TestSpimiBatchIndex(t *testing.T) {
 	cases := []struct {
 		inputWays          []geo.OSMWay
@@ -581,15 +611,14 @@ func TestSpimiBatchIndex(t *testing.T) {
 				AdministrativeBoundaryRtree: datastructure.NewRtree(25, 50, 2),
 			}
 
-			spimi, err := NewDynamicIndex("test", 500, false, nil, NewIndexedData([]geo.OSMWay{}, []geo.OSMNode{}, geo.NodeMapContainer{},
+			spimi, err := NewDynamicIndex("test", 500, false, nil, NewIndexedData(geo.SliceSource[geo.OSMWay]{}, geo.SliceSource[geo.OSMNode]{}, nil,
 				nil, spatialIndex, []geo.Boundary{}), bboltKV)
 			if err != nil {
 				t.Errorf("Error creating new dynamic index: %v", err)
 			}
-			spimi.IndexedData.Ctr = geo.NodeMapContainer{}
-			spimi.IndexedData.Ctr.SetNodeMap(c.nodeMap)
-			spimi.IndexedData.Ways = c.inputWays
-			spimi.IndexedData.Nodes = c.inputNodes
+			spimi.IndexedData.Ctr = writeTestNodeCoords(t, c.nodeMap)
+			spimi.IndexedData.Ways = geo.SliceSource[geo.OSMWay](c.inputWays)
+			spimi.IndexedData.Nodes = geo.SliceSource[geo.OSMNode](c.inputNodes)
 
 			nodes, errResults := spimi.SpimiBatchIndex(context.Background())
 			assert.Nil(t, errResults)
@@ -886,3 +915,133 @@ func TestSpimiBatchIndex(t *testing.T) {
 // 	})
 
 // }
+
+func TestTermIDMapFromFST(t *testing.T) {
+	termIDMap := pkg.NewIDMap()
+	for _, term := range []string{"taman", "pintar", "kebun", "binatang"} {
+		termIDMap.GetID(term)
+	}
+
+	fstPath := filepath.Join("test", TERMS_FST_FILE)
+	fstFile, err := os.Create(fstPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, termIDMap.WriteFST(fstFile))
+	assert.Nil(t, fstFile.Close())
+	defer os.Remove(fstPath)
+
+	termTablePath := filepath.Join("test", TERMS_BY_ID_FILE)
+	assert.Nil(t, writeTermTable(termTablePath, termIDMap.IDToStr))
+	defer os.Remove(termTablePath)
+
+	termsFST, err := vellum.Open(fstPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer termsFST.Close()
+	termsByID, err := openTermTable(termTablePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer termsByID.Close()
+
+	fstIDMap := pkg.NewIDMapFromFST(termsFST, termsByID)
+	for term, id := range termIDMap.StrToID {
+		assert.Equal(t, id, fstIDMap.GetID(term))
+		assert.Equal(t, term, fstIDMap.GetStr(id))
+	}
+
+	baruID := fstIDMap.GetID("baru")
+	assert.Equal(t, 4, baruID)
+	assert.Equal(t, "baru", fstIDMap.GetStr(baruID))
+	assert.Equal(t, "", fstIDMap.GetStr(100))
+	assert.Equal(t, []string{"baru", "binatang", "kebun", "pintar", "taman"}, fstIDMap.GetSortedTerms())
+}
+
+func TestGetFullAdressOSMAdminBoundary(t *testing.T) {
+	square := func(minLat, minLon, maxLat, maxLon float64) [][]float64 {
+		return [][]float64{{minLat, minLon}, {minLat, maxLon}, {maxLat, maxLon}, {maxLat, minLon}, {minLat, minLon}}
+	}
+	admin := func(id int64, name, level string, ring [][]float64) geo.OSMRelation {
+		return geo.OSMRelation{ID: id, Type: geo.RELATION_BOUNDARY, OuterRings: [][][]float64{ring},
+			TagMap: map[string]string{"name": name, "boundary": "administrative", "admin_level": level}}
+	}
+	relations := []geo.OSMRelation{
+		admin(1, "Daerah Istimewa Yogyakarta", "4", square(-8.2, 110.0, -7.5, 110.9)),
+		admin(2, "Sleman", "5", square(-7.8, 110.2, -7.6, 110.5)),
+		admin(3, "Depok", "6", square(-7.8, 110.35, -7.7, 110.45)),
+		admin(4, "Caturtunggal", "7", square(-7.79, 110.37, -7.76, 110.41)),
+	}
+
+	spatialIndex := geo.OSMSpatialIndex{
+		StreetRtree:                 datastructure.NewRtree(25, 50, 2),
+		AdministrativeBoundaryRtree: datastructure.NewRtree(25, 50, 2),
+	}
+	boundaries := geo.BuildAdminBoundaries(relations, geo.AdminBoundaryConfig{FromOSM: true, Country: "ID"},
+		spatialIndex.AdministrativeBoundaryRtree)
+
+	spimi, err := NewDynamicIndex("test", 500, false, nil, NewIndexedData(geo.SliceSource[geo.OSMWay]{}, geo.SliceSource[geo.OSMNode]{}, nil,
+		nil, spatialIndex, boundaries), nil)
+	assert.Nil(t, err)
+
+	address, city := spimi.GetFullAdress("Jalan Kaliurang", "", "12", -7.77, 110.38)
+	assert.Equal(t, "Jalan Kaliurang, 12, Caturtunggal, Depok, Sleman, Daerah Istimewa Yogyakarta", address)
+	assert.Equal(t, "Sleman", city)
+
+	address, city = spimi.GetFullAdress("Jalan Magelang", "", "", -7.65, 110.3)
+	assert.Equal(t, "Jalan Magelang, Sleman, Daerah Istimewa Yogyakarta", address)
+	assert.Equal(t, "Sleman", city)
+}
+
+type streetCoordLookup map[int64][2]float64
+
+func (m streetCoordLookup) GetNode(id int64) (float64, float64, bool) {
+	coord, ok := m[id]
+	return coord[0], coord[1], ok
+}
+
+func TestGetFullAdressNearestStreet(t *testing.T) {
+	coords := streetCoordLookup{
+		// Jalan Lengkung melengkung ke utara, titik tengahnya +-11 m dari (-7.77, 110.38), garis titik awal-akhirnya +-1.1 km.
+		1: {-7.78, 110.37}, 2: {-7.7701, 110.38}, 3: {-7.78, 110.39},
+		// Jalan Lurus +-55 m dari (-7.77, 110.38).
+		4: {-7.7705, 110.375}, 5: {-7.7705, 110.385},
+		// Jalan Panjang +-22 m dari (-7.75, 110.4), titik tengahnya +-11 km.
+		6: {-7.7498, 110.399}, 7: {-7.7498, 110.6},
+	}
+	street := func(id int64, name string, nodeIDs ...int64) geo.OSMWay {
+		return geo.OSMWay{ID: id, NodeIDs: nodeIDs, TagMap: map[string]string{"name": name, "highway": "residential"}}
+	}
+	streetRtree := datastructure.NewRtree(25, 50, 2)
+	streetNames := []string{}
+	for _, way := range []geo.OSMWay{street(1, "Jalan Lengkung", 1, 2, 3), street(2, "Jalan Lurus", 4, 5),
+		street(3, "Jalan Panjang", 6, 7)} {
+		streetNames = geo.InsertStreet(streetRtree, streetNames, way, coords)
+	}
+
+	square := func(minLat, minLon, maxLat, maxLon float64) [][]float64 {
+		return [][]float64{{minLat, minLon}, {minLat, maxLon}, {maxLat, maxLon}, {maxLat, minLon}, {minLat, minLon}}
+	}
+	admin := func(id int64, name, level string, ring [][]float64) geo.OSMRelation {
+		return geo.OSMRelation{ID: id, Type: geo.RELATION_BOUNDARY, OuterRings: [][][]float64{ring},
+			TagMap: map[string]string{"name": name, "boundary": "administrative", "admin_level": level}}
+	}
+	spatialIndex := geo.OSMSpatialIndex{
+		StreetRtree:                 streetRtree,
+		StreetNames:                 streetNames,
+		AdministrativeBoundaryRtree: datastructure.NewRtree(25, 50, 2),
+	}
+	boundaries := geo.BuildAdminBoundaries([]geo.OSMRelation{admin(1, "Sleman", "5", square(-7.8, 110.2, -7.6, 110.7))},
+		geo.AdminBoundaryConfig{FromOSM: true, Country: "ID"}, spatialIndex.AdministrativeBoundaryRtree)
+
+	spimi, err := NewDynamicIndex("test", 500, false, nil, NewIndexedData(geo.SliceSource[geo.OSMWay]{}, geo.SliceSource[geo.OSMNode]{}, nil,
+		nil, spatialIndex, boundaries), nil)
+	assert.Nil(t, err)
+
+	address, _ := spimi.GetFullAdress("", "", "", -7.77, 110.38)
+	assert.Equal(t, "Jalan Lengkung, Sleman", address)
+
+	address, _ = spimi.GetFullAdress("", "", "", -7.75, 110.4)
+	assert.Equal(t, "Jalan Panjang, Sleman", address)
+}
@@ -17,4 +17,5 @@ type DocumentStoreI interface {
 
 type BboltDBI interface {
 	SaveDocs(nodes []datastructure.Node) error
+	SaveOSMIDs(osmIDs map[string]int) error
 }
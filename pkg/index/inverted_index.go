@@ -2,36 +2,60 @@ package index
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io/fs"
 	"iter"
 	"math"
 	"os"
 
 	"github.com/lintang-b-s/osm-search/pkg/compress"
+
+	"github.com/blevesearch/mmap-go"
 )
 
-// inverted index untuk satu field tertentu
+// inverted index untuk satu field tertentu.
+// file di disk:
+//   - <indexName>.index: posting lists (varint encoded).
+//   - <indexName>.metadata: postings directory. header + flat array [startPosition, len(postingList), lengthInBytes] yang di-index pakai termID.
+//   - <indexName>.doclen: flat array uint32 jumlah term field ini per docID.
+//
+// saat dibuka sebagai reader, ketiga file di-mmap, jadi RAM yang dipakai tidak bergantung ukuran vocabulary/jumlah document.
 type InvertedIndex struct {
 	indexName          string
 	dirName            string
-	postingMetadata    map[int][3]int // termID -> [startPositionInIndexFile, len(postingList), lengthInBytesOfPostingLists]
+	postingMetadata    map[int][3]int // termID -> [startPositionInIndexFile, len(postingList), lengthInBytesOfPostingLists]. hanya untuk writer.
 	indexFilePath      string
 	metadataFilePath   string
+	docLenFilePath     string
 	terms              []int
 	indexFile          *os.File
-	lenFieldInDoc      map[int]int // docID -> termCount (jumlah term di dalam document) untuk field tertentu
+	indexData          mmap.MMap // read-only mapping of indexFile. nil kalau index dibuka sebagai writer.
+	metadataFile       *os.File
+	postingDirectory   mmap.MMap    // read-only mapping of metadataFile. nil kalau index dibuka sebagai writer.
+	lenFieldInDoc      map[int]int  // docID -> termCount (jumlah term di dalam document) untuk field tertentu. hanya untuk writer.
+	fieldLengths       *uint32Array // docID -> termCount. hanya untuk reader.
 	averageFieldLength float64
+	termCount          int
 	currTermPosition   int
+	writer             bool
 }
 
+const (
+	postingDirectoryHeaderSize = 24 // termCount (8 byte), averageFieldLength (8 byte), entryCount (8 byte)
+	postingDirectoryEntrySize  = 16 // startPosition (8 byte), len(postingList) (4 byte), lengthInBytes (4 byte)
+)
+
 func NewInvertedIndex(index_name, directoryName, workingDir string,
 ) *InvertedIndex {
 
 	indexFilePath := directoryName + "/" + index_name + ".index"
 	metadataFilePath := directoryName + "/" + index_name + ".metadata"
+	docLenFilePath := directoryName + "/" + index_name + ".doclen"
 	if workingDir != "/" {
 		indexFilePath = workingDir + "/" + directoryName + "/" + index_name + ".index"
 		metadataFilePath = workingDir + "/" + directoryName + "/" + index_name + ".metadata"
+		docLenFilePath = workingDir + "/" + directoryName + "/" + index_name + ".doclen"
 	}
 
 	return &InvertedIndex{
@@ -40,6 +64,7 @@ func NewInvertedIndex(index_name, directoryName, workingDir string,
 		postingMetadata:  make(map[int][3]int),
 		indexFilePath:    indexFilePath,
 		metadataFilePath: metadataFilePath,
+		docLenFilePath:   docLenFilePath,
 		terms:            []int{},
 		lenFieldInDoc:    make(map[int]int),
 		currTermPosition: 0,
@@ -50,8 +75,27 @@ func (Idx *InvertedIndex) SetLenFieldInDoc(lenFieldInDoc map[int]int) {
 	Idx.lenFieldInDoc = lenFieldInDoc
 }
 
-func (Idx *InvertedIndex) GetLenFieldInDoc() map[int]int {
-	return Idx.lenFieldInDoc
+// GetFieldLength. return jumlah term field ini di document docID.
+func (Idx *InvertedIndex) GetFieldLength(docID int) int {
+	if Idx.fieldLengths != nil {
+		return Idx.fieldLengths.Get(docID)
+	}
+	return Idx.lenFieldInDoc[docID]
+}
+
+// forEachFieldLength. iterate semua document yang punya term di field ini.
+func (Idx *InvertedIndex) forEachFieldLength(fn func(docID, fieldLength int)) {
+	if Idx.fieldLengths != nil {
+		for docID := 0; docID < Idx.fieldLengths.Len(); docID++ {
+			if fieldLength := Idx.fieldLengths.Get(docID); fieldLength != 0 {
+				fn(docID, fieldLength)
+			}
+		}
+		return
+	}
+	for docID, fieldLength := range Idx.lenFieldInDoc {
+		fn(docID, fieldLength)
+	}
 }
 
 func (Idx *InvertedIndex) GetAverageFieldLength() float64 {
@@ -64,126 +108,136 @@ func (Idx *InvertedIndex) OpenWriter() error {
 		return err
 	}
 	Idx.indexFile = file
+	Idx.writer = true
 	return nil
 }
 
 func (Idx *InvertedIndex) Close() error {
-	if Idx.indexFile != nil {
-		err := Idx.indexFile.Close()
+	if Idx.indexData != nil {
+		err := Idx.indexData.Unmap()
 		if err != nil {
 			return err
 		}
-
-		metadataFile, err := os.OpenFile(Idx.metadataFilePath, os.O_RDWR|os.O_CREATE, 0700)
+		Idx.indexData = nil
+	}
+	if Idx.postingDirectory != nil {
+		err := Idx.postingDirectory.Unmap()
 		if err != nil {
 			return err
 		}
-		defer metadataFile.Close()
-
-		err = metadataFile.Truncate(0)
+		Idx.postingDirectory = nil
+	}
+	if Idx.metadataFile != nil {
+		err := Idx.metadataFile.Close()
 		if err != nil {
 			return err
 		}
-
-		metadataBuf := Idx.SerializeMetadata()
-
-		_, err = metadataFile.Write(metadataBuf)
+		Idx.metadataFile = nil
+	}
+	if Idx.fieldLengths != nil {
+		err := Idx.fieldLengths.Close()
 		if err != nil {
 			return err
 		}
-
-		pwd, err := os.Getwd()
+		Idx.fieldLengths = nil
+	}
+	if Idx.indexFile != nil {
+		err := Idx.indexFile.Close()
 		if err != nil {
 			return err
 		}
 
-		var bufferSizeFile *os.File
-		if pwd != "/" {
-			bufferSizeFile, err = os.OpenFile(pwd+"/"+Idx.dirName+"/"+Idx.indexName+"_size.metadata", os.O_RDWR|os.O_CREATE, 0700)
-		} else {
-			bufferSizeFile, err = os.OpenFile(Idx.dirName+"/"+Idx.indexName+"_size.metadata", os.O_RDWR|os.O_CREATE, 0700)
-		}
-		if err != nil {
-			return err
+		if !Idx.writer {
+			return nil
 		}
 
-		defer bufferSizeFile.Close()
-
-		err = bufferSizeFile.Truncate(0)
+		err = os.WriteFile(Idx.metadataFilePath, Idx.serializePostingDirectory(), 0700)
 		if err != nil {
 			return err
 		}
 
-		metadataBufferSize := len(metadataBuf)
-
-		bufferSizeBuf := make([]byte, 100)
-		binary.LittleEndian.PutUint64(bufferSizeBuf[:], math.Float64bits(float64(metadataBufferSize)))
-
-		_, err = bufferSizeFile.Write(bufferSizeBuf)
+		err = writeUint32Array(Idx.docLenFilePath, Idx.lenFieldInDoc)
 		if err != nil {
 			return err
 		}
-
 	}
 	return nil
 }
 
 func (Idx *InvertedIndex) OpenReader() error {
-	file, err := os.OpenFile(Idx.indexFilePath, os.O_RDONLY|os.O_CREATE, 0700)
+	file, indexData, err := mmapFile(Idx.indexFilePath)
 	if err != nil {
 		return err
 	}
 	Idx.indexFile = file
+	Idx.indexData = indexData
 
-	metadataFile, err := os.OpenFile(Idx.metadataFilePath, os.O_RDONLY|os.O_CREATE, 0700)
+	metadataFile, postingDirectory, err := mmapFile(Idx.metadataFilePath)
 	if err != nil {
 		return err
 	}
-	defer metadataFile.Close()
-
-	pwd, err := os.Getwd()
-	if err != nil {
-		return err
+	Idx.metadataFile = metadataFile
+	Idx.postingDirectory = postingDirectory
+	if len(postingDirectory) < postingDirectoryHeaderSize {
+		return fmt.Errorf("posting directory %s is empty or corrupted", Idx.metadataFilePath)
 	}
 
-	var bufferSizeFile *os.File
-	if pwd != "/" {
-		bufferSizeFile, err = os.OpenFile(pwd+"/"+Idx.dirName+"/"+Idx.indexName+"_size.metadata", os.O_RDONLY|os.O_CREATE, 0700)
-	} else {
-		bufferSizeFile, err = os.OpenFile(Idx.dirName+"/"+Idx.indexName+"_size.metadata", os.O_RDONLY|os.O_CREATE, 0700)
+	Idx.termCount = int(binary.LittleEndian.Uint64(postingDirectory[0:8]))
+	Idx.averageFieldLength = math.Float64frombits(binary.LittleEndian.Uint64(postingDirectory[8:16]))
+	entryCount := int(binary.LittleEndian.Uint64(postingDirectory[16:24]))
+	if len(postingDirectory) < postingDirectoryHeaderSize+entryCount*postingDirectoryEntrySize {
+		return fmt.Errorf("posting directory %s is truncated", Idx.metadataFilePath)
 	}
 
+	fieldLengths, err := openUint32Array(Idx.docLenFilePath)
 	if err != nil {
 		return err
 	}
-	defer bufferSizeFile.Close()
+	Idx.fieldLengths = fieldLengths
 
-	buf := make([]byte, 100)
-	_, err = bufferSizeFile.Read(buf)
-	if err != nil {
-		return err
-	}
-	approxBufferSize := int(math.Float64frombits(binary.LittleEndian.Uint64(buf)))
+	return nil
+}
 
-	buf = make([]byte, approxBufferSize)
-	_, err = metadataFile.Read(buf)
-	if err != nil {
-		return err
+func (Idx *InvertedIndex) postingDirectoryEntryCount() int {
+	return int(binary.LittleEndian.Uint64(Idx.postingDirectory[16:24]))
+}
+
+// getPostingMetadata. return [startPositionInIndexFile, len(postingList), lengthInBytesOfPostingLists] milik termID.
+func (Idx *InvertedIndex) getPostingMetadata(termID int) ([3]int, bool) {
+	if Idx.postingDirectory == nil {
+		postingMetadata, ok := Idx.postingMetadata[termID]
+		return postingMetadata, ok
 	}
-	Idx.DeserializeMetadata(buf)
 
-	return nil
+	if termID < 0 || termID >= Idx.postingDirectoryEntryCount() {
+		return [3]int{}, false
+	}
+	entry := Idx.postingDirectory[postingDirectoryHeaderSize+termID*postingDirectoryEntrySize:]
+	lenPostingList := int(binary.LittleEndian.Uint32(entry[8:12]))
+	if lenPostingList == 0 {
+		return [3]int{}, false
+	}
+	return [3]int{int(binary.LittleEndian.Uint64(entry[0:8])), lenPostingList,
+		int(binary.LittleEndian.Uint32(entry[12:16]))}, true
 }
 
+// GetPostingList. safe untuk dipanggil dari banyak goroutine sekaligus, karena tidak ada shared file offset (baca dari mmap / ReadAt).
 func (Idx *InvertedIndex) GetPostingList(termID int) ([]int, error) {
-	postingMetadata, ok := Idx.postingMetadata[termID]
+	postingMetadata, ok := Idx.getPostingMetadata(termID)
 	if !ok {
 		return []int{}, nil // in case termID not found
 	}
-	startPositionInIndexFile := int64(postingMetadata[0])
-	Idx.indexFile.Seek(startPositionInIndexFile, 0)
+
+	if Idx.indexData != nil {
+		buf, err := Idx.postingListBytes(postingMetadata[0], postingMetadata[2])
+		if err != nil {
+			return []int{}, err
+		}
+		return compress.DecodePostingsListNoCopy(buf), nil
+	}
+
 	buf := make([]byte, postingMetadata[2])
-	_, err := Idx.indexFile.Read(buf)
+	_, err := Idx.indexFile.ReadAt(buf, int64(postingMetadata[0]))
 	if err != nil {
 		return []int{}, err
 	}
@@ -192,6 +246,15 @@ func (Idx *InvertedIndex) GetPostingList(termID int) ([]int, error) {
 	return postingList, nil
 }
 
+// postingListBytes. return slice dari mmap index file di [startPosition, startPosition+lengthInBytes). jangan dimodifikasi, mapping-nya read-only.
+func (Idx *InvertedIndex) postingListBytes(startPosition, lengthInBytes int) ([]byte, error) {
+	endPosition := startPosition + lengthInBytes
+	if startPosition < 0 || endPosition > len(Idx.indexData) {
+		return nil, fmt.Errorf("posting list [%d, %d) out of index file bounds (%d bytes)", startPosition, endPosition, len(Idx.indexData))
+	}
+	return Idx.indexData[startPosition:endPosition], nil
+}
+
 func (Idx *InvertedIndex) AppendPostingList(termID int, postingList []int) error {
 	encodedPostingList := compress.EncodePostingsList(postingList)
 	startPositionInIndexFile, err := Idx.indexFile.Seek(0, 2)
@@ -248,24 +311,19 @@ func NewInvertedIndexIterator(idx *InvertedIndex) *InvertedIndexIterator {
 // IterateInvertedIndex. iterate inverted index sorted by termID. yield termID and postinglists. O(N) where N is total number of terms in inverted index.
 func (it *InvertedIndexIterator) IterateInvertedIndex() iter.Seq2[IndexIteratorItem, error] {
 	return func(yield func(IndexIteratorItem, error) bool) {
-		for it.invertedIndex.currTermPosition < len(it.invertedIndex.terms) {
-			termID := it.invertedIndex.terms[it.invertedIndex.currTermPosition]
-			it.invertedIndex.currTermPosition += 1
-			startPosition, _, lengthInBytes := it.invertedIndex.postingMetadata[termID][0], it.invertedIndex.postingMetadata[termID][1], it.invertedIndex.postingMetadata[termID][2]
-			_, err := it.invertedIndex.indexFile.Seek(int64(startPosition), 0)
-			if err != nil {
-				yield(NewIndexIteratorItem(-1, -1, []int{}), fmt.Errorf("error when iterating inverted index: %w", err))
+		termSize := it.invertedIndex.getTermSize()
+		for {
+			termID, ok := it.invertedIndex.nextTerm()
+			if !ok {
 				return
 			}
-			buf := make([]byte, lengthInBytes)
-			_, err = it.invertedIndex.indexFile.Read(buf)
+			postingList, err := it.invertedIndex.GetPostingList(termID)
 			if err != nil {
 				yield(NewIndexIteratorItem(-1, -1, []int{}), fmt.Errorf("error when iterating inverted index: %w", err))
 				return
 			}
 
-			postingList := compress.DecodePostingsList(buf)
-			item := NewIndexIteratorItem(termID, len(it.invertedIndex.terms), postingList)
+			item := NewIndexIteratorItem(termID, termSize, postingList)
 
 			if !yield(item, nil) {
 				return
@@ -274,6 +332,36 @@ func (it *InvertedIndexIterator) IterateInvertedIndex() iter.Seq2[IndexIteratorI
 	}
 }
 
+func (Idx *InvertedIndex) getTermSize() int {
+	if Idx.postingDirectory != nil {
+		return Idx.termCount
+	}
+	return len(Idx.terms)
+}
+
+// nextTerm. return termID berikutnya (sorted by termID) mulai dari currTermPosition.
+func (Idx *InvertedIndex) nextTerm() (int, bool) {
+	if Idx.postingDirectory == nil {
+		if Idx.currTermPosition >= len(Idx.terms) {
+			return -1, false
+		}
+		termID := Idx.terms[Idx.currTermPosition]
+		Idx.currTermPosition += 1
+		return termID, true
+	}
+
+	// reader: scan postings directory, skip termID yang tidak ada di index ini.
+	entryCount := Idx.postingDirectoryEntryCount()
+	for Idx.currTermPosition < entryCount {
+		termID := Idx.currTermPosition
+		Idx.currTermPosition += 1
+		if _, ok := Idx.getPostingMetadata(termID); ok {
+			return termID, true
+		}
+	}
+	return -1, false
+}
+
 func (Idx *InvertedIndex) ExitAndRemove() error {
 	err := Idx.Close()
 	if err != nil {
@@ -287,128 +375,46 @@ func (Idx *InvertedIndex) ExitAndRemove() error {
 	if err != nil {
 		return err
 	}
+	err = os.Remove(Idx.docLenFilePath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
 	return nil
 }
 
-func (Idx *InvertedIndex) GetAproximateMetadataBufferSize() int {
-	allLen := 4 * 3 // 4 byte* 3
-	termsSize := 4 * len(Idx.terms)
-	postingMetadata := 4 * 4 * len(Idx.postingMetadata)
-	docTermCountDict := 4 * 2 * len(Idx.lenFieldInDoc)
-	return allLen + termsSize + postingMetadata + docTermCountDict + 8
-}
-
-func (Idx *InvertedIndex) SerializeMetadata() []byte {
-	approxBufferSize := Idx.GetAproximateMetadataBufferSize()
-	buf := make([]byte, approxBufferSize)
-	leftPos := 0
-
-	binary.LittleEndian.PutUint32(buf[leftPos:], uint32(len(Idx.terms)))
-	leftPos += 4 // 32 bit
-
-	binary.LittleEndian.PutUint32(buf[leftPos:], uint32(len(Idx.postingMetadata)))
-	leftPos += 4 // 32 bit
-
-	binary.LittleEndian.PutUint32(buf[leftPos:], uint32(len(Idx.lenFieldInDoc)))
-	leftPos += 4 // 32 bit
-
-	for _, term := range Idx.terms {
-		// kita pakai uint32bit untuk menyimpan term
-
-		binary.LittleEndian.PutUint32(buf[leftPos:], uint32(term))
-		leftPos += 4 // 32 bit
-	}
-
-	for term, val := range Idx.postingMetadata {
-
-		binary.LittleEndian.PutUint32(buf[leftPos:], uint32(term))
-		leftPos += 4 // 32 bit
-
-		startPositionInIndexFile := val[0]    // 4 byte
-		lenPostingList := val[1]              // 4 byte
-		lengthInBytesOfPostingLists := val[2] // 4 byte
-
-		binary.LittleEndian.PutUint32(buf[leftPos:], uint32(lengthInBytesOfPostingLists))
-		leftPos += 4
-
-		binary.LittleEndian.PutUint32(buf[leftPos:], uint32(lenPostingList))
-		leftPos += 4
-
-		binary.LittleEndian.PutUint32(buf[leftPos:], uint32(startPositionInIndexFile))
-		leftPos += 4
-
+// serializePostingDirectory. postingMetadata -> header + flat array yang di-index pakai termID.
+func (Idx *InvertedIndex) serializePostingDirectory() []byte {
+	entryCount := 0
+	for termID := range Idx.postingMetadata {
+		if termID+1 > entryCount {
+			entryCount = termID + 1
+		}
 	}
 
-	for docID, termCount := range Idx.lenFieldInDoc {
-		// docID = 4 byte, termCount = 4 byte
-
-		binary.LittleEndian.PutUint32(buf[leftPos:], uint32(docID))
-		leftPos += 4 // 32 bit
-
-		binary.LittleEndian.PutUint32(buf[leftPos:], uint32(termCount))
-		leftPos += 4 // 32 bit
-
+	Idx.averageFieldLength = 0
+	for _, termCount := range Idx.lenFieldInDoc {
 		Idx.averageFieldLength += float64(termCount)
 	}
-
-	Idx.averageFieldLength = Idx.averageFieldLength / float64(len(Idx.lenFieldInDoc))
-
-	binary.LittleEndian.PutUint64(buf[leftPos:], math.Float64bits(Idx.averageFieldLength))
-
-	return buf
-}
-
-// DeserializeMetadata.
-func (Idx *InvertedIndex) DeserializeMetadata(buf []byte) {
-	leftPos := 0
-
-	termCount := int(binary.LittleEndian.Uint32(buf[0:4]))
-	leftPos += 4
-
-	PostingMetadatacount := int(binary.LittleEndian.Uint32(buf[4:8]))
-	leftPos += 4
-
-	docTermCountDictCount := int(binary.LittleEndian.Uint32(buf[8:12]))
-	leftPos += 4
-
-	Idx.terms = make([]int, termCount)
-	Idx.postingMetadata = make(map[int][3]int)
-	Idx.lenFieldInDoc = make(map[int]int)
-
-	for i := 0; i < termCount; i++ {
-
-		term := int(binary.LittleEndian.Uint32(buf[leftPos:]))
-		leftPos += 4
-		Idx.terms[i] = term
-	}
-
-	for i := 0; i < PostingMetadatacount; i++ {
-
-		term := int(binary.LittleEndian.Uint32(buf[leftPos:]))
-		leftPos += 4
-
-		lengthInBytesOfPostingLists := int(binary.LittleEndian.Uint32(buf[leftPos:]))
-		leftPos += 4
-
-		lenPostingList := int(binary.LittleEndian.Uint32(buf[leftPos:]))
-		leftPos += 4
-
-		startPositionInIndexFile := int(binary.LittleEndian.Uint32(buf[leftPos:]))
-		leftPos += 4
-
-		Idx.postingMetadata[term] = [3]int{startPositionInIndexFile, lenPostingList, lengthInBytesOfPostingLists}
+	if len(Idx.lenFieldInDoc) != 0 {
+		Idx.averageFieldLength = Idx.averageFieldLength / float64(len(Idx.lenFieldInDoc))
 	}
 
-	for i := 0; i < docTermCountDictCount; i++ {
+	buf := make([]byte, postingDirectoryHeaderSize+entryCount*postingDirectoryEntrySize)
+	binary.LittleEndian.PutUint64(buf[0:8], uint64(len(Idx.postingMetadata)))
+	binary.LittleEndian.PutUint64(buf[8:16], math.Float64bits(Idx.averageFieldLength))
+	binary.LittleEndian.PutUint64(buf[16:24], uint64(entryCount))
 
-		docID := int(binary.LittleEndian.Uint32(buf[leftPos:]))
-		leftPos += 4
+	for termID, val := range Idx.postingMetadata {
+		entry := buf[postingDirectoryHeaderSize+termID*postingDirectoryEntrySize:]
 
-		termCount := int(binary.LittleEndian.Uint32(buf[leftPos:]))
-		leftPos += 4
+		startPositionInIndexFile := val[0]
+		lenPostingList := val[1]
+		lengthInBytesOfPostingLists := val[2]
 
-		Idx.lenFieldInDoc[docID] = termCount
+		binary.LittleEndian.PutUint64(entry[0:8], uint64(startPositionInIndexFile))
+		binary.LittleEndian.PutUint32(entry[8:12], uint32(lenPostingList))
+		binary.LittleEndian.PutUint32(entry[12:16], uint32(lengthInBytesOfPostingLists))
 	}
 
-	Idx.averageFieldLength = math.Float64frombits(binary.LittleEndian.Uint64(buf[leftPos:]))
+	return buf
 }
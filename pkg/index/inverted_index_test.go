@@ -255,3 +255,61 @@ func TestIterateInvertedIndex(t *testing.T) {
 	})
 
 }
+
+func TestOpenReader(t *testing.T) {
+	t.Run("success read postings directory & field lengths from disk", func(t *testing.T) {
+		pwd, err := os.Getwd()
+		if err != nil {
+			t.Error(err)
+		}
+		prepare(t)
+
+		invIndex := NewInvertedIndex("test", "test", pwd)
+		err = invIndex.OpenWriter()
+		if err != nil {
+			t.Error(err)
+		}
+		invIndex.SetLenFieldInDoc(map[int]int{1: 2, 3: 4, 6: 6})
+
+		err = invIndex.AppendPostingList(1, []int{1, 3})
+		if err != nil {
+			t.Error(err)
+		}
+		err = invIndex.AppendPostingList(5, []int{3, 6})
+		if err != nil {
+			t.Error(err)
+		}
+		err = invIndex.Close()
+		if err != nil {
+			t.Error(err)
+		}
+
+		reader := NewInvertedIndex("test", "test", pwd)
+		err = reader.OpenReader()
+		if err != nil {
+			t.Error(err)
+		}
+		defer reader.Close()
+
+		postings, err := reader.GetPostingList(5)
+		assert.Nil(t, err)
+		assert.Equal(t, []int{3, 6}, postings)
+
+		postings, err = reader.GetPostingList(2)
+		assert.Nil(t, err)
+		assert.Empty(t, postings)
+
+		assert.Equal(t, 4, reader.GetFieldLength(3))
+		assert.Equal(t, 0, reader.GetFieldLength(2))
+		assert.Equal(t, 0, reader.GetFieldLength(100))
+		assert.Equal(t, 4.0, reader.GetAverageFieldLength())
+
+		termIDs := []int{}
+		for item, err := range NewInvertedIndexIterator(reader).IterateInvertedIndex() {
+			assert.Nil(t, err)
+			assert.Equal(t, 2, item.GetTermSize())
+			termIDs = append(termIDs, item.GetTermID())
+		}
+		assert.Equal(t, []int{1, 5}, termIDs)
+	})
+}
@@ -0,0 +1,644 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lintang-b-s/osm-search/pkg"
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	SEGMENTS_MANIFEST_FILE = "segments.manifest"
+	SEGMENT_MERGE_FACTOR   = 10 // jumlah segment di tier yang sama sebelum di-merge
+)
+
+// SegmentMeta. satu segment immutable: inverted index field name & address untuk docID [MinDocID, MaxDocID).
+type SegmentMeta struct {
+	ID           int
+	NameIndex    string
+	AddressIndex string
+	DocsCount    int
+	MinDocID     int
+	MaxDocID     int
+	Purged       []int // docID terhapus yang sudah dibuang dari posting list saat merge (sorted), tidak dihitung di DocsCount
+}
+
+// SegmentManifest. daftar segment yang bisa di-query, urut berdasarkan docID.
+type SegmentManifest struct {
+	NextSegmentID int
+	Segments      []SegmentMeta
+	Terms         map[string]int      // term -> termID yang tidak ada di term dictionary FST
+	Vocabulary    map[string]struct{} // term baru yang ada di segment hasil flush
+}
+
+// NewSegmentManifest. manifest awal hasil indexing: satu segment dari merged_name_index & merged_address_index.
+func NewSegmentManifest(docsCount int) SegmentManifest {
+	return SegmentManifest{
+		NextSegmentID: 1,
+		Segments: []SegmentMeta{{
+			ID:           0,
+			NameIndex:    "merged_name_index",
+			AddressIndex: "merged_address_index",
+			DocsCount:    docsCount,
+			MinDocID:     0,
+			MaxDocID:     docsCount,
+		}},
+		Terms:      map[string]int{},
+		Vocabulary: map[string]struct{}{},
+	}
+}
+
+type segment struct {
+	meta    SegmentMeta
+	name    *InvertedIndex
+	address *InvertedIndex
+}
+
+// SegmentedIndex. beberapa segment immutable + manifest. document baru ditambahkan sebagai segment baru (flush delta index),
+// segment kecil di-merge di background pakai tiered merge policy.
+type SegmentedIndex struct {
+	outputDir   string
+	workingDir  string
+	termIDMap   *pkg.IDMap
+	manifest    SegmentManifest
+	segments    []*segment
+	lock        sync.RWMutex // lock segments & manifest
+	mergeLock   sync.Mutex   // satu merge dalam satu waktu
+	mergeFactor int
+}
+
+func NewSegmentedIndex(outputDir, workingDir string, termIDMap *pkg.IDMap) *SegmentedIndex {
+	return &SegmentedIndex{
+		outputDir:   outputDir,
+		workingDir:  workingDir,
+		termIDMap:   termIDMap,
+		mergeFactor: SEGMENT_MERGE_FACTOR,
+	}
+}
+
+func (s *SegmentedIndex) getFilePath(name string) string {
+	if s.workingDir != "/" {
+		return s.workingDir + "/" + s.outputDir + "/" + name
+	}
+	return s.outputDir + "/" + name
+}
+
+// Open. load manifest & open semua segment. kalau manifest belum ada (index lama), main index dipakai sebagai segment 0.
+func (s *SegmentedIndex) Open(mainDocsCount int) error {
+	manifest, err := readSegmentManifest(s.getFilePath(SEGMENTS_MANIFEST_FILE))
+	if errors.Is(err, fs.ErrNotExist) {
+		manifest = NewSegmentManifest(mainDocsCount)
+	} else if err != nil {
+		return err
+	}
+
+	s.termIDMap.AddTerms(manifest.Terms)
+
+	segments := make([]*segment, 0, len(manifest.Segments))
+	for _, meta := range manifest.Segments {
+		seg, err := s.openSegment(meta)
+		if err != nil {
+			return err
+		}
+		segments = append(segments, seg)
+	}
+
+	s.lock.Lock()
+	s.manifest = manifest
+	s.segments = segments
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *SegmentedIndex) openSegment(meta SegmentMeta) (*segment, error) {
+	name := NewInvertedIndex(meta.NameIndex, s.outputDir, s.workingDir)
+	err := name.OpenReader()
+	if err != nil {
+		return nil, fmt.Errorf("error when opening segment %d: %w", meta.ID, err)
+	}
+	address := NewInvertedIndex(meta.AddressIndex, s.outputDir, s.workingDir)
+	err = address.OpenReader()
+	if err != nil {
+		name.Close()
+		return nil, fmt.Errorf("error when opening segment %d: %w", meta.ID, err)
+	}
+	return &segment{meta: meta, name: name, address: address}, nil
+}
+
+func readSegmentManifest(path string) (SegmentManifest, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return SegmentManifest{}, err
+	}
+	manifest := SegmentManifest{}
+	err = msgpack.Unmarshal(buf, &manifest)
+	if err != nil {
+		return SegmentManifest{}, fmt.Errorf("error when unmarshalling segment manifest: %w", err)
+	}
+	if manifest.Terms == nil {
+		manifest.Terms = map[string]int{}
+	}
+	if manifest.Vocabulary == nil {
+		manifest.Vocabulary = map[string]struct{}{}
+	}
+	return manifest, nil
+}
+
+// writeSegmentManifest. tulis ke file sementara lalu rename, jadi manifest di disk selalu lengkap.
+func writeSegmentManifest(path string, manifest SegmentManifest) error {
+	buf, err := msgpack.Marshal(&manifest)
+	if err != nil {
+		return fmt.Errorf("error when marshalling segment manifest: %w", err)
+	}
+	tmpPath := path + ".tmp"
+	err = os.WriteFile(tmpPath, buf, 0700)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// DocsCount. jumlah document di semua segment.
+func (s *SegmentedIndex) DocsCount() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	docsCount := 0
+	for _, seg := range s.segments {
+		docsCount += seg.meta.DocsCount
+	}
+	return docsCount
+}
+
+// MaxDocID. semua docID < MaxDocID sudah ada di segment.
+func (s *SegmentedIndex) MaxDocID() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.getLastMaxDocID()
+}
+
+func (s *SegmentedIndex) getLastMaxDocID() int {
+	if len(s.segments) == 0 {
+		return 0
+	}
+	return s.segments[len(s.segments)-1].meta.MaxDocID
+}
+
+// ContainsTerm. true kalau term baru (tidak ada di term dictionary FST) ada di salah satu segment.
+func (s *SegmentedIndex) ContainsTerm(term string) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	_, ok := s.manifest.Vocabulary[term]
+	return ok
+}
+
+// GetSegments. return metadata semua segment, urut berdasarkan docID.
+func (s *SegmentedIndex) GetSegments() []SegmentMeta {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	metas := make([]SegmentMeta, 0, len(s.segments))
+	for _, seg := range s.segments {
+		metas = append(metas, seg.meta)
+	}
+	return metas
+}
+
+// findSegment. return segment yang berisi docID. caller harus pegang s.lock.
+func (s *SegmentedIndex) findSegment(docID int) *segment {
+	i := sort.Search(len(s.segments), func(i int) bool {
+		return s.segments[i].meta.MaxDocID > docID
+	})
+	if i == len(s.segments) || s.segments[i].meta.MinDocID > docID {
+		return nil
+	}
+	return s.segments[i]
+}
+
+// GetDocWordCount. jumlah term (name + address) document di segment.
+func (s *SegmentedIndex) GetDocWordCount(docID int) int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	seg := s.findSegment(docID)
+	if seg == nil {
+		return 0
+	}
+	return seg.name.GetFieldLength(docID) + seg.address.GetFieldLength(docID)
+}
+
+// IsPurged. true kalau docID terhapus & sudah dibuang dari segment saat merge.
+func (s *SegmentedIndex) IsPurged(docID int) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	seg := s.findSegment(docID)
+	if seg == nil {
+		return false
+	}
+	i := sort.SearchInts(seg.meta.Purged, docID)
+	return i < len(seg.meta.Purged) && seg.meta.Purged[i] == docID
+}
+
+// NameField. field name dari semua segment.
+func (s *SegmentedIndex) NameField() *SegmentedField {
+	return &SegmentedField{segments: s, getField: func(seg *segment) *InvertedIndex { return seg.name }}
+}
+
+// AddressField. field address dari semua segment.
+func (s *SegmentedIndex) AddressField() *SegmentedField {
+	return &SegmentedField{segments: s, getField: func(seg *segment) *InvertedIndex { return seg.address }}
+}
+
+func (s *SegmentedIndex) segmentIndexName(segmentID int, field string) string {
+	return "segment_" + strconv.Itoa(segmentID) + "_" + field + "_index"
+}
+
+// SegmentData. isi segment baru dari document yang belum ada di segment mana pun (delta index).
+type SegmentData struct {
+	NameField    map[int][]int // termID -> docIDs (sorted)
+	NameLen      map[int]int
+	AddressField map[int][]int
+	AddressLen   map[int]int
+	Terms        []string // term baru (tidak ada di term dictionary FST) di document segment
+	DocsCount    int
+	MinDocID     int
+	MaxDocID     int
+}
+
+// writeSegment. tulis segment baru ke disk & open reader-nya. segment belum bisa di-query sampai publishSegment.
+func (s *SegmentedIndex) writeSegment(data SegmentData) (*segment, error) {
+	if s.getLastMaxDocID() > data.MinDocID {
+		return nil, fmt.Errorf("segment docID range [%d, %d) overlaps existing segments", data.MinDocID, data.MaxDocID)
+	}
+
+	s.lock.Lock()
+	segmentID := s.manifest.NextSegmentID
+	s.manifest.NextSegmentID++
+	s.lock.Unlock()
+
+	meta := SegmentMeta{
+		ID:           segmentID,
+		NameIndex:    s.segmentIndexName(segmentID, "name"),
+		AddressIndex: s.segmentIndexName(segmentID, "address"),
+		DocsCount:    data.DocsCount,
+		MinDocID:     data.MinDocID,
+		MaxDocID:     data.MaxDocID,
+	}
+
+	err := writeFieldIndex(NewInvertedIndex(meta.NameIndex, s.outputDir, s.workingDir), data.NameField, data.NameLen)
+	if err != nil {
+		return nil, err
+	}
+	err = writeFieldIndex(NewInvertedIndex(meta.AddressIndex, s.outputDir, s.workingDir), data.AddressField, data.AddressLen)
+	if err != nil {
+		return nil, err
+	}
+	return s.openSegment(meta)
+}
+
+func writeFieldIndex(index *InvertedIndex, postings map[int][]int, fieldLen map[int]int) error {
+	termIDs := make([]int, 0, len(postings))
+	for termID := range postings {
+		termIDs = append(termIDs, termID)
+	}
+	sort.Ints(termIDs)
+
+	index.SetLenFieldInDoc(fieldLen)
+	err := index.OpenWriter()
+	if err != nil {
+		return err
+	}
+	for _, termID := range termIDs {
+		if len(postings[termID]) == 0 {
+			continue
+		}
+		err := index.AppendPostingList(termID, postings[termID])
+		if err != nil {
+			index.Close()
+			return err
+		}
+	}
+	return index.Close()
+}
+
+// publishSegment. tambahkan segment ke manifest. caller (DeltaIndex.Flush) pegang lock delta, jadi document tidak pernah hilang/double saat query.
+func (s *SegmentedIndex) publishSegment(seg *segment, terms []string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	manifest := s.copyManifest()
+	manifest.Segments = append(manifest.Segments, seg.meta)
+	// hanya term yang ada di segment. term query (misal typo) yang dibuat GetID tidak perlu dipersist.
+	manifest.Terms = make(map[string]int, len(s.manifest.Terms)+len(terms))
+	for term, id := range s.manifest.Terms {
+		manifest.Terms[term] = id
+	}
+	for term, id := range s.termIDMap.GetNonFSTTerms(terms) {
+		manifest.Terms[term] = id
+	}
+	manifest.Vocabulary = make(map[string]struct{}, len(s.manifest.Vocabulary)+len(terms))
+	for term := range s.manifest.Vocabulary {
+		manifest.Vocabulary[term] = struct{}{}
+	}
+	for _, term := range terms {
+		manifest.Vocabulary[term] = struct{}{}
+	}
+	err := writeSegmentManifest(s.getFilePath(SEGMENTS_MANIFEST_FILE), manifest)
+	if err != nil {
+		return err
+	}
+
+	s.manifest = manifest
+	s.segments = append(s.segments, seg)
+	return nil
+}
+
+func (s *SegmentedIndex) copyManifest() SegmentManifest {
+	manifest := SegmentManifest{
+		NextSegmentID: s.manifest.NextSegmentID,
+		Segments:      make([]SegmentMeta, len(s.manifest.Segments)),
+		Terms:         s.manifest.Terms,
+		Vocabulary:    s.manifest.Vocabulary,
+	}
+	copy(manifest.Segments, s.manifest.Segments)
+	return manifest
+}
+
+// tier. segment dengan jumlah document di orde yang sama (basis mergeFactor) ada di tier yang sama.
+func (s *SegmentedIndex) tier(docsCount int) int {
+	if docsCount <= 1 {
+		return 0
+	}
+	return int(math.Log(float64(docsCount)) / math.Log(float64(s.mergeFactor)))
+}
+
+// findMergeCandidates. tiered merge policy: cari mergeFactor segment berurutan di tier yang sama.
+// segment yang di-merge harus berurutan supaya range docID segment tidak overlap.
+func (s *SegmentedIndex) findMergeCandidates() (int, int, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	start := 0
+	for i := 1; i <= len(s.segments); i++ {
+		if i < len(s.segments) && s.tier(s.segments[i].meta.DocsCount) == s.tier(s.segments[start].meta.DocsCount) {
+			if i-start+1 == s.mergeFactor {
+				return start, i + 1, true
+			}
+			continue
+		}
+		start = i
+	}
+	return 0, 0, false
+}
+
+// MaybeMerge. merge segment sampai tidak ada tier yang punya mergeFactor segment berurutan.
+// document yang ada di tombstone delta (boleh nil) dibuang dari segment hasil merge.
+func (s *SegmentedIndex) MaybeMerge(delta *DeltaIndex) error {
+	s.mergeLock.Lock()
+	defer s.mergeLock.Unlock()
+
+	for {
+		start, end, ok := s.findMergeCandidates()
+		if !ok {
+			return nil
+		}
+		err := s.mergeSegments(start, end, delta)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// mergeSegments. merge segment [start, end) jadi satu segment pakai MergeKArrayIterator. caller pegang s.mergeLock.
+// docID di tombstone delta dibuang dari posting list & tombstone-nya di-clear setelah manifest baru ditulis.
+func (s *SegmentedIndex) mergeSegments(start, end int, delta *DeltaIndex) error {
+	s.lock.Lock()
+	metas := make([]SegmentMeta, 0, end-start)
+	for _, seg := range s.segments[start:end] {
+		metas = append(metas, seg.meta)
+	}
+	segmentID := s.manifest.NextSegmentID
+	s.manifest.NextSegmentID++
+	s.lock.Unlock()
+
+	merged := SegmentMeta{
+		ID:           segmentID,
+		NameIndex:    s.segmentIndexName(segmentID, "name"),
+		AddressIndex: s.segmentIndexName(segmentID, "address"),
+		MinDocID:     metas[0].MinDocID,
+		MaxDocID:     metas[len(metas)-1].MaxDocID,
+	}
+	deleted := datastructure.NewBitmap()
+	if delta != nil {
+		deleted = delta.tombstonesInRange(merged.MinDocID, merged.MaxDocID)
+	}
+	for _, meta := range metas {
+		merged.DocsCount += meta.DocsCount
+		merged.Purged = append(merged.Purged, meta.Purged...)
+	}
+	deleted.ForEach(func(docID int) {
+		merged.Purged = append(merged.Purged, docID)
+	})
+	sort.Ints(merged.Purged)
+	merged.DocsCount -= deleted.Count()
+
+	log.Printf("merging segments %d-%d (%d docs) into segment %d...\n", metas[0].ID, metas[len(metas)-1].ID,
+		merged.DocsCount, segmentID)
+
+	// reader baru khusus untuk merge, iterator inverted index punya state sendiri.
+	err := s.mergeField(metas, merged.NameIndex, deleted, func(meta SegmentMeta) string { return meta.NameIndex })
+	if err != nil {
+		return err
+	}
+	err = s.mergeField(metas, merged.AddressIndex, deleted, func(meta SegmentMeta) string { return meta.AddressIndex })
+	if err != nil {
+		return err
+	}
+
+	mergedSegment, err := s.openSegment(merged)
+	if err != nil {
+		return err
+	}
+
+	oldSegments, err := s.commitMerge(start, end, mergedSegment, deleted, delta)
+	if err != nil {
+		return err
+	}
+
+	// query yang sedang berjalan pegang s.lock, jadi segment lama sudah tidak dipakai.
+	for _, seg := range oldSegments {
+		err := seg.name.ExitAndRemove()
+		if err != nil {
+			return err
+		}
+		err = seg.address.ExitAndRemove()
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Printf("merging segments into segment %d done\n", segmentID)
+	return nil
+}
+
+// commitMerge. ganti segment [start, end) dengan mergedSegment di manifest & clear tombstone document yang sudah dibuang.
+// lock delta dipegang sebelum s.lock (urutan sama dengan DeltaIndex.Flush), jadi document yang dibuang tidak pernah
+// terhitung dua kali (di DocsCount segment & tombstone delta).
+func (s *SegmentedIndex) commitMerge(start, end int, mergedSegment *segment, deleted *datastructure.Bitmap,
+	delta *DeltaIndex) ([]*segment, error) {
+	if delta != nil {
+		delta.lock.Lock()
+		defer delta.lock.Unlock()
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	manifest := s.copyManifest()
+	manifest.Segments = append(append(append([]SegmentMeta{}, manifest.Segments[:start]...), mergedSegment.meta), manifest.Segments[end:]...)
+	err := writeSegmentManifest(s.getFilePath(SEGMENTS_MANIFEST_FILE), manifest)
+	if err != nil {
+		return nil, err
+	}
+	oldSegments := append([]*segment{}, s.segments[start:end]...)
+	s.segments = append(append(append([]*segment{}, s.segments[:start]...), mergedSegment), s.segments[end:]...)
+	s.manifest = manifest
+
+	if delta != nil {
+		deleted.ForEach(delta.tombstones.Remove)
+	}
+	return oldSegments, nil
+}
+
+func (s *SegmentedIndex) mergeField(metas []SegmentMeta, mergedName string, deleted *datastructure.Bitmap,
+	indexName func(SegmentMeta) string) error {
+	indices := make([]*InvertedIndex, 0, len(metas))
+	defer func() {
+		for _, index := range indices {
+			index.Close()
+		}
+	}()
+	for _, meta := range metas {
+		index := NewInvertedIndex(indexName(meta), s.outputDir, s.workingDir)
+		err := index.OpenReader()
+		if err != nil {
+			return err
+		}
+		indices = append(indices, index)
+	}
+
+	mergedIndex := NewInvertedIndex(mergedName, s.outputDir, s.workingDir)
+	err := mergedIndex.OpenWriter()
+	if err != nil {
+		return err
+	}
+	err = mergeIndices(indices, mergedIndex, deleted)
+	if err != nil {
+		mergedIndex.Close()
+		return err
+	}
+	mergedIndex.SetLenFieldInDoc(mergeFieldLengths(indices, deleted))
+	return mergedIndex.Close()
+}
+
+// StartBackgroundMerge. cek merge policy setiap interval sampai ctx selesai.
+func (s *SegmentedIndex) StartBackgroundMerge(ctx context.Context, interval time.Duration, delta *DeltaIndex) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := s.MaybeMerge(delta)
+			if err != nil {
+				log.Printf("error when merging segments: %v\n", err)
+			}
+		}
+	}
+}
+
+// Close. close reader semua segment.
+func (s *SegmentedIndex) Close() error {
+	s.mergeLock.Lock()
+	defer s.mergeLock.Unlock()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, seg := range s.segments {
+		err := seg.name.Close()
+		if err != nil {
+			return err
+		}
+		err = seg.address.Close()
+		if err != nil {
+			return err
+		}
+	}
+	s.segments = nil
+	return nil
+}
+
+// SegmentedField. satu field (name/address) dari semua segment. posting list semua segment digabung, tetap sorted karena range docID segment urut & tidak overlap.
+type SegmentedField struct {
+	segments *SegmentedIndex
+	getField func(seg *segment) *InvertedIndex
+}
+
+// Close. reader segment di-close lewat SegmentedIndex.Close.
+func (f *SegmentedField) Close() error {
+	return nil
+}
+
+func (f *SegmentedField) GetPostingList(termID int) ([]int, error) {
+	f.segments.lock.RLock()
+	defer f.segments.lock.RUnlock()
+
+	if len(f.segments.segments) == 1 {
+		return f.getField(f.segments.segments[0]).GetPostingList(termID)
+	}
+
+	postings := []int{}
+	for _, seg := range f.segments.segments {
+		segmentPostings, err := f.getField(seg).GetPostingList(termID)
+		if err != nil {
+			return []int{}, err
+		}
+		postings = append(postings, segmentPostings...)
+	}
+	return postings, nil
+}
+
+func (f *SegmentedField) GetFieldLength(docID int) int {
+	f.segments.lock.RLock()
+	defer f.segments.lock.RUnlock()
+	seg := f.segments.findSegment(docID)
+	if seg == nil {
+		return 0
+	}
+	return f.getField(seg).GetFieldLength(docID)
+}
+
+// GetAverageFieldLength. rata-rata panjang field semua segment, dibobot jumlah document segment.
+func (f *SegmentedField) GetAverageFieldLength() float64 {
+	f.segments.lock.RLock()
+	defer f.segments.lock.RUnlock()
+	totalLength, docsCount := 0.0, 0
+	for _, seg := range f.segments.segments {
+		totalLength += f.getField(seg).GetAverageFieldLength() * float64(seg.meta.DocsCount)
+		docsCount += seg.meta.DocsCount
+	}
+	if docsCount == 0 {
+		return 0
+	}
+	return totalLength / float64(docsCount)
+}
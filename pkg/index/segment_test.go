@@ -0,0 +1,187 @@
+package index
+
+import (
+	"os"
+	"testing"
+
+	"github.com/lintang-b-s/osm-search/pkg"
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/stretchr/testify/assert"
+)
+
+func prepareSegments(t *testing.T, dir string, termIDMap *pkg.IDMap) string {
+	t.Helper()
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// main index: docID 0-9
+	tamanID := termIDMap.GetID("taman")
+	err = writeFieldIndex(NewInvertedIndex("merged_name_index", dir, pwd), map[int][]int{tamanID: {0, 9}},
+		map[int]int{0: 1, 9: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writeFieldIndex(NewInvertedIndex("merged_address_index", dir, pwd), map[int][]int{}, map[int]int{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pwd
+}
+
+func TestSegmentedIndex(t *testing.T) {
+	dir := "test_segments"
+	defer os.RemoveAll(dir)
+
+	termIDMap := pkg.NewIDMap()
+	pwd := prepareSegments(t, dir, termIDMap)
+	tamanID := termIDMap.GetID("taman")
+
+	segments := NewSegmentedIndex(dir, pwd, termIDMap)
+	segments.mergeFactor = 2
+	err := segments.Open(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	delta := NewDeltaIndex(termIDMap, segments.MaxDocID())
+	nameField := delta.WrapNameField(segments.NameField())
+
+	// term query (typo) yang tidak ada di document manapun
+	termIDMap.GetID("tamn")
+
+	// flush 2 segment kecil
+	for _, name := range []string{"Taman Pintar", "Taman Sari"} {
+		docID := delta.NextDocID()
+		delta.AddDocument(datastructure.Node{ID: docID, Name: name, Address: "Jalan Malioboro"})
+		err := delta.Flush(segments)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.Equal(t, 0, delta.DocsCount())
+	assert.Len(t, segments.GetSegments(), 3)
+	assert.Equal(t, 12, segments.DocsCount())
+	assert.Equal(t, 12, segments.MaxDocID())
+	assert.True(t, segments.ContainsTerm("pintar"))
+	assert.False(t, segments.ContainsTerm("kebun"))
+
+	postings, err := nameField.GetPostingList(tamanID)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{0, 9, 10, 11}, postings)
+	assert.Equal(t, 2, nameField.GetFieldLength(11))
+	assert.Equal(t, 4, segments.GetDocWordCount(11))
+
+	// segment 1 & 2 di tier yang sama, main index di tier yang lebih tinggi
+	err = segments.MaybeMerge(delta)
+	assert.Nil(t, err)
+	metas := segments.GetSegments()
+	if assert.Len(t, metas, 2) {
+		assert.Equal(t, 10, metas[1].MinDocID)
+		assert.Equal(t, 12, metas[1].MaxDocID)
+		assert.Equal(t, 2, metas[1].DocsCount)
+	}
+
+	postings, err = nameField.GetPostingList(tamanID)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{0, 9, 10, 11}, postings)
+	assert.Equal(t, 2, nameField.GetFieldLength(10))
+
+	manifest, err := readSegmentManifest(segments.getFilePath(SEGMENTS_MANIFEST_FILE))
+	assert.Nil(t, err)
+	assert.Contains(t, manifest.Terms, "pintar")
+	assert.Contains(t, manifest.Terms, "malioboro")
+	assert.NotContains(t, manifest.Terms, "tamn")
+
+	err = segments.Close()
+	assert.Nil(t, err)
+
+	t.Run("reopen segments from manifest", func(t *testing.T) {
+		reloadedTermIDMap := pkg.NewIDMap()
+		reloadedTermIDMap.AddTerms(map[string]int{"taman": tamanID})
+		reopened := NewSegmentedIndex(dir, pwd, reloadedTermIDMap)
+		err := reopened.Open(10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer reopened.Close()
+
+		assert.Equal(t, metas, reopened.GetSegments())
+		assert.Equal(t, termIDMap.GetID("sari"), reloadedTermIDMap.GetID("sari"))
+		_, ok := reloadedTermIDMap.Lookup("tamn")
+		assert.False(t, ok)
+
+		// id term baru tidak boleh sama dengan id term yang dipersist
+		newID := reloadedTermIDMap.GetID("kraton")
+		for term, id := range manifest.Terms {
+			assert.NotEqual(t, id, newID, term)
+		}
+		assert.NotEqual(t, tamanID, newID)
+
+		postings, err := reopened.AddressField().GetPostingList(termIDMap.GetID("malioboro"))
+		assert.Nil(t, err)
+		assert.Equal(t, []int{10, 11}, postings)
+	})
+}
+
+func TestMergeSegmentsPurgesDeletedDocs(t *testing.T) {
+	dir := "test_segments_purge"
+	defer os.RemoveAll(dir)
+
+	termIDMap := pkg.NewIDMap()
+	pwd := prepareSegments(t, dir, termIDMap)
+	tamanID := termIDMap.GetID("taman")
+
+	segments := NewSegmentedIndex(dir, pwd, termIDMap)
+	segments.mergeFactor = 2
+	err := segments.Open(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer segments.Close()
+
+	delta := NewDeltaIndex(termIDMap, segments.MaxDocID())
+	for _, name := range []string{"Taman Pintar", "Taman Sari"} {
+		docID := delta.NextDocID()
+		delta.AddDocument(datastructure.Node{ID: docID, Name: name, Address: "Jalan Malioboro"})
+		err := delta.Flush(segments)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// docID 10 dihapus setelah di-flush, docID 9 ada di main index yang tidak ikut di-merge.
+	delta.Delete(10)
+	delta.Delete(9)
+	assert.Equal(t, 10, segments.DocsCount()+delta.DocsCountDiff())
+
+	err = segments.MaybeMerge(delta)
+	assert.Nil(t, err)
+	metas := segments.GetSegments()
+	if assert.Len(t, metas, 2) {
+		assert.Equal(t, 1, metas[1].DocsCount)
+		assert.Equal(t, []int{10}, metas[1].Purged)
+	}
+
+	postings, err := segments.NameField().GetPostingList(tamanID)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{0, 9, 11}, postings)
+	postings, err = segments.AddressField().GetPostingList(termIDMap.GetID("malioboro"))
+	assert.Nil(t, err)
+	assert.Equal(t, []int{11}, postings)
+	assert.Equal(t, 0, segments.NameField().GetFieldLength(10))
+
+	assert.False(t, delta.IsDeleted(10))
+	assert.True(t, segments.IsPurged(10))
+	assert.True(t, delta.IsDeleted(9))
+	assert.False(t, segments.IsPurged(9))
+	assert.Equal(t, 10, segments.DocsCount()+delta.DocsCountDiff())
+
+	postings, err = delta.WrapNameField(segments.NameField()).GetPostingList(tamanID)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{0, 11}, postings)
+}
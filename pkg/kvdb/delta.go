@@ -0,0 +1,193 @@
+package kvdb
+
+import (
+	"bytes"
+	"encoding/gob"
+	"strconv"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+
+	"go.etcd.io/bbolt"
+)
+
+const lastAppliedChangeKey = "last_applied_change"
+
+// SaveOSMIDs. simpan mapping osm key -> docID. dipanggil saat indexing.
+func (db *KVDB) SaveOSMIDs(osmIDs map[string]int) error {
+	if len(osmIDs) == 0 {
+		return nil
+	}
+	return db.db.Batch(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(BBOLTDB_OSM_ID_BUCKET))
+		if err != nil {
+			return err
+		}
+		for osmKey, docID := range osmIDs {
+			err := b.Put([]byte(osmKey), []byte(strconv.Itoa(docID)))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetDocIDByOSMID. return docID dari osm key. ErrorsKeyNotExists kalau osm object tidak di index.
+func (db *KVDB) GetDocIDByOSMID(osmKey string) (docID int, err error) {
+	err = db.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(BBOLTDB_OSM_ID_BUCKET))
+		if b == nil {
+			return ErrorsKeyNotExists
+		}
+		val := b.Get([]byte(osmKey))
+		if val == nil {
+			return ErrorsKeyNotExists
+		}
+		docID, err = strconv.Atoi(string(val))
+		return err
+	})
+	return
+}
+
+// DeltaDocData. data r-tree document baru: tag osm feature (filter nearby) & boundary osm way (nil untuk osm node).
+// disimpan di delta docs bucket supaya Restore meng-index ulang document dengan filter & geometry yang sama.
+type DeltaDocData struct {
+	TagMap          map[string]string
+	BoundaryLatLons [][]float64
+}
+
+// DeltaDoc. document hasil osmChange/document api beserta DeltaDocData-nya.
+type DeltaDoc struct {
+	Node datastructure.Node
+	Data DeltaDocData
+}
+
+// DocChanges. perubahan doc store hasil satu osmChange. disimpan dalam satu transaksi.
+type DocChanges struct {
+	NewDocs       []datastructure.Node
+	NewDocsData   map[int]DeltaDocData // docID -> DeltaDocData document baru, boleh kosong
+	NewOSMIDs     map[string]int       // osm key -> docID baru
+	DeletedDocIDs []int
+	DeletedOSMIDs []string
+	ChangeName    string // nama file osmChange yang sudah di-apply
+}
+
+// SaveChanges. simpan document baru, tombstone & mapping osm key dalam satu transaksi.
+func (db *KVDB) SaveChanges(changes DocChanges) error {
+	db.Lock()
+	defer db.Unlock()
+	return db.db.Update(func(tx *bbolt.Tx) error {
+		buckets := make(map[string]*bbolt.Bucket, 5)
+		for _, name := range []string{BBOLTDB_BUCKET, BBOLTDB_OSM_ID_BUCKET, BBOLTDB_TOMBSTONE_BUCKET,
+			BBOLTDB_DELTA_DOCS_BUCKET, BBOLTDB_UPDATER_BUCKET} {
+			b, err := tx.CreateBucketIfNotExists([]byte(name))
+			if err != nil {
+				return err
+			}
+			buckets[name] = b
+		}
+
+		for _, osmKey := range changes.DeletedOSMIDs {
+			err := buckets[BBOLTDB_OSM_ID_BUCKET].Delete([]byte(osmKey))
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, docID := range changes.DeletedDocIDs {
+			err := buckets[BBOLTDB_TOMBSTONE_BUCKET].Put([]byte(strconv.Itoa(docID)), []byte{})
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, node := range changes.NewDocs {
+			err := db.Set(node, tx)
+			if err != nil {
+				return err
+			}
+			buf := new(bytes.Buffer)
+			err = gob.NewEncoder(buf).Encode(changes.NewDocsData[node.ID])
+			if err != nil {
+				return err
+			}
+			err = buckets[BBOLTDB_DELTA_DOCS_BUCKET].Put([]byte(strconv.Itoa(node.ID)), buf.Bytes())
+			if err != nil {
+				return err
+			}
+		}
+
+		for osmKey, docID := range changes.NewOSMIDs {
+			err := buckets[BBOLTDB_OSM_ID_BUCKET].Put([]byte(osmKey), []byte(strconv.Itoa(docID)))
+			if err != nil {
+				return err
+			}
+		}
+
+		if changes.ChangeName != "" {
+			err := buckets[BBOLTDB_UPDATER_BUCKET].Put([]byte(lastAppliedChangeKey), []byte(changes.ChangeName))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetDeltaState. return semua document hasil osmChange & docID yang sudah dihapus. dipakai untuk build ulang delta index saat server start.
+func (db *KVDB) GetDeltaState() (docs []DeltaDoc, tombstones []int, err error) {
+	docs = []DeltaDoc{}
+	tombstones = []int{}
+	err = db.db.View(func(tx *bbolt.Tx) error {
+		if b := tx.Bucket([]byte(BBOLTDB_TOMBSTONE_BUCKET)); b != nil {
+			err := b.ForEach(func(k, v []byte) error {
+				docID, err := strconv.Atoi(string(k))
+				if err != nil {
+					return err
+				}
+				tombstones = append(tombstones, docID)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		b := tx.Bucket([]byte(BBOLTDB_DELTA_DOCS_BUCKET))
+		if b == nil {
+			return nil
+		}
+		docBucket := tx.Bucket([]byte(BBOLTDB_BUCKET))
+		return b.ForEach(func(k, v []byte) error {
+			nodeBytes := docBucket.Get(k)
+			if nodeBytes == nil {
+				return nil
+			}
+			node, err := deserializeNode(nodeBytes)
+			if err != nil {
+				return err
+			}
+			doc := DeltaDoc{Node: node}
+			err = gob.NewDecoder(bytes.NewReader(v)).Decode(&doc.Data)
+			if err != nil {
+				return err
+			}
+			docs = append(docs, doc)
+			return nil
+		})
+	})
+	return
+}
+
+// GetLastAppliedChange. return nama file osmChange terakhir yang sudah di-apply. "" kalau belum ada.
+func (db *KVDB) GetLastAppliedChange() (name string, err error) {
+	err = db.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(BBOLTDB_UPDATER_BUCKET))
+		if b == nil {
+			return nil
+		}
+		name = string(b.Get([]byte(lastAppliedChangeKey)))
+		return nil
+	})
+	return
+}
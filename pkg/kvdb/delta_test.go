@@ -0,0 +1,37 @@
+package kvdb
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/stretchr/testify/assert"
+	"go.etcd.io/bbolt"
+)
+
+func TestDeltaState(t *testing.T) {
+	db, err := bbolt.Open(filepath.Join(t.TempDir(), "docs_store.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	kv := NewKVDB(db)
+
+	park := datastructure.NewNode(10, "Taman Pintar", -7.8, 110.36, "", "park", "", false)
+	cafe := datastructure.NewNode(11, "Kopi Klotok", -7.79, 110.37, "", "cafe", "", false)
+	boundary := [][]float64{{-7.8, 110.36}, {-7.8, 110.37}, {-7.79, 110.37}, {-7.8, 110.36}}
+	err = kv.SaveChanges(DocChanges{NewDocs: []datastructure.Node{park, cafe}, DeletedDocIDs: []int{3},
+		NewDocsData: map[int]DeltaDocData{10: {TagMap: map[string]string{"leisure": "park"}, BoundaryLatLons: boundary}}})
+	assert.Nil(t, err)
+
+	docs, tombstones, err := kv.GetDeltaState()
+	assert.Nil(t, err)
+	assert.Equal(t, []int{3}, tombstones)
+	if assert.Len(t, docs, 2) {
+		assert.Equal(t, "Taman Pintar", docs[0].Node.Name)
+		assert.Equal(t, map[string]string{"leisure": "park"}, docs[0].Data.TagMap)
+		assert.Equal(t, boundary, docs[0].Data.BoundaryLatLons)
+		assert.Empty(t, docs[1].Data.TagMap)
+		assert.Nil(t, docs[1].Data.BoundaryLatLons)
+	}
+}
@@ -19,8 +19,12 @@ var (
 )
 
 const (
-	BBOLTDB_BUCKET          = "osmSearch"
-	BBOLTDB_GEOFENCE_BUCKET = "geofence"
+	BBOLTDB_BUCKET            = "osmSearch"
+	BBOLTDB_GEOFENCE_BUCKET   = "geofence"
+	BBOLTDB_OSM_ID_BUCKET     = "osmID"     // osm key (node/123, way/123) -> docID
+	BBOLTDB_TOMBSTONE_BUCKET  = "tombstone" // docID yang dihapus/dimodifikasi lewat osmChange
+	BBOLTDB_DELTA_DOCS_BUCKET = "deltaDocs" // docID yang ditambahkan lewat osmChange
+	BBOLTDB_UPDATER_BUCKET    = "updater"
 )
 
 type KVDB struct {
@@ -187,8 +191,12 @@ func PutString(bb *bytes.Buffer, offset int, s string) int {
 }
 
 func GetDocSize(doc datastructure.Node) int {
-	return 4 + 4 + len([]byte(doc.Name)) + 8 + 8 + 4 + len([]byte(doc.Address)) + 4 + len([]byte(doc.Tipe)) +
-		4
+	size := 4 + 4 + len([]byte(doc.Name)) + 8 + 8 + 4 + len([]byte(doc.Address)) + 4 + len([]byte(doc.Tipe)) +
+		4 + len([]byte(doc.Source)) + 4 + len([]byte(doc.OSMType)) + 8 + 4 + 4 + len([]byte(doc.City))
+	for k, v := range doc.Tags {
+		size += 4 + len([]byte(k)) + 4 + len([]byte(v))
+	}
+	return size
 }
 
 func serializeNode(node datastructure.Node) ([]byte, error) {
@@ -215,6 +223,26 @@ func serializeNode(node datastructure.Node) ([]byte, error) {
 	stringLen = PutString(bb, leftPos, node.Tipe)
 	leftPos += stringLen + 4
 
+	stringLen = PutString(bb, leftPos, node.Source)
+	leftPos += stringLen + 4
+
+	stringLen = PutString(bb, leftPos, node.OSMType)
+	leftPos += stringLen + 4
+
+	binary.LittleEndian.PutUint64(bb.Bytes()[leftPos:], uint64(node.OSMID))
+	leftPos += 8
+
+	PutInt(bb, leftPos, len(node.Tags))
+	leftPos += 4
+	for k, v := range node.Tags {
+		stringLen = PutString(bb, leftPos, k)
+		leftPos += stringLen + 4
+		stringLen = PutString(bb, leftPos, v)
+		leftPos += stringLen + 4
+	}
+
+	PutString(bb, leftPos, node.City)
+
 	return bb.Bytes(), nil
 }
 
@@ -241,6 +269,40 @@ func deserializeNode(buf []byte) (datastructure.Node, error) {
 	node.Tipe = GetString(bb, leftPos)
 	leftPos += len([]byte(node.Tipe)) + 4
 
+	// field di bawah tidak ada di document yang disimpan versi sebelumnya.
+	if leftPos+4 > len(buf) {
+		return node, nil
+	}
+	node.Source = GetString(bb, leftPos)
+	leftPos += len([]byte(node.Source)) + 4
+
+	if leftPos+4+8+4 > len(buf) {
+		return node, nil
+	}
+	node.OSMType = GetString(bb, leftPos)
+	leftPos += len([]byte(node.OSMType)) + 4
+
+	node.OSMID = int64(binary.LittleEndian.Uint64(buf[leftPos:]))
+	leftPos += 8
+
+	tagsLen := GetInt(bb, leftPos)
+	leftPos += 4
+	if tagsLen > 0 {
+		node.Tags = make(map[string]string, tagsLen)
+	}
+	for i := 0; i < tagsLen; i++ {
+		k := GetString(bb, leftPos)
+		leftPos += len([]byte(k)) + 4
+		v := GetString(bb, leftPos)
+		leftPos += len([]byte(v)) + 4
+		node.Tags[k] = v
+	}
+
+	if leftPos+4 > len(buf) {
+		return node, nil
+	}
+	node.City = GetString(bb, leftPos)
+
 	return node, nil
 }
 
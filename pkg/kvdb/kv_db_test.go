@@ -0,0 +1,50 @@
+package kvdb
+
+import (
+	"testing"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSerializeNode(t *testing.T) {
+	node := datastructure.NewNode(7, "Kopi Kenangan", -6.2, 106.8, "Jalan Sudirman, Jakarta", "cafe", "Jakarta Pusat", false).
+		WithOSMObject("node", 123456789012, map[string]string{"opening_hours": "Mo-Su 08:00-22:00", "brand": "Kopi Kenangan"})
+	node.Source = "osm"
+
+	buf, err := serializeNode(node)
+	assert.Nil(t, err)
+	got, err := deserializeNode(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, node.ID, got.ID)
+	assert.Equal(t, node.Name, got.Name)
+	assert.Equal(t, node.Address, got.Address)
+	assert.Equal(t, node.Tipe, got.Tipe)
+	assert.Equal(t, "osm", got.Source)
+	assert.Equal(t, "node", got.OSMType)
+	assert.Equal(t, int64(123456789012), got.OSMID)
+	assert.Equal(t, node.Tags, got.Tags)
+	assert.Equal(t, "Jakarta Pusat", got.City)
+
+	t.Run("document without osm object", func(t *testing.T) {
+		doc := datastructure.NewNode(8, "Rumah", -6.2, 106.8, "", "custom", "", false)
+		buf, err := serializeNode(doc)
+		assert.Nil(t, err)
+		got, err := deserializeNode(buf)
+		assert.Nil(t, err)
+		assert.Equal(t, "", got.OSMType)
+		assert.Equal(t, int64(0), got.OSMID)
+		assert.Nil(t, got.Tags)
+	})
+
+	t.Run("document stored by previous version", func(t *testing.T) {
+		// document versi sebelumnya berakhir setelah field Tipe.
+		oldLen := 4 + 4 + len(node.Name) + 8 + 8 + 4 + len(node.Address) + 4 + len(node.Tipe)
+		got, err := deserializeNode(buf[:oldLen])
+		assert.Nil(t, err)
+		assert.Equal(t, node.Name, got.Name)
+		assert.Equal(t, node.Tipe, got.Tipe)
+		assert.Equal(t, "", got.Source)
+		assert.Equal(t, int64(0), got.OSMID)
+	})
+}
@@ -0,0 +1,147 @@
+package searcher
+
+import (
+	"math"
+	"sort"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+
+	"github.com/RadhiFadlillah/go-sastrawi"
+)
+
+// SearchWithConfidence. full text search satu query batch forward geocoding. setiap hasil diberi confidence (0-1) seberapa
+// cocok term query dengan nama & address document. kalau query punya lokasi, hasil di-rank ulang dengan bias jarak ke lokasi.
+func (se *Searcher) SearchWithConfidence(query datastructure.SearchBatchQuery) ([]datastructure.SearchMatch, error) {
+	k := query.TopK
+	if k <= 0 {
+		k = SEARCH_BATCH_DEFAULT_TOP_K
+	}
+	// filter type/radius & bias lokasi butuh kandidat lebih banyak dari k.
+	candidates := k
+	if query.HasLocation || query.Type != "" {
+		candidates = max(k, min(k*SEARCH_BATCH_CANDIDATE_FACTOR, SEARCH_BATCH_MAX_CANDIDATES))
+	}
+
+	docs, err := se.FreeFormQueryBySource(query.Query, query.Source, candidates, 0)
+	if err != nil {
+		return []datastructure.SearchMatch{}, err
+	}
+
+	queryTerms := sastrawi.Tokenize(query.Query)
+	matches := make([]datastructure.SearchMatch, 0, len(docs))
+	rankScores := make(map[int]float64, len(docs))
+	for _, doc := range docs {
+		if query.Type != "" && doc.Tipe != query.Type {
+			continue
+		}
+		match := datastructure.SearchMatch{Data: doc, Confidence: matchConfidence(queryTerms, doc)}
+		rankScores[doc.ID] = match.Confidence
+		if query.HasLocation {
+			match.Dist = datastructure.HaversineDistance(query.Lat, query.Lon, doc.Lat, doc.Lon)
+			if query.Radius > 0 && match.Dist > query.Radius {
+				continue
+			}
+			rankScores[doc.ID] = locationRankScore(match.Confidence, match.Dist)
+		}
+		matches = append(matches, match)
+	}
+
+	if query.HasLocation {
+		sort.SliceStable(matches, func(i, j int) bool {
+			return rankScores[matches[i].Data.ID] > rankScores[matches[j].Data.ID]
+		})
+	}
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches, nil
+}
+
+// AutocompleteNear. autocomplete dengan bias lokasi: kandidat autocomplete di-rank ulang dengan confidence & jarak ke (lat, lon),
+// sama seperti SearchWithConfidence untuk query yang punya lokasi.
+func (se *Searcher) AutocompleteNear(query, source string, k int, lat, lon float64) ([]datastructure.Node, error) {
+	candidates := max(k, min(k*SEARCH_BATCH_CANDIDATE_FACTOR, SEARCH_BATCH_MAX_CANDIDATES))
+	docs, err := se.AutocompleteBySource(query, source, candidates, 0)
+	if err != nil {
+		return []datastructure.Node{}, err
+	}
+
+	queryTerms := sastrawi.Tokenize(query)
+	rankScores := make(map[int]float64, len(docs))
+	for _, doc := range docs {
+		rankScores[doc.ID] = locationRankScore(matchConfidence(queryTerms, doc),
+			datastructure.HaversineDistance(lat, lon, doc.Lat, doc.Lon))
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		return rankScores[docs[i].ID] > rankScores[docs[j].ID]
+	})
+	if len(docs) > k {
+		docs = docs[:k]
+	}
+	return docs, nil
+}
+
+// locationRankScore. skor rank hasil yang punya jarak (km) ke lokasi query: confidence dikurangi bias jarak.
+func locationRankScore(confidence, dist float64) float64 {
+	return confidence - SEARCH_BATCH_LOCATION_BIAS*dist/(dist+SEARCH_BATCH_LOCATION_SCALE)
+}
+
+// matchConfidence. rata-rata kecocokan setiap term query dengan term nama & address document: 1 untuk term yang sama,
+// lebih kecil untuk prefix atau typo (edit distance 1-2), 0 kalau tidak ada yang cocok.
+func matchConfidence(queryTerms []string, doc datastructure.Node) float64 {
+	if len(queryTerms) == 0 {
+		return 0
+	}
+	docTerms := sastrawi.Tokenize(doc.Name + " " + doc.Address)
+
+	total := 0.0
+	for _, queryTerm := range queryTerms {
+		best := 0.0
+		for _, docTerm := range docTerms {
+			best = math.Max(best, termSimilarity(queryTerm, docTerm))
+			if best == 1 {
+				break
+			}
+		}
+		total += best
+	}
+	return total / float64(len(queryTerms))
+}
+
+func termSimilarity(queryTerm, docTerm string) float64 {
+	if queryTerm == docTerm {
+		return 1
+	}
+	if len(queryTerm) >= 3 && len(docTerm) > len(queryTerm) && docTerm[:len(queryTerm)] == queryTerm {
+		return 0.9
+	}
+	switch dist := editDistance(queryTerm, docTerm); {
+	case dist == 1 && len(queryTerm) >= 3:
+		return 0.8
+	case dist == 2 && len(queryTerm) >= 5:
+		return 0.6
+	}
+	return 0
+}
+
+// editDistance. levenshtein distance antara a & b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -41,3 +41,24 @@ const (
 const (
 	osmObjContainWikiDataWeight = 10
 )
+
+const (
+	REVERSE_GEOCODING_RADIUS            = 0.35 // km. radius awal pencarian osm object terdekat, dikali 2 kalau tidak ada osm object
+	REVERSE_GEOCODING_MAX_RADIUS        = 5.0  // km. radius maksimal bawaan
+	REVERSE_GEOCODING_HOUSE_NUMBER_DIST = 0.05 // km. jarak maksimal osm object dengan addr:housenumber
+	REVERSE_GEOCODING_INTERSECTION_DIST = 0.3  // km. jarak maksimal persimpangan untuk cross street
+	REVERSE_GEOCODING_MAX_DOCS          = 50   // jumlah maksimal document yang dibaca untuk mencari jalan, nomor rumah & poi terdekat
+)
+
+const (
+	SEARCH_BATCH_DEFAULT_TOP_K    = 5
+	SEARCH_BATCH_CANDIDATE_FACTOR = 5   // kandidat = top k * factor kalau ada filter type/radius atau bias lokasi
+	SEARCH_BATCH_MAX_CANDIDATES   = 100 // jumlah kandidat maksimal
+	SEARCH_BATCH_LOCATION_BIAS    = 0.2 // pengurangan skor rank maksimal untuk hasil yang jauh dari lokasi
+	SEARCH_BATCH_LOCATION_SCALE   = 5.0 // km. jarak dengan pengurangan skor rank setengah dari SEARCH_BATCH_LOCATION_BIAS
+)
+
+const (
+	SNAP_TO_ROAD_RADIUS     = 0.05 // km. radius bawaan pencarian jalan terdekat titik gps
+	SNAP_TO_ROAD_MAX_RADIUS = 1.0  // km
+)
@@ -17,23 +17,25 @@ type NgramLM interface {
 type DynamicIndexer interface {
 	GetOutputDir() string
 	GetWorkingDir() string
-	GetDocWordCount() map[int]int
+	GetDocWordCount(docID int) int
 	GetDocsCount() int
 	GetTermIDMap() *pkg.IDMap
 	GetAverageDocLength() float64
 	BuildVocabulary()
 	GetOSMFeatureMap() *pkg.IDMap
 	IsWikiData(nodeID int) bool
+	Close() error
 }
 
 type SearcherDocStore interface {
 	GetDoc(docID int) (datastructure.Node, error)
+	GetDocIDByOSMID(osmKey string) (int, error)
 }
 
 type InvertedIndexI interface {
 	Close() error
 	GetPostingList(termID int) ([]int, error)
-	GetLenFieldInDoc() map[int]int
+	GetFieldLength(docID int) int
 	GetAverageFieldLength() float64
 }
 
@@ -41,4 +43,7 @@ type RtreeI interface {
 	ImprovedNearestNeighbor(p datastructure.Point) datastructure.OSMObject
 	Search(bound datastructure.RtreeBoundingBox) []datastructure.RtreeNode
 	NearestNeighboursRadiusFilterOSM(k int, offfset int, p datastructure.Point, maxRadius float64, osmFeature int) []datastructure.OSMObject
+	NearestNeighboursRadiusFilter(k, offset int, p datastructure.Point, maxRadius float64, filter func(datastructure.OSMObject) bool) []datastructure.OSMObject
+	InsertLeaf(bound datastructure.RtreeBoundingBox, leaf datastructure.OSMObject, reinsert bool)
+	Delete(leaf datastructure.OSMObject) bool
 }
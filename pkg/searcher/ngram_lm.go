@@ -330,7 +330,7 @@ func (lm *NGramLanguageModel) stupidBackoff(nextWord int, prevNgrams []int, n in
 		if newProb != 0 {
 			break
 		}
-		if len(prevNgrams) == 1 {
+		if len(prevNgrams) <= 1 { // term dari delta index tidak ada di n-gram, unigram count = 0
 			prevNgrams = []int{}
 			lambda = lambda * 0.4
 			continue
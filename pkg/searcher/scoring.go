@@ -11,10 +11,8 @@ func (se *Searcher) scoreBM25Field(allPostingsNameField map[int][]int,
 
 	documentScore := make(map[int]float64)
 
-	docCount := float64(se.Idx.GetDocsCount())
+	docCount := float64(se.docsCount())
 
-	nameLenDF := se.MainIndexNameField.GetLenFieldInDoc()
-	addressLenDF := se.MainIndexAddressField.GetLenFieldInDoc()
 	averageNameLenDF := se.MainIndexNameField.GetAverageFieldLength()
 	averageAddressLenDF := se.MainIndexAddressField.GetAverageFieldLength()
 
@@ -51,12 +49,12 @@ func (se *Searcher) scoreBM25Field(allPostingsNameField map[int][]int,
 		idf := math.Log10(docCount-float64(len(uniqueDocContainingTerm))+0.5) - math.Log10(float64(len(uniqueDocContainingTerm))+0.5) // log(N-df_t+0.5/df_t+0.5)
 
 		for docID, tftd := range tfTermDocNameField {
-			weightTD := NAME_WEIGHT * (tftd / (1 + NAME_B*((float64(nameLenDF[docID])/averageNameLenDF)-1)))
+			weightTD := NAME_WEIGHT * (tftd / (1 + NAME_B*((float64(se.MainIndexNameField.GetFieldLength(docID))/averageNameLenDF)-1)))
 			documentScore[docID] += (weightTD / (K1_BM25F + weightTD)) * idf
 		}
 
 		for docID, tftd := range tfTermDocAddressField {
-			weightTD := ADDRESS_WEIGHT * (tftd / (1 + NAME_B*((float64(addressLenDF[docID])/averageAddressLenDF)-1)))
+			weightTD := ADDRESS_WEIGHT * (tftd / (1 + NAME_B*((float64(se.MainIndexAddressField.GetFieldLength(docID))/averageAddressLenDF)-1)))
 			documentScore[docID] += (weightTD / (K1_BM25F + weightTD)) * idf
 		}
 
@@ -80,10 +78,8 @@ func (se *Searcher) scoreBM25FieldWithScores(allPostingsNameField map[int][]int,
 
 	documentScore := make(map[int]float64)
 
-	docCount := float64(se.Idx.GetDocsCount())
+	docCount := float64(se.docsCount())
 
-	nameLenDF := se.MainIndexNameField.GetLenFieldInDoc()
-	addressLenDF := se.MainIndexAddressField.GetLenFieldInDoc()
 	averageNameLenDF := se.MainIndexNameField.GetAverageFieldLength()
 	averageAddressLenDF := se.MainIndexAddressField.GetAverageFieldLength()
 
@@ -120,12 +116,12 @@ func (se *Searcher) scoreBM25FieldWithScores(allPostingsNameField map[int][]int,
 		idf := math.Log10(docCount-float64(len(uniqueDocContainingTerm))+0.5) - math.Log10(float64(len(uniqueDocContainingTerm))+0.5) // log(N-df_t+0.5/df_t+0.5)
 
 		for docID, tftd := range tfTermDocNameField {
-			weightTD := NAME_WEIGHT * (tftd / (1 + NAME_B*((float64(nameLenDF[docID])/averageNameLenDF)-1)))
+			weightTD := NAME_WEIGHT * (tftd / (1 + NAME_B*((float64(se.MainIndexNameField.GetFieldLength(docID))/averageNameLenDF)-1)))
 			documentScore[docID] += (weightTD / (K1_BM25F + weightTD)) * idf
 		}
 
 		for docID, tftd := range tfTermDocAddressField {
-			weightTD := ADDRESS_WEIGHT * (tftd / (1 + NAME_B*((float64(addressLenDF[docID])/averageAddressLenDF)-1)))
+			weightTD := ADDRESS_WEIGHT * (tftd / (1 + NAME_B*((float64(se.MainIndexAddressField.GetFieldLength(docID))/averageAddressLenDF)-1)))
 			documentScore[docID] += (weightTD / (K1_BM25F + weightTD)) * idf
 		}
 
@@ -145,8 +141,7 @@ func (se *Searcher) scoreBM25Plus(allPostingsField map[int][]int) []int {
 
 	documentScore := make(map[int]float64)
 
-	docsCount := float64(se.Idx.GetDocsCount())
-	docWordCount := se.Idx.GetDocWordCount()
+	docsCount := float64(se.docsCount())
 
 	avgDocLength := se.Idx.GetAverageDocLength()
 
@@ -163,7 +158,7 @@ func (se *Searcher) scoreBM25Plus(allPostingsField map[int][]int) []int {
 			// https://www.cs.otago.ac.nz/homepages/andrew/papers/2014-2.pdf
 
 			documentScore[docID] += idf * (DELTA +
-				((K1+1)+tftd)/(K1*(1-B+B*float64(docWordCount[docID])/avgDocLength)+tftd))
+				((K1+1)+tftd)/(K1*(1-B+B*float64(se.docWordCount(docID))/avgDocLength)+tftd))
 		}
 	}
 
@@ -184,7 +179,7 @@ func (se *Searcher) scoreTFIDFCosine(allPostings map[int][]int,
 	queryWordCount map[int]int) []int {
 	documentScore := make(map[int]float64) // menyimpan skor cosine tf-idf docs \dot tf-idf query
 
-	docsCount := float64(se.Idx.GetDocsCount())
+	docsCount := float64(se.docsCount())
 	docNorm := make(map[int]float64)
 	queryNorm := 0.0
 	for qTermID, postings := range allPostings {
@@ -3,16 +3,20 @@ package searcher
 import (
 	"errors"
 	"fmt"
+	"io/fs"
 	"log"
 	"math"
 	"os"
+	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
 
 	"github.com/lintang-b-s/osm-search/pkg"
 	"github.com/lintang-b-s/osm-search/pkg/datastructure"
 	"github.com/lintang-b-s/osm-search/pkg/geo"
 	"github.com/lintang-b-s/osm-search/pkg/index"
+	"github.com/lintang-b-s/osm-search/pkg/kvdb"
 
 	"github.com/RadhiFadlillah/go-sastrawi"
 )
@@ -25,13 +29,21 @@ type Searcher struct {
 	TermIDMap             *pkg.IDMap
 	DocStore              SearcherDocStore
 	osmRtree              RtreeI
+	adminIndex            *geo.AdminIndex        // boundary administratif untuk reverse geocoding. nil kalau index dibuat versi sebelumnya
+	reverseMaxRadius      float64                // km
+	roadNetwork           *geo.RoadNetwork       // jaringan jalan untuk snap-to-road. nil kalau index dibuat versi sebelumnya
+	intersections         *geo.IntersectionIndex // persimpangan jalan. nil kalau index dibuat versi sebelumnya
+	rtreeLock             sync.RWMutex           // lock osmRtree, karena bisa di update lewat osmChange saat server jalan.
+	segments              *index.SegmentedIndex
+	delta                 *index.DeltaIndex
 	similiarityScoring    SimiliarityScoring
 }
 
 func NewSearcher(idx DynamicIndexer, docStore SearcherDocStore, spell index.SpellCorrectorI,
 	scoring SimiliarityScoring) *Searcher {
 
-	return &Searcher{Idx: idx, DocStore: docStore, SpellCorrector: spell, similiarityScoring: scoring}
+	return &Searcher{Idx: idx, DocStore: docStore, SpellCorrector: spell, similiarityScoring: scoring,
+		reverseMaxRadius: REVERSE_GEOCODING_MAX_RADIUS}
 }
 
 func (se *Searcher) LoadMainIndex() error {
@@ -42,23 +54,22 @@ func (se *Searcher) LoadMainIndex() error {
 	if err != nil {
 		return err
 	}
-	mainIndexNameField := index.NewInvertedIndex("merged_name_index", se.Idx.GetOutputDir(), pwd)
-	err = mainIndexNameField.OpenReader()
-	if err != nil {
-		return err
-	}
-	se.MainIndexNameField = mainIndexNameField
 
-	mainIndexAddressField := index.NewInvertedIndex("merged_address_index", se.Idx.GetOutputDir(), pwd)
-	err = mainIndexAddressField.OpenReader()
+	// build vocabulary
+	se.Idx.BuildVocabulary()
+	se.TermIDMap = se.Idx.GetTermIDMap()
+
+	// main index + segment hasil flush delta index.
+	se.segments = index.NewSegmentedIndex(se.Idx.GetOutputDir(), pwd, se.TermIDMap)
+	err = se.segments.Open(se.Idx.GetDocsCount())
 	if err != nil {
 		return err
 	}
-	se.MainIndexAddressField = mainIndexAddressField
 
-	// build vocabulary
-	se.Idx.BuildVocabulary()
-	se.TermIDMap = se.Idx.GetTermIDMap()
+	// document hasil osmChange di-index di delta index & di-merge dengan segment saat query.
+	se.delta = index.NewDeltaIndex(se.TermIDMap, se.segments.MaxDocID())
+	se.MainIndexNameField = se.delta.WrapNameField(se.segments.NameField())
+	se.MainIndexAddressField = se.delta.WrapAddressField(se.segments.AddressField())
 
 	// load r*-tree
 	rt := datastructure.NewRtree(25, 50, 2)
@@ -69,6 +80,25 @@ func (se *Searcher) LoadMainIndex() error {
 	}
 	log.Printf("deserialized rtree done...")
 	se.osmRtree = rt
+
+	// boundary administratif. index yang dibuat versi sebelumnya belum punya file ini.
+	adminIndex, err := geo.LoadAdminIndex(filepath.Join(se.Idx.GetWorkingDir(), se.Idx.GetOutputDir(), geo.ADMIN_BOUNDARIES_FILE))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error when loading admin boundaries: %w", err)
+	}
+	se.adminIndex = adminIndex
+
+	roadNetwork, err := geo.LoadRoadNetwork(filepath.Join(se.Idx.GetWorkingDir(), se.Idx.GetOutputDir(), geo.ROAD_NETWORK_FILE))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error when loading road network: %w", err)
+	}
+	se.roadNetwork = roadNetwork
+
+	intersections, err := geo.LoadIntersectionIndex(filepath.Join(se.Idx.GetWorkingDir(), se.Idx.GetOutputDir(), geo.INTERSECTIONS_FILE))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error when loading intersections: %w", err)
+	}
+	se.intersections = intersections
 	return nil
 }
 
@@ -79,7 +109,21 @@ func (se *Searcher) Close() error {
 	}
 
 	err = se.MainIndexAddressField.Close()
-	return err
+	if err != nil {
+		return err
+	}
+
+	err = se.segments.Close()
+	if err != nil {
+		return err
+	}
+
+	err = se.roadNetwork.Close()
+	if err != nil {
+		return err
+	}
+
+	return se.Idx.Close()
 }
 
 type docWithScore struct {
@@ -95,6 +139,37 @@ func newDocWithScore(docID int, score float64) docWithScore {
 }
 
 func (se *Searcher) FreeFormQuery(query string, k, offset int) ([]datastructure.Node, error) {
+	return se.FreeFormQueryBySource(query, "", k, offset)
+}
+
+// FreeFormQueryBySource. sama dengan FreeFormQuery tapi hanya return document dari source (osm atau source document custom). source kosong = semua document.
+func (se *Searcher) FreeFormQueryBySource(query, source string, k, offset int) ([]datastructure.Node, error) {
+	return se.freeFormQuery(query, source, k, offset, nil)
+}
+
+// FreeFormQueryInBox. sama dengan FreeFormQueryBySource tapi hanya document yang titiknya di dalam bounding box. docID hasil
+// range query r-tree di-intersect dengan semua document dari posting list query (setelah scoring, supaya idf tetap dari
+// seluruh index), jadi hasil tidak terbatas k document teratas tanpa filter.
+func (se *Searcher) FreeFormQueryInBox(query, source string, k, offset int, minLat, minLon, maxLat, maxLon float64) ([]datastructure.Node, error) {
+	boundingBox := datastructure.NewRtreeBoundingBox(2, []float64{minLat, minLon}, []float64{maxLat, maxLon})
+	inBox := datastructure.NewBitmap()
+	se.rtreeLock.RLock()
+	for _, osmObject := range se.osmRtree.Search(boundingBox) {
+		// bound leaf lebih besar dari titik document (RtreeLeafBound).
+		leaf := osmObject.Leaf
+		if leaf.Lat >= minLat && leaf.Lat <= maxLat && leaf.Lon >= minLon && leaf.Lon <= maxLon {
+			inBox.Set(leaf.ID)
+		}
+	}
+	se.rtreeLock.RUnlock()
+	if inBox.Count() == 0 {
+		return []datastructure.Node{}, nil
+	}
+	return se.freeFormQuery(query, source, k, offset, inBox)
+}
+
+// freeFormQuery. docFilter != nil = hanya docID di docFilter yang di-return.
+func (se *Searcher) freeFormQuery(query, source string, k, offset int, docFilter *datastructure.Bitmap) ([]datastructure.Node, error) {
 	if query == "" {
 		return []datastructure.Node{}, errors.New("query is empty")
 	}
@@ -104,55 +179,13 @@ func (se *Searcher) FreeFormQuery(query string, k, offset int) ([]datastructure.
 
 	queryTerms := sastrawi.Tokenize(query)
 
-	queryTermsID := make([]int, 0, len(queryTerms))
-
-	// {{term1,term1OneEdit}, {term2, term2Edit}, ...}
-	allPossibleQueryTerms := make([][]datastructure.WordCandidate, len(queryTerms))
-
 	queryWordCount := make(map[int]int, len(queryTerms))
 
-	for i, tokenizedTerm := range queryTerms {
-		isInVocab := se.TermIDMap.IsInVocabulary(tokenizedTerm)
-
-		if !isInVocab {
-
-			correctionOne, correctionOneString, err := se.SpellCorrector.GetWordCandidates(tokenizedTerm, 1)
-			if err != nil {
-				return []datastructure.Node{}, err
-			}
-			correctionTwo, correctionTwoString, err := se.SpellCorrector.GetWordCandidates(tokenizedTerm, 2)
-			if err != nil {
-				return []datastructure.Node{}, err
-			}
-
-			wordCandidates := make([]datastructure.WordCandidate, 0, len(correctionOne))
-			for i, correction := range correctionOne {
-				wordCandidates = append(wordCandidates, datastructure.NewWordCandidate(correction, tokenizedTerm, correctionOneString[i]))
-			}
-
-			wordCandidatesTwo := make([]datastructure.WordCandidate, 0, len(correctionTwo))
-			for i, correction := range correctionTwo {
-				wordCandidatesTwo = append(wordCandidatesTwo, datastructure.NewWordCandidate(correction, tokenizedTerm, correctionTwoString[i]))
-			}
-
-			allPossibleQueryTerms[i] = append(allPossibleQueryTerms[i], wordCandidates...)
-			allPossibleQueryTerms[i] = append(allPossibleQueryTerms[i], wordCandidatesTwo...)
-
-		} else {
-			termID := se.TermIDMap.GetID(tokenizedTerm)
-			allPossibleQueryTerms[i] = []datastructure.WordCandidate{datastructure.NewWordCandidate(termID, tokenizedTerm, tokenizedTerm)}
-		}
-	}
-
-	allCorrectQueryCandidates := se.SpellCorrector.GetCorrectQueryCandidates(allPossibleQueryTerms)
-	correctQuery, err := se.SpellCorrector.GetCorrectSpellingSuggestion(allCorrectQueryCandidates)
-
+	queryTermsID, err := se.correctQueryTerms(queryTerms)
 	if err != nil {
 		return []datastructure.Node{}, err
 	}
 
-	queryTermsID = append(queryTermsID, correctQuery...)
-
 	allPostingsNameField := make(map[int][]int, len(queryTerms))
 	allPostingsAddressField := make(map[int][]int, len(queryTerms))
 
@@ -186,25 +219,101 @@ func (se *Searcher) FreeFormQuery(query string, k, offset int) ([]datastructure.
 		docWithScores = se.scoreBM25Field(allPostingsNameField, allPostingsAddressField, queryTermsID)
 	}
 
-	relevantDocs := make([]datastructure.Node, 0, k)
+	if docFilter != nil {
+		filtered := make([]int, 0, len(docWithScores))
+		for _, docID := range docWithScores {
+			if docFilter.Contains(docID) {
+				filtered = append(filtered, docID)
+			}
+		}
+		docWithScores = filtered
+	}
+	return se.getDocsBySource(docWithScores, source, k, offset)
+}
 
-	for i := offset; i < len(docWithScores); i++ {
+// correctQueryTerms. term id dari setiap query term, term yang tidak ada di vocabulary diganti hasil spell correction.
+func (se *Searcher) correctQueryTerms(queryTerms []string) ([]int, error) {
+	// {{term1,term1OneEdit}, {term2, term2Edit}, ...}
+	allPossibleQueryTerms := make([][]datastructure.WordCandidate, len(queryTerms))
 
-		if i >= k+offset {
-			break
+	for i, tokenizedTerm := range queryTerms {
+		isInVocab := se.isInVocabulary(tokenizedTerm)
+
+		if !isInVocab {
+
+			correctionOne, correctionOneString, err := se.SpellCorrector.GetWordCandidates(tokenizedTerm, 1)
+			if err != nil {
+				return []int{}, err
+			}
+			correctionTwo, correctionTwoString, err := se.SpellCorrector.GetWordCandidates(tokenizedTerm, 2)
+			if err != nil {
+				return []int{}, err
+			}
+
+			wordCandidates := make([]datastructure.WordCandidate, 0, len(correctionOne))
+			for i, correction := range correctionOne {
+				wordCandidates = append(wordCandidates, datastructure.NewWordCandidate(correction, tokenizedTerm, correctionOneString[i]))
+			}
+
+			wordCandidatesTwo := make([]datastructure.WordCandidate, 0, len(correctionTwo))
+			for i, correction := range correctionTwo {
+				wordCandidatesTwo = append(wordCandidatesTwo, datastructure.NewWordCandidate(correction, tokenizedTerm, correctionTwoString[i]))
+			}
+
+			allPossibleQueryTerms[i] = append(allPossibleQueryTerms[i], wordCandidates...)
+			allPossibleQueryTerms[i] = append(allPossibleQueryTerms[i], wordCandidatesTwo...)
+
+		} else {
+			termID := se.TermIDMap.GetID(tokenizedTerm)
+			allPossibleQueryTerms[i] = []datastructure.WordCandidate{datastructure.NewWordCandidate(termID, tokenizedTerm, tokenizedTerm)}
 		}
+	}
+
+	allCorrectQueryCandidates := se.SpellCorrector.GetCorrectQueryCandidates(allPossibleQueryTerms)
+	return se.SpellCorrector.GetCorrectSpellingSuggestion(allCorrectQueryCandidates)
+}
 
-		doc, err := se.DocStore.GetDoc(docWithScores[i])
+// getDocsBySource. ambil document ke offset sampai offset+k dari docIDs (sudah diurutkan berdasarkan score) yang source-nya sama.
+func (se *Searcher) getDocsBySource(docIDs []int, source string, k, offset int) ([]datastructure.Node, error) {
+	relevantDocs := make([]datastructure.Node, 0, k)
+	if source == "" {
+		for i := offset; i < len(docIDs) && i < k+offset; i++ {
+			doc, err := se.DocStore.GetDoc(docIDs[i])
+			if err != nil {
+				return []datastructure.Node{}, err
+			}
+			relevantDocs = append(relevantDocs, doc)
+		}
+		return relevantDocs, nil
+	}
+
+	skipped := 0
+	for _, docID := range docIDs {
+		if len(relevantDocs) >= k {
+			break
+		}
+		doc, err := se.DocStore.GetDoc(docID)
 		if err != nil {
 			return []datastructure.Node{}, err
 		}
+		if doc.GetSource() != source {
+			continue
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
 		relevantDocs = append(relevantDocs, doc)
 	}
-
 	return relevantDocs, nil
 }
 
 func (se *Searcher) Autocomplete(query string, k, offset int) ([]datastructure.Node, error) {
+	return se.AutocompleteBySource(query, "", k, offset)
+}
+
+// AutocompleteBySource. sama dengan Autocomplete tapi hanya return document dari source. source kosong = semua document.
+func (se *Searcher) AutocompleteBySource(query, source string, k, offset int) ([]datastructure.Node, error) {
 	if query == "" {
 		return []datastructure.Node{}, errors.New("query is empty")
 	}
@@ -222,7 +331,7 @@ func (se *Searcher) Autocomplete(query string, k, offset int) ([]datastructure.N
 	for i, tokenizedTerm := range queryTerms {
 
 		originalQueryTerms = append(originalQueryTerms, se.TermIDMap.GetID(tokenizedTerm))
-		isInVocab := se.TermIDMap.IsInVocabulary(tokenizedTerm)
+		isInVocab := se.isInVocabulary(tokenizedTerm)
 
 		if (i == len(queryTerms)-1 && !isInVocab) || !isInVocab {
 			var (
@@ -329,13 +438,13 @@ func (se *Searcher) Autocomplete(query string, k, offset int) ([]datastructure.N
 
 	var (
 		wg                sync.WaitGroup
-		errChan           chan error
+		errChan           = make(chan error, len(matchedQueries))
 		docWithScoresChan = make(chan []docWithScore, len(matchedQueries))
 	)
 	wg.Add(len(matchedQueries))
 
 	relDocIDs := []docWithScore{}
-	for i, queryTerms := range matchedQueries {
+	for _, queryTerms := range matchedQueries {
 		go func(queryTerms []int) {
 			defer wg.Done()
 
@@ -359,18 +468,13 @@ func (se *Searcher) Autocomplete(query string, k, offset int) ([]datastructure.N
 				queryWordCount[termID] += 1
 			}
 
-			if i == 0 {
-				docWithScoresChan <- se.scoreBM25FieldWithScores(allPostingsNameField, allPostingsAddressField, queryTerms)
-			} else {
-				docWithScoresChan <- se.scoreBM25FieldWithScores(allPostingsNameField, allPostingsAddressField, queryTerms)
-
-			}
-
+			docWithScoresChan <- se.scoreBM25FieldWithScores(allPostingsNameField, allPostingsAddressField, queryTerms)
 		}(queryTerms)
 	}
 	go func() {
 		wg.Wait()
 		close(docWithScoresChan)
+		close(errChan)
 	}()
 
 	for docs := range docWithScoresChan {
@@ -378,89 +482,413 @@ func (se *Searcher) Autocomplete(query string, k, offset int) ([]datastructure.N
 
 	}
 
+	for err := range errChan {
+		if err != nil {
+			return []datastructure.Node{}, err
+		}
+	}
+
 	sort.Slice(relDocIDs, func(i, j int) bool {
 		return relDocIDs[i].Score > relDocIDs[j].Score
 	})
 
-	relevantDocs := make([]datastructure.Node, 0, len(relDocIDs))
+	docIDs := make([]int, 0, len(relDocIDs))
+	for _, doc := range relDocIDs {
+		docIDs = append(docIDs, doc.DocID)
+	}
 
-	for i := offset; i < len(relDocIDs); i++ {
-		if i >= k+offset {
-			break
-		}
+	return se.getDocsBySource(docIDs, source, k, offset)
+}
 
-		doc, err := se.DocStore.GetDoc(relDocIDs[i].DocID)
+// Lookup. return document dari osm key (node/123, way/456, relation/789), urut sesuai osmKeys.
+// osm object yang tidak di index atau sudah dihapus di-skip.
+func (se *Searcher) Lookup(osmKeys []string) ([]datastructure.Node, error) {
+	docs := make([]datastructure.Node, 0, len(osmKeys))
+	for _, osmKey := range osmKeys {
+		docID, err := se.DocStore.GetDocIDByOSMID(osmKey)
+		if errors.Is(err, kvdb.ErrorsKeyNotExists) {
+			continue
+		}
 		if err != nil {
-			return []datastructure.Node{}, err
+			return []datastructure.Node{}, fmt.Errorf("error when get docID of %s: %w", osmKey, err)
 		}
-		relevantDocs = append(relevantDocs, doc)
+		if se.isDeleted(docID) {
+			continue
+		}
+		doc, err := se.DocStore.GetDoc(docID)
+		if err != nil {
+			return []datastructure.Node{}, fmt.Errorf("error when get doc: %w", err)
+		}
+		docs = append(docs, doc)
 	}
+	return docs, nil
+}
 
-	return relevantDocs, nil
+// WithGeometry. isi Geometry & BBox setiap document dari leaf r-tree document tsb. tolerance (derajat) untuk simplifikasi
+// douglas-peucker, 0 = tanpa simplifikasi. document yang tidak punya leaf (custom document tanpa boundary) pakai geometry point.
+func (se *Searcher) WithGeometry(docs []datastructure.Node, tolerance float64) []datastructure.Node {
+	se.rtreeLock.RLock()
+	defer se.rtreeLock.RUnlock()
+	for i := range docs {
+		docs[i].Geometry, docs[i].BBox = geo.NewPointGeometry(docs[i].Lat, docs[i].Lon)
+
+		// bound leaf r-tree selalu berisi titik document.
+		candidates := se.osmRtree.Search(datastructure.NewRtreeBoundingBox(2, []float64{docs[i].Lat - 1e-9, docs[i].Lon - 1e-9},
+			[]float64{docs[i].Lat + 1e-9, docs[i].Lon + 1e-9}))
+		for _, candidate := range candidates {
+			if candidate.Leaf.ID == docs[i].ID {
+				docs[i].Geometry, docs[i].BBox = geo.NewGeometry(candidate.Leaf, tolerance)
+				break
+			}
+		}
+	}
+	return docs
+}
+
+type reverseCandidate struct {
+	docID                      int
+	dist                       float64
+	projectedLat, projectedLon float64
+	centerLat, centerLon       float64
+}
+
+// reverseArea. osm object area (polygon relation / osm way tertutup) yang berisi titik reverse geocoding.
+type reverseArea struct {
+	docID int
+	size  float64
+	leaf  datastructure.OSMObject
+}
+
+// SetReverseGeocodingMaxRadius. radius maksimal (km) pencarian osm object terdekat saat reverse geocoding.
+func (se *Searcher) SetReverseGeocodingMaxRadius(radius float64) {
+	se.reverseMaxRadius = radius
+}
+
+// ReverseGeocoding. alamat terstruktur titik (lat, lon): osm object terdekat, jalan terdekat, nomor rumah terdekat, poi terdekat
+// & hierarki administratif dari polygon boundary yang berisi titik, beserta jarak ke masing-masing.
+// layer menentukan osm object yang bisa jadi Place: building (semua osm object, default), street (hanya jalan), atau
+// village/district/province (boundary administratif yang berisi titik).
+func (se *Searcher) ReverseGeocoding(lat, lon float64, layer string) (datastructure.ReverseGeocodingResult, error) {
+	switch layer {
+	case "", datastructure.REVERSE_LAYER_BUILDING:
+		return se.reverseGeocodingNearest(lat, lon, false)
+	case datastructure.REVERSE_LAYER_STREET:
+		return se.reverseGeocodingNearest(lat, lon, true)
+	case datastructure.REVERSE_LAYER_VILLAGE, datastructure.REVERSE_LAYER_DISTRICT, datastructure.REVERSE_LAYER_PROVINCE:
+		return se.reverseGeocodingAdmin(lat, lon, layer)
+	default:
+		return datastructure.ReverseGeocodingResult{}, pkg.WrapErrorf(fmt.Errorf("invalid layer %q", layer), pkg.ErrBadParamInput,
+			"layer must be one of building, street, village, district or province")
+	}
+}
+
+// reverseGeocodingNearest. radius pencarian dimulai dari REVERSE_GEOCODING_RADIUS & dikali 2 sampai ada osm object yang cocok
+// atau sampai radius maksimal.
+func (se *Searcher) reverseGeocodingNearest(lat, lon float64, streetOnly bool) (datastructure.ReverseGeocodingResult, error) {
+	maxRadius := se.reverseMaxRadius
+	if maxRadius <= 0 {
+		maxRadius = REVERSE_GEOCODING_MAX_RADIUS
+	}
+	for radius := math.Min(REVERSE_GEOCODING_RADIUS, maxRadius); ; radius = math.Min(radius*2, maxRadius) {
+		result, found, err := se.reverseGeocodingWithin(lat, lon, radius, streetOnly)
+		if err != nil {
+			return datastructure.ReverseGeocodingResult{}, err
+		}
+		if found {
+			if result.Street != nil {
+				result.CrossStreet = se.crossStreet(lat, lon, result.Street.Data.Name)
+			}
+			return result, nil
+		}
+		if radius >= maxRadius {
+			break
+		}
+	}
+	return datastructure.ReverseGeocodingResult{}, pkg.WrapErrorf(errors.New("no osm object found"), pkg.ErrNotFound,
+		"no osm object found within %.2f km of the location", maxRadius)
 }
 
-func (se *Searcher) ReverseGeocoding(lat, lon float64) (datastructure.Node, error) {
-	upRightLat, upRightLon := geo.GetDestinationPoint(lat, lon, 45, 0.35)
-	downLeftLat, downLeftLon := geo.GetDestinationPoint(lat, lon, 225, 0.35)
+// reverseGeocodingWithin. kalau titik ada di dalam area (mall, kampus, taman, dll), Place = area terkecil yang berisi titik &
+// POI = poi terdekat di dalam area tsb. kalau tidak, Place = osm object terdekat.
+func (se *Searcher) reverseGeocodingWithin(lat, lon, radius float64, streetOnly bool) (datastructure.ReverseGeocodingResult, bool, error) {
+	// bounding box berisi lingkaran dengan jari-jari radius.
+	upRightLat, upRightLon := geo.GetDestinationPoint(lat, lon, 45, radius*math.Sqrt2)
+	downLeftLat, downLeftLon := geo.GetDestinationPoint(lat, lon, 225, radius*math.Sqrt2)
 	boundingBox := datastructure.NewRtreeBoundingBox(2, []float64{downLeftLat, downLeftLon}, []float64{upRightLat, upRightLon})
+	se.rtreeLock.RLock()
 	nearbyOsmObjects := se.osmRtree.Search(boundingBox)
+	se.rtreeLock.RUnlock()
 
-	projectedLat, projectedLon := -1.0, -1.0
-	nearestOsmObject := -1
-	minDist := math.MaxFloat64
+	candidates := make([]reverseCandidate, 0, len(nearbyOsmObjects))
+	areas := []reverseArea{}
 	for _, osmObject := range nearbyOsmObjects {
-		distance, currProjectedLat, currProjectedLon := pointDistanceToOsmWay(osmObject.Leaf.BoundaryLatLons, lat, lon,
-			osmObject.Leaf.Lat, osmObject.Leaf.Lon)
-		if distance < minDist {
-			minDist = distance
-			nearestOsmObject = osmObject.Leaf.ID
-			projectedLat = currProjectedLat
-			projectedLon = currProjectedLon
+		if se.isDeleted(osmObject.Leaf.ID) {
+			continue
 		}
+		if !streetOnly {
+			if inside, size := geo.PointInArea(lat, lon, osmObject.Leaf); inside {
+				areas = append(areas, reverseArea{docID: osmObject.Leaf.ID, size: size, leaf: osmObject.Leaf})
+				continue
+			}
+		}
+		var distance, projectedLat, projectedLon float64
+		if osmObject.Leaf.Polygon != nil {
+			distance, projectedLat, projectedLon = pointDistanceToMultipolygon(osmObject.Leaf.Polygon, lat, lon,
+				osmObject.Leaf.Lat, osmObject.Leaf.Lon)
+		} else {
+			distance, projectedLat, projectedLon = pointDistanceToOsmWay(osmObject.Leaf.BoundaryLatLons, lat, lon,
+				osmObject.Leaf.Lat, osmObject.Leaf.Lon)
+		}
+		if projectedLat == -1 && projectedLon == -1 {
+			projectedLat, projectedLon = osmObject.Leaf.Lat, osmObject.Leaf.Lon
+		}
+		if distance > radius {
+			// di luar lingkaran, bisa jadi ada osm object lebih dekat di luar bounding box.
+			continue
+		}
+		candidates = append(candidates, reverseCandidate{docID: osmObject.Leaf.ID, dist: distance,
+			projectedLat: projectedLat, projectedLon: projectedLon, centerLat: osmObject.Leaf.Lat, centerLon: osmObject.Leaf.Lon})
 	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].dist < candidates[j].dist
+	})
+	sort.SliceStable(areas, func(i, j int) bool {
+		return areas[i].size < areas[j].size
+	})
 
-	doc, err := se.DocStore.GetDoc(nearestOsmObject)
-	if err != nil {
-		return datastructure.Node{}, fmt.Errorf("error when get doc: %w", err)
+	if len(areas) == 0 && len(candidates) == 0 {
+		return datastructure.ReverseGeocodingResult{}, false, nil
+	}
+
+	result := datastructure.ReverseGeocodingResult{Admin: se.adminAddress(lat, lon), Match: datastructure.REVERSE_MATCH_NEARBY}
+	found := false
+	for i, candidate := range candidates {
+		if i >= REVERSE_GEOCODING_MAX_DOCS || (streetOnly && found) ||
+			(found && result.Street != nil && result.POI != nil && (result.HouseNumber != nil ||
+				candidate.dist > REVERSE_GEOCODING_HOUSE_NUMBER_DIST)) {
+			break
+		}
+		doc, err := se.DocStore.GetDoc(candidate.docID)
+		if err != nil {
+			return datastructure.ReverseGeocodingResult{}, false, fmt.Errorf("error when get doc: %w", err)
+		}
+		doc.Lat = candidate.projectedLat
+		doc.Lon = candidate.projectedLon
+		nearby := &datastructure.NearbyObject{Data: doc, Dist: candidate.dist}
+
+		isStreet := geo.GetIndexRules().ObjectTypeKey(doc.Tags) == "highway"
+		if streetOnly && !isStreet {
+			continue
+		}
+		if !found {
+			result.Place = doc
+			result.Dist = candidate.dist
+			found = true
+		}
+		if isStreet && result.Street == nil && doc.Name != "" {
+			result.Street = nearby
+		}
+		if streetOnly {
+			continue
+		}
+		if !isStreet && result.POI == nil && doc.Name != "" {
+			result.POI = nearby
+		}
+		if number := doc.Tags["addr:housenumber"]; number != "" && result.HouseNumber == nil &&
+			candidate.dist <= REVERSE_GEOCODING_HOUSE_NUMBER_DIST {
+			result.HouseNumber = &datastructure.HouseNumberMatch{Number: number, NearbyObject: *nearby}
+		}
+	}
+
+	for _, area := range areas {
+		doc, err := se.DocStore.GetDoc(area.docID)
+		if err != nil {
+			return datastructure.ReverseGeocodingResult{}, false, fmt.Errorf("error when get doc: %w", err)
+		}
+		if geo.GetIndexRules().ObjectTypeKey(doc.Tags) == "highway" && doc.Tags["area"] != "yes" {
+			// jalan melingkar (bundaran, dll) bukan area.
+			continue
+		}
+		doc.Lat = lat
+		doc.Lon = lon
+		result.Place = doc
+		result.Dist = 0
+		result.Match = datastructure.REVERSE_MATCH_INSIDE
+		found = true
+
+		poi, err := se.nearestPOIInside(area, candidates)
+		if err != nil {
+			return datastructure.ReverseGeocodingResult{}, false, err
+		}
+		result.POI = poi
+		if poi == nil && doc.Name != "" {
+			result.POI = &datastructure.NearbyObject{Data: doc}
+		}
+		break
 	}
-	if doc.Name == "" {
 
-		doc.Name = fmt.Sprintf("%.4f,%.4f,%s", projectedLat, projectedLon, doc.Address)
+	return result, found, nil
+}
 
+// nearestPOIInside. osm object bernama terdekat (bukan jalan) yang titiknya ada di dalam area. nil kalau tidak ada.
+func (se *Searcher) nearestPOIInside(area reverseArea, candidates []reverseCandidate) (*datastructure.NearbyObject, error) {
+	checked := 0
+	for _, candidate := range candidates {
+		if checked >= REVERSE_GEOCODING_MAX_DOCS {
+			break
+		}
+		if inside, _ := geo.PointInArea(candidate.centerLat, candidate.centerLon, area.leaf); !inside {
+			continue
+		}
+		checked++
+		doc, err := se.DocStore.GetDoc(candidate.docID)
+		if err != nil {
+			return nil, fmt.Errorf("error when get doc: %w", err)
+		}
+		if doc.Name == "" || geo.GetIndexRules().ObjectTypeKey(doc.Tags) == "highway" {
+			continue
+		}
+		doc.Lat = candidate.projectedLat
+		doc.Lon = candidate.projectedLon
+		return &datastructure.NearbyObject{Data: doc, Dist: candidate.dist}, nil
 	}
-	doc.Lat = projectedLat
-	doc.Lon = projectedLon
+	return nil, nil
+}
 
-	return doc, nil
+// crossStreet. jalan lain di persimpangan jalan street terdekat dari titik (lat, lon). nil kalau tidak ada persimpangan dekat titik.
+func (se *Searcher) crossStreet(lat, lon float64, street string) *datastructure.CrossStreet {
+	intersection, dist, ok := se.intersections.Nearest(lat, lon, REVERSE_GEOCODING_INTERSECTION_DIST, street)
+	if !ok {
+		return nil
+	}
+	crossStreets := geo.CrossStreets(intersection, street)
+	if len(crossStreets) == 0 {
+		return nil
+	}
+	return &datastructure.CrossStreet{Name: strings.Join(crossStreets, " / "), Lat: intersection.Lat, Lon: intersection.Lon,
+		Dist: dist}
 }
 
+// Intersections. persimpangan jalan street1 & street2 (bisa lebih dari satu, misal jalan yang bersilangan dua kali).
+func (se *Searcher) Intersections(street1, street2 string) ([]datastructure.Intersection, error) {
+	if geo.NormalizeStreetName(street1) == "" || geo.NormalizeStreetName(street2) == "" {
+		return []datastructure.Intersection{}, pkg.WrapErrorf(errors.New("street1 & street2 must be provided"),
+			pkg.ErrBadParamInput, "street1 and street2 must be provided")
+	}
+	intersections := se.intersections.Find(street1, street2)
+	if len(intersections) == 0 {
+		return intersections, pkg.WrapErrorf(errors.New("no intersection found"), pkg.ErrNotFound,
+			"no intersection found between %s and %s", street1, street2)
+	}
+	return intersections, nil
+}
+
+// reverseGeocodingAdmin. Place = boundary administratif level layer yang berisi titik.
+func (se *Searcher) reverseGeocodingAdmin(lat, lon float64, layer string) (datastructure.ReverseGeocodingResult, error) {
+	admin := se.adminAddress(lat, lon)
+	var name string
+	var parents []string
+	switch layer {
+	case datastructure.REVERSE_LAYER_VILLAGE:
+		name, parents = admin.Village, []string{admin.SubDistrict, admin.District, admin.Province, admin.Country}
+	case datastructure.REVERSE_LAYER_DISTRICT:
+		name, parents = admin.District, []string{admin.Province, admin.Country}
+	case datastructure.REVERSE_LAYER_PROVINCE:
+		name, parents = admin.Province, []string{admin.Country}
+	}
+	if name == "" {
+		return datastructure.ReverseGeocodingResult{}, pkg.WrapErrorf(errors.New("no administrative boundary found"), pkg.ErrNotFound,
+			"no %s boundary found at the location", layer)
+	}
+
+	address := []string{}
+	for _, parent := range parents {
+		if parent != "" {
+			address = append(address, parent)
+		}
+	}
+	place := datastructure.NewNode(-1, name, lat, lon, strings.Join(address, ", "), layer, admin.District, false)
+	return datastructure.ReverseGeocodingResult{Place: place, Match: datastructure.REVERSE_MATCH_INSIDE, Admin: admin}, nil
+}
+
+func (se *Searcher) adminAddress(lat, lon float64) datastructure.AdminAddress {
+	boundary, ok := se.adminIndex.Lookup(lat, lon)
+	if !ok {
+		return datastructure.AdminAddress{}
+	}
+	return datastructure.AdminAddress{
+		Village:     boundary.Village,
+		SubDistrict: boundary.SubDistrict,
+		District:    boundary.District,
+		Province:    boundary.Province,
+		Country:     boundary.Country,
+		PostalCode:  boundary.PostalCode,
+	}
+}
+
+// pointDistanceToOsmWay. jarak titik ke segment osm way terdekat & titik proyeksinya. way tertutup sudah berisi segment
+// terakhir->pertama (titik pertama diulang), jadi tidak ada segment tambahan yang tidak ada di way.
 func pointDistanceToOsmWay(wayBoundary [][]float64, pointLat, pointLon float64,
 	wayCenterLat, wayCenterLon float64) (float64, float64, float64) {
 	if len(wayBoundary) == 0 {
 		dist := datastructure.HaversineDistance(pointLat, pointLon, wayCenterLat, wayCenterLon)
 		return dist, -1, -1
 	}
-	minDist := math.MaxFloat64
-
-	projectedLat, projectedLon := -1.0, -1.0
+	projection, dist := geo.ProjectPointToPolyline(wayBoundary, pointLat, pointLon)
+	return dist, projection.Lat, projection.Lon
+}
 
-	for i := 0; i < len(wayBoundary); i++ {
-		j := (i + 1) % len(wayBoundary)
-		projection := geo.ProjectPointToLineCoord(geo.NewCoordinate(wayBoundary[i][0], wayBoundary[i][1]),
-			geo.NewCoordinate(wayBoundary[j][0], wayBoundary[j][1]), geo.NewCoordinate(pointLat, pointLon))
-		distance := datastructure.HaversineDistance(pointLat, pointLon, projection.Lat, projection.Lon)
-		if distance < minDist {
-			minDist = distance
-			projectedLat = projection.Lat
-			projectedLon = projection.Lon
+// pointDistanceToMultipolygon. jarak titik (di luar area atau di dalam lubang) ke ring outer/inner polygon relation terdekat
+// & titik proyeksinya. ring sudah tertutup seperti osm way tertutup.
+func pointDistanceToMultipolygon(polygon *datastructure.Multipolygon, pointLat, pointLon float64,
+	centerLat, centerLon float64) (float64, float64, float64) {
+	minDist, projectedLat, projectedLon := math.Inf(1), -1.0, -1.0
+	for _, rings := range [][][][]float64{polygon.Outer, polygon.Inner} {
+		for _, ring := range rings {
+			if len(ring) == 0 {
+				continue
+			}
+			projection, dist := geo.ProjectPointToPolyline(ring, pointLat, pointLon)
+			if dist < minDist {
+				minDist, projectedLat, projectedLon = dist, projection.Lat, projection.Lon
+			}
 		}
 	}
+	if math.IsInf(minDist, 1) {
+		return pointDistanceToOsmWay(nil, pointLat, pointLon, centerLat, centerLon)
+	}
 	return minDist, projectedLat, projectedLon
 }
 
-func (se *Searcher) NearestNeighboursRadiusWithFeatureFilter(k, offset int, lat, lon, radius float64, featureType string) ([]datastructure.Node, error) {
-	osmFeatureMap := se.Idx.GetOSMFeatureMap()
-	result := se.osmRtree.NearestNeighboursRadiusFilterOSM(k, offset, datastructure.NewPoint(lat, lon), radius, osmFeatureMap.GetID(featureType))
+// NearestNeighboursRadiusWithFeatureFilter. k osm object terdekat dalam radius (km) yang tag-nya memenuhi featureType & nama/alamatnya cocok dengan query.
+// featureType = ekspresi boolean osm feature (lihat CompileTagExpression), query = teks (lihat queryDocIDs). featureType/query kosong = tanpa filter.
+func (se *Searcher) NearestNeighboursRadiusWithFeatureFilter(k, offset int, lat, lon, radius float64, featureType, query string) ([]datastructure.Node, error) {
+	var expression *TagExpression
+	if featureType != "" {
+		var err error
+		expression, err = CompileTagExpression(featureType, se.Idx.GetOSMFeatureMap())
+		if err != nil {
+			return []datastructure.Node{}, err
+		}
+	}
+	var queryDocs *datastructure.Bitmap
+	if query != "" {
+		var err error
+		queryDocs, err = se.queryDocIDs(query)
+		if err != nil {
+			return []datastructure.Node{}, err
+		}
+		if queryDocs.Count() == 0 {
+			return []datastructure.Node{}, nil
+		}
+	}
+
+	se.rtreeLock.RLock()
+	result := se.osmRtree.NearestNeighboursRadiusFilter(k, offset, datastructure.NewPoint(lat, lon), radius, func(o datastructure.OSMObject) bool {
+		return (queryDocs == nil || queryDocs.Contains(o.ID)) && !se.isDeleted(o.ID) &&
+			(expression == nil || expression.Match(o.Tag))
+	})
+	se.rtreeLock.RUnlock()
 	docs := []datastructure.Node{}
 	for _, r := range result {
 		doc, err := se.DocStore.GetDoc(r.ID)
@@ -471,3 +899,42 @@ func (se *Searcher) NearestNeighboursRadiusWithFeatureFilter(k, offset int, lat,
 	}
 	return docs, nil
 }
+
+// queryDocIDs. docID yang nama atau alamatnya mengandung semua term query (setelah spell correction), dihitung sekali dari posting list
+// sebagai filter nearest neighbour.
+func (se *Searcher) queryDocIDs(query string) (*datastructure.Bitmap, error) {
+	queryTerms := sastrawi.Tokenize(query)
+	if len(queryTerms) == 0 {
+		return nil, pkg.WrapErrorf(errors.New("query is empty"), pkg.ErrBadParamInput, "query must contain at least one word")
+	}
+	queryTermsID, err := se.correctQueryTerms(queryTerms)
+	if err != nil {
+		return nil, err
+	}
+
+	var docIDs *datastructure.Bitmap
+	for _, termID := range queryTermsID {
+		postings, err := se.MainIndexNameField.GetPostingList(termID)
+		if err != nil {
+			return nil, err
+		}
+		postingsAddress, err := se.MainIndexAddressField.GetPostingList(termID)
+		if err != nil {
+			return nil, err
+		}
+
+		termDocIDs := datastructure.NewBitmap()
+		for _, fieldPostings := range [][]int{postings, postingsAddress} {
+			for _, docID := range fieldPostings {
+				if docIDs == nil || docIDs.Contains(docID) {
+					termDocIDs.Set(docID)
+				}
+			}
+		}
+		docIDs = termDocIDs
+	}
+	if docIDs == nil {
+		return datastructure.NewBitmap(), nil
+	}
+	return docIDs, nil
+}
@@ -1,12 +1,19 @@
 package searcher
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log"
+	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/lintang-b-s/osm-search/pkg"
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/lintang-b-s/osm-search/pkg/geo"
 	"github.com/lintang-b-s/osm-search/pkg/index"
 	"github.com/lintang-b-s/osm-search/pkg/kvdb"
 
@@ -37,7 +44,7 @@ func LoadIndex() (*Searcher, *bolt.DB) {
 		log.Fatal(err)
 	}
 
-	err = spellCorrector.InitializeSpellCorrector(invertedIndex.TermIDMap.GetSortedTerms(), invertedIndex.GetTermIDMap())
+	err = spellCorrector.InitializeSpellCorrector(invertedIndex.GetTermIDMap())
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -284,10 +291,602 @@ func BenchmarkReverseGeocoding(b *testing.B) {
 	b.ResetTimer()
 
 	for n := 0; n < b.N; n++ {
-		_, err := searcher.ReverseGeocoding(-6.1754, 106.8272)
+		_, err := searcher.ReverseGeocoding(-6.1754, 106.8272, datastructure.REVERSE_LAYER_BUILDING)
 		if err != nil {
 			b.Fatal(err)
 		}
 	}
 
 }
+
+// buildFixtureIndex. bikin index kecil di directory dir (relative ke package ini), lalu load seperti di server.
+func buildFixtureIndex(t *testing.T, dir string) (*Searcher, *bolt.DB) {
+	t.Helper()
+
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := bolt.Open(dir+"/docs_store.db", 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(kvdb.BBOLTDB_BUCKET))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bboltKV := kvdb.NewKVDB(db)
+
+	ngramLM := NewNGramLanguageModel(dir)
+	spellCorrectorBuilder := NewSpellCorrector(ngramLM, dir)
+	err = spellCorrectorBuilder.BuildEditProb("../../spell-errors.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = spellCorrectorBuilder.SaveNoisyChannelModelData()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{"Dunia Fantasi", "Taman Anggrek", "Taman Mini Indonesia Indah", "Monumen Nasional",
+		"Stasiun Gambir", "Kebun Binatang Ragunan", "Bandar Udara Halim Perdanakusuma", "Mall Taman Anggrek"}
+	nodes := make([]geo.OSMNode, 0, len(names))
+	for i, name := range names {
+		nodes = append(nodes, geo.OSMNode{ID: int64(i + 1), TagMap: map[string]string{"name": name, "addr:street": "Jalan " + name},
+			Lat: -6.1 - float64(i)*0.01, Lon: 106.8 + float64(i)*0.01})
+	}
+	nodes[3].TagMap["name:en"] = "National Monument"
+
+	spatialIndex := geo.OSMSpatialIndex{
+		StreetRtree:                 datastructure.NewRtree(25, 50, 2),
+		AdministrativeBoundaryRtree: datastructure.NewRtree(25, 50, 2),
+	}
+	square := func(minLat, minLon, maxLat, maxLon float64) [][]float64 {
+		return [][]float64{{minLat, minLon}, {minLat, maxLon}, {maxLat, maxLon}, {maxLat, minLon}, {minLat, minLon}}
+	}
+	admin := func(id int64, name, level string, ring [][]float64) geo.OSMRelation {
+		return geo.OSMRelation{ID: id, Type: geo.RELATION_BOUNDARY, OuterRings: [][][]float64{ring},
+			TagMap: map[string]string{"name": name, "boundary": "administrative", "admin_level": level}}
+	}
+	boundaries := geo.BuildAdminBoundaries([]geo.OSMRelation{
+		admin(1, "DKI Jakarta", "4", square(-6.4, 106.6, -6.0, 107.1)),
+		admin(2, "Ancol", "7", square(-6.15, 106.75, -6.05, 106.85)),
+	}, geo.AdminBoundaryConfig{FromOSM: true, Country: "ID"}, spatialIndex.AdministrativeBoundaryRtree)
+	indexedData := index.NewIndexedData(geo.SliceSource[geo.OSMWay]{}, geo.SliceSource[geo.OSMNode](nodes), nil, pkg.NewIDMap(), spatialIndex, boundaries)
+	invertedIndex, err := index.NewDynamicIndex(dir, 1e7, false, spellCorrectorBuilder, indexedData, bboltKV)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allSearchNodes, err := invertedIndex.SpimiBatchIndex(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ngramLM.SetTermIDMap(invertedIndex.GetTermIDMap())
+	err = invertedIndex.BuildSpellCorrectorAndNgram(context.Background(), allSearchNodes, spatialIndex, boundaries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = invertedIndex.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// load ulang seperti di server
+	ngramLM = NewNGramLanguageModel(dir)
+	spellCorrector := NewSpellCorrector(ngramLM, dir)
+	serverIndex, err := index.NewDynamicIndex(dir, 1e7, true, spellCorrector, index.IndexedData{}, bboltKV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = spellCorrector.InitializeSpellCorrector(serverIndex.GetTermIDMap())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = spellCorrector.LoadNoisyChannelData()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	searcher := NewSearcher(serverIndex, bboltKV, spellCorrector, BM25_FIELD)
+	err = searcher.LoadMainIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return searcher, db
+}
+
+func TestReverseGeocoding(t *testing.T) {
+	dir := "test_reverse"
+	searcher, db := buildFixtureIndex(t, dir)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+	defer searcher.Close()
+
+	bboltKV := kvdb.NewKVDB(db)
+
+	street := datastructure.NewNode(searcher.NextDocID(), "Jalan Lodan Raya", -6.1002, 106.8, "", "primary", "", false).
+		WithOSMObject("way", 500, map[string]string{"highway": "primary"})
+	house := datastructure.NewNode(searcher.NextDocID(), "", -6.1001, 106.8001, "", "", "", false).
+		WithOSMObject("node", 501, map[string]string{"addr:housenumber": "12"})
+	err := bboltKV.SaveChanges(kvdb.DocChanges{NewDocs: []datastructure.Node{street, house}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	searcher.AddDocument(street, map[string]string{"highway": "primary"}, [][]float64{{-6.1002, 106.79}, {-6.1002, 106.81}})
+	searcher.AddDocument(house, map[string]string{}, nil)
+
+	searcher.intersections = geo.NewIntersectionIndex([]datastructure.Intersection{
+		{Lat: -6.1002, Lon: 106.8005, Streets: []string{"Jalan Ancol Barat", "Jalan Lodan Raya"}},
+	})
+
+	// Dunia Fantasi di (-6.1, 106.8)
+	result, err := searcher.ReverseGeocoding(-6.1, 106.8, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "Dunia Fantasi", result.Place.Name)
+	assert.InDelta(t, 0, result.Dist, 1e-6)
+	assert.Equal(t, datastructure.REVERSE_MATCH_NEARBY, result.Match)
+	if assert.NotNil(t, result.POI) {
+		assert.Equal(t, "Dunia Fantasi", result.POI.Data.Name)
+	}
+	if assert.NotNil(t, result.Street) {
+		assert.Equal(t, "Jalan Lodan Raya", result.Street.Data.Name)
+		assert.InDelta(t, 0.022, result.Street.Dist, 0.001)
+		assert.InDelta(t, -6.1002, result.Street.Data.Lat, 1e-6)
+	}
+	if assert.NotNil(t, result.CrossStreet) {
+		assert.Equal(t, "Jalan Ancol Barat", result.CrossStreet.Name)
+		assert.InDelta(t, 0.06, result.CrossStreet.Dist, 0.01)
+	}
+	if assert.NotNil(t, result.HouseNumber) {
+		assert.Equal(t, "12", result.HouseNumber.Number)
+		assert.Equal(t, house.ID, result.HouseNumber.Data.ID)
+	}
+	assert.Equal(t, datastructure.AdminAddress{Village: "Ancol", Province: "DKI Jakarta"}, result.Admin)
+
+	// di luar boundary Ancol
+	result, err = searcher.ReverseGeocoding(-6.17, 106.87, "")
+	assert.Nil(t, err)
+	assert.Equal(t, datastructure.AdminAddress{Province: "DKI Jakarta"}, result.Admin)
+
+	errorCode := func(err error) error {
+		var ierr *pkg.Error
+		if errors.As(err, &ierr) {
+			return ierr.Code()
+		}
+		return err
+	}
+	_, err = searcher.ReverseGeocoding(10, 10, "")
+	assert.Equal(t, pkg.ErrNotFound, errorCode(err))
+
+	// layer
+	result, err = searcher.ReverseGeocoding(-6.1, 106.8, datastructure.REVERSE_LAYER_STREET)
+	assert.Nil(t, err)
+	assert.Equal(t, street.ID, result.Place.ID)
+	assert.Nil(t, result.POI)
+
+	result, err = searcher.ReverseGeocoding(-6.1, 106.8, datastructure.REVERSE_LAYER_VILLAGE)
+	assert.Nil(t, err)
+	assert.Equal(t, "Ancol", result.Place.Name)
+	assert.Equal(t, "DKI Jakarta", result.Place.Address)
+
+	result, err = searcher.ReverseGeocoding(-6.1, 106.8, datastructure.REVERSE_LAYER_PROVINCE)
+	assert.Nil(t, err)
+	assert.Equal(t, "DKI Jakarta", result.Place.Name)
+
+	_, err = searcher.ReverseGeocoding(-6.1, 106.8, datastructure.REVERSE_LAYER_DISTRICT)
+	assert.Equal(t, pkg.ErrNotFound, errorCode(err))
+
+	_, err = searcher.ReverseGeocoding(-6.1, 106.8, "country")
+	assert.Equal(t, pkg.ErrBadParamInput, errorCode(err))
+
+	intersections, err := searcher.Intersections("jl. lodan raya", "Jalan Ancol Barat")
+	assert.Nil(t, err)
+	assert.Len(t, intersections, 1)
+	_, err = searcher.Intersections("Jalan Lodan Raya", "Jalan Sudirman")
+	assert.Equal(t, pkg.ErrNotFound, errorCode(err))
+	_, err = searcher.Intersections("Jalan Lodan Raya", " ")
+	assert.Equal(t, pkg.ErrBadParamInput, errorCode(err))
+
+	// titik di dalam area: Mall Kota (di dalam Taman Kota) berisi Halte Mall & Toko Buku.
+	square := func(minLat, minLon, maxLat, maxLon float64) [][]float64 {
+		return [][]float64{{minLat, minLon}, {minLat, maxLon}, {maxLat, maxLon}, {maxLat, minLon}, {minLat, minLon}}
+	}
+	park := datastructure.NewNode(searcher.NextDocID(), "Taman Kota", -6.2, 106.95, "", "park", "", false).
+		WithOSMObject("way", 502, map[string]string{"leisure": "park"})
+	mall := datastructure.NewNode(searcher.NextDocID(), "Mall Kota", -6.2, 106.95, "", "mall", "", false).
+		WithOSMObject("way", 503, map[string]string{"shop": "mall"})
+	busStop := datastructure.NewNode(searcher.NextDocID(), "Halte Mall", -6.2, 106.9501, "", "bus_stop", "", false).
+		WithOSMObject("node", 504, map[string]string{"highway": "bus_stop"})
+	shop := datastructure.NewNode(searcher.NextDocID(), "Toko Buku", -6.1995, 106.9505, "", "books", "", false).
+		WithOSMObject("node", 505, map[string]string{"shop": "books"})
+	err = bboltKV.SaveChanges(kvdb.DocChanges{NewDocs: []datastructure.Node{park, mall, busStop, shop}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	searcher.AddDocument(park, map[string]string{"leisure": "park"}, square(-6.21, 106.94, -6.19, 106.96))
+	searcher.AddDocument(mall, map[string]string{"shop": "mall"}, square(-6.202, 106.948, -6.198, 106.952))
+	searcher.AddDocument(busStop, map[string]string{"highway": "bus_stop"}, nil)
+	searcher.AddDocument(shop, map[string]string{"shop": "books"}, nil)
+
+	result, err = searcher.ReverseGeocoding(-6.2, 106.9502, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "Mall Kota", result.Place.Name)
+	assert.Equal(t, datastructure.REVERSE_MATCH_INSIDE, result.Match)
+	assert.Equal(t, 0.0, result.Dist)
+	if assert.NotNil(t, result.POI) {
+		assert.Equal(t, "Toko Buku", result.POI.Data.Name)
+	}
+
+	result, err = searcher.ReverseGeocoding(-6.205, 106.945, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "Taman Kota", result.Place.Name)
+	assert.Equal(t, datastructure.REVERSE_MATCH_INSIDE, result.Match)
+	if assert.NotNil(t, result.POI) {
+		assert.Equal(t, "Taman Kota", result.POI.Data.Name)
+	}
+
+	// area besar (+-11 km) yang ditambah lewat AddDocument: bound leaf r-tree mencakup seluruh area, titik +-4 km dari pusat tetap inside.
+	campus := datastructure.NewNode(searcher.NextDocID(), "Kampus Besar", -6.3, 107.05, "", "university", "", false).
+		WithOSMObject("way", 506, map[string]string{"amenity": "university"})
+	canteen := datastructure.NewNode(searcher.NextDocID(), "Kantin Teknik", -6.33, 107.02, "", "cafe", "", false).
+		WithOSMObject("node", 507, map[string]string{"amenity": "cafe"})
+	err = bboltKV.SaveChanges(kvdb.DocChanges{NewDocs: []datastructure.Node{campus, canteen}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	searcher.AddDocument(campus, map[string]string{"amenity": "university"}, square(-6.35, 107.0, -6.25, 107.1))
+	searcher.AddDocument(canteen, map[string]string{"amenity": "cafe"}, nil)
+
+	result, err = searcher.ReverseGeocoding(-6.3301, 107.0201, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "Kampus Besar", result.Place.Name)
+	assert.Equal(t, datastructure.REVERSE_MATCH_INSIDE, result.Match)
+	if assert.NotNil(t, result.POI) {
+		assert.Equal(t, "Kantin Teknik", result.POI.Data.Name)
+	}
+
+	// jalan berbentuk U (tidak tertutup): ujung-ujungnya tidak tersambung, jadi jalan terdekat = Jalan Lurus (+-78 m), bukan
+	// Jalan Melengkung lewat segment ujung->awal (+-55 m) yang tidak ada.
+	curved := datastructure.NewNode(searcher.NextDocID(), "Jalan Melengkung", -6.4, 107.304, "", "residential", "", false).
+		WithOSMObject("way", 508, map[string]string{"highway": "residential"})
+	straight := datastructure.NewNode(searcher.NextDocID(), "Jalan Lurus", -6.4012, 107.305, "", "residential", "", false).
+		WithOSMObject("way", 509, map[string]string{"highway": "residential"})
+	err = bboltKV.SaveChanges(kvdb.DocChanges{NewDocs: []datastructure.Node{curved, straight}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	searcher.AddDocument(curved, map[string]string{"highway": "residential"},
+		[][]float64{{-6.4, 107.304}, {-6.39, 107.304}, {-6.39, 107.306}, {-6.4, 107.306}})
+	searcher.AddDocument(straight, map[string]string{"highway": "residential"}, [][]float64{{-6.4012, 107.303}, {-6.4012, 107.307}})
+
+	result, err = searcher.ReverseGeocoding(-6.4005, 107.305, datastructure.REVERSE_LAYER_STREET)
+	assert.Nil(t, err)
+	if assert.NotNil(t, result.Street) {
+		assert.Equal(t, "Jalan Lurus", result.Street.Data.Name)
+		assert.InDelta(t, 0.078, result.Street.Dist, 0.002)
+	}
+
+	// polygon relation yang tidak berisi titik tetap jadi kandidat, jaraknya ke ring outer/inner terdekat.
+	field := datastructure.NewNode(searcher.NextDocID(), "Lapangan Banteng", -6.3, 106.7, "", "park", "", false).
+		WithOSMObject("relation", 510, map[string]string{"leisure": "park"})
+	err = bboltKV.SaveChanges(kvdb.DocChanges{NewDocs: []datastructure.Node{field}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fieldLeaf := datastructure.OSMObject{ID: field.ID, Lat: field.Lat, Lon: field.Lon, Polygon: &datastructure.Multipolygon{
+		Outer: [][][]float64{square(-6.302, 106.698, -6.298, 106.702)},
+		Inner: [][][]float64{square(-6.3005, 106.6995, -6.2995, 106.7005)},
+	}}
+	searcher.osmRtree.InsertLeaf(geo.RtreeLeafBound(fieldLeaf), fieldLeaf, false)
+
+	result, err = searcher.ReverseGeocoding(-6.3, 106.7035, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "Lapangan Banteng", result.Place.Name)
+	assert.Equal(t, datastructure.REVERSE_MATCH_NEARBY, result.Match)
+	assert.InDelta(t, 0.166, result.Dist, 0.002)
+	assert.InDelta(t, 106.702, result.Place.Lon, 1e-6)
+
+	// di dalam lubang (inner ring): jarak ke ring inner, bukan ke ring outer.
+	result, err = searcher.ReverseGeocoding(-6.3, 106.7, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "Lapangan Banteng", result.Place.Name)
+	assert.Equal(t, datastructure.REVERSE_MATCH_NEARBY, result.Match)
+	assert.InDelta(t, 0.055, result.Dist, 0.002)
+
+	// radius diperlebar sampai ketemu osm object terdekat (Taman Mini Indonesia Indah, +-2.5 km)
+	result, err = searcher.ReverseGeocoding(-6.13, 106.8, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "Taman Mini Indonesia Indah", result.Place.Name)
+	assert.InDelta(t, 2.48, result.Dist, 0.05)
+
+	searcher.SetReverseGeocodingMaxRadius(1)
+	_, err = searcher.ReverseGeocoding(-6.13, 106.8, "")
+	assert.Equal(t, pkg.ErrNotFound, errorCode(err))
+}
+
+// go test -race -run TestAutocompleteConcurrent ./pkg/searcher/
+func TestAutocompleteConcurrent(t *testing.T) {
+	dir := "test_concurrent"
+	searcher, db := buildFixtureIndex(t, dir)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+	defer searcher.Close()
+
+	queries := []string{"Taman An", "Dunia Fan", "Monumen Nas", "Stasi", "Kebun Bin", "Bandar Uda", "Mall"}
+
+	expected := make(map[string][]datastructure.Node, len(queries))
+	for _, query := range queries {
+		relevantDocs, err := searcher.Autocomplete(query, 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected[query] = relevantDocs
+	}
+
+	var wg sync.WaitGroup
+	errChan := make(chan error, 8*len(queries))
+	for i := 0; i < 8; i++ {
+		for _, query := range queries {
+			wg.Add(1)
+			go func(query string) {
+				defer wg.Done()
+				relevantDocs, err := searcher.Autocomplete(query, 10, 0)
+				if err != nil {
+					errChan <- err
+					return
+				}
+				if len(relevantDocs) != len(expected[query]) {
+					errChan <- fmt.Errorf("query %q: got %d docs, want %d", query, len(relevantDocs), len(expected[query]))
+				}
+			}(query)
+		}
+	}
+	wg.Wait()
+	close(errChan)
+
+	for err := range errChan {
+		t.Error(err)
+	}
+
+	relevantDocs, err := searcher.Autocomplete("Dunia Fan", 10, 0)
+	assert.Nil(t, err)
+	if assert.NotEmpty(t, relevantDocs) {
+		assert.Contains(t, relevantDocs[0].Name, "Dunia Fantasi")
+	}
+}
+
+func TestDeltaUpdate(t *testing.T) {
+	dir := "test_delta"
+	searcher, db := buildFixtureIndex(t, dir)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+	defer searcher.Close()
+
+	bboltKV := kvdb.NewKVDB(db)
+
+	// osm node baru dengan term yang tidak ada di main index
+	docID := searcher.NextDocID()
+	newDoc := datastructure.NewNode(docID, "Kopi Klotok", -6.2, 106.9, "Jalan Kaliurang", "cafe", "", false).
+		WithOSMObject("node", 99999, map[string]string{"opening_hours": "07:00-22:00"})
+	err := bboltKV.SaveChanges(kvdb.DocChanges{NewDocs: []datastructure.Node{newDoc},
+		NewOSMIDs: map[string]int{"node/99999": docID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	searcher.AddDocument(newDoc, map[string]string{"amenity": "cafe"}, nil)
+
+	relevantDocs, err := searcher.FreeFormQuery("Kopi Klotok", 15, 0)
+	assert.Nil(t, err)
+	if assert.NotEmpty(t, relevantDocs) {
+		assert.Equal(t, "Kopi Klotok", relevantDocs[0].Name)
+	}
+
+	// lookup by osm id, osm id yang tidak di index di-skip
+	lookupDocs, err := searcher.Lookup([]string{"node/99999", "way/1"})
+	assert.Nil(t, err)
+	if assert.Len(t, lookupDocs, 1) {
+		assert.Equal(t, docID, lookupDocs[0].ID)
+		assert.Equal(t, int64(99999), lookupDocs[0].OSMID)
+		assert.Equal(t, "07:00-22:00", lookupDocs[0].Tags["opening_hours"])
+	}
+
+	// geometry dari leaf r-tree
+	boundaryDoc := datastructure.NewNode(searcher.NextDocID(), "Taman Kopi", -6.25, 106.95, "", "park", "", false)
+	err = bboltKV.SaveChanges(kvdb.DocChanges{NewDocs: []datastructure.Node{boundaryDoc}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	searcher.AddDocument(boundaryDoc, map[string]string{"leisure": "park"},
+		[][]float64{{-6.26, 106.94}, {-6.26, 106.96}, {-6.24, 106.96}, {-6.24, 106.94}, {-6.26, 106.94}})
+	geometryDocs := searcher.WithGeometry([]datastructure.Node{newDoc, boundaryDoc}, 0)
+	assert.Equal(t, geo.GEOMETRY_POINT, geometryDocs[0].Geometry.Type)
+	assert.Equal(t, geo.GEOMETRY_POLYGON, geometryDocs[1].Geometry.Type)
+	assert.Equal(t, []float64{106.94, -6.26, 106.96, -6.24}, geometryDocs[1].BBox)
+
+	// document custom hanya muncul di filter source-nya
+	customDoc := datastructure.NewNode(searcher.NextDocID(), "Kopi Klotok Cabang", -6.21, 106.91, "Jalan Kaliurang", "cafe", "", false)
+	customDoc.Source = "merchant"
+	err = bboltKV.SaveChanges(kvdb.DocChanges{NewDocs: []datastructure.Node{customDoc}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	searcher.AddDocument(customDoc, map[string]string{}, nil)
+
+	relevantDocs, err = searcher.FreeFormQueryBySource("Kopi Klotok", "merchant", 15, 0)
+	assert.Nil(t, err)
+	if assert.Len(t, relevantDocs, 1) {
+		assert.Equal(t, customDoc.ID, relevantDocs[0].ID)
+		assert.Equal(t, "merchant", relevantDocs[0].Source)
+	}
+	relevantDocs, err = searcher.FreeFormQueryBySource("Kopi Klotok", datastructure.SOURCE_OSM, 15, 0)
+	assert.Nil(t, err)
+	for _, doc := range relevantDocs {
+		assert.NotEqual(t, customDoc.ID, doc.ID)
+	}
+
+	// hapus osm node dari main index
+	relevantDocs, err = searcher.FreeFormQuery("Dunia Fantasi", 15, 0)
+	assert.Nil(t, err)
+	if assert.NotEmpty(t, relevantDocs) {
+		deleted := relevantDocs[0]
+		searcher.DeleteDocument(deleted.ID, deleted.Lat, deleted.Lon)
+
+		relevantDocs, err = searcher.FreeFormQuery("Dunia Fantasi", 15, 0)
+		assert.Nil(t, err)
+		for _, doc := range relevantDocs {
+			assert.NotEqual(t, deleted.ID, doc.ID)
+		}
+
+		nearby, err := searcher.ReverseGeocoding(deleted.Lat, deleted.Lon, "")
+		if err == nil {
+			assert.NotEqual(t, deleted.ID, nearby.Place.ID)
+		}
+	}
+}
+
+func TestSearchWithConfidence(t *testing.T) {
+	dir := "test_batch"
+	searcher, db := buildFixtureIndex(t, dir)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+	defer searcher.Close()
+
+	matches, err := searcher.SearchWithConfidence(datastructure.SearchBatchQuery{Query: "Taman Anggrek"})
+	assert.Nil(t, err)
+	if assert.NotEmpty(t, matches) {
+		assert.Contains(t, matches[0].Data.Name, "Taman Anggrek")
+		assert.InDelta(t, 1, matches[0].Confidence, 1e-9)
+	}
+
+	// bias lokasi di Mall Taman Anggrek (-6.17, 106.87)
+	matches, err = searcher.SearchWithConfidence(datastructure.SearchBatchQuery{Query: "Taman Anggrek", HasLocation: true,
+		Lat: -6.17, Lon: 106.87})
+	assert.Nil(t, err)
+	if assert.NotEmpty(t, matches) {
+		assert.Equal(t, "Mall Taman Anggrek", matches[0].Data.Name)
+		assert.InDelta(t, 0, matches[0].Dist, 1e-6)
+	}
+
+	matches, err = searcher.SearchWithConfidence(datastructure.SearchBatchQuery{Query: "Taman Anggrek", HasLocation: true,
+		Lat: -6.17, Lon: 106.87, Radius: 1, TopK: 1})
+	assert.Nil(t, err)
+	if assert.Len(t, matches, 1) {
+		assert.Equal(t, "Mall Taman Anggrek", matches[0].Data.Name)
+	}
+
+	assert.InDelta(t, 0.9, matchConfidence([]string{"taman", "angrek"}, datastructure.Node{Name: "Taman Anggrek"}), 1e-9)
+	assert.InDelta(t, 0.5, matchConfidence([]string{"taman", "xyz"}, datastructure.Node{Name: "Taman Anggrek"}), 1e-9)
+	assert.Equal(t, 2, editDistance("anggrek", "angrk"))
+}
+
+func TestNearestNeighboursRadiusWithQuery(t *testing.T) {
+	dir := "test_nearby_query"
+	searcher, db := buildFixtureIndex(t, dir)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+	defer searcher.Close()
+
+	names := func(docs []datastructure.Node) []string {
+		result := []string{}
+		for _, doc := range docs {
+			result = append(result, doc.Name)
+		}
+		return result
+	}
+
+	// lokasi di Mall Taman Anggrek (-6.17, 106.87), hasil urut berdasarkan jarak
+	docs, err := searcher.NearestNeighboursRadiusWithFeatureFilter(10, 0, -6.17, 106.87, 20, "", "taman anggrek")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Mall Taman Anggrek", "Taman Anggrek"}, names(docs))
+
+	docs, err = searcher.NearestNeighboursRadiusWithFeatureFilter(10, 0, -6.17, 106.87, 20, "", "taman")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Mall Taman Anggrek", "Taman Mini Indonesia Indah", "Taman Anggrek"}, names(docs))
+
+	docs, err = searcher.NearestNeighboursRadiusWithFeatureFilter(10, 1, -6.17, 106.87, 20, "", "taman")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Taman Mini Indonesia Indah", "Taman Anggrek"}, names(docs))
+
+	docs, err = searcher.NearestNeighboursRadiusWithFeatureFilter(10, 0, -6.17, 106.87, 1, "", "taman")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Mall Taman Anggrek"}, names(docs))
+
+	docs, err = searcher.NearestNeighboursRadiusWithFeatureFilter(10, 0, -6.17, 106.87, 20, "amenity=*", "taman")
+	assert.Nil(t, err)
+	assert.Empty(t, docs)
+
+	_, err = searcher.NearestNeighboursRadiusWithFeatureFilter(10, 0, -6.17, 106.87, 20, "", "+")
+	var ierr *pkg.Error
+	assert.True(t, errors.As(err, &ierr) && ierr.Code() == pkg.ErrBadParamInput)
+}
+
+func TestAutocompleteNear(t *testing.T) {
+	dir := "test_autocomplete_near"
+	searcher, db := buildFixtureIndex(t, dir)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+	defer searcher.Close()
+
+	docs, err := searcher.AutocompleteBySource("Taman Angg", "", 5, 0)
+	assert.Nil(t, err)
+	if assert.NotEmpty(t, docs) {
+		assert.NotEqual(t, "Mall Taman Anggrek", docs[0].Name)
+	}
+
+	// bias lokasi di Mall Taman Anggrek (-6.17, 106.87)
+	docs, err = searcher.AutocompleteNear("Taman Angg", "", 5, -6.17, 106.87)
+	assert.Nil(t, err)
+	if assert.NotEmpty(t, docs) {
+		assert.Equal(t, "Mall Taman Anggrek", docs[0].Name)
+	}
+
+	docs, err = searcher.AutocompleteNear("Taman Angg", "", 1, -6.17, 106.87)
+	assert.Nil(t, err)
+	assert.Len(t, docs, 1)
+}
+
+// name:<language> disimpan dengan index rules bawaan, untuk nama lokal di api nominatim & photon.
+func TestStoredLocalizedNames(t *testing.T) {
+	dir := "test_localized_names"
+	searcher, db := buildFixtureIndex(t, dir)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+	defer searcher.Close()
+
+	docs, err := searcher.FreeFormQuery("Monumen Nasional", 1, 0)
+	assert.Nil(t, err)
+	if assert.Len(t, docs, 1) {
+		assert.Equal(t, "Monumen Nasional", docs[0].Name)
+		assert.Equal(t, "National Monument", docs[0].Tags["name:en"])
+	}
+}
+
+func TestFreeFormQueryInBox(t *testing.T) {
+	dir := "test_search_in_box"
+	searcher, db := buildFixtureIndex(t, dir)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+	defer searcher.Close()
+
+	docs, err := searcher.FreeFormQuery("Taman", 1, 0)
+	assert.Nil(t, err)
+	if assert.Len(t, docs, 1) {
+		assert.NotEqual(t, "Mall Taman Anggrek", docs[0].Name)
+	}
+
+	// bounding box di sekitar Mall Taman Anggrek (-6.17, 106.87): document di luar box tidak ikut, walaupun skornya lebih tinggi.
+	docs, err = searcher.FreeFormQueryInBox("Taman", "", 1, 0, -6.18, 106.86, -6.16, 106.88)
+	assert.Nil(t, err)
+	if assert.Len(t, docs, 1) {
+		assert.Equal(t, "Mall Taman Anggrek", docs[0].Name)
+	}
+
+	docs, err = searcher.FreeFormQueryInBox("Taman", "", 10, 0, -6.3, 107.0, -6.2, 107.1)
+	assert.Nil(t, err)
+	assert.Empty(t, docs)
+}
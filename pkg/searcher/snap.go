@@ -0,0 +1,48 @@
+package searcher
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lintang-b-s/osm-search/pkg"
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+)
+
+// SnapToRoad. segment jalan terdekat dari titik (lat, lon) dalam radius (km, 0 = SNAP_TO_ROAD_RADIUS), titik proyeksi ke jalan,
+// nama & kelas jalan.
+func (se *Searcher) SnapToRoad(lat, lon, radius float64) (datastructure.RoadMatch, error) {
+	radius, err := se.snapRadius(radius)
+	if err != nil {
+		return datastructure.RoadMatch{}, err
+	}
+	match, ok := se.roadNetwork.Snap(lat, lon, radius)
+	if !ok {
+		return datastructure.RoadMatch{}, pkg.WrapErrorf(errors.New("no road found"), pkg.ErrNotFound,
+			"no road found within %.2f km of the location", radius)
+	}
+	return match, nil
+}
+
+// MatchTrace. map matching trace gps (hmm + viterbi) ke jaringan jalan. titik tanpa jalan dalam radius tidak di-match.
+func (se *Searcher) MatchTrace(points []datastructure.Point, radius float64) (datastructure.TraceMatch, error) {
+	radius, err := se.snapRadius(radius)
+	if err != nil {
+		return datastructure.TraceMatch{}, err
+	}
+	return se.roadNetwork.MatchTrace(points, radius), nil
+}
+
+func (se *Searcher) snapRadius(radius float64) (float64, error) {
+	if radius < 0 || radius > SNAP_TO_ROAD_MAX_RADIUS {
+		return 0, pkg.WrapErrorf(fmt.Errorf("invalid radius %f", radius), pkg.ErrBadParamInput,
+			"radius must be between 0 and %.0f km", SNAP_TO_ROAD_MAX_RADIUS)
+	}
+	if radius == 0 {
+		radius = SNAP_TO_ROAD_RADIUS
+	}
+	if se.roadNetwork.Size() == 0 {
+		return 0, pkg.WrapErrorf(errors.New("road network not loaded"), pkg.ErrNotFound,
+			"road network is not available, re-run the indexer to build it")
+	}
+	return radius, nil
+}
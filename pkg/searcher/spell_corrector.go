@@ -251,9 +251,17 @@ func (sc *SpellCorrector) BuildFiniteStateTransducerSortedTerms(sortedTerms []st
 	return nil
 }
 
-func (sc *SpellCorrector) InitializeSpellCorrector(sortedTerms []string, termIDMap *pkg.IDMap) error {
+// InitializeSpellCorrector. kalau termIDMap dibuat dari term dictionary FST di disk, FST itu langsung dipakai (tidak build FST baru di RAM).
+func (sc *SpellCorrector) InitializeSpellCorrector(termIDMap *pkg.IDMap) error {
 	sc.TermIDMap = termIDMap
-	sc.BuildFiniteStateTransducerSortedTerms(sortedTerms)
+	if fst := termIDMap.GetFST(); fst != nil {
+		sc.CorpusTermsFST = fst
+	} else {
+		err := sc.BuildFiniteStateTransducerSortedTerms(termIDMap.GetSortedTerms())
+		if err != nil {
+			return err
+		}
+	}
 	err := sc.NGram.LoadNGramData()
 	sc.NGram.SetTermIDMap(termIDMap)
 
@@ -346,11 +354,15 @@ func (sc *SpellCorrector) GetCorrectSpellingSuggestion(allCorrectQueryCandidates
 		}
 		curQueryProb += editProb
 
-		if curQueryProb > maxProb {
+		// probability n-gram term dari delta index = 0 (log prob -inf), tetap pilih salah satu kandidat.
+		if curQueryProb > maxProb || correctQueryIDX == -1 {
 			maxProb = curQueryProb
 			correctQueryIDX = key
 		}
 	}
+	if correctQueryIDX == -1 {
+		return correctQuery, nil
+	}
 	correctQuery = append(correctQuery, allCorrectQueryCandidateIDs[correctQueryIDX]...)
 	return correctQuery, nil
 }
@@ -0,0 +1,220 @@
+package searcher
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lintang-b-s/osm-search/pkg"
+)
+
+// TagExpression. ekspresi boolean osm feature hasil CompileTagExpression, misal
+// "(amenity=restaurant OR amenity=cafe) AND NOT cuisine=fast_food" atau "shop=*".
+type TagExpression struct {
+	root tagExpressionNode
+}
+
+type tagExpressionNode interface {
+	match(tag map[int]int) bool
+}
+
+type tagExpressionTerm struct {
+	ids []int // id osm feature (key=value) di OSMFeatureMap, cukup salah satu ada di tag.
+}
+
+type tagExpressionNot struct {
+	child tagExpressionNode
+}
+
+type tagExpressionAnd struct {
+	children []tagExpressionNode
+}
+
+type tagExpressionOr struct {
+	children []tagExpressionNode
+}
+
+func (t tagExpressionTerm) match(tag map[int]int) bool {
+	for _, id := range t.ids {
+		if _, ok := tag[id]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (n tagExpressionNot) match(tag map[int]int) bool {
+	return !n.child.match(tag)
+}
+
+func (a tagExpressionAnd) match(tag map[int]int) bool {
+	for _, child := range a.children {
+		if !child.match(tag) {
+			return false
+		}
+	}
+	return true
+}
+
+func (o tagExpressionOr) match(tag map[int]int) bool {
+	for _, child := range o.children {
+		if child.match(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match. true kalau tag osm object (OSMObject.Tag) memenuhi ekspresi.
+func (te *TagExpression) Match(tag map[int]int) bool {
+	return te.root.match(tag)
+}
+
+// CompileTagExpression. parse ekspresi osm feature & resolve setiap key=value ke id di osmFeatureMap.
+// operator (case insensitive): OR / | / ",", AND / &, NOT / !, dengan precedence NOT > AND > OR & kurung untuk grouping.
+// key=* cocok dengan semua value dari key. key=value yang tidak ada di osmFeatureMap tidak cocok dengan osm object apapun.
+func CompileTagExpression(expression string, osmFeatureMap *pkg.IDMap) (*TagExpression, error) {
+	tokens := tokenizeTagExpression(expression)
+	if len(tokens) == 0 {
+		return nil, pkg.WrapErrorf(errors.New("empty feature expression"), pkg.ErrBadParamInput, "feature expression must not be empty")
+	}
+	p := &tagExpressionParser{tokens: tokens, osmFeatureMap: osmFeatureMap}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, p.errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return &TagExpression{root: root}, nil
+}
+
+func tokenizeTagExpression(expression string) []string {
+	tokens := []string{}
+	term := strings.Builder{}
+	flush := func() {
+		if term.Len() > 0 {
+			tokens = append(tokens, term.String())
+			term.Reset()
+		}
+	}
+	for _, r := range expression {
+		switch {
+		case r == '(' || r == ')' || r == '|' || r == '&' || r == '!' || r == ',':
+			flush()
+			tokens = append(tokens, string(r))
+		case r == ' ' || r == '\t' || r == '\n':
+			flush()
+		default:
+			term.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+type tagExpressionParser struct {
+	tokens        []string
+	pos           int
+	osmFeatureMap *pkg.IDMap
+}
+
+func (p *tagExpressionParser) errorf(format string, a ...interface{}) error {
+	msg := fmt.Sprintf("invalid feature expression: "+format, a...)
+	return pkg.WrapErrorf(errors.New(msg), pkg.ErrBadParamInput, "%s", msg)
+}
+
+// accept. konsumsi token berikutnya kalau salah satu dari operators (case insensitive).
+func (p *tagExpressionParser) accept(operators ...string) bool {
+	if p.pos >= len(p.tokens) {
+		return false
+	}
+	for _, operator := range operators {
+		if strings.EqualFold(p.tokens[p.pos], operator) {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *tagExpressionParser) parseOr() (tagExpressionNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []tagExpressionNode{left}
+	for p.accept("OR", "|", ",") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, right)
+	}
+	if len(children) == 1 {
+		return left, nil
+	}
+	return tagExpressionOr{children: children}, nil
+}
+
+func (p *tagExpressionParser) parseAnd() (tagExpressionNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	children := []tagExpressionNode{left}
+	for p.accept("AND", "&") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, right)
+	}
+	if len(children) == 1 {
+		return left, nil
+	}
+	return tagExpressionAnd{children: children}, nil
+}
+
+func (p *tagExpressionParser) parseNot() (tagExpressionNode, error) {
+	if p.accept("NOT", "!") {
+		child, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return tagExpressionNot{child: child}, nil
+	}
+	return p.parseTerm()
+}
+
+func (p *tagExpressionParser) parseTerm() (tagExpressionNode, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, p.errorf("unexpected end of expression")
+	}
+	if p.accept("(") {
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.errorf("missing )")
+		}
+		return node, nil
+	}
+
+	token := p.tokens[p.pos]
+	key, value, ok := strings.Cut(token, "=")
+	if !ok || key == "" || value == "" || strings.Contains(value, "=") ||
+		(strings.Contains(value, "*") && value != "*") {
+		return nil, p.errorf("%q must be key=value or key=*", token)
+	}
+	p.pos++
+
+	if value == "*" {
+		return tagExpressionTerm{ids: p.osmFeatureMap.LookupPrefix(key + "=")}, nil
+	}
+	if id, ok := p.osmFeatureMap.Lookup(token); ok {
+		return tagExpressionTerm{ids: []int{id}}, nil
+	}
+	return tagExpressionTerm{}, nil
+}
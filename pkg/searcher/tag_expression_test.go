@@ -0,0 +1,60 @@
+package searcher
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lintang-b-s/osm-search/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileTagExpression(t *testing.T) {
+	osmFeatureMap := pkg.NewIDMap()
+	tag := func(features ...string) map[int]int {
+		tag := make(map[int]int, len(features))
+		for _, feature := range features {
+			tag[osmFeatureMap.GetID(feature)] = osmFeatureMap.GetID("")
+		}
+		return tag
+	}
+	restaurant := tag("amenity=restaurant", "cuisine=indonesian")
+	fastFood := tag("amenity=restaurant", "cuisine=fast_food")
+	cafe := tag("amenity=cafe")
+	mall := tag("shop=mall")
+
+	cases := []struct {
+		expression string
+		expected   []bool // restaurant, fastFood, cafe, mall
+	}{
+		{"amenity=restaurant", []bool{true, true, false, false}},
+		{"amenity=*", []bool{true, true, true, false}},
+		{"amenity=restaurant OR amenity=cafe", []bool{true, true, true, false}},
+		{"amenity=restaurant | amenity=cafe, shop=mall", []bool{true, true, true, true}},
+		{"(amenity=restaurant or amenity=cafe) AND NOT cuisine=fast_food", []bool{true, false, true, false}},
+		{"amenity=* & !cuisine=fast_food", []bool{true, false, true, false}},
+		{"NOT amenity=* OR cuisine=indonesian", []bool{true, false, false, true}},
+		{"NOT NOT shop=mall", []bool{false, false, false, true}},
+		{"amenity=bank", []bool{false, false, false, false}},
+	}
+	for _, c := range cases {
+		expression, err := CompileTagExpression(c.expression, osmFeatureMap)
+		if !assert.Nil(t, err, c.expression) {
+			continue
+		}
+		for i, tag := range []map[int]int{restaurant, fastFood, cafe, mall} {
+			assert.Equal(t, c.expected[i], expression.Match(tag), "%s %d", c.expression, i)
+		}
+	}
+
+	size := len(osmFeatureMap.StrToID)
+	_, err := CompileTagExpression("amenity=bank OR leisure=*", osmFeatureMap)
+	assert.Nil(t, err)
+	assert.Equal(t, size, len(osmFeatureMap.StrToID))
+
+	for _, expression := range []string{"", "amenity", "amenity=", "=cafe", "amenity=ca*", "amenity=cafe OR",
+		"(amenity=cafe", "amenity=cafe)", "amenity=cafe shop=mall", "NOT", "a=b=c"} {
+		_, err := CompileTagExpression(expression, osmFeatureMap)
+		var ierr *pkg.Error
+		assert.True(t, errors.As(err, &ierr) && ierr.Code() == pkg.ErrBadParamInput, expression)
+	}
+}
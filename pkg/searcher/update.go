@@ -0,0 +1,112 @@
+package searcher
+
+import (
+	"context"
+	"time"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/lintang-b-s/osm-search/pkg/geo"
+	"github.com/lintang-b-s/osm-search/pkg/index"
+)
+
+const (
+	DELTA_FLUSH_THRESHOLD  = 1000 // jumlah document di delta index sebelum di-flush jadi segment baru
+	SEGMENT_MERGE_INTERVAL = time.Minute
+)
+
+// NextDocID. reserve docID untuk document baru hasil osmChange.
+func (se *Searcher) NextDocID() int {
+	return se.delta.NextDocID()
+}
+
+// AddDocument. index document baru ke delta index & r-tree. tagMap untuk osm feature (filter nearby), boundaryLatLons boundary osm way (nil untuk osm node).
+func (se *Searcher) AddDocument(node datastructure.Node, tagMap map[string]string, boundaryLatLons [][]float64) {
+	if node.ID >= se.segments.MaxDocID() {
+		// document yang sudah di-flush ke segment (saat restore) cukup di-insert ke r-tree.
+		se.delta.AddDocument(node)
+	}
+
+	osmFeatureMap := se.Idx.GetOSMFeatureMap()
+	osmFeature := index.GetOSMFeature(tagMap)
+	osmFeatureInt := make(map[int]int, len(osmFeature))
+	for k, v := range osmFeature {
+		osmFeatureInt[osmFeatureMap.GetID(k)] = osmFeatureMap.GetID(v)
+	}
+
+	rtreeItem := datastructure.OSMObject{
+		ID:              node.ID,
+		Lat:             node.Lat,
+		Lon:             node.Lon,
+		Tag:             osmFeatureInt,
+		BoundaryLatLons: boundaryLatLons,
+	}
+
+	se.rtreeLock.Lock()
+	se.osmRtree.InsertLeaf(geo.RtreeLeafBound(rtreeItem), rtreeItem, false)
+	se.rtreeLock.Unlock()
+}
+
+// DeleteDocument. tandai document sebagai terhapus & hapus dari r-tree. lat, lon = posisi document yang dihapus.
+func (se *Searcher) DeleteDocument(docID int, lat, lon float64) {
+	// tombstone yang di-restore dari doc store untuk document yang sudah dibuang saat merge segment tidak perlu di-set lagi.
+	if !se.segments.IsPurged(docID) {
+		se.delta.Delete(docID)
+	}
+
+	// bound titik selalu ada di dalam bound leaf (RtreeLeafBound), cukup untuk FindLeaf.
+	leaf := datastructure.OSMObject{ID: docID, Lat: lat, Lon: lon}
+	leaf.SetBound(geo.RtreeLeafBound(leaf))
+
+	se.rtreeLock.Lock()
+	se.osmRtree.Delete(leaf)
+	se.rtreeLock.Unlock()
+}
+
+// MaybeFlushDelta. flush delta index jadi segment baru kalau sudah >= DELTA_FLUSH_THRESHOLD document.
+// dipanggil setelah semua docID yang di-reserve lewat NextDocID sudah di AddDocument.
+func (se *Searcher) MaybeFlushDelta() error {
+	if se.delta.DocsCount() < DELTA_FLUSH_THRESHOLD {
+		return nil
+	}
+	return se.delta.Flush(se.segments)
+}
+
+// StartSegmentMerge. merge segment kecil di background sampai ctx selesai.
+func (se *Searcher) StartSegmentMerge(ctx context.Context) {
+	se.segments.StartBackgroundMerge(ctx, SEGMENT_MERGE_INTERVAL, se.delta)
+}
+
+// IsDeleted. true kalau document sudah dihapus setelah indexing.
+func (se *Searcher) IsDeleted(docID int) bool {
+	return se.isDeleted(docID)
+}
+
+func (se *Searcher) isDeleted(docID int) bool {
+	return se.delta != nil && (se.delta.IsDeleted(docID) || se.segments.IsPurged(docID))
+}
+
+func (se *Searcher) isInVocabulary(term string) bool {
+	if se.TermIDMap.IsInVocabulary(term) {
+		return true
+	}
+	return se.delta != nil && (se.segments.ContainsTerm(term) || se.delta.ContainsTerm(term))
+}
+
+func (se *Searcher) docsCount() int {
+	if se.delta == nil {
+		return se.Idx.GetDocsCount()
+	}
+	return se.segments.DocsCount() + se.delta.DocsCountDiff()
+}
+
+func (se *Searcher) docWordCount(docID int) int {
+	if se.delta != nil {
+		if wordCount, ok := se.delta.GetDocWordCount(docID); ok {
+			return wordCount
+		}
+	}
+	if docID >= se.Idx.GetDocsCount() && se.segments != nil {
+		return se.segments.GetDocWordCount(docID)
+	}
+	return se.Idx.GetDocWordCount(docID)
+}
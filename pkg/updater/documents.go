@@ -0,0 +1,83 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/lintang-b-s/osm-search/pkg"
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/lintang-b-s/osm-search/pkg/kvdb"
+)
+
+// CustomDocument. document custom (POI dari luar osm) yang ditambahkan lewat API.
+type CustomDocument struct {
+	Node   datastructure.Node
+	TagMap map[string]string // osm tag untuk filter nearby, boleh kosong
+}
+
+// AddDocuments. assign docID ke setiap document custom, simpan ke doc store & index ke delta index + r-tree.
+// return document dengan docID yang sudah di-assign.
+func (u *Updater) AddDocuments(docs []CustomDocument) ([]datastructure.Node, error) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	newDocs := make([]datastructure.Node, 0, len(docs))
+	for _, doc := range docs {
+		if doc.Node.Source == "" || doc.Node.Source == datastructure.SOURCE_OSM {
+			return nil, pkg.WrapErrorf(nil, pkg.ErrBadParamInput, "source of custom document must not be empty or %s", datastructure.SOURCE_OSM)
+		}
+		node := doc.Node
+		node.ID = u.searchIndex.NextDocID()
+		newDocs = append(newDocs, node)
+	}
+
+	newDocsData := make(map[int]kvdb.DeltaDocData, len(newDocs))
+	for i, node := range newDocs {
+		newDocsData[node.ID] = kvdb.DeltaDocData{TagMap: featureTagMap(docs[i].TagMap)}
+	}
+	err := u.docStore.SaveChanges(kvdb.DocChanges{NewDocs: newDocs, NewDocsData: newDocsData})
+	if err != nil {
+		return nil, fmt.Errorf("error when saving custom documents: %w", err)
+	}
+
+	for i, node := range newDocs {
+		tagMap := docs[i].TagMap
+		if tagMap == nil {
+			tagMap = map[string]string{}
+		}
+		u.searchIndex.AddDocument(node, tagMap, nil)
+	}
+
+	log.Printf("added %d custom documents\n", len(newDocs))
+	// document sudah tersimpan & bisa di-query dari delta index, gagal flush tidak membatalkan request.
+	// delta index di-flush lagi saat MaybeFlushDelta berikutnya.
+	if err := u.searchIndex.MaybeFlushDelta(); err != nil {
+		log.Printf("error when flushing delta index: %v\n", err)
+	}
+	return newDocs, nil
+}
+
+// DeleteDocument. hapus document custom. document dari osm hanya bisa dihapus lewat osmChange.
+func (u *Updater) DeleteDocument(docID int) error {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	doc, err := u.docStore.GetDoc(docID)
+	if errors.Is(err, kvdb.ErrorsKeyNotExists) || (err == nil && u.searchIndex.IsDeleted(docID)) {
+		return pkg.WrapErrorf(err, pkg.ErrNotFound, "document %d not found", docID)
+	}
+	if err != nil {
+		return err
+	}
+	if doc.Source == "" {
+		return pkg.WrapErrorf(nil, pkg.ErrBadParamInput, "document %d is an osm object, only custom documents can be deleted", docID)
+	}
+
+	err = u.docStore.SaveChanges(kvdb.DocChanges{DeletedDocIDs: []int{docID}})
+	if err != nil {
+		return fmt.Errorf("error when deleting custom document %d: %w", docID, err)
+	}
+	u.searchIndex.DeleteDocument(docID, doc.Lat, doc.Lon)
+	return nil
+}
@@ -0,0 +1,488 @@
+package updater
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/lintang-b-s/osm-search/pkg/geo"
+	"github.com/lintang-b-s/osm-search/pkg/kvdb"
+
+	"github.com/paulmach/osm"
+)
+
+type DocStore interface {
+	GetDoc(id int) (datastructure.Node, error)
+	GetDocIDByOSMID(osmKey string) (int, error)
+	SaveChanges(changes kvdb.DocChanges) error
+	GetDeltaState() ([]kvdb.DeltaDoc, []int, error)
+	GetLastAppliedChange() (string, error)
+}
+
+type SearchIndex interface {
+	NextDocID() int
+	AddDocument(node datastructure.Node, tagMap map[string]string, boundaryLatLons [][]float64)
+	DeleteDocument(docID int, lat, lon float64)
+	IsDeleted(docID int) bool
+	MaybeFlushDelta() error
+}
+
+// CoordinateLookup. koordinat osm node yang tidak ada di osmChange (node dari osm way yang tidak berubah).
+type CoordinateLookup interface {
+	GetNode(id int64) (lat, lon float64, ok bool)
+}
+
+// Updater. apply osmChange (.osc) ke doc store, r-tree & delta inverted index.
+type Updater struct {
+	docStore    DocStore
+	searchIndex SearchIndex
+	coordinates CoordinateLookup
+	lock        sync.Mutex
+}
+
+func NewUpdater(docStore DocStore, searchIndex SearchIndex) *Updater {
+	return &Updater{
+		docStore:    docStore,
+		searchIndex: searchIndex,
+	}
+}
+
+// SetCoordinateLookup. tanpa lookup, osm way yang node-nya tidak ada di osmChange di-skip.
+func (u *Updater) SetCoordinateLookup(coordinates CoordinateLookup) {
+	u.coordinates = coordinates
+}
+
+// Restore. build ulang delta index & tombstone dari doc store. dipanggil sekali saat server start.
+func (u *Updater) Restore() error {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	docs, tombstones, err := u.docStore.GetDeltaState()
+	if err != nil {
+		return err
+	}
+
+	for _, doc := range docs {
+		tagMap := doc.Data.TagMap
+		if tagMap == nil {
+			tagMap = map[string]string{}
+		}
+		u.searchIndex.AddDocument(doc.Node, tagMap, doc.Data.BoundaryLatLons)
+	}
+	for _, docID := range tombstones {
+		doc, err := u.docStore.GetDoc(docID)
+		if err != nil {
+			return fmt.Errorf("error when restoring tombstone docID %d: %w", docID, err)
+		}
+		u.searchIndex.DeleteDocument(docID, doc.Lat, doc.Lon)
+	}
+	if len(docs) > 0 || len(tombstones) > 0 {
+		log.Printf("restored %d osmChange documents & %d deleted documents\n", len(docs), len(tombstones))
+	}
+	return nil
+}
+
+type newDoc struct {
+	node            datastructure.Node
+	tagMap          map[string]string
+	boundaryLatLons [][]float64
+}
+
+// changeSet. perubahan hasil satu osmChange sebelum disimpan.
+type changeSet struct {
+	docChanges kvdb.DocChanges
+	newDocs    map[int]newDoc // docID -> document baru
+	deleted    []datastructure.Node
+	deletedIDs map[int]bool
+}
+
+// ApplyChange. apply create, modify & delete dari osmChange. name disimpan sebagai osmChange terakhir yang sudah di-apply.
+// create/modify = document lama (kalau ada) ditandai terhapus & document baru di index dengan docID baru.
+func (u *Updater) ApplyChange(change *osm.Change, name string) error {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	cs := &changeSet{
+		docChanges: kvdb.DocChanges{
+			NewOSMIDs:   make(map[string]int),
+			NewDocsData: make(map[int]kvdb.DeltaDocData),
+			ChangeName:  name,
+		},
+		newDocs:    make(map[int]newDoc),
+		deletedIDs: make(map[int]bool),
+	}
+
+	// koordinat node di osmChange, untuk osm way yang dibuat/dimodifikasi di osmChange yang sama.
+	// node id osm way di osmChange, untuk member way osm relation.
+	nodeCoords := make(map[osm.NodeID][2]float64)
+	wayNodes := make(map[int64][]int64)
+	for _, o := range []*osm.OSM{change.Create, change.Modify} {
+		if o == nil {
+			continue
+		}
+		for _, node := range o.Nodes {
+			nodeCoords[node.ID] = [2]float64{node.Lat, node.Lon}
+		}
+		for _, way := range o.Ways {
+			nodeIDs := make([]int64, 0, len(way.Nodes))
+			for _, wayNode := range way.Nodes {
+				nodeIDs = append(nodeIDs, int64(wayNode.ID))
+				if _, ok := nodeCoords[wayNode.ID]; !ok && (wayNode.Lat != 0 || wayNode.Lon != 0) {
+					nodeCoords[wayNode.ID] = [2]float64{wayNode.Lat, wayNode.Lon}
+				}
+			}
+			wayNodes[int64(way.ID)] = nodeIDs
+		}
+	}
+
+	for _, o := range []*osm.OSM{change.Create, change.Modify} {
+		if o == nil {
+			continue
+		}
+		for _, node := range o.Nodes {
+			err := u.upsertNode(cs, node)
+			if err != nil {
+				return err
+			}
+		}
+		for _, way := range o.Ways {
+			err := u.upsertWay(cs, way, nodeCoords)
+			if err != nil {
+				return err
+			}
+		}
+		for _, relation := range o.Relations {
+			err := u.upsertRelation(cs, relation, wayNodes, nodeCoords)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if change.Delete != nil {
+		for _, node := range change.Delete.Nodes {
+			err := u.delete(cs, geo.OSMKey(osm.TypeNode, int64(node.ID)))
+			if err != nil {
+				return err
+			}
+		}
+		for _, way := range change.Delete.Ways {
+			err := u.delete(cs, geo.OSMKey(osm.TypeWay, int64(way.ID)))
+			if err != nil {
+				return err
+			}
+		}
+		for _, relation := range change.Delete.Relations {
+			err := u.delete(cs, geo.OSMKey(osm.TypeRelation, int64(relation.ID)))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for docID, doc := range cs.newDocs {
+		cs.docChanges.NewDocs = append(cs.docChanges.NewDocs, doc.node)
+		cs.docChanges.NewDocsData[docID] = kvdb.DeltaDocData{TagMap: featureTagMap(doc.tagMap), BoundaryLatLons: doc.boundaryLatLons}
+	}
+
+	err := u.docStore.SaveChanges(cs.docChanges)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range cs.deleted {
+		u.searchIndex.DeleteDocument(node.ID, node.Lat, node.Lon)
+	}
+	for docID, doc := range cs.newDocs {
+		if cs.deletedIDs[docID] {
+			continue
+		}
+		u.searchIndex.AddDocument(doc.node, doc.tagMap, doc.boundaryLatLons)
+	}
+
+	log.Printf("applied osmChange %s: %d new documents, %d deleted documents\n", name,
+		len(cs.newDocs), len(cs.deleted))
+	return u.searchIndex.MaybeFlushDelta()
+}
+
+func (u *Updater) upsertNode(cs *changeSet, node *osm.Node) error {
+	osmKey := geo.OSMKey(osm.TypeNode, int64(node.ID))
+	err := u.delete(cs, osmKey)
+	if err != nil {
+		return err
+	}
+
+	searchNode, ok := geo.NewSearchOSMNode(node)
+	if !ok {
+		return nil
+	}
+
+	name, street, tipe, postalCode, houseNumber := geo.GetNameAddressTypeFromOSMWay(searchNode.TagMap)
+	docID := u.searchIndex.NextDocID()
+	doc := datastructure.NewNode(docID, name, searchNode.Lat, searchNode.Lon,
+		getAddress(street, postalCode, houseNumber), tipe, "", searchNode.ContainWikiData).
+		WithOSMObject(string(osm.TypeNode), searchNode.ID, geo.GetIndexRules().StoredTagMap(searchNode.TagMap))
+
+	cs.newDocs[docID] = newDoc{node: doc, tagMap: searchNode.TagMap}
+	cs.docChanges.NewOSMIDs[osmKey] = docID
+	return nil
+}
+
+func (u *Updater) upsertWay(cs *changeSet, way *osm.Way, nodeCoords map[osm.NodeID][2]float64) error {
+	osmKey := geo.OSMKey(osm.TypeWay, int64(way.ID))
+
+	searchWay, ok := geo.NewSearchOSMWay(way)
+	if !ok {
+		return u.delete(cs, osmKey)
+	}
+
+	latLons := make([][]float64, 0, len(way.Nodes))
+	for _, wayNode := range way.Nodes {
+		nodeLat, nodeLon, ok := u.getNodeCoord(wayNode, nodeCoords)
+		if !ok {
+			// document lama tetap dipakai, koordinat osm way tidak lengkap.
+			log.Printf("skipping osm way %d: coordinate of node %d not found\n", way.ID, wayNode.ID)
+			return nil
+		}
+		latLons = append(latLons, []float64{nodeLat, nodeLon})
+	}
+	if len(latLons) == 0 {
+		return nil
+	}
+
+	err := u.delete(cs, osmKey)
+	if err != nil {
+		return err
+	}
+
+	centerLat, centerLon := geo.RepresentativePoint(latLons)
+
+	name, street, tipe, postalCode, houseNumber := geo.GetNameAddressTypeFromOSMWay(searchWay.TagMap)
+	docID := u.searchIndex.NextDocID()
+	doc := datastructure.NewNode(docID, name, centerLat, centerLon,
+		getAddress(street, postalCode, houseNumber), tipe, "", searchWay.ContainWikidata).
+		WithOSMObject(string(osm.TypeWay), searchWay.ID, geo.GetIndexRules().StoredTagMap(searchWay.TagMap))
+
+	cs.newDocs[docID] = newDoc{node: doc, tagMap: searchWay.TagMap, boundaryLatLons: latLons}
+	cs.docChanges.NewOSMIDs[osmKey] = docID
+	return nil
+}
+
+// upsertRelation. geometry relation dirakit dari member way di osmChange (server tidak menyimpan node osm way).
+// kalau ada member way yang tidak ada di osmChange, document lama tetap dipakai.
+func (u *Updater) upsertRelation(cs *changeSet, relation *osm.Relation, wayNodes map[int64][]int64,
+	nodeCoords map[osm.NodeID][2]float64) error {
+	osmKey := geo.OSMKey(osm.TypeRelation, int64(relation.ID))
+
+	searchRelation, ok := geo.NewSearchOSMRelation(relation)
+	if !ok {
+		return u.delete(cs, osmKey)
+	}
+
+	for _, member := range searchRelation.Members {
+		if _, ok := wayNodes[member.WayID]; !ok {
+			log.Printf("skipping osm relation %d: member way %d not found in osmChange\n", relation.ID, member.WayID)
+			return nil
+		}
+	}
+	if !searchRelation.BuildGeometry(wayNodes, changeCoords{u: u, nodeCoords: nodeCoords}) {
+		log.Printf("skipping osm relation %d: geometry can not be assembled from member ways\n", relation.ID)
+		return nil
+	}
+
+	err := u.delete(cs, osmKey)
+	if err != nil {
+		return err
+	}
+
+	centerLat, centerLon := searchRelation.Center()
+
+	name, street, _, postalCode, houseNumber := geo.GetNameAddressTypeFromOSMWay(searchRelation.TagMap)
+	docID := u.searchIndex.NextDocID()
+	doc := datastructure.NewNode(docID, name, centerLat, centerLon,
+		getAddress(street, postalCode, houseNumber), searchRelation.ObjectType(), "", searchRelation.ContainWikidata).
+		WithOSMObject(string(osm.TypeRelation), searchRelation.ID, geo.GetIndexRules().StoredTagMap(searchRelation.TagMap))
+
+	cs.newDocs[docID] = newDoc{node: doc, tagMap: searchRelation.TagMap, boundaryLatLons: searchRelation.BoundaryLatLons()}
+	cs.docChanges.NewOSMIDs[osmKey] = docID
+	return nil
+}
+
+// changeCoords. geo.NodeCoordLookup dari koordinat node di osmChange & CoordinateLookup updater.
+type changeCoords struct {
+	u          *Updater
+	nodeCoords map[osm.NodeID][2]float64
+}
+
+func (c changeCoords) GetNode(id int64) (float64, float64, bool) {
+	return c.u.getNodeCoord(osm.WayNode{ID: osm.NodeID(id)}, c.nodeCoords)
+}
+
+func (u *Updater) getNodeCoord(wayNode osm.WayNode, nodeCoords map[osm.NodeID][2]float64) (float64, float64, bool) {
+	if coord, ok := nodeCoords[wayNode.ID]; ok {
+		return coord[0], coord[1], true
+	}
+	if wayNode.Lat != 0 || wayNode.Lon != 0 {
+		return wayNode.Lat, wayNode.Lon, true
+	}
+	if u.coordinates != nil {
+		return u.coordinates.GetNode(int64(wayNode.ID))
+	}
+	return 0, 0, false
+}
+
+// delete. tandai document dari osm object osmKey sebagai terhapus (kalau ada).
+func (u *Updater) delete(cs *changeSet, osmKey string) error {
+	if docID, ok := cs.docChanges.NewOSMIDs[osmKey]; ok {
+		// document dibuat di osmChange yang sama.
+		delete(cs.docChanges.NewOSMIDs, osmKey)
+		delete(cs.newDocs, docID)
+		return nil
+	}
+
+	docID, err := u.docStore.GetDocIDByOSMID(osmKey)
+	if errors.Is(err, kvdb.ErrorsKeyNotExists) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if cs.deletedIDs[docID] {
+		return nil
+	}
+
+	doc, err := u.docStore.GetDoc(docID)
+	if err != nil {
+		return fmt.Errorf("error when getting document %d of %s: %w", docID, osmKey, err)
+	}
+
+	cs.deleted = append(cs.deleted, doc)
+	cs.deletedIDs[docID] = true
+	cs.docChanges.DeletedDocIDs = append(cs.docChanges.DeletedDocIDs, docID)
+	cs.docChanges.DeletedOSMIDs = append(cs.docChanges.DeletedOSMIDs, osmKey)
+	return nil
+}
+
+// featureTagMap. subset tag yang jadi osm feature r-tree (key di feature_keys), yang perlu disimpan untuk Restore.
+func featureTagMap(tagMap map[string]string) map[string]string {
+	features := make(map[string]string)
+	for key, value := range tagMap {
+		if geo.GetIndexRules().IsFeatureKey(key) {
+			features[key] = value
+		}
+	}
+	return features
+}
+
+// getAddress. address document dari osmChange. tanpa region administratif karena boundary tidak di-load di server.
+func getAddress(street, postalCode, houseNumber string) string {
+	address := street
+	if houseNumber != "" {
+		address += ", " + houseNumber
+	}
+	if postalCode != "" {
+		address += ", " + postalCode
+	}
+	return address
+}
+
+// ApplyChangeFile. apply file osmChange (.osc atau .osc.gz).
+func (u *Updater) ApplyChangeFile(path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	change := &osm.Change{}
+	err = xml.NewDecoder(r).Decode(change)
+	if err != nil {
+		return fmt.Errorf("error when decoding osmChange %s: %w", path, err)
+	}
+
+	return u.ApplyChange(change, name)
+}
+
+// ApplyDir. apply semua file osmChange di dir yang belum di-apply, urut berdasarkan path relatif
+// (format minutely diff: 000/123/456.osc.gz).
+func (u *Updater) ApplyDir(dir string) error {
+	lastApplied, err := u.docStore.GetLastAppliedChange()
+	if err != nil {
+		return err
+	}
+
+	changeFiles := []string{}
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isChangeFile(path) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel > lastApplied {
+			changeFiles = append(changeFiles, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(changeFiles)
+	for _, rel := range changeFiles {
+		err := u.ApplyChangeFile(filepath.Join(dir, rel), rel)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isChangeFile(path string) bool {
+	return strings.HasSuffix(path, ".osc") || strings.HasSuffix(path, ".osc.gz")
+}
+
+// Watch. cek dir setiap interval & apply file osmChange baru sampai ctx selesai.
+func (u *Updater) Watch(ctx context.Context, dir string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := u.ApplyDir(dir)
+		if err != nil {
+			log.Printf("error when applying osmChange from %s: %v\n", dir, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package updater
+
+import (
+	"encoding/xml"
+	"errors"
+	"testing"
+
+	"github.com/lintang-b-s/osm-search/pkg"
+	"github.com/lintang-b-s/osm-search/pkg/datastructure"
+	"github.com/lintang-b-s/osm-search/pkg/kvdb"
+	"github.com/paulmach/osm"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockDocStore struct {
+	docs        map[int]datastructure.Node
+	osmIDs      map[string]int
+	tombstones  []int
+	deltaDocs   []kvdb.DeltaDoc
+	lastApplied string
+}
+
+func (m *mockDocStore) GetDoc(id int) (datastructure.Node, error) {
+	doc, ok := m.docs[id]
+	if !ok {
+		return datastructure.Node{}, kvdb.ErrorsKeyNotExists
+	}
+	return doc, nil
+}
+
+func (m *mockDocStore) GetDocIDByOSMID(osmKey string) (int, error) {
+	docID, ok := m.osmIDs[osmKey]
+	if !ok {
+		return 0, kvdb.ErrorsKeyNotExists
+	}
+	return docID, nil
+}
+
+func (m *mockDocStore) SaveChanges(changes kvdb.DocChanges) error {
+	for _, osmKey := range changes.DeletedOSMIDs {
+		delete(m.osmIDs, osmKey)
+	}
+	m.tombstones = append(m.tombstones, changes.DeletedDocIDs...)
+	for _, doc := range changes.NewDocs {
+		m.docs[doc.ID] = doc
+		m.deltaDocs = append(m.deltaDocs, kvdb.DeltaDoc{Node: doc, Data: changes.NewDocsData[doc.ID]})
+	}
+	for osmKey, docID := range changes.NewOSMIDs {
+		m.osmIDs[osmKey] = docID
+	}
+	if changes.ChangeName != "" {
+		m.lastApplied = changes.ChangeName
+	}
+	return nil
+}
+
+func (m *mockDocStore) GetDeltaState() ([]kvdb.DeltaDoc, []int, error) {
+	return m.deltaDocs, m.tombstones, nil
+}
+
+func (m *mockDocStore) GetLastAppliedChange() (string, error) {
+	return m.lastApplied, nil
+}
+
+type mockSearchIndex struct {
+	nextDocID  int
+	docs       map[int]datastructure.Node
+	tagMaps    map[int]map[string]string
+	boundaries map[int][][]float64
+	deleted    []int
+	flushErr   error
+}
+
+func (m *mockSearchIndex) NextDocID() int {
+	docID := m.nextDocID
+	m.nextDocID++
+	return docID
+}
+
+func (m *mockSearchIndex) AddDocument(node datastructure.Node, tagMap map[string]string, boundaryLatLons [][]float64) {
+	m.docs[node.ID] = node
+	if m.tagMaps == nil {
+		m.tagMaps, m.boundaries = make(map[int]map[string]string), make(map[int][][]float64)
+	}
+	m.tagMaps[node.ID] = tagMap
+	m.boundaries[node.ID] = boundaryLatLons
+}
+
+func (m *mockSearchIndex) DeleteDocument(docID int, lat, lon float64) {
+	m.deleted = append(m.deleted, docID)
+}
+
+func (m *mockSearchIndex) IsDeleted(docID int) bool {
+	for _, deleted := range m.deleted {
+		if deleted == docID {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *mockSearchIndex) MaybeFlushDelta() error {
+	return m.flushErr
+}
+
+const testChange = `<?xml version="1.0" encoding="UTF-8"?>
+<osmChange version="0.6">
+  <create>
+    <node id="10" lat="-7.7956" lon="110.3695" version="1">
+      <tag k="name" v="Kopi Klotok"/>
+      <tag k="amenity" v="cafe"/>
+      <tag k="addr:street" v="Jalan Kaliurang"/>
+    </node>
+    <node id="11" lat="-7.7900" lon="110.3600" version="1"/>
+    <node id="12" lat="-7.7910" lon="110.3610" version="1"/>
+    <node id="13" lat="-7.7920" lon="110.3600" version="1"/>
+    <way id="20" version="1">
+      <nd ref="11"/>
+      <nd ref="12"/>
+      <nd ref="13"/>
+      <nd ref="11"/>
+      <tag k="name" v="Taman Pintar"/>
+      <tag k="leisure" v="park"/>
+    </way>
+  </create>
+  <modify>
+    <node id="1" lat="-7.8000" lon="110.3700" version="2">
+      <tag k="name" v="Warung Baru"/>
+      <tag k="amenity" v="restaurant"/>
+    </node>
+  </modify>
+  <delete>
+    <node id="2" lat="-7.8100" lon="110.3800" version="3"/>
+  </delete>
+</osmChange>`
+
+func TestApplyChange(t *testing.T) {
+	docStore := &mockDocStore{
+		docs: map[int]datastructure.Node{
+			0: {ID: 0, Name: "Warung Lama", Lat: -7.8, Lon: 110.37},
+			1: {ID: 1, Name: "Toko Tutup", Lat: -7.81, Lon: 110.38},
+		},
+		osmIDs: map[string]int{"node/1": 0, "node/2": 1},
+	}
+	searchIndex := &mockSearchIndex{nextDocID: 2, docs: map[int]datastructure.Node{}}
+	u := NewUpdater(docStore, searchIndex)
+
+	change := &osm.Change{}
+	err := xml.Unmarshal([]byte(testChange), change)
+	assert.Nil(t, err)
+
+	err = u.ApplyChange(change, "000/000/001.osc")
+	assert.Nil(t, err)
+
+	assert.ElementsMatch(t, []int{0, 1}, searchIndex.deleted)
+	assert.ElementsMatch(t, []int{0, 1}, docStore.tombstones)
+	assert.Len(t, searchIndex.docs, 3)
+
+	names := map[string]datastructure.Node{}
+	for _, doc := range searchIndex.docs {
+		names[doc.Name] = doc
+	}
+	assert.Contains(t, names, "Kopi Klotok")
+	assert.Contains(t, names, "Warung Baru")
+	assert.Contains(t, names, "Taman Pintar")
+	assert.Equal(t, "Jalan Kaliurang", names["Kopi Klotok"].Address)
+	// point-on-surface way tertutup, bukan median lat & lon (titik sudut -7.79, 110.36).
+	assert.InDelta(t, -7.791, names["Taman Pintar"].Lat, 1e-9)
+	assert.InDelta(t, 110.3605, names["Taman Pintar"].Lon, 1e-9)
+
+	_, ok := docStore.osmIDs["node/2"]
+	assert.False(t, ok)
+	assert.Equal(t, names["Warung Baru"].ID, docStore.osmIDs["node/1"])
+	assert.Equal(t, names["Taman Pintar"].ID, docStore.osmIDs["way/20"])
+	assert.Equal(t, "000/000/001.osc", docStore.lastApplied)
+
+	t.Run("restore delta index from doc store", func(t *testing.T) {
+		restored := &mockSearchIndex{docs: map[int]datastructure.Node{}}
+		err := NewUpdater(docStore, restored).Restore()
+		assert.Nil(t, err)
+		assert.Len(t, restored.docs, 3)
+		assert.ElementsMatch(t, []int{0, 1}, restored.deleted)
+		for docID := range searchIndex.docs {
+			assert.Equal(t, featureTagMap(searchIndex.tagMaps[docID]), restored.tagMaps[docID])
+			assert.Equal(t, searchIndex.boundaries[docID], restored.boundaries[docID])
+		}
+		taman := names["Taman Pintar"].ID
+		assert.Equal(t, map[string]string{"leisure": "park"}, restored.tagMaps[taman])
+		assert.Len(t, restored.boundaries[taman], 4)
+	})
+}
+
+const testRelationChange = `<?xml version="1.0" encoding="UTF-8"?>
+<osmChange version="0.6">
+  <modify>
+    <node id="41" lat="-7.8110" lon="110.3620" version="2"/>
+    <node id="42" lat="-7.8110" lon="110.3650" version="2"/>
+    <node id="43" lat="-7.8140" lon="110.3650" version="2"/>
+    <node id="44" lat="-7.8140" lon="110.3620" version="2"/>
+    <way id="40" version="2">
+      <nd ref="41"/>
+      <nd ref="42"/>
+      <nd ref="43"/>
+      <nd ref="44"/>
+      <nd ref="41"/>
+    </way>
+    <relation id="30" version="2">
+      <member type="way" ref="40" role="outer"/>
+      <tag k="type" v="multipolygon"/>
+      <tag k="name" v="Alun-Alun Kidul"/>
+      <tag k="leisure" v="park"/>
+    </relation>
+    <relation id="32" version="3">
+      <member type="way" ref="50" role="outer"/>
+      <tag k="type" v="multipolygon"/>
+      <tag k="name" v="Taman Sari"/>
+      <tag k="tourism" v="attraction"/>
+    </relation>
+  </modify>
+  <delete>
+    <relation id="31" version="4"/>
+  </delete>
+</osmChange>`
+
+func TestApplyChangeRelation(t *testing.T) {
+	docStore := &mockDocStore{
+		docs: map[int]datastructure.Node{
+			0: {ID: 0, Name: "Alun-Alun Selatan", Lat: -7.812, Lon: 110.363},
+			1: {ID: 1, Name: "Kampung Lama", Lat: -7.82, Lon: 110.37},
+			2: {ID: 2, Name: "Taman Sari", Lat: -7.81, Lon: 110.359},
+		},
+		osmIDs: map[string]int{"relation/30": 0, "relation/31": 1, "relation/32": 2},
+	}
+	searchIndex := &mockSearchIndex{nextDocID: 3, docs: map[int]datastructure.Node{}}
+	u := NewUpdater(docStore, searchIndex)
+
+	change := &osm.Change{}
+	err := xml.Unmarshal([]byte(testRelationChange), change)
+	assert.Nil(t, err)
+
+	err = u.ApplyChange(change, "000/000/002.osc")
+	assert.Nil(t, err)
+
+	// relation 32: member way 50 tidak ada di osmChange, document lama tetap dipakai.
+	assert.ElementsMatch(t, []int{0, 1}, searchIndex.deleted)
+	assert.ElementsMatch(t, []int{0, 1}, docStore.tombstones)
+	assert.Equal(t, 2, docStore.osmIDs["relation/32"])
+	_, ok := docStore.osmIDs["relation/31"]
+	assert.False(t, ok)
+
+	if assert.Len(t, searchIndex.docs, 1) {
+		docID := docStore.osmIDs["relation/30"]
+		doc := searchIndex.docs[docID]
+		assert.Equal(t, "Alun-Alun Kidul", doc.Name)
+		assert.Equal(t, "relation", doc.OSMType)
+		assert.Equal(t, int64(30), doc.OSMID)
+		assert.InDelta(t, -7.8125, doc.Lat, 1e-9)
+		assert.InDelta(t, 110.3635, doc.Lon, 1e-9)
+		assert.Len(t, searchIndex.boundaries[docID], 5)
+		assert.Equal(t, map[string]string{"leisure": "park"}, docStore.deltaDocs[0].Data.TagMap)
+	}
+}
+
+func TestCustomDocuments(t *testing.T) {
+	docStore := &mockDocStore{
+		docs: map[int]datastructure.Node{
+			0: {ID: 0, Name: "Warung Lama", Lat: -7.8, Lon: 110.37},
+		},
+		osmIDs: map[string]int{"node/1": 0},
+	}
+	searchIndex := &mockSearchIndex{nextDocID: 1, docs: map[int]datastructure.Node{}}
+	u := NewUpdater(docStore, searchIndex)
+
+	docs, err := u.AddDocuments([]CustomDocument{
+		{Node: datastructure.Node{Name: "Kedai Kopi Senja", Lat: -7.78, Lon: 110.36, Source: "merchant"}},
+		{Node: datastructure.Node{Name: "Bakmi Jawa", Lat: -7.79, Lon: 110.37, Source: "merchant"},
+			TagMap: map[string]string{"amenity": "restaurant"}},
+	})
+	assert.Nil(t, err)
+	assert.Len(t, docs, 2)
+	assert.Equal(t, 1, docs[0].ID)
+	assert.Equal(t, 2, docs[1].ID)
+	assert.Equal(t, "merchant", searchIndex.docs[2].Source)
+	assert.Equal(t, "Bakmi Jawa", docStore.docs[2].Name)
+	assert.Equal(t, map[string]string{"amenity": "restaurant"}, docStore.deltaDocs[1].Data.TagMap)
+
+	t.Run("reject document without source", func(t *testing.T) {
+		_, err := u.AddDocuments([]CustomDocument{{Node: datastructure.Node{Name: "Tanpa Source"}}})
+		var ierr *pkg.Error
+		assert.True(t, errors.As(err, &ierr))
+		assert.Equal(t, pkg.ErrBadParamInput, ierr.Code())
+	})
+
+	t.Run("documents are added when delta flush fails", func(t *testing.T) {
+		searchIndex.flushErr = errors.New("disk full")
+		defer func() { searchIndex.flushErr = nil }()
+
+		docs, err := u.AddDocuments([]CustomDocument{
+			{Node: datastructure.Node{Name: "Gudeg Yu Djum", Lat: -7.77, Lon: 110.38, Source: "merchant"}},
+		})
+		assert.Nil(t, err)
+		if assert.Len(t, docs, 1) {
+			assert.Equal(t, "Gudeg Yu Djum", searchIndex.docs[docs[0].ID].Name)
+			assert.Equal(t, "Gudeg Yu Djum", docStore.docs[docs[0].ID].Name)
+		}
+	})
+
+	t.Run("delete custom document", func(t *testing.T) {
+		err := u.DeleteDocument(1)
+		assert.Nil(t, err)
+		assert.Equal(t, []int{1}, searchIndex.deleted)
+		assert.Equal(t, []int{1}, docStore.tombstones)
+
+		var ierr *pkg.Error
+		err = u.DeleteDocument(1)
+		assert.True(t, errors.As(err, &ierr))
+		assert.Equal(t, pkg.ErrNotFound, ierr.Code())
+	})
+
+	t.Run("osm document & unknown document can not be deleted", func(t *testing.T) {
+		var ierr *pkg.Error
+		err := u.DeleteDocument(0)
+		assert.True(t, errors.As(err, &ierr))
+		assert.Equal(t, pkg.ErrBadParamInput, ierr.Code())
+
+		err = u.DeleteDocument(100)
+		assert.True(t, errors.As(err, &ierr))
+		assert.Equal(t, pkg.ErrNotFound, ierr.Code())
+	})
+}
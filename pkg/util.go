@@ -3,10 +3,13 @@ package pkg
 import (
 	"errors"
 	"fmt"
+	"io"
 	"sort"
+	"strings"
 	"sync"
 
 	"github.com/RadhiFadlillah/go-sastrawi"
+	"github.com/blevesearch/vellum"
 )
 
 var dictionary = sastrawi.DefaultDictionary()
@@ -18,6 +21,14 @@ type IDMap struct {
 	IDToStr    map[int]string
 	Vocabulary map[string]bool
 	sync.Mutex
+
+	// fst. term dictionary read-only di disk (term -> id). kalau tidak nil, StrToID & IDToStr hanya berisi term yang tidak ada di fst (misal query term baru),
+	// dengan id mulai dari fst.Len().
+	fst *vellum.FST
+	// fstTerms. id -> term untuk term di fst (vellum tidak bisa reverse lookup).
+	fstTerms TermTable
+	// nextID. id untuk term baru berikutnya. term hasil AddTerms bisa tidak berurutan, jadi id baru tidak bisa dari len(StrToID).
+	nextID int
 }
 
 func NewIDMap() *IDMap {
@@ -27,14 +38,35 @@ func NewIDMap() *IDMap {
 	}
 }
 
+// TermTable. lookup id -> term dari term di fst.
+type TermTable interface {
+	GetTerm(id int) (string, bool)
+}
+
+// NewIDMapFromFST. IDMap yang lookup str->id nya pakai vellum FST (hasil WriteFST) & id->str pakai fstTerms. keduanya di-mmap, jadi RAM yang dipakai hampir konstan.
+func NewIDMapFromFST(fst *vellum.FST, fstTerms TermTable) *IDMap {
+	idMap := NewIDMap()
+	idMap.fst = fst
+	idMap.fstTerms = fstTerms
+	return idMap
+}
+
 func (idMap *IDMap) GetID(str string) int {
+	if idMap.fst != nil {
+		// vellum FST read-only, aman dipanggil concurrent tanpa lock.
+		if id, ok, err := idMap.fst.Get([]byte(str)); err == nil && ok {
+			return int(id)
+		}
+	}
+
 	idMap.Lock()
 	defer idMap.Unlock()
 	if id, ok := idMap.StrToID[str]; ok {
 		return id
 	}
 
-	id := len(idMap.StrToID)
+	id := max(idMap.nextID, idMap.fstLen()+len(idMap.StrToID))
+	idMap.nextID = id + 1
 	idMap.StrToID[str] = id
 	idMap.IDToStr[id] = str
 
@@ -42,22 +74,145 @@ func (idMap *IDMap) GetID(str string) int {
 }
 
 func (idMap *IDMap) GetStr(id int) string {
+	if idMap.fstTerms != nil && id < idMap.fstLen() {
+		str, _ := idMap.fstTerms.GetTerm(id)
+		return str
+	}
+
+	idMap.Lock()
+	defer idMap.Unlock()
 	if str, ok := idMap.IDToStr[id]; ok {
 		return str
 	}
 	return ""
 }
 
+// Lookup. id dari str tanpa menambah str baru ke IDMap (beda dengan GetID).
+func (idMap *IDMap) Lookup(str string) (int, bool) {
+	if idMap.fst != nil {
+		if id, ok, err := idMap.fst.Get([]byte(str)); err == nil && ok {
+			return int(id), true
+		}
+	}
+
+	idMap.Lock()
+	defer idMap.Unlock()
+	id, ok := idMap.StrToID[str]
+	return id, ok
+}
+
+// LookupPrefix. id semua str yang diawali prefix, urut naik.
+func (idMap *IDMap) LookupPrefix(prefix string) []int {
+	ids := []int{}
+	if idMap.fst != nil {
+		it, err := idMap.fst.Iterator([]byte(prefix), nil)
+		for err == nil {
+			term, id := it.Current()
+			if !strings.HasPrefix(string(term), prefix) {
+				break
+			}
+			ids = append(ids, int(id))
+			err = it.Next()
+		}
+	}
+
+	idMap.Lock()
+	for str, id := range idMap.StrToID {
+		if strings.HasPrefix(str, prefix) {
+			ids = append(ids, id)
+		}
+	}
+	idMap.Unlock()
+	sort.Ints(ids)
+	return ids
+}
+
+func (idMap *IDMap) fstLen() int {
+	if idMap.fst == nil {
+		return 0
+	}
+	return idMap.fst.Len()
+}
+
+// GetNonFSTTerms. return term dari terms yang tidak ada di fst (term baru setelah indexing) beserta id-nya.
+func (idMap *IDMap) GetNonFSTTerms(terms []string) map[string]int {
+	idMap.Lock()
+	defer idMap.Unlock()
+	nonFSTTerms := make(map[string]int, len(terms))
+	for _, term := range terms {
+		if id, ok := idMap.StrToID[term]; ok {
+			nonFSTTerms[term] = id
+		}
+	}
+	return nonFSTTerms
+}
+
+// AddTerms. tambah term dengan id yang sudah ditentukan (hasil GetNonFSTTerms). harus dipanggil sebelum GetID term baru.
+func (idMap *IDMap) AddTerms(terms map[string]int) {
+	idMap.Lock()
+	defer idMap.Unlock()
+	for term, id := range terms {
+		idMap.StrToID[term] = id
+		idMap.IDToStr[id] = term
+		idMap.nextID = max(idMap.nextID, id+1)
+	}
+}
+
+// GetFST. return term dictionary FST. nil kalau IDMap tidak dibuat dari NewIDMapFromFST.
+func (idMap *IDMap) GetFST() *vellum.FST {
+	return idMap.fst
+}
+
+// GetSortedTerms. semua term (term di fst & term baru) urut naik.
 func (idMap *IDMap) GetSortedTerms() []string {
-	sortedTerms := make([]string, len(idMap.StrToID))
-	for term, id := range idMap.StrToID {
-		sortedTerms[id] = term
+	idMap.Lock()
+	nonFSTTerms := make([]string, 0, len(idMap.StrToID))
+	for term := range idMap.StrToID {
+		nonFSTTerms = append(nonFSTTerms, term)
+	}
+	idMap.Unlock()
+	sort.Strings(nonFSTTerms)
+	if idMap.fst == nil {
+		return nonFSTTerms
+	}
+
+	// merge term fst (sudah urut) dengan term baru
+	sortedTerms := make([]string, 0, idMap.fst.Len()+len(nonFSTTerms))
+	i := 0
+	it, err := idMap.fst.Iterator(nil, nil)
+	for err == nil {
+		term, _ := it.Current()
+		for i < len(nonFSTTerms) && nonFSTTerms[i] < string(term) {
+			sortedTerms = append(sortedTerms, nonFSTTerms[i])
+			i++
+		}
+		sortedTerms = append(sortedTerms, string(term))
+		err = it.Next()
 	}
-	sort.Strings(sortedTerms)
-	return sortedTerms
+	return append(sortedTerms, nonFSTTerms[i:]...)
+}
+
+// WriteFST. tulis semua term ke w sebagai vellum FST (term -> id). dipanggil saat indexing, dibaca lagi pakai NewIDMapFromFST.
+func (idMap *IDMap) WriteFST(w io.Writer) error {
+	sortedTerms := idMap.GetSortedTerms()
+
+	fstBuilder, err := vellum.New(w, nil)
+	if err != nil {
+		return err
+	}
+	for _, term := range sortedTerms {
+		if err := fstBuilder.Insert([]byte(term), uint64(idMap.GetID(term))); err != nil {
+			return err
+		}
+	}
+	return fstBuilder.Close()
 }
 
 func (idMap *IDMap) BuildVocabulary() {
+	if idMap.fst != nil {
+		// vocabulary = semua term di fst
+		return
+	}
 	idMap.Vocabulary = make(map[string]bool)
 	for id := range idMap.StrToID {
 		idMap.Vocabulary[id] = true
@@ -69,12 +224,14 @@ func (idMap *IDMap) GetVocabulary() map[string]bool {
 }
 
 func (idMap *IDMap) IsInVocabulary(term string) bool {
+	if idMap.fst != nil {
+		ok, err := idMap.fst.Contains([]byte(term))
+		return err == nil && ok
+	}
 	_, ok := idMap.Vocabulary[term]
 	return ok
 }
 
-
-
 // error
 
 type Error struct {